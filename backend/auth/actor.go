@@ -0,0 +1,24 @@
+package auth
+
+import "context"
+
+type actorKey struct{}
+
+// SystemActor is recorded for changes made by background jobs rather than a user request
+const SystemActor = "system"
+
+// AnonymousActor is recorded for changes made by requests without a valid token
+const AnonymousActor = "anonymous"
+
+// WithActor returns a copy of ctx carrying the username of whoever is making the request
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the username stored by WithActor, or AnonymousActor if there is none
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
@@ -0,0 +1,48 @@
+// Command seedcountries loads the ISO-3166 country list into the country table.
+// Existing countries are matched by iso_code and updated in place, so it is safe to re-run.
+package main
+
+import (
+	"context"
+	_ "embed"
+	"log"
+	"strings"
+	// the tz database is embedded so timezones validate on hosts without one
+	_ "time/tzdata"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/config"
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+// countries.csv columns: iso_code, name, currency, timezone, languages ('|' separated ISO-639-1 codes)
+//
+//go:embed countries.csv
+var countriesCSV string
+
+func main() {
+	cfg := config.Load()
+
+	db, err := database.ConnectDB(cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
+	router := database.NewRouter(db, nil, 0)
+	defer router.Close()
+
+	countries, err := service.ParseCountriesCSV(strings.NewReader(countriesCSV))
+	if err != nil {
+		log.Fatalf("failed to read countries.csv: %v", err)
+	}
+
+	countryRepo := repository.NewCountryRepository(router)
+	ctx := auth.WithActor(context.Background(), auth.SystemActor)
+	for i := range countries {
+		if err := countryRepo.UpsertCountry(ctx, &countries[i]); err != nil {
+			log.Fatalf("failed to seed %s: %v", countries[i].ISOCode, err)
+		}
+	}
+	log.Printf("seeded %d countries", len(countries))
+}
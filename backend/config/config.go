@@ -4,14 +4,22 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	MinIO    MinIOConfig
+	Storage  StorageConfig
 	Gorse    GorseConfig
 	Cache    CacheConfig
+	Purge    PurgeConfig
+	Admin    AdminConfig
+	Geocoder GeocoderConfig
+	Exchange ExchangeConfig
+	Planner  PlannerConfig
 }
 
 type ServerConfig struct {
@@ -25,6 +33,10 @@ type DatabaseConfig struct {
 	Password string
 	DBName   string
 	DBURL    string
+
+	ReplicaDSNs           []string
+	ReplicaHealthInterval time.Duration
+	ReadYourWritesWindow  time.Duration
 }
 
 type MinIOConfig struct {
@@ -32,6 +44,29 @@ type MinIOConfig struct {
 	AccessKey  string
 	Secret     string
 	BucketName string
+	UseSSL     bool
+
+	// presigned download urls and upload urls expire after these, for either storage backend
+	URLExpiry    time.Duration
+	UploadExpiry time.Duration
+	// largest image a client may upload, in bytes
+	MaxUploadBytes int64
+	// how often the image worker looks for confirmed uploads that still need variants
+	WorkerInterval time.Duration
+}
+
+type StorageConfig struct {
+	// minio or local
+	Backend string
+	// local backend only: where files live, the url this server is reachable at and the key signing its urls
+	LocalDir   string
+	PublicURL  string
+	SigningKey string
+
+	// orphaned objects older than GCGracePeriod are removed every GCInterval, GCDryRun only reports them
+	GCInterval    time.Duration
+	GCGracePeriod time.Duration
+	GCDryRun      bool
 }
 
 type GorseConfig struct {
@@ -42,6 +77,39 @@ type CacheConfig struct {
 	Addr     string
 	Password string
 	Db       int
+	// idle itinerary event streams get a comment this often so proxies do not close them
+	EventHeartbeat time.Duration
+}
+
+type PurgeConfig struct {
+	Retention time.Duration
+	Interval  time.Duration
+}
+
+type AdminConfig struct {
+	Usernames []string
+}
+
+type GeocoderConfig struct {
+	Provider      string
+	URL           string
+	GazetteerFile string
+}
+
+type ExchangeConfig struct {
+	// http, file or none
+	Provider string
+	URL      string
+	File     string
+	Interval time.Duration
+}
+
+// PlannerConfig bounds a single itinerary day before it is flagged as overloaded
+type PlannerConfig struct {
+	MaxItemsPerDay int
+	MaxHoursPerDay time.Duration
+	// average speed between activities including walking and waiting, in km/h
+	TravelSpeedKmh float64
 }
 
 func Load() *Config {
@@ -50,6 +118,81 @@ func Load() *Config {
 		log.Fatalf("Invalid Redis DB value %v", err)
 	}
 
+	retention, err := time.ParseDuration(getEnv("SOFT_DELETE_RETENTION", "720h"))
+	if err != nil {
+		log.Fatalf("Invalid soft delete retention %v", err)
+	}
+	purgeInterval, err := time.ParseDuration(getEnv("PURGE_INTERVAL", "24h"))
+	if err != nil {
+		log.Fatalf("Invalid purge interval %v", err)
+	}
+
+	eventHeartbeat, err := time.ParseDuration(getEnv("ITINERARY_EVENT_HEARTBEAT", "25s"))
+	if err != nil || eventHeartbeat <= 0 {
+		log.Fatalf("Invalid itinerary event heartbeat %v", err)
+	}
+
+	replicaHealthInterval, err := time.ParseDuration(getEnv("DB_REPLICA_HEALTH_INTERVAL", "10s"))
+	if err != nil {
+		log.Fatalf("Invalid replica health interval %v", err)
+	}
+	readYourWritesWindow, err := time.ParseDuration(getEnv("DB_READ_YOUR_WRITES_WINDOW", "5s"))
+	if err != nil {
+		log.Fatalf("Invalid read your writes window %v", err)
+	}
+
+	minioUseSSL, err := strconv.ParseBool(getEnv("MINIO_USE_SSL", "false"))
+	if err != nil {
+		log.Fatalf("Invalid MinIO SSL flag %v", err)
+	}
+	minioURLExpiry, err := time.ParseDuration(getEnv("MINIO_URL_EXPIRY", "1h"))
+	if err != nil {
+		log.Fatalf("Invalid MinIO url expiry %v", err)
+	}
+	minioUploadExpiry, err := time.ParseDuration(getEnv("MINIO_UPLOAD_EXPIRY", "15m"))
+	if err != nil {
+		log.Fatalf("Invalid MinIO upload expiry %v", err)
+	}
+	maxUploadBytes, err := strconv.ParseInt(getEnv("MINIO_MAX_UPLOAD_BYTES", "10485760"), 10, 64)
+	if err != nil {
+		log.Fatalf("Invalid MinIO max upload size %v", err)
+	}
+	gcInterval, err := time.ParseDuration(getEnv("STORAGE_GC_INTERVAL", "24h"))
+	if err != nil {
+		log.Fatalf("Invalid storage gc interval %v", err)
+	}
+	gcGracePeriod, err := time.ParseDuration(getEnv("STORAGE_GC_GRACE_PERIOD", "24h"))
+	if err != nil {
+		log.Fatalf("Invalid storage gc grace period %v", err)
+	}
+	// the scheduled gc only reports until STORAGE_GC_DRY_RUN=false is set explicitly
+	gcDryRun, err := strconv.ParseBool(getEnv("STORAGE_GC_DRY_RUN", "true"))
+	if err != nil {
+		log.Fatalf("Invalid storage gc dry run flag %v", err)
+	}
+	imageWorkerInterval, err := time.ParseDuration(getEnv("IMAGE_WORKER_INTERVAL", "30s"))
+	if err != nil {
+		log.Fatalf("Invalid image worker interval %v", err)
+	}
+
+	exchangeInterval, err := time.ParseDuration(getEnv("EXCHANGE_RATE_INTERVAL", "24h"))
+	if err != nil {
+		log.Fatalf("Invalid exchange rate interval %v", err)
+	}
+
+	maxItemsPerDay, err := strconv.Atoi(getEnv("PLANNER_MAX_ITEMS_PER_DAY", "6"))
+	if err != nil {
+		log.Fatalf("Invalid planner max items per day %v", err)
+	}
+	maxHoursPerDay, err := time.ParseDuration(getEnv("PLANNER_MAX_HOURS_PER_DAY", "10h"))
+	if err != nil {
+		log.Fatalf("Invalid planner max hours per day %v", err)
+	}
+	travelSpeed, err := strconv.ParseFloat(getEnv("PLANNER_TRAVEL_SPEED_KMH", "25"), 64)
+	if err != nil {
+		log.Fatalf("Invalid planner travel speed %v", err)
+	}
+
 	cfg := &Config{
 		Server: ServerConfig{
 			Port: getEnv("PORT", "8080"),
@@ -61,6 +204,11 @@ func Load() *Config {
 			User:     getEnv("DB_USER", "joshua"),
 			Password: getEnv("DB_PASSWORD", "joshua"),
 			DBName:   getEnv("DB_NAME", "postgres"),
+
+			// full DSNs separated by ';' since a DSN can itself contain commas
+			ReplicaDSNs:           splitListSep(getEnv("DB_REPLICA_DSNS", ""), ";"),
+			ReplicaHealthInterval: replicaHealthInterval,
+			ReadYourWritesWindow:  readYourWritesWindow,
 		},
 
 		MinIO: MinIOConfig{
@@ -68,6 +216,23 @@ func Load() *Config {
 			AccessKey:  getEnv("MINIO_ACCESS_KEY", "minio"),
 			Secret:     getEnv("MINIO_SECRET_KEY", ""),
 			BucketName: getEnv("MINIO_BUCKET", "joshua"),
+			UseSSL:     minioUseSSL,
+
+			URLExpiry:      minioURLExpiry,
+			UploadExpiry:   minioUploadExpiry,
+			MaxUploadBytes: maxUploadBytes,
+			WorkerInterval: imageWorkerInterval,
+		},
+
+		Storage: StorageConfig{
+			Backend:    getEnv("STORAGE_BACKEND", "minio"),
+			LocalDir:   getEnv("STORAGE_LOCAL_DIR", "data/blobs"),
+			PublicURL:  getEnv("STORAGE_PUBLIC_URL", "http://localhost:"+getEnv("PORT", "8080")),
+			SigningKey: getEnv("STORAGE_SIGNING_KEY", ""),
+
+			GCInterval:    gcInterval,
+			GCGracePeriod: gcGracePeriod,
+			GCDryRun:      gcDryRun,
 		},
 
 		Gorse: GorseConfig{
@@ -78,6 +243,40 @@ func Load() *Config {
 			Addr:     getEnv("REDIS_ADDR", "localhost:6432"),
 			Password: getEnv("REDIS_PASSWORD", "localhost:6969"),
 			Db:       dbNum,
+
+			EventHeartbeat: eventHeartbeat,
+		},
+
+		Purge: PurgeConfig{
+			Retention: retention,
+			Interval:  purgeInterval,
+		},
+
+		Admin: AdminConfig{
+			Usernames: splitList(getEnv("ADMIN_USERS", "")),
+		},
+
+		Geocoder: GeocoderConfig{
+			// nominatim, gazetteer or none. nominatim needs GEOCODER_URL, the public instance only
+			// allows one request a second so point it at a self-hosted one where possible.
+			Provider:      getEnv("GEOCODER", "none"),
+			URL:           getEnv("GEOCODER_URL", ""),
+			GazetteerFile: getEnv("GEOCODER_GAZETTEER_FILE", "gazetteer.csv"),
+		},
+
+		Exchange: ExchangeConfig{
+			// http, file or none. http calls EXCHANGE_RATE_URL, a third party service by default,
+			// so it has to be turned on explicitly.
+			Provider: getEnv("EXCHANGE_RATE_PROVIDER", "none"),
+			URL:      getEnv("EXCHANGE_RATE_URL", "https://api.frankfurter.app/latest?from=USD"),
+			File:     getEnv("EXCHANGE_RATE_FILE", "exchange_rates.json"),
+			Interval: exchangeInterval,
+		},
+
+		Planner: PlannerConfig{
+			MaxItemsPerDay: maxItemsPerDay,
+			MaxHoursPerDay: maxHoursPerDay,
+			TravelSpeedKmh: travelSpeed,
 		},
 	}
 
@@ -92,3 +291,18 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// splitList parses a comma separated env value, ignoring blanks
+func splitList(value string) []string {
+	return splitListSep(value, ",")
+}
+
+func splitListSep(value, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
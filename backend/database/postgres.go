@@ -19,3 +19,27 @@ func ConnectDB(cfg config.DatabaseConfig) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// ConnectRouter connects to the primary and any configured replicas.
+// An unreachable replica is not fatal, it starts ejected and rejoins once a health check passes.
+func ConnectRouter(cfg config.DatabaseConfig) (*Router, error) {
+	primary, err := ConnectDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var replicas []*sql.DB
+	for _, dsn := range cfg.ReplicaDSNs {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			primary.Close()
+			return nil, fmt.Errorf("invalid replica dsn: %w", err)
+		}
+		replicas = append(replicas, db)
+	}
+
+	router := NewRouter(primary, replicas, cfg.ReadYourWritesWindow)
+	router.CheckReplicas()
+	router.StartHealthChecks(cfg.ReplicaHealthInterval)
+	return router, nil
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+)
+
+type primaryKey struct{}
+
+// WithPrimary marks ctx so every read made with it goes to the primary
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// Router sends writes and transactions to the primary and spreads reads over healthy replicas.
+// With a pin window set, a user who just wrote keeps reading from the primary for that long
+// so they always see their own changes despite replication lag. The pins live in this process
+// only, behind several API instances middleware.ReadYourWrites carries them in a cookie.
+type Router struct {
+	primary   *sql.DB
+	replicas  []*replica
+	next      atomic.Uint64
+	pinWindow time.Duration
+
+	mu   sync.Mutex
+	pins map[string]time.Time
+
+	stop chan struct{}
+}
+
+func NewRouter(primary *sql.DB, replicas []*sql.DB, pinWindow time.Duration) *Router {
+	router := &Router{
+		primary:   primary,
+		pinWindow: pinWindow,
+		pins:      make(map[string]time.Time),
+		stop:      make(chan struct{}),
+	}
+	for _, db := range replicas {
+		rep := &replica{db: db}
+		rep.healthy.Store(true)
+		router.replicas = append(router.replicas, rep)
+	}
+	return router
+}
+
+// Primary returns the primary without pinning anyone, for background jobs
+func (r *Router) Primary() *sql.DB {
+	return r.primary
+}
+
+// Writer returns the primary and pins the request's user to it for the read-your-writes window
+func (r *Router) Writer(ctx context.Context) *sql.DB {
+	actor := auth.ActorFromContext(ctx)
+	if r.pinWindow > 0 && actor != auth.AnonymousActor && actor != auth.SystemActor {
+		r.mu.Lock()
+		r.pins[actor] = time.Now().Add(r.pinWindow)
+		r.mu.Unlock()
+	}
+	return r.primary
+}
+
+// Reader returns the next healthy replica in round-robin order, or the primary if there
+// are none, the context asks for it, or the user is pinned after a recent write
+func (r *Router) Reader(ctx context.Context) *sql.DB {
+	if len(r.replicas) == 0 {
+		return r.primary
+	}
+	if forced, _ := ctx.Value(primaryKey{}).(bool); forced {
+		return r.primary
+	}
+	if r.isPinned(auth.ActorFromContext(ctx)) {
+		return r.primary
+	}
+
+	start := r.next.Add(1)
+	for i := range r.replicas {
+		rep := r.replicas[(start+uint64(i))%uint64(len(r.replicas))]
+		if rep.healthy.Load() {
+			return rep.db
+		}
+	}
+	return r.primary
+}
+
+func (r *Router) isPinned(actor string) bool {
+	if r.pinWindow <= 0 {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	until, ok := r.pins[actor]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(r.pins, actor)
+		return false
+	}
+	return true
+}
+
+// CheckReplicas pings every replica, ejecting the ones that fail and bringing back the ones that recover
+func (r *Router) CheckReplicas() {
+	for i, rep := range r.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err := rep.db.PingContext(ctx)
+		cancel()
+
+		healthy := err == nil
+		if rep.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Printf("replica %d is healthy again, routing reads to it", i)
+			} else {
+				log.Printf("warning: ejecting replica %d from read pool: %v", i, err)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	now := time.Now()
+	for actor, until := range r.pins {
+		if now.After(until) {
+			delete(r.pins, actor)
+		}
+	}
+	r.mu.Unlock()
+}
+
+// StartHealthChecks runs CheckReplicas on every interval until Close is called
+func (r *Router) StartHealthChecks(interval time.Duration) {
+	if len(r.replicas) == 0 || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.CheckReplicas()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (r *Router) Close() error {
+	close(r.stop)
+	for _, rep := range r.replicas {
+		rep.db.Close()
+	}
+	return r.primary.Close()
+}
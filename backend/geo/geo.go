@@ -0,0 +1,73 @@
+// Package geo has the spherical distance helpers shared by nearby search and itinerary planning.
+package geo
+
+import (
+	"math"
+	"time"
+)
+
+// EarthRadiusMeters is the mean earth radius used for every distance in the app
+const EarthRadiusMeters = 6371000.0
+
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// Valid reports whether p is a real coordinate
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// DistanceMeters is the great-circle distance between a and b using the haversine formula
+func DistanceMeters(a, b Point) float64 {
+	dLat := toRadians(b.Lat - a.Lat)
+	dLng := toRadians(b.Lng - a.Lng)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(a.Lat))*math.Cos(toRadians(b.Lat))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * EarthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// detourFactor stretches straight line distances to roughly what streets add on top
+const detourFactor = 1.3
+
+// TravelTime estimates how long getting from a to b takes at speedKmh along the road network
+func TravelTime(a, b Point, speedKmh float64) time.Duration {
+	if speedKmh <= 0 {
+		return 0
+	}
+	hours := DistanceMeters(a, b) * detourFactor / 1000 / speedKmh
+	return time.Duration(hours * float64(time.Hour))
+}
+
+// BoundingBox returns the corners of a box that contains every point within radius of center,
+// cheap to check with plain indexes before computing exact distances. Longitudes wrap at the
+// antimeridian, a box crossing it has min.Lng > max.Lng and covers min.Lng..180 and -180..max.Lng.
+func BoundingBox(center Point, radiusMeters float64) (min, max Point) {
+	angular := radiusMeters / EarthRadiusMeters
+	dLat := angular * 180 / math.Pi
+	min.Lat = math.Max(-90, center.Lat-dLat)
+	max.Lat = math.Min(90, center.Lat+dLat)
+
+	// near the poles every longitude is in range
+	sinLng := math.Sin(angular) / math.Cos(toRadians(center.Lat))
+	if sinLng >= 1 || max.Lat >= 90 || min.Lat <= -90 {
+		min.Lng, max.Lng = -180, 180
+		return min, max
+	}
+	// the widest point of the circle is slightly off the centre's parallel, hence asin rather than dLat / cos
+	dLng := math.Asin(sinLng) * 180 / math.Pi
+	min.Lng = center.Lng - dLng
+	if min.Lng < -180 {
+		min.Lng += 360
+	}
+	max.Lng = center.Lng + dLng
+	if max.Lng > 180 {
+		max.Lng -= 360
+	}
+	return min, max
+}
@@ -0,0 +1,177 @@
+package geo
+
+import "math"
+
+// ExactRouteLimit is the most stops routed by trying every order, longer routes use 2-opt
+const ExactRouteLimit = 9
+
+// Stop is a place on a route, Point is nil when its location is unknown. Fixed stops keep the
+// order they are given in relative to each other, other stops can go anywhere.
+type Stop struct {
+	Point *Point
+	Fixed bool
+}
+
+// RouteLength is the distance of visiting stops in order from start, which may be nil to begin at
+// the first stop. Stops without a location are passed through without adding any distance.
+func RouteLength(start *Point, stops []Stop, order []int) float64 {
+	prev := start
+	total := 0.0
+	for _, i := range order {
+		p := stops[i].Point
+		if p == nil {
+			continue
+		}
+		if prev != nil {
+			total += DistanceMeters(*prev, *p)
+		}
+		prev = p
+	}
+	return total
+}
+
+// keepsFixedOrder reports whether the fixed stops appear in order in order
+func keepsFixedOrder(stops []Stop, order []int) bool {
+	last := -1
+	for _, i := range order {
+		if stops[i].Fixed {
+			if i < last {
+				return false
+			}
+			last = i
+		}
+	}
+	return true
+}
+
+// OptimiseRoute orders stops to make the route from start as short as possible. Stops that are
+// neither located nor fixed cannot be placed and go last in their original order. exact is false
+// when there were more than ExactRouteLimit stops to route and the order is a 2-opt approximation.
+func OptimiseRoute(start *Point, stops []Stop) (order []int, meters float64, exact bool) {
+	var routed, unplaced []int
+	for i, s := range stops {
+		if s.Point == nil && !s.Fixed {
+			unplaced = append(unplaced, i)
+		} else {
+			routed = append(routed, i)
+		}
+	}
+
+	exact = len(routed) <= ExactRouteLimit
+	if exact {
+		order = exactRoute(start, stops, routed)
+	} else {
+		order = twoOpt(start, stops, nearestNeighbour(start, stops, routed))
+	}
+	order = append(order, unplaced...)
+	return order, RouteLength(start, stops, order), exact
+}
+
+// nextFixed is the first fixed stop in routed not yet used, -1 when all are
+func nextFixed(stops []Stop, routed []int, used []bool) int {
+	for _, i := range routed {
+		if stops[i].Fixed && !used[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// exactRoute tries every order of routed that keeps the fixed stops in order, pruning orders
+// already longer than the best found
+func exactRoute(start *Point, stops []Stop, routed []int) []int {
+	best := append([]int(nil), routed...)
+	bestLength := RouteLength(start, stops, best)
+	used := make([]bool, len(stops))
+	path := make([]int, 0, len(routed))
+
+	var visit func(prev *Point, length float64)
+	visit = func(prev *Point, length float64) {
+		if length >= bestLength {
+			return
+		}
+		if len(path) == len(routed) {
+			best = append(best[:0], path...)
+			bestLength = length
+			return
+		}
+		fixed := nextFixed(stops, routed, used)
+		for _, i := range routed {
+			if used[i] || (stops[i].Fixed && i != fixed) {
+				continue
+			}
+			next, leg := prev, 0.0
+			if p := stops[i].Point; p != nil {
+				if prev != nil {
+					leg = DistanceMeters(*prev, *p)
+				}
+				next = p
+			}
+			used[i] = true
+			path = append(path, i)
+			visit(next, length+leg)
+			path = path[:len(path)-1]
+			used[i] = false
+		}
+	}
+	// the given order is a valid route, so only strictly shorter ones replace it
+	bestLength += 1e-9
+	visit(start, 0)
+	return best
+}
+
+// nearestNeighbour builds a route by always going to the closest stop that may come next
+func nearestNeighbour(start *Point, stops []Stop, routed []int) []int {
+	used := make([]bool, len(stops))
+	order := make([]int, 0, len(routed))
+	prev := start
+	for len(order) < len(routed) {
+		fixed := nextFixed(stops, routed, used)
+		pick, pickDistance := -1, math.Inf(1)
+		for _, i := range routed {
+			if used[i] || (stops[i].Fixed && i != fixed) {
+				continue
+			}
+			d := 0.0
+			if p := stops[i].Point; p != nil && prev != nil {
+				d = DistanceMeters(*prev, *p)
+			}
+			if d < pickDistance {
+				pick, pickDistance = i, d
+			}
+		}
+		used[pick] = true
+		order = append(order, pick)
+		if p := stops[pick].Point; p != nil {
+			prev = p
+		}
+	}
+	return order
+}
+
+// twoOpt keeps reversing stretches of the route while that makes it shorter without breaking the
+// order of the fixed stops
+func twoOpt(start *Point, stops []Stop, order []int) []int {
+	length := RouteLength(start, stops, order)
+	candidate := make([]int, len(order))
+	for improved := true; improved; {
+		improved = false
+		for i := 0; i < len(order)-1; i++ {
+			for j := i + 1; j < len(order); j++ {
+				copy(candidate, order)
+				for a, b := i, j; a < b; a, b = a+1, b-1 {
+					candidate[a], candidate[b] = candidate[b], candidate[a]
+				}
+				if !keepsFixedOrder(stops, candidate) {
+					continue
+				}
+				if l := RouteLength(start, stops, candidate); l < length-1e-6 {
+					copy(order, candidate)
+					length = l
+					improved = true
+				}
+			}
+		}
+	}
+	return order
+}
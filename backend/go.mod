@@ -8,6 +8,7 @@ require (
 	github.com/minio/minio-go/v7 v7.0.95
 	github.com/redis/go-redis/v9 v9.14.0
 	golang.org/x/crypto v0.41.0
+	golang.org/x/image v0.25.0
 )
 
 require (
@@ -1,34 +1,149 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Joshua-Pok/FYP-backend/geo"
 	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/money"
 	"github.com/Joshua-Pok/FYP-backend/repository"
 	"github.com/Joshua-Pok/FYP-backend/service"
 )
 
 type ActivityHandler struct {
-	activityRepo repository.ActivityRepository
-	minioService *service.MinIOService
-	gorseService *service.GorseService
-	cacheService *service.CacheService
+	activityRepo   repository.ActivityRepositoryInterface
+	blobStore      service.BlobStore
+	gorseService   *service.GorseService
+	cacheService   *service.CacheService
+	suggestService *service.SuggestService
+	countryRepo    *repository.CountryRepository
+	geocoder       service.Geocoder
+	prices         *PriceConverter
+	hoursRepo      *repository.OpeningHoursRepository
+	itineraryRepo  *repository.ItineraryRepository
+	validator      *service.ScheduleValidator
+	access         *service.ItineraryAccess
 }
 
+const (
+	defaultNearbyRadius = 5000
+	maxNearbyRadius     = 100000
+	defaultNearbyLimit  = 20
+	maxNearbyLimit      = 100
+)
+
 type CreateActivityRequest struct {
-	Name      string `json:"name"`
-	Title     string `json:"Title"`
-	Price     int    `json:"price"`
-	Address   string `json:"address"`
-	ImageURL  string `json:"imageurl"`
-	CountryID int    `json:"countryid"`
+	Name  string `json:"name"`
+	Title string `json:"Title"`
+	// price in major units of currency, which defaults to the country's currency
+	Price     float64 `json:"price"`
+	Currency  string  `json:"currency"`
+	Address   string  `json:"address"`
+	ImageURL  string  `json:"imageurl"`
+	CountryID int     `json:"countryid"`
+	// optional, the address is geocoded when these are left out
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	// category slugs and free-form tags
+	Categories []string `json:"categories"`
+	Tags       []string `json:"tags"`
+}
+
+type ActivityTaxonomyRequest struct {
+	Categories []string `json:"categories"`
+	Tags       []string `json:"tags"`
+}
+
+func NewActivityhandler(activityRepo repository.ActivityRepositoryInterface, blobStore service.BlobStore, gorseService *service.GorseService, cacheService *service.CacheService, suggestService *service.SuggestService, countryRepo *repository.CountryRepository, geocoder service.Geocoder, prices *PriceConverter, hoursRepo *repository.OpeningHoursRepository, itineraryRepo *repository.ItineraryRepository, validator *service.ScheduleValidator, access *service.ItineraryAccess) *ActivityHandler {
+	return &ActivityHandler{activityRepo: activityRepo, blobStore: blobStore, gorseService: gorseService, cacheService: cacheService, suggestService: suggestService, countryRepo: countryRepo, geocoder: geocoder, prices: prices, hoursRepo: hoursRepo, itineraryRepo: itineraryRepo, validator: validator, access: mustHaveAccess(access)}
+}
+
+// itineraryDates reads ?itinerary_id= into the itinerary's first and last day, both nil when it
+// is not given. It writes the error response itself when ok is false.
+func (h *ActivityHandler) itineraryDates(w http.ResponseWriter, r *http.Request) (from, to *time.Time, ok bool) {
+	v := r.URL.Query().Get("itinerary_id")
+	if v == "" {
+		return nil, nil, true
+	}
+	itineraryID, err := strconv.Atoi(v)
+	if err != nil {
+		http.Error(w, "Invalid itinerary_id parameter", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleViewer) {
+		return nil, nil, false
+	}
+	start, end, err := h.itineraryRepo.GetItineraryDates(r.Context(), itineraryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Itinerary with that id does not exist", http.StatusNotFound)
+		return nil, nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch itinerary", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	return &start, &end, true
+}
+
+// filterAvailable drops the activities that are closed on every day from from to to
+func (h *ActivityHandler) filterAvailable(ctx context.Context, activities []models.Activity, from, to *time.Time) ([]models.Activity, error) {
+	if from == nil || len(activities) == 0 {
+		return activities, nil
+	}
+	ids := make([]int, len(activities))
+	for i, a := range activities {
+		ids[i] = a.ID
+	}
+	available, err := h.hoursRepo.FilterAvailable(ctx, ids, *from, *to)
+	if err != nil {
+		return nil, err
+	}
+	kept := activities[:0]
+	for _, a := range activities {
+		if available[a.ID] {
+			kept = append(kept, a)
+		}
+	}
+	return kept, nil
 }
 
-func NewActivityhandler(activityRepo repository.ActivityRepository, minioService *service.MinIOService, gorseService *service.GorseService, cacheService *service.CacheService) *ActivityHandler {
-	return &ActivityHandler{activityRepo: activityRepo, minioService: minioService, gorseService: gorseService, cacheService: cacheService}
+// locate fills in coordinates for an activity that was created without them. Geocoding is best
+// effort, an address that cannot be resolved just leaves the activity without a location.
+func (h *ActivityHandler) locate(ctx context.Context, activity *models.Activity) {
+	if h.geocoder == nil || strings.TrimSpace(activity.Address) == "" {
+		return
+	}
+	countryCode := ""
+	if activity.CountryID != 0 {
+		if country, err := h.countryRepo.GetCountryById(ctx, activity.CountryID); err == nil {
+			countryCode = country.ISOCode
+		}
+	}
+	point, err := h.geocoder.Geocode(ctx, activity.Address, countryCode)
+	if err != nil {
+		log.Printf("warning: failed to geocode %q: %v", activity.Address, err)
+		return
+	}
+	activity.Latitude = &point.Lat
+	activity.Longitude = &point.Lng
+}
+
+// presignImages swaps the cover image key and its srcset variant keys for presigned urls
+func (h *ActivityHandler) presignImages(ctx context.Context, activity *models.Activity) {
+	activity.ImageURL = h.blobStore.GetObjectURL(ctx, activity.ImageURL)
+	for width, key := range activity.ImageSrcset {
+		activity.ImageSrcset[width] = h.blobStore.GetObjectURL(ctx, key)
+	}
 }
 
 func (h *ActivityHandler) CreateActivity(w http.ResponseWriter, r *http.Request) {
@@ -38,12 +153,77 @@ func (h *ActivityHandler) CreateActivity(w http.ResponseWriter, r *http.Request)
 		json.NewEncoder(w).Encode(map[string]string{"error": "invalid activity format"})
 		return
 	}
-	activity, err := h.activityRepo.CreateActivity(req.Name, req.Title, req.Price, req.Address, req.ImageURL, req.CountryID)
+	categories, err := normalizeCategories(req.Categories)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "latitude and longitude must be given together"})
+		return
+	}
+	if req.Price < 0 || math.IsNaN(req.Price) || math.IsInf(req.Price, 0) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "price must be a non-negative number"})
+		return
+	}
+	countryCurrency := ""
+	if req.Currency == "" && req.CountryID != 0 {
+		if country, err := h.countryRepo.GetCountryById(r.Context(), req.CountryID); err == nil {
+			countryCurrency = country.Currency
+		}
+	}
+	currency, minorUnits, err := priceCurrency(req.Currency, countryCurrency, h.prices.exchange.MinorUnits)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	input := models.Activity{
+		Name:       req.Name,
+		Title:      req.Title,
+		PriceMinor: money.ToMinor(req.Price, minorUnits),
+		Currency:   currency,
+		Address:    req.Address,
+		ImageURL:   req.ImageURL,
+		CountryID:  req.CountryID,
+		Latitude:   req.Latitude,
+		Longitude:  req.Longitude,
+		Categories: categories,
+		Tags:       tags,
+	}
+	if input.Latitude != nil {
+		if !(geo.Point{Lat: *input.Latitude, Lng: *input.Longitude}).Valid() {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "latitude or longitude out of range"})
+			return
+		}
+	} else {
+		h.locate(r.Context(), &input)
+	}
+
+	activity, err := h.activityRepo.CreateActivity(r.Context(), input)
+	if errors.Is(err, repository.ErrUnknownCategory) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "unknown category"})
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "failed to create activity"})
 		return
 	}
+	h.suggestService.RebuildAsync()
+	syncGorseItems(h.activityRepo, h.gorseService, activity.ID)
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -52,6 +232,8 @@ func (h *ActivityHandler) CreateActivity(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// GetActivitiesByItinerary handles GET /activity?itinerary_id= returning the itinerary day by day,
+// each day lists its items in order with their activity
 func (h *ActivityHandler) GetActivitiesByItinerary(w http.ResponseWriter, r *http.Request) {
 	itineraryIDstr := r.URL.Query().Get("itinerary_id")
 	if itineraryIDstr == "" {
@@ -63,17 +245,50 @@ func (h *ActivityHandler) GetActivitiesByItinerary(w http.ResponseWriter, r *htt
 		http.Error(w, "Invalid itinerary_id parameter", http.StatusBadRequest)
 		return
 	}
-	activities, err := h.activityRepo.GetActivitiesByItinerary(itineraryID)
+	if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleViewer) {
+		return
+	}
+	currency, ok := h.prices.displayCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	schedule, err := h.itineraryRepo.GetItinerarySchedule(r.Context(), itineraryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Itinerary with that id does not exist", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to fetch activities", http.StatusInternalServerError)
 		return
 	}
+	prepare := func(items []models.ItineraryItem) {
+		for i := range items {
+			h.presignImages(r.Context(), items[i].Activity)
+			h.prices.convert(currency, items[i].Activity)
+		}
+	}
+	for _, day := range schedule.Days {
+		prepare(day.Items)
+	}
+	prepare(schedule.Unscheduled)
+
+	var warnings []models.ScheduleWarning
+	if h.validator != nil {
+		if warnings, err = h.validator.Check(r.Context(), schedule); err != nil {
+			log.Printf("failed to check itinerary %d: %v", itineraryID, err)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success":    true,
-		"itinerary":  itineraryID,
-		"activities": activities,
+		"success":     true,
+		"itinerary":   itineraryID,
+		"start_date":  schedule.StartDate,
+		"end_date":    schedule.EndDate,
+		"days":        schedule.Days,
+		"unscheduled": schedule.Unscheduled,
+		"warnings":    warnings,
 	})
 }
 
@@ -89,11 +304,18 @@ func (h *ActivityHandler) GetActivityById(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	activity, err := h.activityRepo.GetActivityById(activityID)
+	currency, ok := h.prices.displayCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	activity, err := h.activityRepo.GetActivityById(r.Context(), activityID)
 	if err != nil {
 		http.Error(w, "Activity with that id does not exist", http.StatusNotFound)
 		return
 	}
+	h.presignImages(r.Context(), activity)
+	h.prices.convert(currency, activity)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(activity)
@@ -106,20 +328,40 @@ func (h *ActivityHandler) GetRecommendedActivities(w http.ResponseWriter, r *htt
 		return
 	}
 
-	ids, err := h.gorseService.GetRecommendations(userId, 10)
+	category, err := parseTaxonomyFilter(url.Values{"category": {r.URL.Query().Get("category")}})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	currency, ok := h.prices.displayCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	from, to, ok := h.itineraryDates(w, r)
+	if !ok {
+		return
+	}
+
+	ids, err := h.gorseService.GetRecommendations(userId, 10, category.Category)
 	if err != nil {
 		http.Error(w, "Failed to get recommendations", http.StatusInternalServerError)
 		return
 	}
 
-	activities, err := h.activityRepo.GetActivitiesByIds(ids)
+	activities, err := h.activityRepo.GetActivitiesByIds(r.Context(), ids)
 	if err != nil {
 		http.Error(w, "failed to get activities", http.StatusInternalServerError)
 		return
 	}
+	if activities, err = h.filterAvailable(r.Context(), activities, from, to); err != nil {
+		http.Error(w, "failed to check availability", http.StatusInternalServerError)
+		return
+	}
 	for i := range activities {
-		url := h.minioService.GetPublicURL(activities[i].ImageURL)
-		activities[i].ImageURL = url
+		h.presignImages(r.Context(), &activities[i])
+		h.prices.convert(currency, &activities[i])
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -131,13 +373,40 @@ func (h *ActivityHandler) GetRecommendedActivities(w http.ResponseWriter, r *htt
 }
 
 func (h *ActivityHandler) GetPopularActivities(w http.ResponseWriter, r *http.Request) {
+	category, err := parseTaxonomyFilter(url.Values{"category": {r.URL.Query().Get("category")}})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	currency, ok := h.prices.displayCurrency(w, r)
+	if !ok {
+		return
+	}
+
 	cacheKey := "popular_activities"
+	if category.Category != "" {
+		cacheKey += ":" + category.Category
+	}
+	from, to, ok := h.itineraryDates(w, r)
+	if !ok {
+		return
+	}
 	var activities []models.Activity
 
 	data, err := h.cacheService.Get(cacheKey)
 	if err == nil && len(data) > 0 {
 		if err := json.Unmarshal(data, &activities); err == nil {
 			//cache exists, can return
+			// availability depends on the itinerary so it is filtered after the cache
+			if activities, err = h.filterAvailable(r.Context(), activities, from, to); err != nil {
+				http.Error(w, "failed to check availability", http.StatusInternalServerError)
+				return
+			}
+			// the cache holds object keys, image urls are presigned per request so they never expire in the cache
+			for i := range activities {
+				h.presignImages(r.Context(), &activities[i])
+				h.prices.convert(currency, &activities[i])
+			}
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]interface{}{
 				"success":    true,
@@ -147,29 +416,340 @@ func (h *ActivityHandler) GetPopularActivities(w http.ResponseWriter, r *http.Re
 		}
 	}
 
-	ids, err := h.gorseService.GetPopularActivities(10)
+	ids, err := h.gorseService.GetPopularActivities(10, category.Category)
 	if err != nil {
 		http.Error(w, "failed to get popular activities", http.StatusInternalServerError)
 		return
 	}
-	activities, err = h.activityRepo.GetActivitiesByIds(ids)
+	activities, err = h.activityRepo.GetActivitiesByIds(r.Context(), ids)
 	if err != nil {
 		http.Error(w, "Failed to get activities", http.StatusInternalServerError)
 		return
 	}
 
+	cacheData, _ := json.Marshal(activities)
+	_ = h.cacheService.Set(cacheKey, cacheData, 5*time.Minute)
+
+	if activities, err = h.filterAvailable(r.Context(), activities, from, to); err != nil {
+		http.Error(w, "failed to check availability", http.StatusInternalServerError)
+		return
+	}
+
 	for i := range activities {
-		url := h.minioService.GetPublicURL(activities[i].ImageURL)
-		activities[i].ImageURL = url
+		h.presignImages(r.Context(), &activities[i])
+		h.prices.convert(currency, &activities[i])
 	}
 
-	cacheData, _ := json.Marshal(activities)
-	_ = h.cacheService.Set(cacheKey, cacheData, 5*time.Minute)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"activities": activities,
+	})
+
+}
+
+func (h *ActivityHandler) DeleteActivity(w http.ResponseWriter, r *http.Request) {
+	activityID, err := strconv.Atoi(r.URL.Query().Get("activity_id"))
+	if err != nil {
+		http.Error(w, "Invalid activity id", http.StatusBadRequest)
+		return
+	}
+	if err := h.activityRepo.DeleteActivity(r.Context(), activityID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Activity with that id does not exist", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete activity", http.StatusInternalServerError)
+		return
+	}
+	h.suggestService.RebuildAsync()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Activity deleted",
+	})
+}
+
+func (h *ActivityHandler) RestoreActivity(w http.ResponseWriter, r *http.Request) {
+	activityID, err := strconv.Atoi(r.URL.Query().Get("activity_id"))
+	if err != nil {
+		http.Error(w, "Invalid activity id", http.StatusBadRequest)
+		return
+	}
+	if err := h.activityRepo.RestoreActivity(r.Context(), activityID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "No deleted activity with that id", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to restore activity", http.StatusInternalServerError)
+		return
+	}
+	h.suggestService.RebuildAsync()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Activity restored",
+	})
+}
+
+// ParseActivitySearch reads the search parameters of GET /activities/search, price bounds are in
+// currency. ?country= is a country id or an ISO code.
+func ParseActivitySearch(q url.Values, currency string) (models.ActivitySearchFilter, error) {
+	taxonomy, err := parseTaxonomyFilter(q)
+	if err != nil {
+		return models.ActivitySearchFilter{}, err
+	}
+	filter := models.ActivitySearchFilter{ActivityTaxonomyFilter: taxonomy, Query: strings.TrimSpace(q.Get("q")), PriceCurrency: currency}
+	if filter.Query == "" {
+		return filter, errors.New("missing search query")
+	}
+	if len(filter.Query) > 200 {
+		return filter, errors.New("search query too long")
+	}
+	if filter.PriceCurrency == "" {
+		filter.PriceCurrency = defaultCurrency
+	}
+	if filter.MinPrice, err = parsePriceBound(q, "min_price"); err != nil {
+		return filter, err
+	}
+	if filter.MaxPrice, err = parsePriceBound(q, "max_price"); err != nil {
+		return filter, err
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return filter, errors.New("min_price must not be above max_price")
+	}
+	if v := q.Get("min_rating"); v != "" {
+		rating, err := strconv.ParseFloat(v, 64)
+		if err != nil || rating < 0 || rating > 5 {
+			return filter, errors.New("Invalid min_rating parameter")
+		}
+		filter.MinRating = &rating
+	}
+	if v := q.Get("country"); v != "" {
+		if id, err := strconv.Atoi(v); err == nil {
+			filter.CountryID = id
+		} else {
+			filter.CountryCode = strings.ToUpper(v)
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return filter, errors.New("Invalid limit parameter")
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if filter.Offset, err = strconv.Atoi(v); err != nil || filter.Offset < 0 {
+			return filter, errors.New("Invalid offset parameter")
+		}
+	}
+	return filter, nil
+}
+
+// parsePriceBound reads an optional non-negative price parameter
+func parsePriceBound(q url.Values, name string) (*float64, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	price, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(price) || math.IsInf(price, 0) || price < 0 {
+		return nil, errors.New("Invalid " + name + " parameter")
+	}
+	return &price, nil
+}
+
+// SearchActivities handles GET /activities/search?q=&min_price=&max_price=&min_rating=&country=&currency=&limit=&offset=
+// where country is either a country id or an ISO-3166 alpha-2 code and prices are in currency, USD by default
+func (h *ActivityHandler) SearchActivities(w http.ResponseWriter, r *http.Request) {
+	currency, ok := h.prices.displayCurrency(w, r)
+	if !ok {
+		return
+	}
+	filter, err := ParseActivitySearch(r.URL.Query(), currency)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.activityRepo.SearchActivities(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to search activities", http.StatusInternalServerError)
+		return
+	}
+	for i := range results {
+		h.presignImages(r.Context(), &results[i].Activity)
+		h.prices.convert(currency, &results[i].Activity)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"query":   filter.Query,
+		"results": results,
+	})
+}
+
+// NearbyActivities handles GET /activities/nearby?lat=&lng=&radius=&limit= with radius in metres
+func (h *ActivityHandler) NearbyActivities(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	lat, err := strconv.ParseFloat(q.Get("lat"), 64)
+	if err != nil {
+		http.Error(w, "Invalid lat parameter", http.StatusBadRequest)
+		return
+	}
+	lng, err := strconv.ParseFloat(q.Get("lng"), 64)
+	if err != nil {
+		http.Error(w, "Invalid lng parameter", http.StatusBadRequest)
+		return
+	}
+	taxonomy, err := parseTaxonomyFilter(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	center := geo.Point{Lat: lat, Lng: lng}
+	if !center.Valid() {
+		http.Error(w, "lat or lng out of range", http.StatusBadRequest)
+		return
+	}
+
+	radius := float64(defaultNearbyRadius)
+	if v := q.Get("radius"); v != "" {
+		if radius, err = strconv.ParseFloat(v, 64); err != nil || radius <= 0 {
+			http.Error(w, "Invalid radius parameter", http.StatusBadRequest)
+			return
+		}
+		if radius > maxNearbyRadius {
+			radius = maxNearbyRadius
+		}
+	}
+	limit := defaultNearbyLimit
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		if limit > maxNearbyLimit {
+			limit = maxNearbyLimit
+		}
+	}
+
+	currency, ok := h.prices.displayCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	activities, err := h.activityRepo.GetActivitiesNearby(r.Context(), center, radius, limit, taxonomy)
+	if err != nil {
+		http.Error(w, "Failed to fetch nearby activities", http.StatusInternalServerError)
+		return
+	}
+	for i := range activities {
+		h.presignImages(r.Context(), &activities[i].Activity)
+		h.prices.convert(currency, &activities[i].Activity)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success":    true,
+		"center":     center,
+		"radius":     radius,
 		"activities": activities,
 	})
+}
+
+// ListActivities handles GET /activities?category=&tag=&itinerary_id=&limit=&offset=, itinerary_id keeps
+// only activities open on some day of that itinerary
+func (h *ActivityHandler) ListActivities(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	taxonomy, err := parseTaxonomyFilter(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter := models.ActivityListFilter{ActivityTaxonomyFilter: taxonomy}
+	var ok bool
+	if filter.AvailableFrom, filter.AvailableTo, ok = h.itineraryDates(w, r); !ok {
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if filter.Offset, err = strconv.Atoi(v); err != nil || filter.Offset < 0 {
+			http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	currency, ok := h.prices.displayCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	activities, err := h.activityRepo.ListActivities(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to fetch activities", http.StatusInternalServerError)
+		return
+	}
+	for i := range activities {
+		h.presignImages(r.Context(), &activities[i])
+		h.prices.convert(currency, &activities[i])
+	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"activities": activities,
+	})
+}
+
+// SetActivityTaxonomy handles PUT /activity/taxonomy?activity_id= replacing the activity's categories and tags
+func (h *ActivityHandler) SetActivityTaxonomy(w http.ResponseWriter, r *http.Request) {
+	activityID, err := strconv.Atoi(r.URL.Query().Get("activity_id"))
+	if err != nil {
+		http.Error(w, "Invalid activity id", http.StatusBadRequest)
+		return
+	}
+	var req ActivityTaxonomyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	categories, err := normalizeCategories(req.Categories)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	activity, err := h.activityRepo.SetActivityTaxonomy(r.Context(), activityID, categories, tags)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Activity with that id does not exist", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, repository.ErrUnknownCategory) {
+			http.Error(w, "Unknown category", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to update activity", http.StatusInternalServerError)
+		return
+	}
+	syncGorseItems(h.activityRepo, h.gorseService, activity.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    activity,
+	})
 }
@@ -0,0 +1,555 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/geo"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/money"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+const (
+	// largest import file accepted, it is spooled to disk before the job reads it
+	maxImportBytes = 50 << 20
+	// imports running at once, later ones wait in the queued state
+	maxConcurrentImports = 2
+	// progress is written back to the job every this many rows
+	importProgressEvery    = 100
+	maxActivityFieldLength = 255
+)
+
+// importColumns are the CSV columns in export order, imports accept them in any order
+var importColumns = []string{"title", "name", "price", "currency", "address", "country", "latitude", "longitude", "imageurl", "categories", "tags"}
+
+// gorseColumns maps the item export of the recommender, as in scripts/gorse_items.csv, onto import
+// columns. Columns mapped to "" carry nothing an activity keeps and are ignored.
+var gorseColumns = map[string]string{
+	"itemid":    "title",
+	"comment":   "name",
+	"labels":    "tags",
+	"ishidden":  "",
+	"timestamp": "",
+}
+
+type ImportHandler struct {
+	importRepo     *repository.ImportJobRepository
+	activityRepo   *repository.ActivityRepository
+	countryRepo    *repository.CountryRepository
+	currencyRepo   *repository.CurrencyRepository
+	gorseService   *service.GorseService
+	suggestService *service.SuggestService
+	slots          chan struct{}
+}
+
+func NewImportHandler(importRepo *repository.ImportJobRepository, activityRepo *repository.ActivityRepository, countryRepo *repository.CountryRepository, currencyRepo *repository.CurrencyRepository, gorseService *service.GorseService, suggestService *service.SuggestService) *ImportHandler {
+	return &ImportHandler{
+		importRepo:     importRepo,
+		activityRepo:   activityRepo,
+		countryRepo:    countryRepo,
+		currencyRepo:   currencyRepo,
+		gorseService:   gorseService,
+		suggestService: suggestService,
+		slots:          make(chan struct{}, maxConcurrentImports),
+	}
+}
+
+// RowError is a problem with a single row, the import skips the row and carries on
+type RowError struct{ err error }
+
+func (e RowError) Error() string { return e.err.Error() }
+
+// ActivityRowReader yields import rows until io.EOF. Errors other than RowError abort the import.
+type ActivityRowReader interface {
+	Next() (models.ActivityImportRow, error)
+}
+
+type csvRowReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	fields  int
+}
+
+// NewCSVRowReader reads the header to learn the column order, a gorse item export is accepted too
+func NewCSVRowReader(r io.Reader) (ActivityRowReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	known := make(map[string]bool)
+	for _, c := range importColumns {
+		known[c] = true
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if alias, ok := gorseColumns[name]; ok {
+			if alias == "" {
+				continue
+			}
+			name = alias
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if _, dup := columns[name]; dup {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("missing title column")
+	}
+	return &csvRowReader{reader: reader, columns: columns, fields: len(header)}, nil
+}
+
+func (c *csvRowReader) Next() (models.ActivityImportRow, error) {
+	var row models.ActivityImportRow
+	record, err := c.reader.Read()
+	if err != nil {
+		return row, err
+	}
+	if len(record) != c.fields {
+		return row, RowError{fmt.Errorf("expected %d fields, got %d", c.fields, len(record))}
+	}
+	field := func(name string) string {
+		if i, ok := c.columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	row = models.ActivityImportRow{
+		Title:      field("title"),
+		Name:       field("name"),
+		Currency:   field("currency"),
+		Address:    field("address"),
+		Country:    field("country"),
+		ImageURL:   field("imageurl"),
+		Categories: SplitListCell(field("categories")),
+		Tags:       SplitListCell(field("tags")),
+	}
+	if v := field("price"); v != "" {
+		if row.Price, err = strconv.ParseFloat(v, 64); err != nil {
+			return row, RowError{errors.New("invalid price")}
+		}
+	}
+	for name, dst := range map[string]**float64{"latitude": &row.Latitude, "longitude": &row.Longitude} {
+		if v := field(name); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return row, RowError{errors.New("invalid " + name)}
+			}
+			*dst = &f
+		}
+	}
+	return row, nil
+}
+
+// SplitListCell reads "a; b", "a, b" or a list literal such as "['a', 'b']" as in scripts/gorse_items.csv
+func SplitListCell(cell string) []string {
+	cell = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(cell), "["), "]")
+	var items []string
+	for _, item := range strings.FieldsFunc(cell, func(r rune) bool { return r == ';' || r == ',' }) {
+		if item = strings.Trim(strings.TrimSpace(item), `'"`); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+type jsonRowReader struct {
+	decoder *json.Decoder
+	done    bool
+}
+
+// NewJSONRowReader reads a top level array of rows one element at a time
+func NewJSONRowReader(r io.Reader) (ActivityRowReader, error) {
+	decoder := json.NewDecoder(r)
+	if tok, err := decoder.Token(); err != nil || tok != json.Delim('[') {
+		return nil, errors.New("expected a JSON array of activities")
+	}
+	return &jsonRowReader{decoder: decoder}, nil
+}
+
+func (j *jsonRowReader) Next() (models.ActivityImportRow, error) {
+	var row models.ActivityImportRow
+	if j.done || !j.decoder.More() {
+		j.done = true
+		return row, io.EOF
+	}
+	if err := j.decoder.Decode(&row); err != nil {
+		// a value of the wrong type is consumed whole, so the next row can still be read
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return row, RowError{fmt.Errorf("invalid %s", typeErr.Field)}
+		}
+		return row, err
+	}
+	return row, nil
+}
+
+// ToActivity validates an import row, resolving its country through countries and the
+// decimal places of its price through currencies
+func ToActivity(row models.ActivityImportRow, countries map[string]models.Country, currencies map[string]int) (models.Activity, error) {
+	input := models.Activity{
+		Title:     strings.TrimSpace(row.Title),
+		Name:      strings.TrimSpace(row.Name),
+		Address:   strings.TrimSpace(row.Address),
+		ImageURL:  strings.TrimSpace(row.ImageURL),
+		Latitude:  row.Latitude,
+		Longitude: row.Longitude,
+	}
+	if input.Title == "" {
+		return input, errors.New("title is required")
+	}
+	if input.Name == "" {
+		input.Name = input.Title
+	}
+	if len(input.Name) > maxActivityFieldLength || len(input.Address) > maxActivityFieldLength || len(input.ImageURL) > maxActivityFieldLength {
+		return input, errors.New("name, address and imageurl must be at most 255 characters")
+	}
+	if row.Price < 0 || math.IsNaN(row.Price) || math.IsInf(row.Price, 0) {
+		return input, errors.New("price must be a non-negative number")
+	}
+
+	var country models.Country
+	if name := strings.ToLower(strings.TrimSpace(row.Country)); name != "" {
+		var ok bool
+		if country, ok = countries[name]; !ok {
+			return input, errors.New("unknown country " + strconv.Quote(row.Country))
+		}
+		input.CountryID = country.ID
+	}
+	currency, minorUnits, err := priceCurrency(row.Currency, country.Currency, func(code string) (int, error) {
+		if units, ok := currencies[code]; ok {
+			return units, nil
+		}
+		return 0, service.ErrUnknownCurrency
+	})
+	if err != nil {
+		return input, err
+	}
+	input.Currency = currency
+	input.PriceMinor = money.ToMinor(row.Price, minorUnits)
+
+	if (input.Latitude == nil) != (input.Longitude == nil) {
+		return input, errors.New("latitude and longitude must be given together")
+	}
+	if input.Latitude != nil && !(geo.Point{Lat: *input.Latitude, Lng: *input.Longitude}).Valid() {
+		return input, errors.New("latitude or longitude out of range")
+	}
+
+	if input.Categories, err = normalizeCategories(row.Categories); err != nil {
+		return input, err
+	}
+	if input.Tags, err = normalizeTags(row.Tags); err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+// importFormat picks csv or json from ?format= or else the Content-Type
+func importFormat(r *http.Request) string {
+	if format := strings.ToLower(r.URL.Query().Get("format")); format != "" {
+		return format
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/json":
+		return "json"
+	}
+	return ""
+}
+
+// StartImport handles POST /activities/import?format=csv|json with the file as the request body.
+// The file is stored and imported in the background, poll GET /activities/import?job_id= for progress.
+func (h *ImportHandler) StartImport(w http.ResponseWriter, r *http.Request) {
+	format := importFormat(r)
+	if format != "csv" && format != "json" {
+		http.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	file, err := os.CreateTemp("", "activity-import-*")
+	if err != nil {
+		http.Error(w, "Failed to store import", http.StatusInternalServerError)
+		return
+	}
+	_, err = io.Copy(file, http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Import file must be at most 50MB", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to read import", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.importRepo.CreateImportJob(r.Context(), format, auth.ActorFromContext(r.Context()))
+	if err != nil {
+		os.Remove(file.Name())
+		http.Error(w, "Failed to create import job", http.StatusInternalServerError)
+		return
+	}
+	go h.runImport(*job, file.Name())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"job":        job,
+		"status_url": "/activities/import?job_id=" + strconv.Itoa(job.ID),
+	})
+}
+
+// runImport works through the spooled file row by row, each row is upserted in its own transaction
+func (h *ImportHandler) runImport(job models.ImportJob, path string) {
+	defer os.Remove(path)
+	h.slots <- struct{}{}
+	defer func() { <-h.slots }()
+
+	// changes are audited as the admin who started the import
+	ctx := auth.WithActor(context.Background(), job.CreatedBy)
+	if err := h.importRepo.StartImportJob(ctx, job.ID); err != nil {
+		log.Printf("warning: failed to start import job %d: %v", job.ID, err)
+	}
+	job.Status = models.ImportRunning
+	job.RowErrors = []models.ImportRowError{}
+
+	var changed []int
+	err := h.importRows(ctx, &job, path, &changed)
+	if err != nil {
+		job.Status = models.ImportFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = models.ImportSucceeded
+	}
+	if err := h.importRepo.UpdateImportJob(ctx, &job); err != nil {
+		log.Printf("warning: failed to save import job %d: %v", job.ID, err)
+	}
+	log.Printf("import job %d %s: %d created, %d updated, %d failed", job.ID, job.Status, job.Created, job.Updated, job.Failed)
+
+	if len(changed) > 0 {
+		h.suggestService.RebuildAsync()
+		syncGorseItems(h.activityRepo, h.gorseService, changed...)
+	}
+}
+
+func (h *ImportHandler) importRows(ctx context.Context, job *models.ImportJob, path string, changed *[]int) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var rows ActivityRowReader
+	if job.Format == "csv" {
+		rows, err = NewCSVRowReader(file)
+	} else {
+		rows, err = NewJSONRowReader(file)
+	}
+	if err != nil {
+		return err
+	}
+	countries, err := h.countryRepo.GetCountryLookup(ctx)
+	if err != nil {
+		return err
+	}
+	currencies, err := h.currencyRepo.GetCurrencies(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		row, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var bad RowError
+		if err != nil && !errors.As(err, &bad) {
+			return fmt.Errorf("row %d: %w", job.Processed+1, err)
+		}
+		job.Processed++
+		if err == nil {
+			err = h.importRow(ctx, job, row, countries, currencies, changed)
+		}
+		if err != nil {
+			job.Failed++
+			if len(job.RowErrors) < repository.MaxImportRowErrors {
+				job.RowErrors = append(job.RowErrors, models.ImportRowError{Row: job.Processed, Title: strings.TrimSpace(row.Title), Error: err.Error()})
+			}
+		}
+		if job.Processed%importProgressEvery == 0 {
+			if err := h.importRepo.UpdateImportJob(ctx, job); err != nil {
+				log.Printf("warning: failed to save import job %d progress: %v", job.ID, err)
+			}
+		}
+	}
+}
+
+func (h *ImportHandler) importRow(ctx context.Context, job *models.ImportJob, row models.ActivityImportRow, countries map[string]models.Country, currencies map[string]int, changed *[]int) error {
+	input, err := ToActivity(row, countries, currencies)
+	if err != nil {
+		return err
+	}
+	activity, created, err := h.activityRepo.UpsertActivityByTitle(ctx, input)
+	if errors.Is(err, repository.ErrUnknownCategory) {
+		return errors.New("unknown category")
+	}
+	if err != nil {
+		log.Printf("warning: import job %d failed to save %q: %v", job.ID, input.Title, err)
+		return errors.New("failed to save activity")
+	}
+	if created {
+		job.Created++
+	} else {
+		job.Updated++
+	}
+	*changed = append(*changed, activity.ID)
+	return nil
+}
+
+// GetImport handles GET /activities/import?job_id= reporting the job's progress and row errors
+func (h *ImportHandler) GetImport(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.Atoi(r.URL.Query().Get("job_id"))
+	if err != nil {
+		http.Error(w, "Invalid job id", http.StatusBadRequest)
+		return
+	}
+	job, err := h.importRepo.GetImportJobById(r.Context(), jobID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Import job with that id does not exist", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to fetch import job", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"job":     job,
+	})
+}
+
+// toImportRow is the inverse of ToActivity so an export can be imported again unchanged
+func toImportRow(activity models.Activity) models.ActivityImportRow {
+	row := models.ActivityImportRow{
+		Title:      activity.Title,
+		Name:       activity.Name,
+		Price:      activity.Price,
+		Currency:   activity.Currency,
+		Address:    activity.Address,
+		Latitude:   activity.Latitude,
+		Longitude:  activity.Longitude,
+		ImageURL:   activity.ImageURL,
+		Categories: activity.Categories,
+		Tags:       activity.Tags,
+	}
+	if activity.Country != nil {
+		row.Country = activity.Country.ISOCode
+		if row.Country == "" {
+			row.Country = activity.Country.Name
+		}
+	}
+	return row
+}
+
+func formatOptionalFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+// ExportActivities handles GET /activities/export?format=csv|json streaming every live activity
+// in the import format
+func (h *ImportHandler) ExportActivities(w http.ResponseWriter, r *http.Request) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "csv"
+	}
+
+	var write func(models.ActivityImportRow) error
+	var finish func() error
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		cw := csv.NewWriter(w)
+		cw.Write(importColumns)
+		write = func(row models.ActivityImportRow) error {
+			return cw.Write([]string{
+				row.Title, row.Name, strconv.FormatFloat(row.Price, 'f', -1, 64), row.Currency, row.Address, row.Country,
+				formatOptionalFloat(row.Latitude), formatOptionalFloat(row.Longitude), row.ImageURL,
+				strings.Join(row.Categories, ";"), strings.Join(row.Tags, ";"),
+			})
+		}
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, "[")
+		first := true
+		write = func(row models.ActivityImportRow) error {
+			if !first {
+				io.WriteString(w, ",")
+			}
+			first = false
+			data, err := json.Marshal(row)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		}
+		finish = func() error {
+			_, err := io.WriteString(w, "]\n")
+			return err
+		}
+	default:
+		http.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="activities.`+format+`"`)
+
+	// the status is already sent once rows stream, a failure part way can only be logged
+	err := h.activityRepo.ExportActivities(r.Context(), func(activity models.Activity) error {
+		return write(toImportRow(activity))
+	})
+	if err == nil {
+		err = finish()
+	}
+	if err != nil {
+		log.Printf("warning: activity export failed: %v", err)
+	}
+}
@@ -0,0 +1,506 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/money"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+const (
+	maxExpenseDescriptionLength = 500
+	// most shares one member can hold of an expense split by shares
+	maxExpenseShares = 1000
+)
+
+type BudgetHandler struct {
+	budgets     *service.BudgetService
+	expenseRepo *repository.ExpenseRepository
+	memberRepo  *repository.MemberRepository
+	exchange    *service.ExchangeService
+	prices      *PriceConverter
+	userRepo    *repository.UserRepository
+	access      *service.ItineraryAccess
+}
+
+type BudgetRequest struct {
+	// in major units of currency, null clears the budget
+	Amount   *float64 `json:"amount"`
+	Currency string   `json:"currency"`
+}
+
+type ExpenseRequest struct {
+	Category    string  `json:"category"`
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	// user id of whoever paid, the signed in user by default
+	PaidBy int    `json:"paid_by"`
+	Date   string `json:"date"`
+	// equal by default, shared among everyone on the itinerary when no shares are given
+	Split  string                `json:"split"`
+	Shares []ExpenseShareRequest `json:"shares"`
+}
+
+// ExpenseShareRequest is a member's part of an expense, Shares is read when splitting by shares
+// and Amount, in major units, when splitting by exact amounts
+type ExpenseShareRequest struct {
+	UserID int     `json:"user_id"`
+	Shares int     `json:"shares"`
+	Amount float64 `json:"amount"`
+}
+
+func NewBudgetHandler(budgets *service.BudgetService, expenseRepo *repository.ExpenseRepository, memberRepo *repository.MemberRepository, exchange *service.ExchangeService, prices *PriceConverter, userRepo *repository.UserRepository, access *service.ItineraryAccess) *BudgetHandler {
+	return &BudgetHandler{budgets: budgets, expenseRepo: expenseRepo, memberRepo: memberRepo, exchange: exchange, prices: prices, userRepo: userRepo, access: mustHaveAccess(access)}
+}
+
+// summaryCurrency is the display currency of the request, dollars when there is none
+func (h *BudgetHandler) summaryCurrency(w http.ResponseWriter, r *http.Request) (string, bool) {
+	currency, ok := h.prices.displayCurrency(w, r)
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	return currency, ok
+}
+
+// budgetAlerts summarises the itinerary after a write on the primary, a failure only loses the alerts
+func (h *BudgetHandler) budgetAlerts(ctx context.Context, itineraryID int, currency string) []models.BudgetAlert {
+	summary, err := h.budgets.Summary(database.WithPrimary(ctx), itineraryID, currency)
+	if err != nil {
+		log.Printf("failed to summarise budget of itinerary %d: %v", itineraryID, err)
+		return nil
+	}
+	return summary.Alerts
+}
+
+// toExpense checks the request and turns it into an expense on the itinerary, the payer defaults to
+// the signed in user
+func (h *BudgetHandler) toExpense(r *http.Request, itineraryID int, req ExpenseRequest) (models.Expense, error) {
+	expense := models.Expense{
+		ItineraryID: itineraryID,
+		Category:    strings.ToLower(strings.TrimSpace(req.Category)),
+		Description: strings.TrimSpace(req.Description),
+		PaidBy:      req.PaidBy,
+		SplitMethod: strings.ToLower(strings.TrimSpace(req.Split)),
+		Date:        strings.TrimSpace(req.Date),
+	}
+	if expense.Category == "" {
+		expense.Category = models.ExpenseOther
+	}
+	if !slices.Contains(models.ExpenseCategories, expense.Category) {
+		return expense, errors.New("category must be one of " + strings.Join(models.ExpenseCategories, ", "))
+	}
+	if len(expense.Description) > maxExpenseDescriptionLength {
+		return expense, errors.New("description must be at most 500 characters")
+	}
+	if _, err := time.Parse("2006-01-02", expense.Date); err != nil {
+		return expense, errors.New("date must be a YYYY-MM-DD date")
+	}
+	if req.Amount <= 0 || math.IsNaN(req.Amount) || math.IsInf(req.Amount, 0) {
+		return expense, errors.New("amount must be positive")
+	}
+	currency, units, err := priceCurrency(req.Currency, "", h.exchange.MinorUnits)
+	if err != nil {
+		return expense, err
+	}
+	expense.Currency = currency
+	if expense.AmountMinor = money.ToMinor(req.Amount, units); expense.AmountMinor <= 0 {
+		return expense, errors.New("amount is too small for the currency")
+	}
+
+	if expense.PaidBy == 0 {
+		actor := auth.ActorFromContext(r.Context())
+		if actor == auth.AnonymousActor || actor == auth.SystemActor {
+			return expense, errors.New("paid_by is required")
+		}
+		user, err := h.userRepo.GetUserByEmail(actor)
+		if err != nil {
+			return expense, errors.New("paid_by is required")
+		}
+		expense.PaidBy = user.ID
+	}
+	if err := h.splitExpense(r.Context(), &expense, req.Shares, units); err != nil {
+		return expense, err
+	}
+	return expense, nil
+}
+
+// splitExpense works out each member's part of the expense in minor units so the parts add up to
+// the amount exactly, whether they are members is left to the repository
+func (h *BudgetHandler) splitExpense(ctx context.Context, expense *models.Expense, shares []ExpenseShareRequest, units int) error {
+	if expense.SplitMethod == "" {
+		expense.SplitMethod = models.SplitEqual
+	}
+	seen := make(map[int]bool, len(shares))
+	for _, share := range shares {
+		if share.UserID <= 0 {
+			return errors.New("every share needs a user_id")
+		}
+		if seen[share.UserID] {
+			return errors.New("each member can only have one share of an expense")
+		}
+		seen[share.UserID] = true
+	}
+
+	switch expense.SplitMethod {
+	case models.SplitEqual:
+		users := make([]int, len(shares))
+		for i, share := range shares {
+			users[i] = share.UserID
+		}
+		if len(users) == 0 {
+			members, err := h.memberRepo.GetMembers(ctx, expense.ItineraryID)
+			if err != nil {
+				return err
+			}
+			for _, m := range members {
+				users = append(users, m.UserID)
+			}
+		}
+		if len(users) == 0 {
+			return errors.New("the itinerary has no members to split the expense between")
+		}
+		parts := money.SplitEqual(expense.AmountMinor, len(users))
+		for i, id := range users {
+			expense.Shares = append(expense.Shares, models.ExpenseShare{UserID: id, Shares: 1, AmountMinor: parts[i]})
+		}
+	case models.SplitShares:
+		if len(shares) == 0 {
+			return errors.New("shares are required to split by shares")
+		}
+		weights := make([]int64, len(shares))
+		for i, share := range shares {
+			if share.Shares <= 0 || share.Shares > maxExpenseShares {
+				return errors.New("shares must be between 1 and 1000")
+			}
+			weights[i] = int64(share.Shares)
+		}
+		parts := money.Split(expense.AmountMinor, weights)
+		for i, share := range shares {
+			expense.Shares = append(expense.Shares, models.ExpenseShare{UserID: share.UserID, Shares: share.Shares, AmountMinor: parts[i]})
+		}
+	case models.SplitExact:
+		if len(shares) == 0 {
+			return errors.New("shares are required to split by exact amounts")
+		}
+		var total int64
+		for _, share := range shares {
+			if share.Amount < 0 || math.IsNaN(share.Amount) || math.IsInf(share.Amount, 0) {
+				return errors.New("share amounts must not be negative")
+			}
+			amount := money.ToMinor(share.Amount, units)
+			total += amount
+			expense.Shares = append(expense.Shares, models.ExpenseShare{UserID: share.UserID, Shares: 1, AmountMinor: amount})
+		}
+		if total != expense.AmountMinor {
+			return errors.New("share amounts must add up to the expense amount")
+		}
+	default:
+		return errors.New("split must be one of equal, shares, exact")
+	}
+	return nil
+}
+
+// writeExpenseError maps the repository errors shared by the expense endpoints
+func writeExpenseError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Itinerary or expense not found", http.StatusNotFound)
+	case isPQError(err, pqForeignKeyViolation):
+		http.Error(w, "paid_by is not a user", http.StatusBadRequest)
+	case errors.Is(err, repository.ErrNotMember):
+		http.Error(w, "paid_by and everyone sharing the expense must be members of the itinerary", http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to "+action, http.StatusInternalServerError)
+	}
+}
+
+// GetBudget handles GET /itinerary/budget?itinerary_id=&currency= comparing the budget with the
+// estimated cost and the expenses per day and category
+func (h *BudgetHandler) GetBudget(w http.ResponseWriter, r *http.Request) {
+	itineraryID, err := strconv.Atoi(r.URL.Query().Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleViewer) {
+		return
+	}
+	currency, ok := h.summaryCurrency(w, r)
+	if !ok {
+		return
+	}
+	summary, err := h.budgets.Summary(r.Context(), itineraryID, currency)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Itinerary with that id does not exist", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to summarise budget", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    summary,
+	})
+}
+
+// SetBudget handles PUT /itinerary/budget?itinerary_id=, the currency defaults to the display currency
+func (h *BudgetHandler) SetBudget(w http.ResponseWriter, r *http.Request) {
+	itineraryID, err := strconv.Atoi(r.URL.Query().Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleEditor) {
+		return
+	}
+	var req BudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	fallback, ok := h.summaryCurrency(w, r)
+	if !ok {
+		return
+	}
+	if req.Currency == "" {
+		req.Currency = fallback
+	}
+	currency, units, err := priceCurrency(req.Currency, "", h.exchange.MinorUnits)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	budget := models.ItineraryBudget{ItineraryID: itineraryID, Currency: currency}
+	if req.Amount != nil {
+		if *req.Amount < 0 || math.IsNaN(*req.Amount) || math.IsInf(*req.Amount, 0) {
+			http.Error(w, "amount must not be negative", http.StatusBadRequest)
+			return
+		}
+		amount := money.ToMinor(*req.Amount, units)
+		budget.AmountMinor = &amount
+	}
+	if err := h.expenseRepo.SetBudget(r.Context(), budget); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Itinerary with that id does not exist", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to set budget", http.StatusInternalServerError)
+		return
+	}
+
+	summary, err := h.budgets.Summary(database.WithPrimary(r.Context()), itineraryID, currency)
+	if err != nil {
+		http.Error(w, "Failed to summarise budget", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    summary,
+	})
+}
+
+// GetExpenses handles GET /itinerary/expenses?itinerary_id=&currency=
+func (h *BudgetHandler) GetExpenses(w http.ResponseWriter, r *http.Request) {
+	itineraryID, err := strconv.Atoi(r.URL.Query().Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleViewer) {
+		return
+	}
+	currency, ok := h.prices.displayCurrency(w, r)
+	if !ok {
+		return
+	}
+	expenses, err := h.expenseRepo.GetExpensesByItinerary(r.Context(), itineraryID)
+	if err != nil {
+		writeExpenseError(w, err, "fetch expenses")
+		return
+	}
+	for i := range expenses {
+		h.prices.convertExpense(currency, &expenses[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"expenses": expenses,
+	})
+}
+
+// CreateExpense handles POST /itinerary/expenses?itinerary_id=
+func (h *BudgetHandler) CreateExpense(w http.ResponseWriter, r *http.Request) {
+	itineraryID, err := strconv.Atoi(r.URL.Query().Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleEditor) {
+		return
+	}
+	var req ExpenseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	currency, ok := h.summaryCurrency(w, r)
+	if !ok {
+		return
+	}
+	expense, err := h.toExpense(r, itineraryID, req)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Itinerary with that id does not exist", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.expenseRepo.CreateExpense(r.Context(), &expense); err != nil {
+		writeExpenseError(w, err, "add expense")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    expense,
+		"alerts":  h.budgetAlerts(r.Context(), itineraryID, currency),
+	})
+}
+
+// UpdateExpense handles PUT /itinerary/expenses?expense_id=
+func (h *BudgetHandler) UpdateExpense(w http.ResponseWriter, r *http.Request) {
+	expenseID, err := strconv.Atoi(r.URL.Query().Get("expense_id"))
+	if err != nil {
+		http.Error(w, "Invalid expense id", http.StatusBadRequest)
+		return
+	}
+	var req ExpenseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	currency, ok := h.summaryCurrency(w, r)
+	if !ok {
+		return
+	}
+	current, err := h.expenseRepo.GetExpenseById(database.WithPrimary(r.Context()), expenseID)
+	if err != nil {
+		writeExpenseError(w, err, "update expense")
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, current.ItineraryID, models.RoleEditor) {
+		return
+	}
+	expense, err := h.toExpense(r, current.ItineraryID, req)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Itinerary with that id does not exist", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	expense.ID = expenseID
+
+	if err := h.expenseRepo.UpdateExpense(r.Context(), &expense); err != nil {
+		writeExpenseError(w, err, "update expense")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    expense,
+		"alerts":  h.budgetAlerts(r.Context(), expense.ItineraryID, currency),
+	})
+}
+
+// DeleteExpense handles DELETE /itinerary/expenses?expense_id=
+func (h *BudgetHandler) DeleteExpense(w http.ResponseWriter, r *http.Request) {
+	expenseID, err := strconv.Atoi(r.URL.Query().Get("expense_id"))
+	if err != nil {
+		http.Error(w, "Invalid expense id", http.StatusBadRequest)
+		return
+	}
+	currency, ok := h.summaryCurrency(w, r)
+	if !ok {
+		return
+	}
+	current, err := h.expenseRepo.GetExpenseById(r.Context(), expenseID)
+	if err != nil {
+		writeExpenseError(w, err, "delete expense")
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, current.ItineraryID, models.RoleEditor) {
+		return
+	}
+	itineraryID, err := h.expenseRepo.DeleteExpense(r.Context(), expenseID)
+	if err != nil {
+		writeExpenseError(w, err, "delete expense")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Expense deleted",
+		"alerts":  h.budgetAlerts(r.Context(), itineraryID, currency),
+	})
+}
+
+// GetBalances handles GET /itinerary/balances?itinerary_id=&currency= with what each member paid and
+// owes and who pays whom to settle up
+func (h *BudgetHandler) GetBalances(w http.ResponseWriter, r *http.Request) {
+	itineraryID, err := strconv.Atoi(r.URL.Query().Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleViewer) {
+		return
+	}
+	currency, ok := h.summaryCurrency(w, r)
+	if !ok {
+		return
+	}
+	balances, err := h.budgets.Balances(r.Context(), itineraryID, currency)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Itinerary with that id does not exist", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to work out balances", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    balances,
+	})
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+const (
+	maxTagLength      = 50
+	maxTagsPerRequest = 20
+)
+
+type CategoryHandler struct {
+	categoryRepo *repository.CategoryRepository
+	activityRepo *repository.ActivityRepository
+	gorseService *service.GorseService
+}
+
+type CategoryRequest struct {
+	Slug   string `json:"slug"`
+	Name   string `json:"name"`
+	Parent string `json:"parent"`
+}
+
+func NewCategoryHandler(categoryRepo *repository.CategoryRepository, activityRepo *repository.ActivityRepository, gorseService *service.GorseService) *CategoryHandler {
+	return &CategoryHandler{categoryRepo: categoryRepo, activityRepo: activityRepo, gorseService: gorseService}
+}
+
+// normalizeCategories lower cases and de-duplicates category slugs, rejecting malformed ones
+func normalizeCategories(slugs []string) ([]string, error) {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, slug := range slugs {
+		slug = strings.ToLower(strings.TrimSpace(slug))
+		if !slugPattern.MatchString(slug) {
+			return nil, errors.New("invalid category " + strconv.Quote(slug))
+		}
+		if !seen[slug] {
+			seen[slug] = true
+			normalized = append(normalized, slug)
+		}
+	}
+	return normalized, nil
+}
+
+// normalizeTags lower cases, trims and de-duplicates free-form tags
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+		if tag == "" {
+			continue
+		}
+		if len(tag) > maxTagLength {
+			return nil, errors.New("tags must be at most 50 characters")
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > maxTagsPerRequest {
+		return nil, errors.New("too many tags")
+	}
+	return normalized, nil
+}
+
+// parseTaxonomyFilter reads ?category= and ?tag= from a listing request. Tags can be repeated
+// or comma separated, an activity must carry all of them to match.
+func parseTaxonomyFilter(q url.Values) (models.ActivityTaxonomyFilter, error) {
+	var filter models.ActivityTaxonomyFilter
+	if category := q.Get("category"); category != "" {
+		categories, err := normalizeCategories([]string{category})
+		if err != nil {
+			return filter, err
+		}
+		filter.Category = categories[0]
+	}
+
+	var tags []string
+	for _, v := range q["tag"] {
+		tags = append(tags, strings.Split(v, ",")...)
+	}
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return filter, err
+	}
+	if len(tags) > 0 {
+		filter.Tags = tags
+	}
+	return filter, nil
+}
+
+// syncGorseItems pushes the activities' categories and tags to gorse in the background so
+// recommendations can be scoped by category. Failures are only logged, the next change retries.
+func syncGorseItems(activityRepo repository.ActivityRepositoryInterface, gorseService *service.GorseService, activityIDs ...int) {
+	if len(activityIDs) == 0 {
+		return
+	}
+	go func() {
+		// read from the primary, the change being synced was only just committed
+		ctx := database.WithPrimary(context.Background())
+		for _, id := range activityIDs {
+			activity, err := activityRepo.GetActivityById(ctx, id)
+			if err != nil {
+				log.Printf("warning: failed to load activity %d for gorse: %v", id, err)
+				continue
+			}
+			categories, err := activityRepo.GetCategoryLineage(ctx, id)
+			if err != nil {
+				log.Printf("warning: failed to load categories of activity %d for gorse: %v", id, err)
+				continue
+			}
+			item := models.GorseItem{
+				ItemId:     strconv.Itoa(activity.ID),
+				Categories: categories,
+				Labels:     activity.Tags,
+				Comment:    activity.Title,
+			}
+			if err := gorseService.UpsertItem(item); err != nil {
+				log.Printf("warning: failed to push activity %d to gorse: %v", id, err)
+			}
+		}
+	}()
+}
+
+// GetCategories returns the whole taxonomy as a tree
+func (h *CategoryHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.categoryRepo.GetCategoryTree(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get categories", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"categories": categories,
+	})
+}
+
+func decodeCategoryRequest(r *http.Request) (CategoryRequest, error) {
+	var req CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, errors.New("Invalid JSON")
+	}
+	req.Slug = strings.ToLower(strings.TrimSpace(req.Slug))
+	req.Name = strings.TrimSpace(req.Name)
+	req.Parent = strings.ToLower(strings.TrimSpace(req.Parent))
+	if !slugPattern.MatchString(req.Slug) {
+		return req, errors.New("slug must be lower case words separated by hyphens")
+	}
+	if req.Name == "" {
+		return req, errors.New("name is required")
+	}
+	return req, nil
+}
+
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeCategoryRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	category := models.Category{Slug: req.Slug, Name: req.Name}
+	if err := h.categoryRepo.CreateCategory(r.Context(), &category, req.Parent); err != nil {
+		if errors.Is(err, repository.ErrUnknownCategory) {
+			http.Error(w, "Parent category does not exist", http.StatusBadRequest)
+			return
+		}
+		if isPQError(err, pqUniqueViolation) {
+			http.Error(w, "A category with that slug already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create category", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
+
+// UpdateCategory renames or moves the category given by the slug in the body. Moving it changes
+// the ancestors of every activity below it, so those are pushed to gorse again.
+func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeCategoryRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	category := models.Category{Slug: req.Slug, Name: req.Name}
+	if err := h.categoryRepo.UpdateCategory(r.Context(), &category, req.Parent); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Category with that slug does not exist", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, repository.ErrUnknownCategory) {
+			http.Error(w, "Parent category does not exist", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, repository.ErrCategoryCycle) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to update category", http.StatusInternalServerError)
+		return
+	}
+
+	ids, err := h.categoryRepo.GetActivityIdsInCategory(database.WithPrimary(r.Context()), category.Slug)
+	if err != nil {
+		log.Printf("warning: failed to list activities in category %s: %v", category.Slug, err)
+	}
+	syncGorseItems(h.activityRepo, h.gorseService, ids...)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(category)
+}
+
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	slug := r.URL.Query().Get("slug")
+	if err := h.categoryRepo.DeleteCategory(r.Context(), slug); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Category with that slug does not exist", http.StatusNotFound)
+			return
+		}
+		if isPQError(err, pqForeignKeyViolation) {
+			http.Error(w, "Category still has subcategories or activities", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to delete category", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Category deleted",
+	})
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+	"github.com/lib/pq"
+)
+
+type CountryHandler struct {
+	countryRepo    repository.CountryRepositoryInterface
+	activityRepo   *repository.ActivityRepository
+	suggestService *service.SuggestService
+	prices         *PriceConverter
+}
+
+func NewCountryHandler(countryRepo repository.CountryRepositoryInterface, activityRepo *repository.ActivityRepository, suggestService *service.SuggestService, prices *PriceConverter) *CountryHandler {
+	return &CountryHandler{countryRepo: countryRepo, activityRepo: activityRepo, suggestService: suggestService, prices: prices}
+}
+
+// isPQError reports whether err is a postgres error with the given SQLSTATE code
+func isPQError(err error, code string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && string(pqErr.Code) == code
+}
+
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+)
+
+func (h *CountryHandler) CreateCountry(w http.ResponseWriter, r *http.Request) {
+	var country models.Country
+	if err := json.NewDecoder(r.Body).Decode(&country); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := service.ValidateCountry(&country); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.countryRepo.CreateCountry(r.Context(), &country); err != nil {
+		if isPQError(err, pqUniqueViolation) {
+			http.Error(w, "A country with that name or iso_code already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create country", http.StatusInternalServerError)
+		return
+	}
+	h.suggestService.RebuildAsync()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(country)
+}
+
+// GetCountries returns a single country for ?id= or ?code=, otherwise the whole catalogue
+func (h *CountryHandler) GetCountries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Has("id") || q.Has("code") {
+		country, ok := h.lookupCountry(w, r)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(country)
+		return
+	}
+
+	countries, err := h.countryRepo.GetAllCountries(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get countries", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"countries": countries,
+	})
+}
+
+func (h *CountryHandler) UpdateCountry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+		return
+	}
+	var country models.Country
+	if err := json.NewDecoder(r.Body).Decode(&country); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	country.ID = id
+	if err := service.ValidateCountry(&country); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.countryRepo.UpdateCountry(r.Context(), &country); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Country with that id does not exist", http.StatusNotFound)
+			return
+		}
+		if isPQError(err, pqUniqueViolation) {
+			http.Error(w, "A country with that name or iso_code already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to update country", http.StatusInternalServerError)
+		return
+	}
+	h.suggestService.RebuildAsync()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(country)
+}
+
+func (h *CountryHandler) DeleteCountry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.countryRepo.DeleteCountry(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Country with that id does not exist", http.StatusNotFound)
+			return
+		}
+		if isPQError(err, pqForeignKeyViolation) {
+			http.Error(w, "Country still has activities", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to delete country", http.StatusInternalServerError)
+		return
+	}
+	h.suggestService.RebuildAsync()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Country deleted",
+	})
+}
+
+// GetCountryActivities lists the activities in the country given by ?id= or ?code=, optionally
+// narrowed by ?category= and ?tag=, with prices converted to ?currency=
+func (h *CountryHandler) GetCountryActivities(w http.ResponseWriter, r *http.Request) {
+	taxonomy, err := parseTaxonomyFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	currency, ok := h.prices.displayCurrency(w, r)
+	if !ok {
+		return
+	}
+	country, ok := h.lookupCountry(w, r)
+	if !ok {
+		return
+	}
+
+	activities, err := h.activityRepo.GetActivitiesByCountry(r.Context(), country.ID, taxonomy)
+	if err != nil {
+		http.Error(w, "Failed to fetch activities", http.StatusInternalServerError)
+		return
+	}
+	for i := range activities {
+		h.prices.convert(currency, &activities[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"country":    country,
+		"activities": activities,
+	})
+}
+
+// lookupCountry resolves ?id= or ?code= and writes the error response itself when it fails
+func (h *CountryHandler) lookupCountry(w http.ResponseWriter, r *http.Request) (*models.Country, bool) {
+	q := r.URL.Query()
+	var country *models.Country
+	var err error
+	if code := q.Get("code"); code != "" {
+		country, err = h.countryRepo.GetCountryByCode(r.Context(), strings.ToUpper(code))
+	} else {
+		id, convErr := strconv.Atoi(q.Get("id"))
+		if convErr != nil {
+			http.Error(w, "Invalid id parameter", http.StatusBadRequest)
+			return nil, false
+		}
+		country, err = h.countryRepo.GetCountryById(r.Context(), id)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Country not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch country", http.StatusInternalServerError)
+		return nil, false
+	}
+	return country, true
+}
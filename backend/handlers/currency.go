@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/money"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+// prices without a currency, and activities whose country has none, are taken to be in dollars
+const defaultCurrency = "USD"
+
+// priceCurrency picks the currency a new price is given in: the requested one, else the
+// country's when it is supported, else defaultCurrency. It also returns its minor units.
+func priceCurrency(requested, countryCurrency string, minorUnits func(string) (int, error)) (string, int, error) {
+	code := strings.ToUpper(strings.TrimSpace(requested))
+	if code == "" {
+		code = defaultCurrency
+		if _, err := minorUnits(countryCurrency); countryCurrency != "" && err == nil {
+			code = countryCurrency
+		}
+	}
+	units, err := minorUnits(code)
+	if err != nil {
+		return "", 0, fmt.Errorf("unsupported currency %q", code)
+	}
+	return code, units, nil
+}
+
+// PriceConverter shows activity prices in the currency a request asks for
+type PriceConverter struct {
+	exchange *service.ExchangeService
+	userRepo *repository.UserRepository
+}
+
+func NewPriceConverter(exchange *service.ExchangeService, userRepo *repository.UserRepository) *PriceConverter {
+	return &PriceConverter{exchange: exchange, userRepo: userRepo}
+}
+
+// displayCurrency is ?currency= when given, else the signed in user's preferred currency, else
+// empty meaning prices are left as stored. It writes a 400 itself for an unsupported currency.
+func (c *PriceConverter) displayCurrency(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if c == nil {
+		return "", true
+	}
+	if code := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("currency"))); code != "" {
+		if !c.exchange.Supported(code) {
+			http.Error(w, "Unsupported currency", http.StatusBadRequest)
+			return "", false
+		}
+		return code, true
+	}
+	actor := auth.ActorFromContext(r.Context())
+	if actor == auth.AnonymousActor || actor == auth.SystemActor {
+		return "", true
+	}
+	// a failed lookup only means prices are not converted
+	if user, err := c.userRepo.GetUserByEmail(actor); err == nil {
+		return user.PreferredCurrency, true
+	}
+	return "", true
+}
+
+// convert fills in the activity's converted price, leaving it out when there is no rate for its currency
+func (c *PriceConverter) convert(currency string, activity *models.Activity) {
+	if c == nil || currency == "" || activity.Currency == "" {
+		return
+	}
+	if price, err := c.exchange.Convert(activity.PriceMinor, activity.Currency, currency); err == nil {
+		activity.ConvertedPrice = &price
+	}
+}
+
+// convertExpense fills in the expense's converted amount, leaving it out when there is no rate
+func (c *PriceConverter) convertExpense(currency string, expense *models.Expense) {
+	if c == nil || currency == "" {
+		return
+	}
+	if price, err := c.exchange.Convert(expense.AmountMinor, expense.Currency, currency); err == nil {
+		expense.ConvertedPrice = &price
+	}
+}
+
+// convertItinerary converts every activity and totals them when all could be converted
+func (c *PriceConverter) convertItinerary(currency string, itinerary *models.Itinerary) {
+	if c == nil || currency == "" {
+		return
+	}
+	units, err := c.exchange.MinorUnits(currency)
+	if err != nil {
+		return
+	}
+	total := models.ConvertedPrice{Currency: currency, RateDate: c.exchange.RatesDate()}
+	complete := true
+	for i := range itinerary.Activities {
+		c.convert(currency, &itinerary.Activities[i])
+		if price := itinerary.Activities[i].ConvertedPrice; price != nil {
+			total.AmountMinor += price.AmountMinor
+		} else {
+			complete = false
+		}
+	}
+	if complete {
+		total.Amount = money.ToMajor(total.AmountMinor, units)
+		itinerary.TotalPrice = &total
+	}
+}
+
+type CurrencyHandler struct {
+	exchange *service.ExchangeService
+	userRepo *repository.UserRepository
+}
+
+type PreferredCurrencyRequest struct {
+	Currency string `json:"currency"`
+}
+
+func NewCurrencyHandler(exchange *service.ExchangeService, userRepo *repository.UserRepository) *CurrencyHandler {
+	return &CurrencyHandler{exchange: exchange, userRepo: userRepo}
+}
+
+// GetCurrencies handles GET /currencies listing the supported currencies and the date of the rates in use
+func (h *CurrencyHandler) GetCurrencies(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"currencies": h.exchange.Currencies(),
+		"rate_date":  h.exchange.RatesDate(),
+	})
+}
+
+// SetPreferredCurrency handles PUT /users/currency, an empty currency clears the preference
+func (h *CurrencyHandler) SetPreferredCurrency(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r, h.userRepo)
+	if !ok {
+		return
+	}
+	var req PreferredCurrencyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	code := strings.ToUpper(strings.TrimSpace(req.Currency))
+	if code != "" && !h.exchange.Supported(code) {
+		http.Error(w, "Unsupported currency", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.userRepo.SetPreferredCurrency(r.Context(), user.ID, code); err != nil {
+		http.Error(w, "Failed to update preferred currency", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"currency": code,
+	})
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+// imageExtensions lists the content types clients may upload and the extension their objects get
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// sniffLength is how much of an upload is read back to check its real type
+const sniffLength = 512
+
+// ImageRecords is the image metadata the handler keeps, ImageRepository implements it
+type ImageRecords interface {
+	CreatePendingImage(ctx context.Context, image *models.Image) error
+	GetImageById(ctx context.Context, id int) (*models.Image, error)
+	GetImagesByOwner(ctx context.Context, ownerType string, ownerID int) ([]models.Image, error)
+	ConfirmImage(ctx context.Context, image *models.Image) error
+	DeleteImage(ctx context.Context, id int) (models.Image, error)
+	DiscardPendingImage(ctx context.Context, id int) error
+}
+
+type ImageHandler struct {
+	imageRepo      ImageRecords
+	blobStore      service.BlobStore
+	worker         *service.ImageWorker
+	uploadExpiry   time.Duration
+	maxUploadBytes int64
+	access         *service.ItineraryAccess
+	admins         map[string]bool
+}
+
+type UploadRequest struct {
+	OwnerType   string `json:"owner_type"`
+	OwnerID     int    `json:"owner_id"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+func NewImageHandler(imageRepo ImageRecords, blobStore service.BlobStore, worker *service.ImageWorker, uploadExpiry time.Duration, maxUploadBytes int64, access *service.ItineraryAccess, admins []string) *ImageHandler {
+	allowed := make(map[string]bool, len(admins))
+	for _, admin := range admins {
+		allowed[admin] = true
+	}
+	return &ImageHandler{imageRepo: imageRepo, blobStore: blobStore, worker: worker, uploadExpiry: uploadExpiry, maxUploadBytes: maxUploadBytes, access: mustHaveAccess(access), admins: allowed}
+}
+
+// newObjectKey names an upload owner_type/owner_id/<random hex><ext> so keys cannot be guessed or collide
+func newObjectKey(ownerType string, ownerID int, contentType string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return ownerType + "/" + strconv.Itoa(ownerID) + "/" + hex.EncodeToString(b) + imageExtensions[contentType], nil
+}
+
+// RequestUpload handles POST /images/uploads. It records a pending image and returns a presigned
+// PUT url the client uploads the file to directly, followed by POST /images/confirm. Activity
+// images are for admins, itinerary images for the itinerary's editors.
+func (h *ImageHandler) RequestUpload(w http.ResponseWriter, r *http.Request) {
+	var req UploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.ContentType = strings.ToLower(strings.TrimSpace(req.ContentType))
+	if req.OwnerType != models.ImageOwnerActivity && req.OwnerType != models.ImageOwnerItinerary {
+		http.Error(w, "owner_type must be activity or itinerary", http.StatusBadRequest)
+		return
+	}
+	if _, ok := imageExtensions[req.ContentType]; !ok {
+		http.Error(w, "content_type must be image/jpeg, image/png, image/webp or image/gif", http.StatusUnsupportedMediaType)
+		return
+	}
+	if req.Size <= 0 || req.Size > h.maxUploadBytes {
+		http.Error(w, "size must be between 1 and "+strconv.FormatInt(h.maxUploadBytes, 10)+" bytes", http.StatusRequestEntityTooLarge)
+		return
+	}
+	// activities are curated, like the activity routes themselves only admins add their images
+	if req.OwnerType == models.ImageOwnerActivity && !h.admins[auth.ActorFromContext(r.Context())] {
+		http.Error(w, "admin access required", http.StatusForbidden)
+		return
+	}
+	if req.OwnerType == models.ImageOwnerItinerary && !authorizeItinerary(w, r, h.access, req.OwnerID, models.RoleEditor) {
+		return
+	}
+
+	key, err := newObjectKey(req.OwnerType, req.OwnerID, req.ContentType)
+	if err != nil {
+		http.Error(w, "Failed to create upload", http.StatusInternalServerError)
+		return
+	}
+	image := models.Image{
+		ObjectKey:   key,
+		OwnerType:   req.OwnerType,
+		OwnerID:     req.OwnerID,
+		ContentType: req.ContentType,
+		SizeBytes:   req.Size,
+		UploadedBy:  auth.ActorFromContext(r.Context()),
+	}
+	if err := h.imageRepo.CreatePendingImage(r.Context(), &image); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, req.OwnerType+" with that id does not exist", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to create upload", http.StatusInternalServerError)
+		return
+	}
+
+	uploadURL, err := h.blobStore.PresignUpload(r.Context(), key, h.uploadExpiry)
+	if err != nil {
+		http.Error(w, "Failed to create upload url", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"image_id":   image.ID,
+		"upload_url": uploadURL,
+		"method":     http.MethodPut,
+		"headers":    map[string]string{"Content-Type": image.ContentType},
+		"expires_at": time.Now().Add(h.uploadExpiry).UTC(),
+	})
+}
+
+// verifyUpload checks the stored object against what the client declared when requesting the upload
+func (h *ImageHandler) verifyUpload(r *http.Request, image *models.Image) error {
+	info, err := h.blobStore.StatObject(r.Context(), image.ObjectKey)
+	if err != nil {
+		return err
+	}
+	if info.Size != image.SizeBytes {
+		return errors.New("uploaded file size does not match the declared size")
+	}
+	if !strings.EqualFold(info.ContentType, image.ContentType) {
+		return errors.New("uploaded file content type does not match the declared type")
+	}
+
+	head, err := h.blobStore.ReadHead(r.Context(), image.ObjectKey, sniffLength)
+	if err != nil {
+		return err
+	}
+	if sniffed := http.DetectContentType(head); sniffed != image.ContentType {
+		return errors.New("uploaded file is not a " + image.ContentType + " image")
+	}
+	return nil
+}
+
+// ConfirmUpload handles POST /images/confirm?image_id= once the client has uploaded the file.
+// A file that fails verification is deleted and the upload has to start over.
+func (h *ImageHandler) ConfirmUpload(w http.ResponseWriter, r *http.Request) {
+	imageID, err := strconv.Atoi(r.URL.Query().Get("image_id"))
+	if err != nil {
+		http.Error(w, "Invalid image id", http.StatusBadRequest)
+		return
+	}
+	image, err := h.imageRepo.GetImageById(r.Context(), imageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Image with that id does not exist", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to fetch image", http.StatusInternalServerError)
+		return
+	}
+	if image.UploadedBy != auth.ActorFromContext(r.Context()) {
+		http.Error(w, "Only the uploader can confirm an image", http.StatusForbidden)
+		return
+	}
+	if image.Status != models.ImagePending {
+		http.Error(w, "Image is already confirmed", http.StatusConflict)
+		return
+	}
+
+	if err := h.verifyUpload(r, image); err != nil {
+		if errors.Is(err, service.ErrObjectNotFound) {
+			http.Error(w, "File has not been uploaded yet", http.StatusConflict)
+			return
+		}
+		if err := h.blobStore.RemoveObject(r.Context(), image.ObjectKey); err != nil {
+			log.Printf("warning: failed to remove rejected upload %s: %v", image.ObjectKey, err)
+		}
+		if err := h.imageRepo.DiscardPendingImage(r.Context(), image.ID); err != nil {
+			log.Printf("warning: failed to discard rejected image %d: %v", image.ID, err)
+		}
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := h.imageRepo.ConfirmImage(r.Context(), image); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Image is already confirmed", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to confirm image", http.StatusInternalServerError)
+		return
+	}
+	// variants are rendered in the background and show up once processing finishes
+	h.worker.Notify()
+	image.URL = h.blobStore.GetObjectURL(r.Context(), image.ObjectKey)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"image":   image,
+	})
+}
+
+// GetImages handles GET /images?owner_type=&owner_id= returning presigned urls valid for a limited time
+func (h *ImageHandler) GetImages(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	ownerType := q.Get("owner_type")
+	if ownerType != models.ImageOwnerActivity && ownerType != models.ImageOwnerItinerary {
+		http.Error(w, "owner_type must be activity or itinerary", http.StatusBadRequest)
+		return
+	}
+	ownerID, err := strconv.Atoi(q.Get("owner_id"))
+	if err != nil {
+		http.Error(w, "Invalid owner id", http.StatusBadRequest)
+		return
+	}
+	if ownerType == models.ImageOwnerItinerary && !authorizeItinerary(w, r, h.access, ownerID, models.RoleViewer) {
+		return
+	}
+
+	images, err := h.imageRepo.GetImagesByOwner(r.Context(), ownerType, ownerID)
+	if err != nil {
+		http.Error(w, "Failed to fetch images", http.StatusInternalServerError)
+		return
+	}
+	for i := range images {
+		images[i].URL = h.blobStore.GetObjectURL(r.Context(), images[i].ObjectKey)
+		for j := range images[i].Variants {
+			images[i].Variants[j].URL = h.blobStore.GetObjectURL(r.Context(), images[i].Variants[j].ObjectKey)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"images":  images,
+	})
+}
+
+// DeleteImage handles DELETE /images?image_id=, only the uploader or an admin can delete an image
+func (h *ImageHandler) DeleteImage(w http.ResponseWriter, r *http.Request) {
+	imageID, err := strconv.Atoi(r.URL.Query().Get("image_id"))
+	if err != nil {
+		http.Error(w, "Invalid image id", http.StatusBadRequest)
+		return
+	}
+	image, err := h.imageRepo.GetImageById(r.Context(), imageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Image with that id does not exist", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to fetch image", http.StatusInternalServerError)
+		return
+	}
+	if actor := auth.ActorFromContext(r.Context()); image.UploadedBy != actor && !h.admins[actor] {
+		http.Error(w, "Only the uploader or an admin can delete an image", http.StatusForbidden)
+		return
+	}
+
+	deleted, err := h.imageRepo.DeleteImage(r.Context(), imageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Image with that id does not exist", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete image", http.StatusInternalServerError)
+		return
+	}
+	keys := []string{deleted.ObjectKey}
+	for _, v := range deleted.Variants {
+		keys = append(keys, v.ObjectKey)
+	}
+	for _, key := range keys {
+		if err := h.blobStore.RemoveObject(r.Context(), key); err != nil {
+			log.Printf("warning: failed to remove object %s: %v", key, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Image deleted",
+	})
+}
@@ -1,25 +1,38 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"github.com/Joshua-Pok/FYP-backend/database"
 	"github.com/Joshua-Pok/FYP-backend/models"
 	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type ItineraryHandler struct {
-	itineraryRepo repository.ItineraryRepository
+	itineraryRepo repository.ItineraryRepositoryInterface
+	prices        *PriceConverter
+	validator     *service.ScheduleValidator
+	access        *service.ItineraryAccess
+	userRepo      *repository.UserRepository
+	events        *service.ItineraryHub
 }
 
 type CreateItineraryRequest struct {
+	// the signed in user, who becomes the owner
 	UserID      int               `json:"user_id"`
 	Title       string            `json:"title"`
 	Description string            `json:"description"`
 	StartDate   string            `json:"start_date"`
 	EndDate     string            `json:"end_date"`
 	Activities  []models.Activity `json:"activities"`
+	// already scheduled items, as in a generated draft
+	Days []models.ItineraryDay `json:"days"`
 }
 
 type ModifyItineraryRequest struct {
@@ -29,10 +42,31 @@ type ModifyItineraryRequest struct {
 	StartDate   string            `json:"startDate"`
 	EndDate     string            `json:"endDate"`
 	Activities  []models.Activity `json:"activities"`
+	Version     int               `json:"version"`
 }
 
-func NewItineraryHandler(itineraryRepo repository.ItineraryRepository) *ItineraryHandler {
-	return &ItineraryHandler{itineraryRepo: itineraryRepo}
+func NewItineraryHandler(itineraryRepo repository.ItineraryRepositoryInterface, prices *PriceConverter, validator *service.ScheduleValidator, access *service.ItineraryAccess, userRepo *repository.UserRepository, events *service.ItineraryHub) *ItineraryHandler {
+	return &ItineraryHandler{itineraryRepo: itineraryRepo, prices: prices, validator: validator, access: mustHaveAccess(access), userRepo: userRepo, events: events}
+}
+
+// ownUserID checks ?user_id= names the signed in user, who is the default when it is missing
+func (h *ItineraryHandler) ownUserID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	user, ok := currentUser(w, r, h.userRepo)
+	if !ok {
+		return 0, false
+	}
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		userID, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid user id", http.StatusBadRequest)
+			return 0, false
+		}
+		if userID != user.ID {
+			http.Error(w, "You can only list your own itineraries", http.StatusForbidden)
+			return 0, false
+		}
+	}
+	return user.ID, true
 }
 
 func (h *ItineraryHandler) CreateItinerary(w http.ResponseWriter, r *http.Request) {
@@ -56,13 +90,48 @@ func (h *ItineraryHandler) CreateItinerary(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	itinerary, err := h.itineraryRepo.CreateItinerary(req.UserID, req.Title, req.Description, startDate, endDate, req.Activities)
+	var items []models.ItineraryItem
+	for _, day := range req.Days {
+		for _, item := range day.Items {
+			if item.Day == nil {
+				date := day.Date
+				item.Day = &date
+			}
+			scheduled, err := ItineraryItemRequest{ActivityID: item.ActivityID, Day: item.Day, StartTime: item.StartTime, EndTime: item.EndTime, Notes: item.Notes}.toItineraryItem()
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			items = append(items, scheduled)
+		}
+	}
+
+	user, ok := currentUser(w, r, h.userRepo)
+	if !ok {
+		return
+	}
+	if req.UserID != 0 && req.UserID != user.ID {
+		http.Error(w, "Itineraries can only be created for yourself", http.StatusForbidden)
+		return
+	}
+
+	itinerary, err := h.itineraryRepo.CreateItinerary(r.Context(), user.ID, req.Title, req.Description, startDate, endDate, req.Activities, items)
+	if errors.Is(err, repository.ErrOutsideTrip) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "scheduled items fall outside the trip dates"})
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create itinerary"})
 		return
 	}
 
+	if len(items) > 0 {
+		itinerary.Warnings = scheduleWarnings(r.Context(), h.validator, itinerary.Id)
+	}
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -71,14 +140,13 @@ func (h *ItineraryHandler) CreateItinerary(w http.ResponseWriter, r *http.Reques
 	})
 }
 
+// GetItinerariesByUser handles GET /itinerary?user_id= listing the itineraries the signed in user owns or shares
 func (h *ItineraryHandler) GetItinerariesByUser(w http.ResponseWriter, r *http.Request) {
-	userIDstr := r.URL.Query().Get("user_id")
-	userID, err := strconv.Atoi(userIDstr)
-	if err != nil {
-		http.Error(w, "Invalid user id", http.StatusBadRequest)
+	userID, ok := h.ownUserID(w, r)
+	if !ok {
 		return
 	}
-	itineraries, err := h.itineraryRepo.GetItinerariesByUser(userID)
+	itineraries, err := h.itineraryRepo.GetItinerariesByUser(r.Context(), userID)
 	if err != nil {
 		http.Error(w, "failed to get itineraries", http.StatusNotFound)
 		return
@@ -88,6 +156,33 @@ func (h *ItineraryHandler) GetItinerariesByUser(w http.ResponseWriter, r *http.R
 	json.NewEncoder(w).Encode(itineraries)
 }
 
+func (h *ItineraryHandler) GetItinerary(w http.ResponseWriter, r *http.Request) {
+	itineraryIDstr := r.URL.Query().Get("itinerary_id")
+	itineraryID, err := strconv.Atoi(itineraryIDstr)
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleViewer) {
+		return
+	}
+	currency, ok := h.prices.displayCurrency(w, r)
+	if !ok {
+		return
+	}
+	itinerary, err := h.itineraryRepo.GetItineraryById(r.Context(), itineraryID)
+	if err != nil {
+		http.Error(w, "Itinerary with that id does not exist", http.StatusNotFound)
+		return
+	}
+	h.prices.convertItinerary(currency, itinerary)
+	itinerary.Warnings = scheduleWarnings(r.Context(), h.validator, itineraryID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", versionETag(itinerary.Version))
+	json.NewEncoder(w).Encode(itinerary)
+}
+
 func (h *ItineraryHandler) ModifyItinerary(w http.ResponseWriter, r *http.Request) {
 	var req ModifyItineraryRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -98,8 +193,153 @@ func (h *ItineraryHandler) ModifyItinerary(w http.ResponseWriter, r *http.Reques
 	itineraryIDstr := r.URL.Query().Get("itinerary_id")
 	itineraryID, err := strconv.Atoi(itineraryIDstr)
 	if err != nil {
-		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	member, ok := itineraryMember(w, r, h.access, itineraryID, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	// If-Match takes precedence over the version in the body
+	version := req.Version
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		version, err = parseVersionETag(ifMatch)
+		if err != nil {
+			http.Error(w, "Invalid If-Match header", http.StatusBadRequest)
+			return
+		}
+	}
+	if version == 0 {
+		http.Error(w, "Missing If-Match header or version", http.StatusPreconditionRequired)
 		return
 	}
-	itinerary, err := h.itineraryRepo.ModifyItinerary(itineraryID, req.Title, req.Description, req.StartDate, req.EndDate)
+
+	w.Header().Set("Content-Type", "application/json")
+	itinerary, err := h.itineraryRepo.ModifyItinerary(r.Context(), itineraryID, version, req.Title, req.Description, req.StartDate, req.EndDate, req.Activities)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		// the conflicting write may not have reached the replicas yet
+		current, err := h.itineraryRepo.GetItineraryById(database.WithPrimary(r.Context()), itineraryID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "failed to load current itinerary"})
+			return
+		}
+		w.Header().Set("ETag", versionETag(current.Version))
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   "itinerary was modified by someone else",
+			"current": current,
+		})
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "itinerary not found"})
+		return
+	}
+	if errors.Is(err, repository.ErrOutsideTrip) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "scheduled items fall outside the new trip dates"})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to modify itinerary"})
+		return
+	}
+
+	h.events.Publish(r.Context(), models.ItineraryEvent{
+		Type:        models.EventItineraryUpdated,
+		ItineraryID: itineraryID,
+		UserID:      member.UserID,
+		Username:    member.Username,
+		Version:     itinerary.Version,
+	})
+	itinerary.Warnings = scheduleWarnings(r.Context(), h.validator, itineraryID)
+	w.Header().Set("ETag", versionETag(itinerary.Version))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Itinerary updated successfully",
+		"data":    itinerary,
+	})
+}
+
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseVersionETag accepts both strong ("3") and weak (W/"3") etags as well as a bare number
+func parseVersionETag(etag string) (int, error) {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	return strconv.Atoi(strings.Trim(etag, `"`))
+}
+
+func (h *ItineraryHandler) DeleteItinerary(w http.ResponseWriter, r *http.Request) {
+	itineraryID, err := strconv.Atoi(r.URL.Query().Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleOwner) {
+		return
+	}
+	if err := h.itineraryRepo.DeleteItinerary(r.Context(), itineraryID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Itinerary with that id does not exist", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete itinerary", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Itinerary moved to trash",
+	})
+}
+
+func (h *ItineraryHandler) RestoreItinerary(w http.ResponseWriter, r *http.Request) {
+	itineraryID, err := strconv.Atoi(r.URL.Query().Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleOwner) {
+		return
+	}
+	if err := h.itineraryRepo.RestoreItinerary(r.Context(), itineraryID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "No deleted itinerary with that id", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to restore itinerary", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Itinerary restored",
+	})
+}
+
+func (h *ItineraryHandler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.ownUserID(w, r)
+	if !ok {
+		return
+	}
+	itineraries, err := h.itineraryRepo.GetDeletedItinerariesByUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "failed to get trash", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"user_id":     userID,
+		"itineraries": itineraries,
+	})
 }
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+type ItineraryEventsHandler struct {
+	hub    *service.ItineraryHub
+	access *service.ItineraryAccess
+	// how often an idle stream gets a comment so proxies keep it open, membership is checked again then
+	heartbeat time.Duration
+}
+
+func NewItineraryEventsHandler(hub *service.ItineraryHub, access *service.ItineraryAccess, heartbeat time.Duration) *ItineraryEventsHandler {
+	return &ItineraryEventsHandler{hub: hub, access: mustHaveAccess(access), heartbeat: heartbeat}
+}
+
+// StreamEvents handles GET /itinerary/events?itinerary_id= as a server-sent event stream of item
+// changes and presence for the itinerary. Events are not replayed, so clients reload the itinerary
+// whenever they (re)connect.
+func (h *ItineraryEventsHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	itineraryID, err := strconv.Atoi(r.URL.Query().Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	member, ok := itineraryMember(w, r, h.access, itineraryID, models.RoleViewer)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, leave, err := h.hub.Join(itineraryID, member)
+	if err != nil {
+		http.Error(w, "Failed to join itinerary", http.StatusInternalServerError)
+		return
+	}
+	defer leave()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				// fell too far behind, the client reconnects and catches up
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("warning: failed to encode %s event: %v", event.Type, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			// stop streaming to someone removed from the itinerary since they connected
+			if _, err := h.access.Require(r.Context(), itineraryID, models.RoleViewer); err != nil {
+				return
+			}
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
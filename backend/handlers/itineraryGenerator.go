@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/money"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/schedule"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+// longest trip a draft is generated for
+const maxGeneratedDays = 30
+
+type ItineraryGeneratorHandler struct {
+	generator   *service.ItineraryGenerator
+	exchange    *service.ExchangeService
+	userRepo    *repository.UserRepository
+	countryRepo *repository.CountryRepository
+}
+
+type GenerateItineraryRequest struct {
+	CountryID int    `json:"country_id"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	// in major units of currency, zero for no limit
+	Budget float64 `json:"budget"`
+	// defaults to the user's preferred currency, then the country's
+	Currency string `json:"currency"`
+	// relaxed, moderate or packed
+	Pace     string `json:"pace"`
+	Category string `json:"category"`
+	Title    string `json:"title"`
+}
+
+func NewItineraryGeneratorHandler(generator *service.ItineraryGenerator, exchange *service.ExchangeService, userRepo *repository.UserRepository, countryRepo *repository.CountryRepository) *ItineraryGeneratorHandler {
+	return &ItineraryGeneratorHandler{generator: generator, exchange: exchange, userRepo: userRepo, countryRepo: countryRepo}
+}
+
+// GenerateItinerary handles POST /itineraries/generate, planning a draft from the user's
+// recommendations. Nothing is saved, the draft can be posted to /itinerary as is.
+func (h *ItineraryGeneratorHandler) GenerateItinerary(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r, h.userRepo)
+	if !ok {
+		return
+	}
+	var req GenerateItineraryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	start, err := time.Parse(schedule.DateLayout, req.StartDate)
+	if err != nil {
+		http.Error(w, "start_date must be a YYYY-MM-DD date", http.StatusBadRequest)
+		return
+	}
+	end, err := time.Parse(schedule.DateLayout, req.EndDate)
+	if err != nil {
+		http.Error(w, "end_date must be a YYYY-MM-DD date", http.StatusBadRequest)
+		return
+	}
+	if end.Before(start) {
+		http.Error(w, "end_date must not be before start_date", http.StatusBadRequest)
+		return
+	}
+	if end.Sub(start) >= maxGeneratedDays*24*time.Hour {
+		http.Error(w, "trips can be generated for at most 30 days", http.StatusBadRequest)
+		return
+	}
+	pace := schedule.Pace(strings.ToLower(strings.TrimSpace(req.Pace)))
+	if pace == "" {
+		pace = schedule.PaceModerate
+	}
+	if !schedule.ValidPace(pace) {
+		http.Error(w, "pace must be relaxed, moderate or packed", http.StatusBadRequest)
+		return
+	}
+	if req.Budget < 0 || math.IsNaN(req.Budget) || math.IsInf(req.Budget, 0) {
+		http.Error(w, "budget must not be negative", http.StatusBadRequest)
+		return
+	}
+	category, err := parseTaxonomyFilter(url.Values{"category": {req.Category}})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	country, err := h.countryRepo.GetCountryById(r.Context(), req.CountryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Country with that id does not exist", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch country", http.StatusInternalServerError)
+		return
+	}
+	requested := req.Currency
+	if requested == "" {
+		requested = user.PreferredCurrency
+	}
+	currency, units, err := priceCurrency(requested, country.Currency, h.exchange.MinorUnits)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	draft, err := h.generator.Generate(r.Context(), service.GenerateOptions{
+		UserID:    strconv.Itoa(user.ID),
+		CountryID: country.ID,
+		StartDate: start,
+		EndDate:   end,
+		Pace:      pace,
+		Category:  category.Category,
+		Budget:    money.ToMinor(req.Budget, units),
+		Currency:  currency,
+	})
+	if errors.Is(err, service.ErrNoCandidates) {
+		http.Error(w, "No activities found for that destination", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to generate itinerary", http.StatusInternalServerError)
+		return
+	}
+	draft.Title = strings.TrimSpace(req.Title)
+	if draft.Title == "" {
+		draft.Title = "Trip to " + country.Name
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    draft,
+	})
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+const maxItemNotesLength = 2000
+
+type ItineraryItemRequest struct {
+	ActivityID int `json:"activity_id"`
+	// null leaves the item unscheduled
+	Day       *string `json:"day"`
+	StartTime string  `json:"start_time"`
+	EndTime   string  `json:"end_time"`
+	Notes     string  `json:"notes"`
+}
+
+type ReorderItemsRequest struct {
+	// null reorders the unscheduled items
+	Day     *string `json:"day"`
+	ItemIDs []int   `json:"item_ids"`
+}
+
+// validateDay checks a YYYY-MM-DD day, nil is allowed
+func validateDay(day *string) error {
+	if day == nil {
+		return nil
+	}
+	if _, err := time.Parse("2006-01-02", *day); err != nil {
+		return errors.New("day must be a YYYY-MM-DD date")
+	}
+	return nil
+}
+
+// toItineraryItem checks the request and turns it into an item, times need a day and must not run backwards
+func (req ItineraryItemRequest) toItineraryItem() (models.ItineraryItem, error) {
+	item := models.ItineraryItem{
+		ActivityID: req.ActivityID,
+		Day:        req.Day,
+		StartTime:  strings.TrimSpace(req.StartTime),
+		EndTime:    strings.TrimSpace(req.EndTime),
+		Notes:      strings.TrimSpace(req.Notes),
+	}
+	if err := validateDay(item.Day); err != nil {
+		return item, err
+	}
+	var start, end time.Time
+	var err error
+	if item.StartTime != "" {
+		if start, err = time.Parse("15:04", item.StartTime); err != nil {
+			return item, errors.New("start_time must be HH:MM")
+		}
+	}
+	if item.EndTime != "" {
+		if end, err = time.Parse("15:04", item.EndTime); err != nil {
+			return item, errors.New("end_time must be HH:MM")
+		}
+	}
+	if item.Day == nil && (item.StartTime != "" || item.EndTime != "") {
+		return item, errors.New("times can only be set on scheduled items")
+	}
+	if item.StartTime != "" && item.EndTime != "" && !end.After(start) {
+		return item, errors.New("end_time must be after start_time")
+	}
+	if len(item.Notes) > maxItemNotesLength {
+		return item, errors.New("notes must be at most 2000 characters")
+	}
+	return item, nil
+}
+
+// scheduleWarnings checks the itinerary on the primary so just written changes are seen. Warnings are
+// advisory, so a failed check is logged and the response goes out without them.
+func scheduleWarnings(ctx context.Context, validator *service.ScheduleValidator, itineraryID int) []models.ScheduleWarning {
+	if validator == nil {
+		return nil
+	}
+	warnings, err := validator.Validate(database.WithPrimary(ctx), itineraryID)
+	if err != nil {
+		log.Printf("failed to check itinerary %d: %v", itineraryID, err)
+		return nil
+	}
+	return warnings
+}
+
+// writeItemError maps the repository errors shared by the item endpoints
+func writeItemError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Itinerary, item or activity not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrOutsideTrip), errors.Is(err, repository.ErrInvalidOrder):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to "+action, http.StatusInternalServerError)
+	}
+}
+
+// authorizeItem checks the signed in user can edit the itinerary an item is in
+func (h *ItineraryHandler) authorizeItem(w http.ResponseWriter, r *http.Request, itemID int) (models.ItineraryMember, bool) {
+	if h.access == nil {
+		writeNoAccess(w)
+		return models.ItineraryMember{}, false
+	}
+	itineraryID, err := h.itineraryRepo.GetItineraryIdByItem(r.Context(), itemID)
+	if err != nil {
+		writeItemError(w, err, "fetch item")
+		return models.ItineraryMember{}, false
+	}
+	return itineraryMember(w, r, h.access, itineraryID, models.RoleEditor)
+}
+
+// itemEvent is an item change made by member, ready to publish
+func itemEvent(eventType string, itineraryID int, member models.ItineraryMember) models.ItineraryEvent {
+	return models.ItineraryEvent{Type: eventType, ItineraryID: itineraryID, UserID: member.UserID, Username: member.Username}
+}
+
+// AddItineraryItem handles POST /itinerary/items?itinerary_id= adding an activity at the end of its day
+func (h *ItineraryHandler) AddItineraryItem(w http.ResponseWriter, r *http.Request) {
+	itineraryID, err := strconv.Atoi(r.URL.Query().Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	var req ItineraryItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	item, err := req.toItineraryItem()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if item.ActivityID <= 0 {
+		http.Error(w, "activity_id is required", http.StatusBadRequest)
+		return
+	}
+	member, ok := itineraryMember(w, r, h.access, itineraryID, models.RoleEditor)
+	if !ok {
+		return
+	}
+	item.ItineraryID = itineraryID
+
+	added, err := h.itineraryRepo.AddItineraryItem(r.Context(), item)
+	if err != nil {
+		writeItemError(w, err, "add item")
+		return
+	}
+	event := itemEvent(models.EventItemAdded, itineraryID, member)
+	event.Item = &added
+	h.events.Publish(r.Context(), event)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"data":     added,
+		"warnings": scheduleWarnings(r.Context(), h.validator, itineraryID),
+	})
+}
+
+// UpdateItineraryItem handles PUT /itinerary/items?item_id= setting the day, times and notes.
+// Moving an item to another day puts it at the end of that day.
+func (h *ItineraryHandler) UpdateItineraryItem(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(r.URL.Query().Get("item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item id", http.StatusBadRequest)
+		return
+	}
+	var req ItineraryItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	item, err := req.toItineraryItem()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	member, ok := h.authorizeItem(w, r, itemID)
+	if !ok {
+		return
+	}
+	item.ID = itemID
+
+	updated, err := h.itineraryRepo.UpdateItineraryItem(r.Context(), item)
+	if err != nil {
+		writeItemError(w, err, "update item")
+		return
+	}
+	event := itemEvent(models.EventItemUpdated, updated.ItineraryID, member)
+	event.Item = &updated
+	h.events.Publish(r.Context(), event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"data":     updated,
+		"warnings": scheduleWarnings(r.Context(), h.validator, updated.ItineraryID),
+	})
+}
+
+// ReorderItineraryItems handles PUT /itinerary/items/order?itinerary_id= with every item id of one day in the new order
+func (h *ItineraryHandler) ReorderItineraryItems(w http.ResponseWriter, r *http.Request) {
+	itineraryID, err := strconv.Atoi(r.URL.Query().Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	var req ReorderItemsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := validateDay(req.Day); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	member, ok := itineraryMember(w, r, h.access, itineraryID, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	if err := h.itineraryRepo.ReorderItineraryItems(r.Context(), itineraryID, req.Day, req.ItemIDs); err != nil {
+		writeItemError(w, err, "reorder items")
+		return
+	}
+	event := itemEvent(models.EventItemsReordered, itineraryID, member)
+	event.Day = req.Day
+	event.ItemIDs = req.ItemIDs
+	h.events.Publish(r.Context(), event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  "Items reordered",
+		"warnings": scheduleWarnings(r.Context(), h.validator, itineraryID),
+	})
+}
+
+// RemoveItineraryItem handles DELETE /itinerary/items?item_id=
+func (h *ItineraryHandler) RemoveItineraryItem(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.Atoi(r.URL.Query().Get("item_id"))
+	if err != nil {
+		http.Error(w, "Invalid item id", http.StatusBadRequest)
+		return
+	}
+	member, ok := h.authorizeItem(w, r, itemID)
+	if !ok {
+		return
+	}
+	itineraryID, err := h.itineraryRepo.RemoveItineraryItem(r.Context(), itemID)
+	if err != nil {
+		writeItemError(w, err, "remove item")
+		return
+	}
+	event := itemEvent(models.EventItemRemoved, itineraryID, member)
+	event.ItemID = itemID
+	h.events.Publish(r.Context(), event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"message":  "Item removed",
+		"warnings": scheduleWarnings(r.Context(), h.validator, itineraryID),
+	})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Joshua-Pok/FYP-backend/geo"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+type RouteHandler struct {
+	optimiser *service.RouteOptimiser
+	access    *service.ItineraryAccess
+}
+
+func NewRouteHandler(optimiser *service.RouteOptimiser, access *service.ItineraryAccess) *RouteHandler {
+	return &RouteHandler{optimiser: optimiser, access: mustHaveAccess(access)}
+}
+
+// OptimiseRoute handles GET /itinerary/route?itinerary_id=&day=&lat=&lng= proposing the shortest
+// order for a day's items, starting from lat and lng when given. Nothing is changed, the proposal
+// is accepted with PUT /itinerary/items/order.
+func (h *RouteHandler) OptimiseRoute(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	itineraryID, err := strconv.Atoi(q.Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleViewer) {
+		return
+	}
+	day := q.Get("day")
+	if err := validateDay(&day); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var start *geo.Point
+	if q.Has("lat") || q.Has("lng") {
+		lat, err := strconv.ParseFloat(q.Get("lat"), 64)
+		if err != nil {
+			http.Error(w, "Invalid lat parameter", http.StatusBadRequest)
+			return
+		}
+		lng, err := strconv.ParseFloat(q.Get("lng"), 64)
+		if err != nil {
+			http.Error(w, "Invalid lng parameter", http.StatusBadRequest)
+			return
+		}
+		start = &geo.Point{Lat: lat, Lng: lng}
+		if !start.Valid() {
+			http.Error(w, "lat or lng out of range", http.StatusBadRequest)
+			return
+		}
+	}
+
+	proposal, err := h.optimiser.Optimise(r.Context(), itineraryID, day, start)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Itinerary with that id does not exist", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, service.ErrDayNotInTrip) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to optimise route", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    proposal,
+	})
+}
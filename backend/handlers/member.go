@@ -0,0 +1,440 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+type MemberHandler struct {
+	memberRepo     *repository.MemberRepository
+	invitationRepo *repository.InvitationRepository
+	userRepo       *repository.UserRepository
+	access         *service.ItineraryAccess
+}
+
+type MemberRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type TransferOwnershipRequest struct {
+	UserID int `json:"user_id"`
+}
+
+// InvitationRequest names the invitee by email or username, the role defaults to editor
+type InvitationRequest struct {
+	Email    string `json:"email"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+type InvitationResponseRequest struct {
+	Accept bool `json:"accept"`
+}
+
+func NewMemberHandler(memberRepo *repository.MemberRepository, invitationRepo *repository.InvitationRepository, userRepo *repository.UserRepository, access *service.ItineraryAccess) *MemberHandler {
+	return &MemberHandler{memberRepo: memberRepo, invitationRepo: invitationRepo, userRepo: userRepo, access: mustHaveAccess(access)}
+}
+
+// mustHaveAccess stops a handler being built without the access checker its itinerary routes rely on
+func mustHaveAccess(access *service.ItineraryAccess) *service.ItineraryAccess {
+	if access == nil {
+		panic("handlers: an itinerary access checker is required")
+	}
+	return access
+}
+
+// writeNoAccess answers a request a handler cannot authorize because it has no access checker
+func writeNoAccess(w http.ResponseWriter) {
+	http.Error(w, "Failed to check access to the itinerary", http.StatusInternalServerError)
+}
+
+// authorizeItinerary checks the signed in user has at least the role need on the itinerary and
+// writes the error response when they do not. Without an access checker nothing is allowed.
+func authorizeItinerary(w http.ResponseWriter, r *http.Request, access *service.ItineraryAccess, itineraryID int, need string) bool {
+	_, ok := itineraryMember(w, r, access, itineraryID, need)
+	return ok
+}
+
+// itineraryMember is authorizeItinerary returning who the signed in user is on the itinerary
+func itineraryMember(w http.ResponseWriter, r *http.Request, access *service.ItineraryAccess, itineraryID int, need string) (models.ItineraryMember, bool) {
+	if access == nil {
+		writeNoAccess(w)
+		return models.ItineraryMember{}, false
+	}
+	member, err := access.Require(r.Context(), itineraryID, need)
+	switch {
+	case err == nil:
+		return member, true
+	case errors.Is(err, service.ErrSignInRequired):
+		http.Error(w, "Authorization required", http.StatusUnauthorized)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Itinerary with that id does not exist", http.StatusNotFound)
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, "This needs the "+need+" role on the itinerary", http.StatusForbidden)
+	default:
+		http.Error(w, "Failed to check access to the itinerary", http.StatusInternalServerError)
+	}
+	return member, false
+}
+
+// memberRole checks a role that can be given to a member other than the owner
+func memberRole(role string) (string, error) {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if role != models.RoleEditor && role != models.RoleViewer {
+		return role, errors.New("role must be editor or viewer")
+	}
+	return role, nil
+}
+
+// GetMembers handles GET /itinerary/members?itinerary_id=
+func (h *MemberHandler) GetMembers(w http.ResponseWriter, r *http.Request) {
+	itineraryID, err := strconv.Atoi(r.URL.Query().Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleViewer) {
+		return
+	}
+	members, err := h.memberRepo.GetMembers(r.Context(), itineraryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Itinerary with that id does not exist", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch members", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"members": members,
+	})
+}
+
+// SetMemberRole handles PUT /itinerary/members?itinerary_id=&user_id= making a member an editor or viewer
+func (h *MemberHandler) SetMemberRole(w http.ResponseWriter, r *http.Request) {
+	itineraryID, err := strconv.Atoi(r.URL.Query().Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	var req MemberRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	role, err := memberRole(req.Role)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleOwner) {
+		return
+	}
+
+	member, err := h.memberRepo.SetMemberRole(r.Context(), itineraryID, userID, role)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Itinerary or member not found", http.StatusNotFound)
+		return
+	case errors.Is(err, repository.ErrOwnerRole):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, "Failed to change role", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    member,
+	})
+}
+
+// RemoveMember handles DELETE /itinerary/members?itinerary_id=&user_id=. The owner removes others,
+// anyone else can only remove themselves to leave the trip.
+func (h *MemberHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	itineraryID, err := strconv.Atoi(r.URL.Query().Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+	user, ok := currentUser(w, r, h.userRepo)
+	if !ok {
+		return
+	}
+	need := models.RoleOwner
+	if userID == user.ID {
+		need = models.RoleViewer
+	}
+	if !authorizeItinerary(w, r, h.access, itineraryID, need) {
+		return
+	}
+
+	err = h.memberRepo.RemoveMember(r.Context(), itineraryID, userID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Itinerary or member not found", http.StatusNotFound)
+		return
+	case errors.Is(err, repository.ErrRemoveOwner):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, repository.ErrMemberHasExpenses):
+		http.Error(w, "Member paid for or shares in expenses, move those to someone else first", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+
+	message := "Member removed"
+	if userID == user.ID {
+		message = "You left the itinerary"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": message,
+	})
+}
+
+// TransferOwnership handles PUT /itinerary/owner?itinerary_id= handing the itinerary to another member
+func (h *MemberHandler) TransferOwnership(w http.ResponseWriter, r *http.Request) {
+	itineraryID, err := strconv.Atoi(r.URL.Query().Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	var req TransferOwnershipRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.UserID <= 0 {
+		http.Error(w, "user_id is required", http.StatusBadRequest)
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleOwner) {
+		return
+	}
+
+	err = h.memberRepo.TransferOwnership(r.Context(), itineraryID, req.UserID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Itinerary with that id does not exist", http.StatusNotFound)
+		return
+	case errors.Is(err, repository.ErrNotMember):
+		http.Error(w, "The new owner must already be a member of the itinerary", http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, "Failed to transfer ownership", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Ownership transferred",
+	})
+}
+
+// GetInvitations handles GET /itinerary/invitations?itinerary_id= listing the pending invitations to
+// an itinerary, or without itinerary_id those waiting for the signed in user
+func (h *MemberHandler) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	var invitations []models.ItineraryInvitation
+	if v := r.URL.Query().Get("itinerary_id"); v != "" {
+		itineraryID, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+			return
+		}
+		if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleEditor) {
+			return
+		}
+		if invitations, err = h.invitationRepo.GetInvitationsByItinerary(r.Context(), itineraryID); err != nil {
+			http.Error(w, "Failed to fetch invitations", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		user, ok := currentUser(w, r, h.userRepo)
+		if !ok {
+			return
+		}
+		var err error
+		if invitations, err = h.invitationRepo.GetInvitationsByUser(r.Context(), user.ID); err != nil {
+			http.Error(w, "Failed to fetch invitations", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"invitations": invitations,
+	})
+}
+
+// CreateInvitation handles POST /itinerary/invitations?itinerary_id= inviting a user by email or username
+func (h *MemberHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	itineraryID, err := strconv.Atoi(r.URL.Query().Get("itinerary_id"))
+	if err != nil {
+		http.Error(w, "Invalid itinerary id", http.StatusBadRequest)
+		return
+	}
+	var req InvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleEditor
+	}
+	role, err := memberRole(req.Role)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	email, username := strings.TrimSpace(req.Email), strings.TrimSpace(req.Username)
+	if (email == "") == (username == "") {
+		http.Error(w, "Give either an email or a username", http.StatusBadRequest)
+		return
+	}
+	inviter, ok := currentUser(w, r, h.userRepo)
+	if !ok {
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, itineraryID, models.RoleOwner) {
+		return
+	}
+
+	var invitee *models.User
+	if email != "" {
+		invitee, err = h.userRepo.GetUserByEmail(email)
+	} else {
+		invitee, err = h.userRepo.GetUserByUsername(username)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "No user with that email or username", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch user", http.StatusInternalServerError)
+		return
+	}
+
+	invitation := models.ItineraryInvitation{ItineraryID: itineraryID, UserID: invitee.ID, Role: role, InvitedBy: &inviter.ID}
+	err = h.invitationRepo.CreateInvitation(r.Context(), &invitation)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Itinerary with that id does not exist", http.StatusNotFound)
+		return
+	case errors.Is(err, repository.ErrAlreadyMember):
+		http.Error(w, "User is already a member of the itinerary", http.StatusConflict)
+		return
+	case isPQError(err, pqUniqueViolation):
+		http.Error(w, "User already has a pending invitation to the itinerary", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to invite user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    invitation,
+	})
+}
+
+// RespondToInvitation handles PUT /itinerary/invitations?invitation_id= accepting or declining an
+// invitation sent to the signed in user
+func (h *MemberHandler) RespondToInvitation(w http.ResponseWriter, r *http.Request) {
+	invitationID, err := strconv.Atoi(r.URL.Query().Get("invitation_id"))
+	if err != nil {
+		http.Error(w, "Invalid invitation id", http.StatusBadRequest)
+		return
+	}
+	var req InvitationResponseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	user, ok := currentUser(w, r, h.userRepo)
+	if !ok {
+		return
+	}
+
+	invitation, err := h.invitationRepo.RespondToInvitation(r.Context(), invitationID, user.ID, req.Accept)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "No pending invitation with that id", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to answer invitation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    invitation,
+	})
+}
+
+// CancelInvitation handles DELETE /itinerary/invitations?invitation_id=
+func (h *MemberHandler) CancelInvitation(w http.ResponseWriter, r *http.Request) {
+	invitationID, err := strconv.Atoi(r.URL.Query().Get("invitation_id"))
+	if err != nil {
+		http.Error(w, "Invalid invitation id", http.StatusBadRequest)
+		return
+	}
+	invitation, err := h.invitationRepo.GetInvitationById(r.Context(), invitationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "No pending invitation with that id", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch invitation", http.StatusInternalServerError)
+		return
+	}
+	if !authorizeItinerary(w, r, h.access, invitation.ItineraryID, models.RoleOwner) {
+		return
+	}
+
+	if err := h.invitationRepo.CancelInvitation(r.Context(), invitationID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "No pending invitation with that id", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to cancel invitation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Invitation cancelled",
+	})
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/schedule"
+)
+
+type OpeningHoursHandler struct {
+	hoursRepo *repository.OpeningHoursRepository
+}
+
+func NewOpeningHoursHandler(hoursRepo *repository.OpeningHoursRepository) *OpeningHoursHandler {
+	return &OpeningHoursHandler{hoursRepo: hoursRepo}
+}
+
+// GetOpeningHours handles GET /activity/hours?activity_id=
+func (h *OpeningHoursHandler) GetOpeningHours(w http.ResponseWriter, r *http.Request) {
+	activityID, err := strconv.Atoi(r.URL.Query().Get("activity_id"))
+	if err != nil {
+		http.Error(w, "Invalid activity id", http.StatusBadRequest)
+		return
+	}
+	hours, err := h.hoursRepo.GetOpeningHours(r.Context(), activityID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Activity with that id does not exist", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch opening hours", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    hours,
+	})
+}
+
+// SetOpeningHours handles PUT /activity/hours?activity_id= replacing the weekly hours, exceptions
+// and seasonal closures. The timezone comes from the activity's country and cannot be set here.
+func (h *OpeningHoursHandler) SetOpeningHours(w http.ResponseWriter, r *http.Request) {
+	activityID, err := strconv.Atoi(r.URL.Query().Get("activity_id"))
+	if err != nil {
+		http.Error(w, "Invalid activity id", http.StatusBadRequest)
+		return
+	}
+	var hours models.OpeningHours
+	if err := json.NewDecoder(r.Body).Decode(&hours); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := schedule.Validate(hours); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := h.hoursRepo.SetOpeningHours(r.Context(), activityID, hours)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Activity with that id does not exist", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save opening hours", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    saved,
+	})
+}
+
+// IsOpen handles GET /activity/open?activity_id=&at= where at is a local time such as
+// 2026-07-01T18:30 in the activity's timezone, or an RFC 3339 time with an offset. It defaults to now.
+func (h *OpeningHoursHandler) IsOpen(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	activityID, err := strconv.Atoi(q.Get("activity_id"))
+	if err != nil {
+		http.Error(w, "Invalid activity id", http.StatusBadRequest)
+		return
+	}
+	hours, err := h.hoursRepo.GetOpeningHours(r.Context(), activityID)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Activity with that id does not exist", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch opening hours", http.StatusInternalServerError)
+		return
+	}
+
+	loc := schedule.Location(hours)
+	at := time.Now().In(loc)
+	if v := q.Get("at"); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			at = t.In(loc)
+		} else if at, err = time.ParseInLocation("2006-01-02T15:04", v, loc); err != nil {
+			http.Error(w, "Invalid at parameter, expected YYYY-MM-DDTHH:MM", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"activity_id": activityID,
+		"open":        schedule.IsOpen(hours, at),
+		"open_today":  schedule.OpenOn(hours, at),
+		"local_time":  at.Format("2006-01-02T15:04"),
+		"timezone":    loc.String(),
+	})
+}
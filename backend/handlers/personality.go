@@ -25,7 +25,7 @@ func (h *PersonalityHandler) CreatePersonality(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	if err := h.personalityRepo.CreatePersonality(&personality); err != nil {
+	if err := h.personalityRepo.CreatePersonality(r.Context(), &personality); err != nil {
 		http.Error(w, "Failed to create personality", http.StatusInternalServerError)
 		return
 	}
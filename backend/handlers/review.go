@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+const (
+	maxReviewLength = 5000
+	// ratings at or above this are positive feedback for gorse, lower ones only count as a read
+	positiveRating = 3
+)
+
+// ReviewStore is the review data the handler works with, ReviewRepository implements it
+type ReviewStore interface {
+	CreateReview(ctx context.Context, review *models.Review) error
+	GetReviewsByActivity(ctx context.Context, filter models.ReviewFilter) ([]models.Review, int, error)
+	UpdateReview(ctx context.Context, review *models.Review) error
+	DeleteReview(ctx context.Context, id, userID int) (models.Review, error)
+	SetHelpfulVote(ctx context.Context, reviewID, userID int, helpful bool) (int, error)
+}
+
+// UserLookup finds the account behind a signed in actor, UserRepository implements it
+type UserLookup interface {
+	GetUserByEmail(email string) (*models.User, error)
+}
+
+type ReviewHandler struct {
+	reviewRepo   ReviewStore
+	userRepo     UserLookup
+	gorseService *service.GorseService
+}
+
+type ReviewRequest struct {
+	Rating int    `json:"rating"`
+	Body   string `json:"body"`
+}
+
+func NewReviewHandler(reviewRepo ReviewStore, userRepo UserLookup, gorseService *service.GorseService) *ReviewHandler {
+	return &ReviewHandler{reviewRepo: reviewRepo, userRepo: userRepo, gorseService: gorseService}
+}
+
+// currentUser resolves the authenticated user of the request, writing a 401 when there is none
+func currentUser(w http.ResponseWriter, r *http.Request, userRepo UserLookup) (*models.User, bool) {
+	actor := auth.ActorFromContext(r.Context())
+	if actor == auth.AnonymousActor {
+		http.Error(w, "Authorization required", http.StatusUnauthorized)
+		return nil, false
+	}
+	user, err := userRepo.GetUserByEmail(actor)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "User no longer exists", http.StatusUnauthorized)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch user", http.StatusInternalServerError)
+		return nil, false
+	}
+	return user, true
+}
+
+func decodeReviewRequest(r *http.Request) (ReviewRequest, error) {
+	var req ReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, errors.New("Invalid JSON")
+	}
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Rating < 1 || req.Rating > 5 {
+		return req, errors.New("rating must be between 1 and 5")
+	}
+	if len(req.Body) > maxReviewLength {
+		return req, errors.New("review must be at most 5000 characters")
+	}
+	return req, nil
+}
+
+// sendFeedback forwards a rating to gorse in the background. Good ratings are star feedback
+// weighted by the rating, poor ones replace any star with a plain read.
+func (h *ReviewHandler) sendFeedback(review models.Review) {
+	userID := strconv.Itoa(review.UserID)
+	itemID := strconv.Itoa(review.ActivityID)
+	go func() {
+		var err error
+		if review.Rating >= positiveRating {
+			err = h.gorseService.InsertFeedback("star", userID, itemID, float64(review.Rating), review.UpdatedAt)
+		} else if err = h.gorseService.DeleteFeedback("star", userID, itemID); err == nil {
+			err = h.gorseService.InsertFeedback("read", userID, itemID, 1, review.UpdatedAt)
+		}
+		if err != nil {
+			log.Printf("warning: failed to send review feedback to gorse: %v", err)
+		}
+	}()
+}
+
+// GetReviews handles GET /activity/reviews?activity_id=&sort=&limit=&offset=
+// with sort one of recent, helpful, rating_high or rating_low
+func (h *ReviewHandler) GetReviews(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	activityID, err := strconv.Atoi(q.Get("activity_id"))
+	if err != nil {
+		http.Error(w, "Invalid activity id", http.StatusBadRequest)
+		return
+	}
+	filter := models.ReviewFilter{ActivityID: activityID, Sort: q.Get("sort")}
+	switch filter.Sort {
+	case "", models.ReviewSortRecent, models.ReviewSortHelpful, models.ReviewSortRatingHigh, models.ReviewSortRatingLow:
+	default:
+		http.Error(w, "Invalid sort parameter", http.StatusBadRequest)
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if filter.Offset, err = strconv.Atoi(v); err != nil || filter.Offset < 0 {
+			http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	reviews, total, err := h.reviewRepo.GetReviewsByActivity(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to fetch reviews", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"total":   total,
+		"reviews": reviews,
+	})
+}
+
+// CreateReview handles POST /activity/reviews?activity_id=, each user can review an activity once
+func (h *ReviewHandler) CreateReview(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r, h.userRepo)
+	if !ok {
+		return
+	}
+	activityID, err := strconv.Atoi(r.URL.Query().Get("activity_id"))
+	if err != nil {
+		http.Error(w, "Invalid activity id", http.StatusBadRequest)
+		return
+	}
+	req, err := decodeReviewRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	review := models.Review{ActivityID: activityID, UserID: user.ID, Rating: req.Rating, Body: req.Body}
+	if err := h.reviewRepo.CreateReview(r.Context(), &review); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Activity with that id does not exist", http.StatusNotFound)
+			return
+		}
+		if isPQError(err, pqUniqueViolation) {
+			http.Error(w, "You have already reviewed this activity", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create review", http.StatusInternalServerError)
+		return
+	}
+	h.sendFeedback(review)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(review)
+}
+
+// UpdateReview handles PUT /activity/reviews?review_id=, only the author can edit a review
+func (h *ReviewHandler) UpdateReview(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r, h.userRepo)
+	if !ok {
+		return
+	}
+	reviewID, err := strconv.Atoi(r.URL.Query().Get("review_id"))
+	if err != nil {
+		http.Error(w, "Invalid review id", http.StatusBadRequest)
+		return
+	}
+	req, err := decodeReviewRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	review := models.Review{ID: reviewID, UserID: user.ID, Rating: req.Rating, Body: req.Body}
+	if err := h.reviewRepo.UpdateReview(r.Context(), &review); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "You have no review with that id", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to update review", http.StatusInternalServerError)
+		return
+	}
+	h.sendFeedback(review)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(review)
+}
+
+// DeleteReview handles DELETE /activity/reviews?review_id=, the rating is withdrawn from gorse too
+func (h *ReviewHandler) DeleteReview(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r, h.userRepo)
+	if !ok {
+		return
+	}
+	reviewID, err := strconv.Atoi(r.URL.Query().Get("review_id"))
+	if err != nil {
+		http.Error(w, "Invalid review id", http.StatusBadRequest)
+		return
+	}
+
+	review, err := h.reviewRepo.DeleteReview(r.Context(), reviewID, user.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "You have no review with that id", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete review", http.StatusInternalServerError)
+		return
+	}
+	go func() {
+		if err := h.gorseService.DeleteFeedback("star", strconv.Itoa(review.UserID), strconv.Itoa(review.ActivityID)); err != nil {
+			log.Printf("warning: failed to delete review feedback from gorse: %v", err)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Review deleted",
+	})
+}
+
+// VoteHelpful handles POST (vote) and DELETE (withdraw) on /activity/reviews/helpful?review_id=
+func (h *ReviewHandler) VoteHelpful(w http.ResponseWriter, r *http.Request) {
+	user, ok := currentUser(w, r, h.userRepo)
+	if !ok {
+		return
+	}
+	reviewID, err := strconv.Atoi(r.URL.Query().Get("review_id"))
+	if err != nil {
+		http.Error(w, "Invalid review id", http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.reviewRepo.SetHelpfulVote(r.Context(), reviewID, user.ID, r.Method == http.MethodPost)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Review with that id does not exist", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, repository.ErrOwnReview) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(w, "Failed to record vote", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":       true,
+		"review_id":     reviewID,
+		"helpful_count": count,
+	})
+}
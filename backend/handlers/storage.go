@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+type StorageHandler struct {
+	gc *service.StorageGC
+}
+
+func NewStorageHandler(gc *service.StorageGC) *StorageHandler {
+	return &StorageHandler{gc: gc}
+}
+
+// GetGCReport handles GET /admin/storage/gc returning the report of the last run
+func (h *StorageHandler) GetGCReport(w http.ResponseWriter, r *http.Request) {
+	report := h.gc.LastReport()
+	if report == nil {
+		http.Error(w, "Storage gc has not run yet", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"report":  report,
+	})
+}
+
+// RunGC handles POST /admin/storage/gc?dry_run=, dry_run defaults to true so nothing is deleted by accident
+func (h *StorageHandler) RunGC(w http.ResponseWriter, r *http.Request) {
+	dryRun := true
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "Invalid dry_run parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := h.gc.Run(r.Context(), dryRun)
+	if err != nil {
+		if errors.Is(err, service.ErrGCRunning) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Storage gc failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"report":  report,
+	})
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+type SuggestHandler struct {
+	suggestService *service.SuggestService
+}
+
+func NewSuggestHandler(suggestService *service.SuggestService) *SuggestHandler {
+	return &SuggestHandler{suggestService: suggestService}
+}
+
+// Suggest handles GET /activities/suggest?q=&limit= for the search bar autocomplete
+func (h *SuggestHandler) Suggest(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("q")
+	limit := 10
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 50 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"query":       prefix,
+		"suggestions": h.suggestService.Suggest(prefix, limit),
+	})
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -15,6 +17,7 @@ import (
 type UserHandler struct {
 	userRepo     repository.UserRepositoryInterface
 	gorseService *service.GorseService
+	admins       map[string]bool
 }
 
 type CreateUserResponse struct {
@@ -24,8 +27,39 @@ type CreateUserResponse struct {
 	Token string `json:"token"`
 }
 
-func NewUserHandler(userRepo repository.UserRepositoryInterface) *UserHandler {
-	return &UserHandler{userRepo: userRepo}
+func NewUserHandler(userRepo repository.UserRepositoryInterface, admins []string) *UserHandler {
+	allowed := make(map[string]bool, len(admins))
+	for _, admin := range admins {
+		allowed[admin] = true
+	}
+	return &UserHandler{userRepo: userRepo, admins: allowed}
+}
+
+// authorizeAccount checks the signed in user is account id or an admin, writing the error response
+// when they are not
+func (h *UserHandler) authorizeAccount(w http.ResponseWriter, r *http.Request, id int) bool {
+	actor := auth.ActorFromContext(r.Context())
+	if actor == auth.AnonymousActor {
+		http.Error(w, "Authorization required", http.StatusUnauthorized)
+		return false
+	}
+	if h.admins[actor] {
+		return true
+	}
+	callerID, err := h.userRepo.GetAccountIdByEmail(actor)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "User no longer exists", http.StatusUnauthorized)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch user", http.StatusInternalServerError)
+		return false
+	}
+	if callerID != id {
+		http.Error(w, "You can only manage your own account", http.StatusForbidden)
+		return false
+	}
+	return true
 }
 
 func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
@@ -70,13 +104,17 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.userRepo.CreateUser(&user); err != nil {
+	if err := h.userRepo.CreateUser(r.Context(), &user); err != nil {
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		return
 	}
 
 	labels := map[string]string{
-		"personality": user.Personality,
+		"openness":          strconv.FormatFloat(user.Personality.Openness, 'f', 0, 64),
+		"conscientiousness": strconv.FormatFloat(user.Personality.Conscientiousness, 'f', 0, 64),
+		"extraversion":      strconv.FormatFloat(user.Personality.Extraversion, 'f', 0, 64),
+		"agreeableness":     strconv.FormatFloat(user.Personality.Agreeableness, 'f', 0, 64),
+		"neuroticism":       strconv.FormatFloat(user.Personality.Neuroticism, 'f', 0, 64),
 	}
 
 	if err := h.gorseService.AddUser(strconv.Itoa(user.ID), labels); err != nil {
@@ -105,3 +143,53 @@ func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(users)
 }
+
+func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+		return
+	}
+	if !h.authorizeAccount(w, r, id) {
+		return
+	}
+	if err := h.userRepo.DeleteUser(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "User with that ID does not exist", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "User deleted",
+	})
+}
+
+func (h *UserHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "Invalid ID parameter", http.StatusBadRequest)
+		return
+	}
+	if !h.authorizeAccount(w, r, id) {
+		return
+	}
+	if err := h.userRepo.RestoreUser(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "No deleted user with that ID", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to restore user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "User restored",
+	})
+}
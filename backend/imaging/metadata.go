@@ -0,0 +1,221 @@
+// Package imaging strips metadata from uploaded images and renders their resized variants.
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrMalformedImage    = errors.New("malformed image")
+)
+
+var (
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+	exifHeader   = []byte("Exif\x00\x00")
+)
+
+// StripMetadata removes EXIF, XMP, IPTC and text metadata (which is where GPS coordinates live)
+// from a JPEG, PNG or WebP file without re-encoding the pixels. It also returns the EXIF
+// orientation so callers can rotate the pixels themselves, 1 means upright or unknown.
+// GIFs carry no such metadata and are returned unchanged.
+func StripMetadata(data []byte, contentType string) ([]byte, int, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	case "image/gif":
+		return data, 1, nil
+	default:
+		return nil, 1, ErrUnsupportedFormat
+	}
+}
+
+// stripJPEG drops APP1 (EXIF/XMP), APP13 (IPTC) and comment segments ahead of the image data
+func stripJPEG(data []byte) ([]byte, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 1, ErrMalformedImage
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	orientation := 1
+
+	i := 2
+	for i < len(data) {
+		if data[i] != 0xFF {
+			return nil, 1, ErrMalformedImage
+		}
+		// any number of 0xFF fill bytes may precede a marker
+		for i < len(data) && data[i] == 0xFF {
+			i++
+		}
+		if i >= len(data) {
+			return nil, 1, ErrMalformedImage
+		}
+		marker := data[i]
+		i++
+
+		// markers without a length field
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out.Write([]byte{0xFF, marker})
+			continue
+		}
+		if marker == 0xD9 {
+			out.Write([]byte{0xFF, marker})
+			return out.Bytes(), orientation, nil
+		}
+		if i+2 > len(data) {
+			return nil, 1, ErrMalformedImage
+		}
+		length := int(binary.BigEndian.Uint16(data[i:]))
+		if length < 2 || i+length > len(data) {
+			return nil, 1, ErrMalformedImage
+		}
+		segment := data[i+2 : i+length]
+
+		switch marker {
+		case 0xE1:
+			if bytes.HasPrefix(segment, exifHeader) {
+				orientation = exifOrientation(segment[len(exifHeader):])
+			}
+		case 0xED, 0xFE:
+		case 0xDA:
+			// start of scan, the entropy coded data and everything after it is kept as is
+			out.Write([]byte{0xFF, marker})
+			out.Write(data[i:])
+			return out.Bytes(), orientation, nil
+		default:
+			out.Write([]byte{0xFF, marker})
+			out.Write(data[i : i+length])
+		}
+		i += length
+	}
+	return nil, 1, ErrMalformedImage
+}
+
+// pngMetadataChunks are the ancillary chunks that can carry EXIF or free text
+var pngMetadataChunks = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+func stripPNG(data []byte) ([]byte, int, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, 1, ErrMalformedImage
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+	orientation := 1
+
+	i := len(pngSignature)
+	for i < len(data) {
+		if i+8 > len(data) {
+			return nil, 1, ErrMalformedImage
+		}
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		chunkType := string(data[i+4 : i+8])
+		// length, type, data and crc
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, 1, ErrMalformedImage
+		}
+		if chunkType == "eXIf" {
+			orientation = exifOrientation(data[i+8 : i+8+length])
+		}
+		if !pngMetadataChunks[chunkType] {
+			out.Write(data[i:end])
+		}
+		i = end
+		if chunkType == "IEND" {
+			return out.Bytes(), orientation, nil
+		}
+	}
+	return nil, 1, ErrMalformedImage
+}
+
+// stripWebP drops the EXIF and XMP chunks of an extended WebP and clears their flags in VP8X
+func stripWebP(data []byte) ([]byte, int, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, 1, ErrMalformedImage
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+	orientation := 1
+
+	i := 12
+	for i < len(data) {
+		if i+8 > len(data) {
+			return nil, 1, ErrMalformedImage
+		}
+		fourCC := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		// chunks are padded to an even size
+		end := i + 8 + size + size%2
+		if size < 0 || i+8+size > len(data) {
+			return nil, 1, ErrMalformedImage
+		}
+		if end > len(data) {
+			end = len(data)
+		}
+		payload := data[i+8 : i+8+size]
+
+		switch fourCC {
+		case "EXIF":
+			orientation = exifOrientation(bytes.TrimPrefix(payload, exifHeader))
+		case "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if size > 0 {
+				// bit 3 is the EXIF flag and bit 2 the XMP flag
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+	return stripped, orientation, nil
+}
+
+// exifOrientation reads the orientation tag from the first IFD of a TIFF structured EXIF block
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < entries; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		// 0x0112 is Orientation, stored as a SHORT
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			value := int(order.Uint16(tiff[entry+8:]))
+			if value >= 1 && value <= 8 {
+				return value
+			}
+			return 1
+		}
+	}
+	return 1
+}
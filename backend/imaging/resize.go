@@ -0,0 +1,148 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels guards against decompression bombs, larger images are rejected before decoding
+const MaxPixels = 50_000_000
+
+var ErrTooLarge = errors.New("image has too many pixels")
+
+// Variant is a named resized copy, Width is the width it is scaled down to
+type Variant struct {
+	Name  string
+	Width int
+}
+
+var DefaultVariants = []Variant{
+	{Name: "thumbnail", Width: 320},
+	{Name: "medium", Width: 800},
+	{Name: "large", Width: 1600},
+}
+
+// Rendered is an encoded variant ready to store
+type Rendered struct {
+	Variant
+	Height      int
+	Data        []byte
+	ContentType string
+}
+
+// Decode reads a JPEG, PNG, GIF or WebP image after checking its dimensions
+func Decode(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrMalformedImage
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// Orient applies an EXIF orientation so the pixels display upright without metadata
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// orientations 5 to 8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// Encode writes img as JPEG, or as PNG when it has transparency that JPEG would lose
+func Encode(img image.Image) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if isOpaque(img) {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+// Render scales img down to each variant narrower than it, images are never scaled up
+func Render(img image.Image, variants []Variant) ([]Rendered, error) {
+	b := img.Bounds()
+	var rendered []Rendered
+	for _, v := range variants {
+		if v.Width >= b.Dx() {
+			continue
+		}
+		height := b.Dy() * v.Width / b.Dx()
+		if height < 1 {
+			height = 1
+		}
+		dst := image.NewRGBA(image.Rect(0, 0, v.Width, height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+
+		var out image.Image = dst
+		if isOpaque(img) {
+			// keep the opaque hint so the variant is encoded as JPEG
+			out = opaqueRGBA{dst}
+		}
+		data, contentType, err := Encode(out)
+		if err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, Rendered{Variant: v, Height: height, Data: data, ContentType: contentType})
+	}
+	return rendered, nil
+}
+
+// opaqueRGBA reports itself opaque without scanning every pixel
+type opaqueRGBA struct{ *image.RGBA }
+
+func (opaqueRGBA) Opaque() bool { return true }
@@ -11,8 +11,8 @@ import (
 func main() {
 	cfg := config.Load()
 
-	// Use your database.ConnectDB helper
-	db, err := database.ConnectDB(cfg.Database)
+	// primary plus any read replicas
+	db, err := database.ConnectRouter(cfg.Database)
 	if err != nil {
 		log.Fatal("Failed to connect to database: ", err)
 	}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/golang-jwt/jwt/v4"
 	"net/http"
 	"strings"
 )
@@ -10,23 +11,70 @@ func JWTAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "missing authorization header", http.StatusBadRequest)
+			http.Error(w, "missing authorization header", http.StatusUnauthorized)
 			return
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Invalid Auth Header format", http.StatusBadRequest)
+		r, ok := authenticate(w, r, authHeader)
+		if !ok {
 			return
 		}
 
-		tokenStr := parts[1]
-		token, err := auth.VerifyToken(tokenStr)
-		if err != nil || !token.Valid {
-			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// OptionalJWTAuth lets requests without an Authorization header through as anonymous,
+// but still rejects a header that is present and invalid
+func OptionalJWTAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r, ok := authenticate(w, r, authHeader)
+		if !ok {
 			return
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+// StreamJWTAuth is OptionalJWTAuth that also takes the token from ?access_token=, browsers cannot
+// set headers on an EventSource
+func StreamJWTAuth(next http.Handler) http.Handler {
+	optional := OptionalJWTAuth(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r = r.Clone(r.Context())
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		optional.ServeHTTP(w, r)
+	})
+}
+
+// authenticate verifies the bearer token and stores its username on the request context as the actor
+func authenticate(w http.ResponseWriter, r *http.Request, authHeader string) (*http.Request, bool) {
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		http.Error(w, "Invalid Auth Header format", http.StatusBadRequest)
+		return r, false
+	}
+
+	tokenStr := parts[1]
+	token, err := auth.VerifyToken(tokenStr)
+	if err != nil || !token.Valid {
+		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+		return r, false
+	}
+
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		if username, ok := claims["username"].(string); ok {
+			r = r.WithContext(auth.WithActor(r.Context(), username))
+		}
+	}
+	return r, true
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+)
+
+// RequireAdmin only lets through requests whose actor is in the configured admin list.
+// It must be wrapped by JWTAuth so the actor is set.
+func RequireAdmin(admins []string, next http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(admins))
+	for _, admin := range admins {
+		allowed[admin] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowed[auth.ActorFromContext(r.Context())] {
+			http.Error(w, "admin access required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/database"
+)
+
+// readPrimaryCookie holds the unix milliseconds until which a client's reads go to the primary
+const readPrimaryCookie = "read_primary_until"
+
+// ReadYourWrites keeps a client's reads on the primary for window after a successful write. The pin
+// travels in a cookie, so unlike the router's in-memory pin it holds when the next request lands
+// on another API instance.
+func ReadYourWrites(window time.Duration, next http.Handler) http.Handler {
+	if window <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie(readPrimaryCookie); err == nil {
+			until, err := strconv.ParseInt(cookie.Value, 10, 64)
+			now := time.Now()
+			// a pin further out than one window was not set by us
+			if err == nil && until > now.UnixMilli() && until <= now.Add(window).UnixMilli() {
+				r = r.WithContext(database.WithPrimary(r.Context()))
+			}
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			pw := &pinningWriter{ResponseWriter: w, window: window}
+			next.ServeHTTP(pw, r)
+			// a handler that wrote nothing still answered 200
+			if !pw.wroteHeader {
+				pw.WriteHeader(http.StatusOK)
+			}
+		}
+	})
+}
+
+// pinningWriter sets the pin cookie just before the response headers go out, unless the write failed
+type pinningWriter struct {
+	http.ResponseWriter
+	window      time.Duration
+	wroteHeader bool
+}
+
+func (p *pinningWriter) WriteHeader(code int) {
+	if !p.wroteHeader {
+		p.wroteHeader = true
+		if code < http.StatusBadRequest {
+			http.SetCookie(p.ResponseWriter, &http.Cookie{
+				Name:     readPrimaryCookie,
+				Value:    strconv.FormatInt(time.Now().Add(p.window).UnixMilli(), 10),
+				Path:     "/",
+				MaxAge:   int(p.window.Round(time.Second)/time.Second) + 1,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+	}
+	p.ResponseWriter.WriteHeader(code)
+}
+
+func (p *pinningWriter) Write(b []byte) (int, error) {
+	if !p.wroteHeader {
+		p.WriteHeader(http.StatusOK)
+	}
+	return p.ResponseWriter.Write(b)
+}
@@ -0,0 +1,18 @@
+DROP FUNCTION convert_price(BIGINT, CHAR(3), CHAR(3));
+
+ALTER TABLE users
+DROP COLUMN preferred_currency;
+
+ALTER TABLE activity
+ADD COLUMN price NUMERIC(10,2) NOT NULL DEFAULT 0;
+
+-- prices in other currencies cannot be restored faithfully, their amounts are kept as is
+UPDATE activity a SET price = a.price_minor / POWER(10, c.minor_units)
+FROM currency c WHERE c.code = a.currency;
+
+ALTER TABLE activity
+DROP COLUMN price_minor,
+DROP COLUMN currency;
+
+DROP TABLE exchange_rate;
+DROP TABLE currency;
@@ -0,0 +1,47 @@
+-- ISO 4217 currencies prices can be given in, minor_units is the number of decimal places
+CREATE TABLE currency (
+    code CHAR(3) PRIMARY KEY CHECK (code ~ '^[A-Z]{3}$'),
+    minor_units SMALLINT NOT NULL CHECK (minor_units BETWEEN 0 AND 4)
+);
+
+INSERT INTO currency (code, minor_units) VALUES
+('USD', 2), ('EUR', 2), ('GBP', 2), ('JPY', 0), ('SGD', 2), ('MYR', 2), ('THB', 2), ('IDR', 2),
+('VND', 0), ('PHP', 2), ('KRW', 0), ('CNY', 2), ('HKD', 2), ('TWD', 2), ('INR', 2), ('AUD', 2),
+('NZD', 2), ('CAD', 2), ('CHF', 2), ('SEK', 2), ('NOK', 2), ('DKK', 2), ('PLN', 2), ('CZK', 2),
+('HUF', 2), ('RON', 2), ('BGN', 2), ('ISK', 0), ('TRY', 2), ('ILS', 2), ('AED', 2), ('SAR', 2),
+('ZAR', 2), ('BRL', 2), ('MXN', 2), ('KWD', 3), ('BHD', 3), ('OMR', 3), ('JOD', 3);
+
+-- one snapshot per rate_date, rate is units of currency per one unit of base
+CREATE TABLE exchange_rate (
+    rate_date DATE NOT NULL,
+    currency CHAR(3) NOT NULL REFERENCES currency(code),
+    base CHAR(3) NOT NULL REFERENCES currency(code),
+    rate NUMERIC(24, 10) NOT NULL CHECK (rate > 0),
+    PRIMARY KEY (rate_date, currency)
+);
+
+-- existing prices had no currency, they are taken to be US dollars
+ALTER TABLE activity
+ADD COLUMN price_minor BIGINT NOT NULL DEFAULT 0 CHECK (price_minor >= 0),
+ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' REFERENCES currency(code);
+
+UPDATE activity SET price_minor = ROUND(COALESCE(price, 0) * 100);
+
+ALTER TABLE activity
+DROP COLUMN price;
+
+ALTER TABLE users
+ADD COLUMN preferred_currency CHAR(3) REFERENCES currency(code);
+
+-- converts minor units of one currency to major units of another using the latest snapshot that
+-- has both currencies against the same base, NULL when no snapshot has the pair
+CREATE FUNCTION convert_price(amount_minor BIGINT, from_currency CHAR(3), to_currency CHAR(3)) RETURNS NUMERIC AS $$
+    SELECT CASE WHEN from_currency = to_currency THEN amount_minor / POWER(10, fc.minor_units)
+    ELSE amount_minor / POWER(10, fc.minor_units) * (
+        SELECT t.rate / f.rate FROM exchange_rate f
+        JOIN exchange_rate t ON t.rate_date = f.rate_date AND t.base = f.base
+        WHERE f.currency = from_currency AND t.currency = to_currency
+        ORDER BY f.rate_date DESC LIMIT 1)
+    END
+    FROM currency fc WHERE fc.code = from_currency
+$$ LANGUAGE SQL STABLE;
@@ -0,0 +1,5 @@
+DROP INDEX idx_activity_location;
+
+ALTER TABLE activity
+DROP COLUMN longitude,
+DROP COLUMN latitude;
@@ -0,0 +1,7 @@
+ALTER TABLE activity
+ADD COLUMN latitude DOUBLE PRECISION CHECK (latitude BETWEEN -90 AND 90),
+ADD COLUMN longitude DOUBLE PRECISION CHECK (longitude BETWEEN -180 AND 180);
+
+
+-- nearby search narrows to a lat/lng bounding box with this index before computing exact distances
+CREATE INDEX idx_activity_location ON activity(latitude, longitude) WHERE latitude IS NOT NULL AND deleted_at IS NULL;
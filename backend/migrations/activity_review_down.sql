@@ -0,0 +1,5 @@
+ALTER TABLE activity
+DROP COLUMN review_count;
+
+DROP TABLE review_vote;
+DROP TABLE review;
@@ -0,0 +1,29 @@
+CREATE TABLE review (
+    id SERIAL PRIMARY KEY,
+    activity_id INT NOT NULL REFERENCES activity(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    body TEXT NOT NULL DEFAULT '',
+    helpful_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (activity_id, user_id)
+);
+
+CREATE INDEX idx_review_activity_created ON review(activity_id, created_at DESC);
+CREATE INDEX idx_review_activity_helpful ON review(activity_id, helpful_count DESC);
+CREATE INDEX idx_review_user_id ON review(user_id);
+
+CREATE TABLE review_vote (
+    review_id INT NOT NULL REFERENCES review(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (review_id, user_id)
+);
+
+CREATE INDEX idx_review_vote_user_id ON review_vote(user_id);
+
+
+-- rating is the average of the reviews, both columns are kept up to date by the review repository
+ALTER TABLE activity
+ADD COLUMN review_count INT NOT NULL DEFAULT 0;
@@ -0,0 +1,13 @@
+DROP INDEX idx_activity_name_trgm;
+DROP INDEX idx_activity_title_trgm;
+DROP INDEX idx_activity_search_vector;
+
+DROP TRIGGER country_search_update ON country;
+DROP FUNCTION country_search_update;
+
+DROP TRIGGER activity_search_update ON activity;
+DROP FUNCTION activity_search_update;
+DROP FUNCTION activity_search_vector;
+
+ALTER TABLE activity
+DROP COLUMN search_vector;
@@ -0,0 +1,44 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+
+ALTER TABLE activity
+ADD COLUMN search_vector tsvector;
+
+
+-- country names live in another table so the vector is kept up to date by triggers instead of a generated column
+CREATE FUNCTION activity_search_vector(a activity) RETURNS tsvector AS $$
+	SELECT
+		setweight(to_tsvector('english', COALESCE(a.title, '')), 'A') ||
+		setweight(to_tsvector('english', COALESCE(a.name, '')), 'A') ||
+		setweight(to_tsvector('english', COALESCE((SELECT name FROM country WHERE id = a.country_id), '')), 'B') ||
+		setweight(to_tsvector('english', COALESCE(a.address, '')), 'C');
+$$ LANGUAGE sql STABLE;
+
+CREATE FUNCTION activity_search_update() RETURNS trigger AS $$
+BEGIN
+	NEW.search_vector := activity_search_vector(NEW);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER activity_search_update
+BEFORE INSERT OR UPDATE OF name, title, address, country_id ON activity
+FOR EACH ROW EXECUTE FUNCTION activity_search_update();
+
+CREATE FUNCTION country_search_update() RETURNS trigger AS $$
+BEGIN
+	UPDATE activity SET search_vector = activity_search_vector(activity) WHERE country_id = NEW.id;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER country_search_update
+AFTER UPDATE OF name ON country
+FOR EACH ROW EXECUTE FUNCTION country_search_update();
+
+
+UPDATE activity SET search_vector = activity_search_vector(activity);
+
+CREATE INDEX idx_activity_search_vector ON activity USING GIN (search_vector);
+CREATE INDEX idx_activity_title_trgm ON activity USING GIN (title gin_trgm_ops);
+CREATE INDEX idx_activity_name_trgm ON activity USING GIN (name gin_trgm_ops);
@@ -0,0 +1,4 @@
+DROP TABLE activity_tag;
+DROP TABLE activity_category;
+DROP TABLE tag;
+DROP TABLE category;
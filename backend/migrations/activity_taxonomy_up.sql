@@ -0,0 +1,58 @@
+CREATE TABLE category (
+    id SERIAL PRIMARY KEY,
+    slug VARCHAR(64) NOT NULL UNIQUE CHECK (slug ~ '^[a-z0-9]+(-[a-z0-9]+)*$'),
+    name VARCHAR(100) NOT NULL,
+    parent_id INT REFERENCES category(id) ON DELETE RESTRICT,
+    CHECK (parent_id <> id)
+);
+
+CREATE INDEX idx_category_parent_id ON category(parent_id);
+
+CREATE TABLE tag (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE CHECK (name = LOWER(name) AND name <> '')
+);
+
+CREATE TABLE activity_category (
+    activity_id INT NOT NULL REFERENCES activity(id) ON DELETE CASCADE,
+    category_id INT NOT NULL REFERENCES category(id) ON DELETE RESTRICT,
+    PRIMARY KEY (activity_id, category_id)
+);
+
+CREATE INDEX idx_activity_category_category_id ON activity_category(category_id);
+
+CREATE TABLE activity_tag (
+    activity_id INT NOT NULL REFERENCES activity(id) ON DELETE CASCADE,
+    tag_id INT NOT NULL REFERENCES tag(id) ON DELETE CASCADE,
+    PRIMARY KEY (activity_id, tag_id)
+);
+
+CREATE INDEX idx_activity_tag_tag_id ON activity_tag(tag_id);
+
+
+INSERT INTO category (slug, name) VALUES
+('adventure', 'Adventure'),
+('culture', 'Culture'),
+('food', 'Food & Drink'),
+('nature', 'Nature'),
+('relaxation', 'Relaxation'),
+('entertainment', 'Entertainment');
+
+INSERT INTO category (slug, name, parent_id) VALUES
+('extreme-sports', 'Extreme Sports', (SELECT id FROM category WHERE slug = 'adventure')),
+('hiking', 'Hiking & Trekking', (SELECT id FROM category WHERE slug = 'adventure')),
+('water-sports', 'Water Sports', (SELECT id FROM category WHERE slug = 'adventure')),
+('museums', 'Museums & Galleries', (SELECT id FROM category WHERE slug = 'culture')),
+('history', 'History & Heritage', (SELECT id FROM category WHERE slug = 'culture')),
+('festivals', 'Festivals & Ceremonies', (SELECT id FROM category WHERE slug = 'culture')),
+('local-life', 'Local Life', (SELECT id FROM category WHERE slug = 'culture')),
+('street-food', 'Street Food', (SELECT id FROM category WHERE slug = 'food')),
+('fine-dining', 'Fine Dining', (SELECT id FROM category WHERE slug = 'food')),
+('cooking-classes', 'Cooking Classes', (SELECT id FROM category WHERE slug = 'food')),
+('wildlife', 'Wildlife', (SELECT id FROM category WHERE slug = 'nature')),
+('parks', 'Parks & Gardens', (SELECT id FROM category WHERE slug = 'nature')),
+('beaches', 'Beaches', (SELECT id FROM category WHERE slug = 'nature')),
+('spa', 'Spa & Wellness', (SELECT id FROM category WHERE slug = 'relaxation')),
+('resorts', 'Resorts', (SELECT id FROM category WHERE slug = 'relaxation')),
+('theme-parks', 'Theme Parks', (SELECT id FROM category WHERE slug = 'entertainment')),
+('nightlife', 'Nightlife', (SELECT id FROM category WHERE slug = 'entertainment'));
@@ -0,0 +1,27 @@
+CREATE TABLE audit_log(
+id BIGSERIAL PRIMARY KEY,
+actor VARCHAR(255) NOT NULL,
+entity VARCHAR(64) NOT NULL,
+entity_id INT,
+action VARCHAR(32) NOT NULL,
+before JSONB,
+after JSONB,
+diff JSONB,
+created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_log_entity ON audit_log(entity, entity_id, created_at);
+CREATE INDEX idx_audit_log_actor ON audit_log(actor, created_at);
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
+
+
+-- the audit log is append-only
+CREATE FUNCTION audit_log_immutable() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_no_modify
+BEFORE UPDATE OR DELETE ON audit_log
+FOR EACH ROW EXECUTE FUNCTION audit_log_immutable();
@@ -0,0 +1,7 @@
+DROP INDEX idx_activity_country_id;
+
+ALTER TABLE country
+DROP COLUMN languages,
+DROP COLUMN timezone,
+DROP COLUMN currency,
+DROP COLUMN iso_code;
@@ -0,0 +1,8 @@
+ALTER TABLE country
+ADD COLUMN iso_code CHAR(2) UNIQUE,
+ADD COLUMN currency CHAR(3),
+ADD COLUMN timezone VARCHAR(64),
+ADD COLUMN languages TEXT[] NOT NULL DEFAULT '{}';
+
+
+CREATE INDEX idx_activity_country_id ON activity(country_id);
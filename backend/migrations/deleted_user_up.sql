@@ -0,0 +1,4 @@
+-- takes over the payments and shares of purged users so the trips they were on keep every expense.
+-- The sequence never hands out id 0, and the account is deleted so it cannot sign in or be found.
+INSERT INTO users (id, username, name, email, password, deleted_at)
+VALUES (0, 'Deleted user', 'Deleted user', 'deleted-user@invalid', '', NOW());
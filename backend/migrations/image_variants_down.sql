@@ -0,0 +1,9 @@
+DROP TABLE image_variant;
+
+DROP INDEX idx_images_unprocessed;
+
+ALTER TABLE images
+DROP COLUMN processing_error,
+DROP COLUMN processing_attempts,
+DROP COLUMN processing_started_at,
+DROP COLUMN processed_at;
@@ -0,0 +1,18 @@
+ALTER TABLE images
+ADD COLUMN processed_at TIMESTAMPTZ,
+ADD COLUMN processing_started_at TIMESTAMPTZ,
+ADD COLUMN processing_attempts INT NOT NULL DEFAULT 0,
+ADD COLUMN processing_error TEXT;
+
+CREATE INDEX idx_images_unprocessed ON images(id) WHERE status = 'ready' AND processed_at IS NULL;
+
+CREATE TABLE image_variant (
+    image_id INT NOT NULL REFERENCES images(id) ON DELETE CASCADE,
+    name VARCHAR(20) NOT NULL,
+    object_key TEXT NOT NULL UNIQUE,
+    width INT NOT NULL CHECK (width > 0),
+    height INT NOT NULL CHECK (height > 0),
+    size_bytes BIGINT NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    PRIMARY KEY (image_id, name)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE images (
+    id SERIAL PRIMARY KEY,
+    object_key TEXT NOT NULL UNIQUE,
+    owner_type VARCHAR(20) NOT NULL CHECK (owner_type IN ('activity', 'itinerary')),
+    owner_id INT NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    size_bytes BIGINT NOT NULL CHECK (size_bytes > 0),
+    -- pending until the client confirms the upload and the object passes verification
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready')),
+    uploaded_by TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    confirmed_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_images_owner ON images(owner_type, owner_id) WHERE status = 'ready';
+CREATE INDEX idx_images_pending_created ON images(created_at) WHERE status = 'pending';
@@ -0,0 +1,19 @@
+CREATE TABLE import_job (
+    id SERIAL PRIMARY KEY,
+    format VARCHAR(10) NOT NULL CHECK (format IN ('csv', 'json')),
+    status VARCHAR(10) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'failed')),
+    processed_rows INT NOT NULL DEFAULT 0,
+    created_rows INT NOT NULL DEFAULT 0,
+    updated_rows INT NOT NULL DEFAULT 0,
+    failed_rows INT NOT NULL DEFAULT 0,
+    -- per-row errors, capped so a badly broken file cannot bloat the row
+    row_errors JSONB NOT NULL DEFAULT '[]',
+    -- set when the whole job fails, e.g. on an unreadable header
+    error TEXT,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_import_job_unfinished ON import_job(id) WHERE status IN ('queued', 'running');
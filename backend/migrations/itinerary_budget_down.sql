@@ -0,0 +1,6 @@
+DROP TABLE itinerary_expense;
+
+ALTER TABLE itinerary
+DROP CONSTRAINT itinerary_budget_currency,
+DROP COLUMN budget_minor,
+DROP COLUMN budget_currency;
@@ -0,0 +1,22 @@
+-- an itinerary's budget, both NULL when none is set
+ALTER TABLE itinerary
+ADD COLUMN budget_minor BIGINT CHECK (budget_minor >= 0),
+ADD COLUMN budget_currency CHAR(3) REFERENCES currency(code),
+ADD CONSTRAINT itinerary_budget_currency CHECK ((budget_minor IS NULL) = (budget_currency IS NULL));
+
+-- money actually spent on a trip, spent_on may fall outside the trip for things booked ahead
+CREATE TABLE itinerary_expense (
+    id SERIAL PRIMARY KEY,
+    itinerary_id INT NOT NULL REFERENCES itinerary(id) ON DELETE CASCADE,
+    category VARCHAR(20) NOT NULL CHECK (category IN ('accommodation', 'transport', 'food', 'activities', 'shopping', 'other')),
+    description TEXT NOT NULL DEFAULT '',
+    amount_minor BIGINT NOT NULL CHECK (amount_minor > 0),
+    currency CHAR(3) NOT NULL REFERENCES currency(code),
+    paid_by INT NOT NULL REFERENCES users(id),
+    spent_on DATE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_itinerary_expense_itinerary ON itinerary_expense(itinerary_id, spent_on);
+CREATE INDEX idx_itinerary_expense_paid_by ON itinerary_expense(paid_by);
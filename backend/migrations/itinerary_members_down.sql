@@ -0,0 +1,6 @@
+DROP TABLE itinerary_expense_share;
+
+ALTER TABLE itinerary_expense
+DROP COLUMN split_method;
+
+DROP TABLE itinerary_member;
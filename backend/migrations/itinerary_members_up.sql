@@ -0,0 +1,35 @@
+-- travellers sharing an itinerary, the owner is always one of them
+CREATE TABLE itinerary_member (
+    itinerary_id INT NOT NULL REFERENCES itinerary(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (itinerary_id, user_id)
+);
+
+CREATE INDEX idx_itinerary_member_user ON itinerary_member(user_id);
+
+INSERT INTO itinerary_member (itinerary_id, user_id)
+SELECT id, user_id FROM itinerary WHERE user_id IS NOT NULL;
+
+-- how an expense is divided, amount_minor is each member's part in the expense's currency and the
+-- parts add up to the expense amount. shares is only meaningful for the 'shares' method.
+ALTER TABLE itinerary_expense
+ADD COLUMN split_method VARCHAR(10) NOT NULL DEFAULT 'equal' CHECK (split_method IN ('equal', 'shares', 'exact'));
+
+CREATE TABLE itinerary_expense_share (
+    expense_id INT NOT NULL REFERENCES itinerary_expense(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id),
+    shares INT NOT NULL DEFAULT 1 CHECK (shares > 0),
+    amount_minor BIGINT NOT NULL CHECK (amount_minor >= 0),
+    PRIMARY KEY (expense_id, user_id)
+);
+
+CREATE INDEX idx_itinerary_expense_share_user ON itinerary_expense_share(user_id);
+
+-- expenses recorded before splitting were the payer's alone
+INSERT INTO itinerary_expense_share (expense_id, user_id, amount_minor)
+SELECT id, paid_by, amount_minor FROM itinerary_expense;
+
+INSERT INTO itinerary_member (itinerary_id, user_id)
+SELECT DISTINCT itinerary_id, paid_by FROM itinerary_expense
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,6 @@
+DROP TABLE itinerary_invitation;
+
+DROP INDEX idx_itinerary_member_owner;
+
+ALTER TABLE itinerary_member
+DROP COLUMN role;
@@ -0,0 +1,25 @@
+-- what a member may do: owners manage the trip and its members, editors change the plan and
+-- expenses, viewers only read. Every itinerary has exactly one owner, matching itinerary.user_id.
+ALTER TABLE itinerary_member
+ADD COLUMN role VARCHAR(10) NOT NULL DEFAULT 'editor' CHECK (role IN ('owner', 'editor', 'viewer'));
+
+UPDATE itinerary_member m SET role = 'owner'
+FROM itinerary i
+WHERE i.id = m.itinerary_id AND i.user_id = m.user_id;
+
+CREATE UNIQUE INDEX idx_itinerary_member_owner ON itinerary_member(itinerary_id) WHERE role = 'owner';
+
+-- invitations to join an itinerary, a user has at most one pending invitation per itinerary
+CREATE TABLE itinerary_invitation (
+    id SERIAL PRIMARY KEY,
+    itinerary_id INT NOT NULL REFERENCES itinerary(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(10) NOT NULL CHECK (role IN ('editor', 'viewer')),
+    invited_by INT REFERENCES users(id) ON DELETE SET NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'cancelled')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    responded_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX idx_itinerary_invitation_pending ON itinerary_invitation(itinerary_id, user_id) WHERE status = 'pending';
+CREATE INDEX idx_itinerary_invitation_user ON itinerary_invitation(user_id) WHERE status = 'pending';
@@ -0,0 +1,19 @@
+DROP INDEX idx_itinerary_activity_schedule;
+
+-- an activity scheduled on several days collapses back into a single row
+DELETE FROM itinerary_activity ia USING itinerary_activity dup
+WHERE dup.itinerary_id = ia.itinerary_id AND dup.activity_id = ia.activity_id AND dup.id < ia.id;
+
+ALTER TABLE itinerary_activity
+DROP CONSTRAINT itinerary_activity_pkey,
+DROP CONSTRAINT itinerary_activity_time_order,
+DROP CONSTRAINT itinerary_activity_time_day,
+DROP COLUMN id,
+DROP COLUMN day,
+DROP COLUMN start_time,
+DROP COLUMN end_time,
+DROP COLUMN position,
+DROP COLUMN notes;
+
+ALTER TABLE itinerary_activity
+ADD PRIMARY KEY (itinerary_id, activity_id);
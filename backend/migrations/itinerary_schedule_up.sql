@@ -0,0 +1,21 @@
+-- itinerary items get their own id so the same activity can be visited on more than one day.
+-- day is NULL for items not scheduled yet, times are local to the trip.
+ALTER TABLE itinerary_activity
+DROP CONSTRAINT itinerary_activity_pkey;
+
+ALTER TABLE itinerary_activity
+ADD COLUMN id SERIAL PRIMARY KEY,
+ADD COLUMN day DATE,
+ADD COLUMN start_time TIME,
+ADD COLUMN end_time TIME,
+ADD COLUMN position INT NOT NULL DEFAULT 0,
+ADD COLUMN notes TEXT NOT NULL DEFAULT '',
+ADD CONSTRAINT itinerary_activity_time_order CHECK (start_time IS NULL OR end_time IS NULL OR end_time > start_time),
+ADD CONSTRAINT itinerary_activity_time_day CHECK (day IS NOT NULL OR (start_time IS NULL AND end_time IS NULL));
+
+-- keep the order existing items were added in
+UPDATE itinerary_activity ia SET position = o.position
+FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY itinerary_id ORDER BY id) - 1 AS position FROM itinerary_activity) o
+WHERE o.id = ia.id;
+
+CREATE INDEX idx_itinerary_activity_schedule ON itinerary_activity(itinerary_id, day, position);
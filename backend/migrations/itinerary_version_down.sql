@@ -0,0 +1,2 @@
+ALTER TABLE itinerary
+DROP COLUMN version;
@@ -0,0 +1,6 @@
+DROP FUNCTION activity_available(INT, DATE, DATE);
+DROP FUNCTION activity_open_on(INT, DATE);
+
+DROP TABLE activity_closure;
+DROP TABLE activity_hours_exception;
+DROP TABLE activity_hours;
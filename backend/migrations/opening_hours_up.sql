@@ -0,0 +1,61 @@
+-- weekly opening hours in the local time of the activity's country, weekday 0 is sunday.
+-- closes at or before opens means the activity stays open past midnight.
+CREATE TABLE activity_hours (
+    id SERIAL PRIMARY KEY,
+    activity_id INT NOT NULL REFERENCES activity(id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    opens TIME NOT NULL,
+    closes TIME NOT NULL
+);
+
+CREATE INDEX idx_activity_hours_activity_id ON activity_hours(activity_id, weekday);
+
+-- one-off changes for a single date, such as public holidays, they override the weekly hours
+-- and seasonal closures. A row without opens and closes means closed all day.
+CREATE TABLE activity_hours_exception (
+    activity_id INT NOT NULL REFERENCES activity(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    opens TIME,
+    closes TIME,
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    PRIMARY KEY (activity_id, day),
+    CHECK ((opens IS NULL) = (closes IS NULL))
+);
+
+-- closures recurring every year between two MM-DD days inclusive, wrapping over new year when
+-- start_day is after end_day
+CREATE TABLE activity_closure (
+    id SERIAL PRIMARY KEY,
+    activity_id INT NOT NULL REFERENCES activity(id) ON DELETE CASCADE,
+    start_day CHAR(5) NOT NULL CHECK (start_day ~ '^(0[1-9]|1[0-2])-(0[1-9]|[12][0-9]|3[01])$'),
+    end_day CHAR(5) NOT NULL CHECK (end_day ~ '^(0[1-9]|1[0-2])-(0[1-9]|[12][0-9]|3[01])$'),
+    note VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_activity_closure_activity_id ON activity_closure(activity_id);
+
+-- whether the activity opens at all on a local date. Activities without weekly hours have an
+-- unknown schedule and count as open unless a closure or exception says otherwise.
+-- Mirrors schedule.OpenOn in the backend.
+CREATE FUNCTION activity_open_on(p_activity_id INT, p_day DATE) RETURNS BOOLEAN AS $$
+    SELECT COALESCE(
+        (SELECT e.opens IS NOT NULL FROM activity_hours_exception e WHERE e.activity_id = p_activity_id AND e.day = p_day),
+        NOT EXISTS (
+            SELECT 1 FROM activity_closure c WHERE c.activity_id = p_activity_id AND CASE
+                WHEN c.start_day <= c.end_day THEN TO_CHAR(p_day, 'MM-DD') BETWEEN c.start_day AND c.end_day
+                ELSE TO_CHAR(p_day, 'MM-DD') >= c.start_day OR TO_CHAR(p_day, 'MM-DD') <= c.end_day
+            END
+        ) AND (
+            NOT EXISTS (SELECT 1 FROM activity_hours h WHERE h.activity_id = p_activity_id)
+            OR EXISTS (SELECT 1 FROM activity_hours h WHERE h.activity_id = p_activity_id AND h.weekday = EXTRACT(DOW FROM p_day))
+        )
+    )
+$$ LANGUAGE SQL STABLE;
+
+-- whether the activity opens on at least one day of a date range
+CREATE FUNCTION activity_available(p_activity_id INT, p_from DATE, p_to DATE) RETURNS BOOLEAN AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM generate_series(p_from, p_to, INTERVAL '1 day') d(day)
+        WHERE activity_open_on(p_activity_id, d.day::DATE)
+    )
+$$ LANGUAGE SQL STABLE;
@@ -0,0 +1,16 @@
+DROP INDEX idx_activity_deleted_at;
+DROP INDEX idx_itinerary_deleted_at;
+DROP INDEX idx_users_deleted_at;
+
+ALTER TABLE itinerary_activity
+DROP CONSTRAINT itinerary_activity_activity_id_fkey,
+ADD CONSTRAINT itinerary_activity_activity_id_fkey FOREIGN KEY (activity_id) REFERENCES activity(id) ON DELETE CASCADE;
+
+ALTER TABLE activity
+DROP COLUMN deleted_at;
+
+ALTER TABLE itinerary
+DROP COLUMN deleted_at;
+
+ALTER TABLE users
+DROP COLUMN deleted_at;
@@ -0,0 +1,19 @@
+ALTER TABLE users
+ADD COLUMN deleted_at TIMESTAMP;
+
+ALTER TABLE itinerary
+ADD COLUMN deleted_at TIMESTAMP;
+
+ALTER TABLE activity
+ADD COLUMN deleted_at TIMESTAMP;
+
+
+-- purging an activity must never silently strip it from someone's trip
+ALTER TABLE itinerary_activity
+DROP CONSTRAINT itinerary_activity_activity_id_fkey,
+ADD CONSTRAINT itinerary_activity_activity_id_fkey FOREIGN KEY (activity_id) REFERENCES activity(id) ON DELETE RESTRICT;
+
+
+CREATE INDEX idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_itinerary_deleted_at ON itinerary(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_activity_deleted_at ON activity(deleted_at) WHERE deleted_at IS NOT NULL;
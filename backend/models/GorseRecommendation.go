@@ -2,5 +2,5 @@ package models
 
 type GorseRecommendation struct {
 	ItemId string  `json:"id" db:"id"`
-	Score  float64 `json: "Score"`
+	Score  float64 `json:"Score"`
 }
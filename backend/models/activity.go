@@ -1,11 +1,54 @@
 package models
 
+import "time"
+
 type Activity struct {
-	ID        int    `json:"id" db:"id"`
-	Name      string `json:"name" db:"name"`
-	Title     string `json:"title" db:"title"`
-	ImageURL  string `json:"imageurl" db:"imageurl"`
-	CountryID string `json:"countryid" db:"countryid"`
-	Address   string `json:"address" db:"address"`
-	Price     int    `json:"price" db:"price"`
+	ID       int    `json:"id" db:"id"`
+	Name     string `json:"name" db:"name"`
+	Title    string `json:"title" db:"title"`
+	ImageURL string `json:"imageurl" db:"imageurl"`
+	// resized copies of the cover image keyed by width descriptor, e.g. "320w"
+	ImageSrcset map[string]string `json:"image_srcset,omitempty" db:"-"`
+	CountryID   int               `json:"countryid" db:"country_id"`
+	Country     *Country          `json:"country,omitempty" db:"-"`
+	Address     string            `json:"address" db:"address"`
+	Latitude    *float64          `json:"latitude,omitempty" db:"latitude"`
+	Longitude   *float64          `json:"longitude,omitempty" db:"longitude"`
+	// price in major units of Currency, derived from PriceMinor
+	Price      float64 `json:"price" db:"-"`
+	PriceMinor int64   `json:"price_minor" db:"price_minor"`
+	Currency   string  `json:"currency" db:"currency"`
+	// filled in when the request asks for prices in another currency
+	ConvertedPrice *ConvertedPrice `json:"converted_price,omitempty" db:"-"`
+	Rating         float64         `json:"rating" db:"rating"`
+	ReviewCount    int             `json:"review_count" db:"review_count"`
+	// category slugs and tag names, maintained through the activity_category and activity_tag tables
+	Categories []string   `json:"categories" db:"-"`
+	Tags       []string   `json:"tags" db:"-"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+type ActivitySearchFilter struct {
+	ActivityTaxonomyFilter
+	Query string
+	// price bounds in major units of PriceCurrency
+	MinPrice      *float64
+	MaxPrice      *float64
+	PriceCurrency string
+	MinRating     *float64
+	CountryID     int
+	CountryCode   string
+	Limit         int
+	Offset        int
+}
+
+type ActivitySearchResult struct {
+	Activity
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type NearbyActivity struct {
+	Activity
+	DistanceMeters float64 `json:"distance_meters"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditLog struct {
+	ID        int64           `json:"id" db:"id"`
+	Actor     string          `json:"actor" db:"actor"`
+	Entity    string          `json:"entity" db:"entity"`
+	EntityID  *int            `json:"entity_id,omitempty" db:"entity_id"`
+	Action    string          `json:"action" db:"action"`
+	Before    json.RawMessage `json:"before,omitempty" db:"before"`
+	After     json.RawMessage `json:"after,omitempty" db:"after"`
+	Diff      json.RawMessage `json:"diff,omitempty" db:"diff"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+type AuditFilter struct {
+	Entity   string
+	EntityID int
+	Actor    string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+}
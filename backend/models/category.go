@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Category is a node in the activity taxonomy, top level categories have no parent
+type Category struct {
+	ID       int         `json:"id" db:"id"`
+	Slug     string      `json:"slug" db:"slug"`
+	Name     string      `json:"name" db:"name"`
+	ParentID *int        `json:"parent_id,omitempty" db:"parent_id"`
+	Children []*Category `json:"children,omitempty" db:"-"`
+}
+
+// ActivityTaxonomyFilter narrows activity listings. Category matches the category and all of
+// its descendants, every tag in Tags must be present.
+type ActivityTaxonomyFilter struct {
+	Category string
+	Tags     []string
+}
+
+type ActivityListFilter struct {
+	ActivityTaxonomyFilter
+	// when set, only activities open on at least one day in the range are listed
+	AvailableFrom *time.Time
+	AvailableTo   *time.Time
+	Limit         int
+	Offset        int
+}
+
+type GorseItem struct {
+	ItemId     string   `json:"ItemId"`
+	IsHidden   bool     `json:"IsHidden"`
+	Categories []string `json:"Categories"`
+	Timestamp  string   `json:"Timestamp"`
+	Labels     []string `json:"Labels"`
+	Comment    string   `json:"Comment"`
+}
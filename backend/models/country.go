@@ -0,0 +1,10 @@
+package models
+
+type Country struct {
+	ID        int      `json:"id" db:"id"`
+	Name      string   `json:"name" db:"name"`
+	ISOCode   string   `json:"iso_code" db:"iso_code"`
+	Currency  string   `json:"currency" db:"currency"`
+	Timezone  string   `json:"timezone" db:"timezone"`
+	Languages []string `json:"languages" db:"languages"`
+}
@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+const (
+	ExpenseAccommodation = "accommodation"
+	ExpenseTransport     = "transport"
+	ExpenseFood          = "food"
+	ExpenseActivities    = "activities"
+	ExpenseShopping      = "shopping"
+	ExpenseOther         = "other"
+)
+
+// ExpenseCategories lists every expense category, matching the itinerary_expense check constraint
+var ExpenseCategories = []string{ExpenseAccommodation, ExpenseTransport, ExpenseFood, ExpenseActivities, ExpenseShopping, ExpenseOther}
+
+// Expense is money spent on a trip by one of its travellers
+type Expense struct {
+	ID          int    `json:"id" db:"id"`
+	ItineraryID int    `json:"itinerary_id" db:"itinerary_id"`
+	Category    string `json:"category" db:"category"`
+	Description string `json:"description" db:"description"`
+	// amount in major units of Currency, derived from AmountMinor
+	Amount         float64         `json:"amount" db:"-"`
+	AmountMinor    int64           `json:"amount_minor" db:"amount_minor"`
+	Currency       string          `json:"currency" db:"currency"`
+	ConvertedPrice *ConvertedPrice `json:"converted_price,omitempty" db:"-"`
+	PaidBy         int             `json:"paid_by" db:"paid_by"`
+	PaidByName     string          `json:"paid_by_name" db:"-"`
+	// equal, shares or exact, Shares says what each member owes of it
+	SplitMethod string         `json:"split_method" db:"split_method"`
+	Shares      []ExpenseShare `json:"shares" db:"-"`
+	// "2006-01-02"
+	Date      string    `json:"date" db:"spent_on"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+const (
+	SplitEqual  = "equal"
+	SplitShares = "shares"
+	SplitExact  = "exact"
+)
+
+// ExpenseShare is one member's part of an expense, in minor units of the expense's currency
+type ExpenseShare struct {
+	UserID      int     `json:"user_id" db:"user_id"`
+	Username    string  `json:"username" db:"-"`
+	Shares      int     `json:"shares" db:"shares"`
+	AmountMinor int64   `json:"amount_minor" db:"amount_minor"`
+	Amount      float64 `json:"amount" db:"-"`
+}
+
+// ItineraryBudget is how much a trip may cost, AmountMinor is nil when no budget is set
+type ItineraryBudget struct {
+	ItineraryID int    `json:"itinerary_id"`
+	AmountMinor *int64 `json:"amount_minor"`
+	Currency    string `json:"currency"`
+}
+
+// BudgetLine compares planned and actual spend, in minor units of the summary's currency
+type BudgetLine struct {
+	// the date or expense category the line is for
+	Key            string `json:"key"`
+	EstimatedMinor int64  `json:"estimated_minor"`
+	ActualMinor    int64  `json:"actual_minor"`
+}
+
+const (
+	BudgetAlertPlanned     = "planned_over_budget"
+	BudgetAlertActual      = "spent_over_budget"
+	BudgetAlertMissingRate = "missing_rate"
+)
+
+type BudgetAlert struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// BudgetSummary sets an itinerary's budget against the estimated cost of its scheduled activities
+// and the expenses recorded, all in Currency. Planned spend takes the larger of the estimate and
+// the actual spend for each category, since tickets bought show up as activity expenses.
+type BudgetSummary struct {
+	ItineraryID    int           `json:"itinerary_id"`
+	Currency       string        `json:"currency"`
+	MinorUnits     int           `json:"minor_units"`
+	BudgetMinor    *int64        `json:"budget_minor"`
+	EstimatedMinor int64         `json:"estimated_minor"`
+	ActualMinor    int64         `json:"actual_minor"`
+	PlannedMinor   int64         `json:"planned_minor"`
+	Days           []BudgetLine  `json:"days"`
+	Categories     []BudgetLine  `json:"categories"`
+	RateDate       string        `json:"rate_date,omitempty"`
+	Alerts         []BudgetAlert `json:"alerts"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+const (
+	ImageOwnerActivity  = "activity"
+	ImageOwnerItinerary = "itinerary"
+
+	ImagePending = "pending"
+	ImageReady   = "ready"
+)
+
+type Image struct {
+	ID          int            `json:"id" db:"id"`
+	ObjectKey   string         `json:"object_key" db:"object_key"`
+	OwnerType   string         `json:"owner_type" db:"owner_type"`
+	OwnerID     int            `json:"owner_id" db:"owner_id"`
+	ContentType string         `json:"content_type" db:"content_type"`
+	SizeBytes   int64          `json:"size_bytes" db:"size_bytes"`
+	Status      string         `json:"status" db:"status"`
+	UploadedBy  string         `json:"uploaded_by" db:"uploaded_by"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+	ConfirmedAt *time.Time     `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	ProcessedAt *time.Time     `json:"processed_at,omitempty" db:"processed_at"`
+	Variants    []ImageVariant `json:"variants,omitempty" db:"-"`
+	// presigned GET url, filled in by the handler
+	URL string `json:"url,omitempty" db:"-"`
+}
+
+// ImageVariant is a resized copy of an image made by the image worker
+type ImageVariant struct {
+	Name        string `json:"name" db:"name"`
+	ObjectKey   string `json:"object_key" db:"object_key"`
+	Width       int    `json:"width" db:"width"`
+	Height      int    `json:"height" db:"height"`
+	SizeBytes   int64  `json:"size_bytes" db:"size_bytes"`
+	ContentType string `json:"content_type" db:"content_type"`
+	URL         string `json:"url,omitempty" db:"-"`
+}
+
+// StorageGCReport describes one storage garbage collection run. In a dry run Removed lists
+// what would have been deleted and nothing is changed.
+type StorageGCReport struct {
+	DryRun          bool            `json:"dry_run"`
+	StartedAt       time.Time       `json:"started_at"`
+	FinishedAt      time.Time       `json:"finished_at"`
+	Scanned         int             `json:"scanned"`
+	Referenced      int             `json:"referenced"`
+	DiscardedImages []int           `json:"discarded_images"`
+	Removed         []RemovedObject `json:"removed"`
+	RemovedBytes    int64           `json:"removed_bytes"`
+	Failed          []string        `json:"failed,omitempty"`
+}
+
+type RemovedObject struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportSucceeded = "succeeded"
+	ImportFailed    = "failed"
+)
+
+// ImportJob tracks a bulk activity import running in the background
+type ImportJob struct {
+	ID        int              `json:"id" db:"id"`
+	Format    string           `json:"format" db:"format"`
+	Status    string           `json:"status" db:"status"`
+	Processed int              `json:"processed_rows" db:"processed_rows"`
+	Created   int              `json:"created_rows" db:"created_rows"`
+	Updated   int              `json:"updated_rows" db:"updated_rows"`
+	Failed    int              `json:"failed_rows" db:"failed_rows"`
+	RowErrors []ImportRowError `json:"row_errors" db:"row_errors"`
+	Error     string           `json:"error,omitempty" db:"error"`
+	CreatedBy string           `json:"created_by" db:"created_by"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+	StartedAt *time.Time       `json:"started_at,omitempty" db:"started_at"`
+	// set once the job succeeded or failed
+	FinishedAt *time.Time `json:"finished_at,omitempty" db:"finished_at"`
+}
+
+// ImportRowError explains why a row was skipped, Row counts data rows from 1
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Title string `json:"title,omitempty"`
+	Error string `json:"error"`
+}
+
+// ActivityImportRow is one activity in an import or export file. Country is a country name or
+// ISO code, Price is in major units of Currency, and existing activities are matched by Title.
+type ActivityImportRow struct {
+	Title      string   `json:"title"`
+	Name       string   `json:"name"`
+	Price      float64  `json:"price"`
+	Currency   string   `json:"currency"`
+	Address    string   `json:"address"`
+	Country    string   `json:"country"`
+	Latitude   *float64 `json:"latitude"`
+	Longitude  *float64 `json:"longitude"`
+	ImageURL   string   `json:"imageurl"`
+	Categories []string `json:"categories"`
+	Tags       []string `json:"tags"`
+}
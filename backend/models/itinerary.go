@@ -11,4 +11,12 @@ type Itinerary struct {
 	Start_date  time.Time  `json:"start_date" db:"start_date"`
 	End_date    time.Time  `json:"end_date" db:"end_date"`
 	Created_by  User       `json:"created_by" db:"created_by"`
+	Version     int        `json:"version" db:"version"`
+	// the caller's role when listing the itineraries they are a member of
+	Role string `json:"role,omitempty" db:"-"`
+	// sum of the activity prices, only set when prices are converted to a single currency
+	TotalPrice *ConvertedPrice `json:"total_price,omitempty" db:"-"`
+	Deleted_at *time.Time      `json:"deleted_at,omitempty" db:"deleted_at"`
+	// feasibility problems in the day by day schedule
+	Warnings []ScheduleWarning `json:"warnings,omitempty" db:"-"`
 }
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// itinerary event types sent to everyone viewing an itinerary
+const (
+	EventItemAdded        = "item_added"
+	EventItemUpdated      = "item_updated"
+	EventItemsReordered   = "items_reordered"
+	EventItemRemoved      = "item_removed"
+	EventItineraryUpdated = "itinerary_updated"
+	EventPresence         = "presence"
+)
+
+// ItineraryEvent is a change to an itinerary or to who is viewing it. Only the fields of its
+// type are set.
+type ItineraryEvent struct {
+	Type        string `json:"type"`
+	ItineraryID int    `json:"itinerary_id"`
+	// the member who made the change
+	UserID   int    `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	// item_added and item_updated
+	Item *ItineraryItem `json:"item,omitempty"`
+	// item_removed
+	ItemID int `json:"item_id,omitempty"`
+	// items_reordered, a null day is the unscheduled items
+	Day     *string `json:"day,omitempty"`
+	ItemIDs []int   `json:"item_ids,omitempty"`
+	// itinerary_updated
+	Version int `json:"version,omitempty"`
+	// presence, everyone viewing the itinerary on any instance
+	Viewers []ItineraryViewer `json:"viewers,omitempty"`
+	At      time.Time         `json:"at"`
+}
+
+// ItineraryViewer is a member with the itinerary open, in one or more tabs
+type ItineraryViewer struct {
+	UserID      int    `json:"user_id"`
+	Username    string `json:"username"`
+	Connections int    `json:"connections"`
+}
@@ -0,0 +1,68 @@
+package models
+
+// ItineraryItem is one visit to an activity within an itinerary. Day is "2006-01-02" or nil while
+// the item is not scheduled yet, StartTime and EndTime are optional "15:04" local times.
+type ItineraryItem struct {
+	ID          int       `json:"id" db:"id"`
+	ItineraryID int       `json:"itinerary_id" db:"itinerary_id"`
+	ActivityID  int       `json:"activity_id" db:"activity_id"`
+	Day         *string   `json:"day" db:"day"`
+	StartTime   string    `json:"start_time,omitempty" db:"start_time"`
+	EndTime     string    `json:"end_time,omitempty" db:"end_time"`
+	Position    int       `json:"position" db:"position"`
+	Notes       string    `json:"notes" db:"notes"`
+	Activity    *Activity `json:"activity,omitempty" db:"-"`
+}
+
+// ItineraryDay is the ordered plan for one date of a trip
+type ItineraryDay struct {
+	Date  string          `json:"date"`
+	Items []ItineraryItem `json:"items"`
+}
+
+// ItinerarySchedule lays out an itinerary day by day, every trip date is present even when empty
+type ItinerarySchedule struct {
+	ItineraryID int             `json:"itinerary_id"`
+	StartDate   string          `json:"start_date"`
+	EndDate     string          `json:"end_date"`
+	Days        []ItineraryDay  `json:"days"`
+	Unscheduled []ItineraryItem `json:"unscheduled"`
+}
+
+// ItineraryDraft is a generated itinerary that has not been saved yet. Its fields match the create
+// itinerary request so a client can post it back to save it.
+type ItineraryDraft struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	StartDate   string         `json:"start_date"`
+	EndDate     string         `json:"end_date"`
+	Days        []ItineraryDay `json:"days"`
+	// the budget and what the plan costs in the budget's currency
+	Budget    *ConvertedPrice   `json:"budget,omitempty"`
+	TotalCost ConvertedPrice    `json:"total_cost"`
+	Warnings  []ScheduleWarning `json:"warnings"`
+}
+
+// RouteStop is one stop of a proposed route, LegMeters is the distance from the stop before it
+type RouteStop struct {
+	ItemID     int    `json:"item_id"`
+	ActivityID int    `json:"activity_id"`
+	Title      string `json:"title"`
+	// items with a start time keep their order relative to each other
+	Fixed     bool    `json:"fixed"`
+	LegMeters float64 `json:"leg_meters"`
+}
+
+// RouteProposal is a suggested order for the items of one day. It is accepted by reordering the
+// day's items to ItemIDs.
+type RouteProposal struct {
+	ItineraryID   int         `json:"itinerary_id"`
+	Day           string      `json:"day"`
+	ItemIDs       []int       `json:"item_ids"`
+	Stops         []RouteStop `json:"stops"`
+	TotalMeters   float64     `json:"total_meters"`
+	CurrentMeters float64     `json:"current_meters"`
+	TravelMinutes int         `json:"travel_minutes"`
+	// false when the day was too long to try every order
+	Exact bool `json:"exact"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// member roles from most to least trusted
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var roleRanks = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// RoleAllows reports whether a member with role can do what needs at least need
+func RoleAllows(role, need string) bool {
+	return roleRanks[role] > 0 && roleRanks[role] >= roleRanks[need]
+}
+
+// ItineraryMember is a traveller sharing an itinerary
+type ItineraryMember struct {
+	ItineraryID int       `json:"itinerary_id" db:"itinerary_id"`
+	UserID      int       `json:"user_id" db:"user_id"`
+	Username    string    `json:"username" db:"-"`
+	Role        string    `json:"role" db:"role"`
+	JoinedAt    time.Time `json:"joined_at" db:"joined_at"`
+}
+
+const (
+	InvitationPending   = "pending"
+	InvitationAccepted  = "accepted"
+	InvitationDeclined  = "declined"
+	InvitationCancelled = "cancelled"
+)
+
+// ItineraryInvitation asks a user to join an itinerary as an editor or viewer
+type ItineraryInvitation struct {
+	ID             int    `json:"id" db:"id"`
+	ItineraryID    int    `json:"itinerary_id" db:"itinerary_id"`
+	ItineraryTitle string `json:"itinerary_title" db:"-"`
+	UserID         int    `json:"user_id" db:"user_id"`
+	Username       string `json:"username" db:"-"`
+	Role           string `json:"role" db:"role"`
+	// nil once the inviter's account is gone
+	InvitedBy     *int       `json:"invited_by" db:"invited_by"`
+	InvitedByName string     `json:"invited_by_name" db:"-"`
+	Status        string     `json:"status" db:"status"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	RespondedAt   *time.Time `json:"responded_at,omitempty" db:"responded_at"`
+}
+
+// MemberBalance is what a member paid for the group against their own share of it, Balance is
+// positive when the rest of the group owes them
+type MemberBalance struct {
+	UserID       int    `json:"user_id"`
+	Username     string `json:"username"`
+	PaidMinor    int64  `json:"paid_minor"`
+	OwedMinor    int64  `json:"owed_minor"`
+	BalanceMinor int64  `json:"balance_minor"`
+}
+
+// Settlement is a payment that squares up part of the group's balances
+type Settlement struct {
+	FromUserID   int     `json:"from_user_id"`
+	FromUsername string  `json:"from_username"`
+	ToUserID     int     `json:"to_user_id"`
+	ToUsername   string  `json:"to_username"`
+	AmountMinor  int64   `json:"amount_minor"`
+	Amount       float64 `json:"amount"`
+}
+
+// ItineraryBalances is where a group stands after its expenses, all in minor units of Currency
+type ItineraryBalances struct {
+	ItineraryID int             `json:"itinerary_id"`
+	Currency    string          `json:"currency"`
+	MinorUnits  int             `json:"minor_units"`
+	Balances    []MemberBalance `json:"balances"`
+	Settlements []Settlement    `json:"settlements"`
+	// expenses left out because there is no rate to convert them
+	Skipped  int    `json:"skipped"`
+	RateDate string `json:"rate_date,omitempty"`
+}
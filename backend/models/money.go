@@ -0,0 +1,25 @@
+package models
+
+// Currency is an ISO 4217 currency, MinorUnits is its number of decimal places
+type Currency struct {
+	Code       string `json:"code" db:"code"`
+	MinorUnits int    `json:"minor_units" db:"minor_units"`
+}
+
+// ExchangeRates is a snapshot of rates published on Date, each rate is units of the currency per
+// one unit of Base. It matches the JSON served by ECB based rate APIs such as frankfurter.
+type ExchangeRates struct {
+	Base  string             `json:"base"`
+	Date  string             `json:"date"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// ConvertedPrice is a price shown in the currency the user asked for
+type ConvertedPrice struct {
+	Currency    string  `json:"currency"`
+	Amount      float64 `json:"amount"`
+	AmountMinor int64   `json:"amount_minor"`
+	// rate from the activity's currency to Currency, left out of totals, and the date it was published
+	Rate     float64 `json:"rate,omitempty"`
+	RateDate string  `json:"rate_date"`
+}
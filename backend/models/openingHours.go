@@ -0,0 +1,35 @@
+package models
+
+// OpeningHours is when an activity can be visited, in the local time of its country's Timezone.
+// Without any Weekly hours the schedule is unknown and the activity counts as always open,
+// apart from its Closures and Exceptions.
+type OpeningHours struct {
+	ActivityID int                `json:"activity_id" db:"activity_id"`
+	Timezone   string             `json:"timezone" db:"-"`
+	Weekly     []WeeklyHours      `json:"weekly" db:"-"`
+	Exceptions []OpeningException `json:"exceptions" db:"-"`
+	Closures   []SeasonalClosure  `json:"closures" db:"-"`
+}
+
+// WeeklyHours opens every Weekday (0 is sunday) between Opens and Closes given as "15:04".
+// Closes at or before Opens means open past midnight into the next day.
+type WeeklyHours struct {
+	Weekday int    `json:"weekday" db:"weekday"`
+	Opens   string `json:"opens" db:"opens"`
+	Closes  string `json:"closes" db:"closes"`
+}
+
+// OpeningException replaces the usual hours on Date ("2006-01-02"), empty Opens and Closes mean closed
+type OpeningException struct {
+	Date   string `json:"date" db:"day"`
+	Opens  string `json:"opens,omitempty" db:"opens"`
+	Closes string `json:"closes,omitempty" db:"closes"`
+	Note   string `json:"note,omitempty" db:"note"`
+}
+
+// SeasonalClosure closes the activity every year from Start to End inclusive, both "01-02" month-days
+type SeasonalClosure struct {
+	Start string `json:"start" db:"start_day"`
+	End   string `json:"end" db:"end_day"`
+	Note  string `json:"note,omitempty" db:"note"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+type Review struct {
+	ID           int       `json:"id" db:"id"`
+	ActivityID   int       `json:"activity_id" db:"activity_id"`
+	UserID       int       `json:"user_id" db:"user_id"`
+	Username     string    `json:"username" db:"username"`
+	Rating       int       `json:"rating" db:"rating"`
+	Body         string    `json:"body" db:"body"`
+	HelpfulCount int       `json:"helpful_count" db:"helpful_count"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+const (
+	ReviewSortRecent     = "recent"
+	ReviewSortHelpful    = "helpful"
+	ReviewSortRatingHigh = "rating_high"
+	ReviewSortRatingLow  = "rating_low"
+)
+
+type ReviewFilter struct {
+	ActivityID int
+	Sort       string
+	Limit      int
+	Offset     int
+}
@@ -0,0 +1,16 @@
+package models
+
+const (
+	WarningOverlap       = "overlap"
+	WarningOutsideHours  = "outside_hours"
+	WarningTightGap      = "tight_gap"
+	WarningDayOverloaded = "day_overloaded"
+)
+
+// ScheduleWarning is a problem with an itinerary that does not stop it being saved
+type ScheduleWarning struct {
+	Kind    string `json:"kind"`
+	Day     string `json:"day"`
+	ItemIDs []int  `json:"item_ids"`
+	Message string `json:"message"`
+}
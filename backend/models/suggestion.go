@@ -0,0 +1,12 @@
+package models
+
+const (
+	SuggestionActivity = "activity"
+	SuggestionCountry  = "country"
+)
+
+type Suggestion struct {
+	Type string `json:"type"`
+	ID   int    `json:"id"`
+	Text string `json:"text"`
+}
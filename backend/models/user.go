@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type User struct {
 	ID          int         `json:"id" db:"id"`
 	UserID      int         `json:"user_id" db:"user_id"`
@@ -8,4 +10,7 @@ type User struct {
 	Email       string      `json:"email" db:"email"`
 	Password    string      `json:"password" db:"password"`
 	Personality Personality `json:"personality" db:"personality"`
+	// ISO 4217 code prices are converted to when a request does not name a currency
+	PreferredCurrency string     `json:"preferred_currency,omitempty" db:"preferred_currency"`
+	DeletedAt         *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
@@ -0,0 +1,34 @@
+// Package money converts between minor units, major units and currencies.
+package money
+
+import "math"
+
+// ValidCode reports whether code looks like an ISO 4217 code such as "USD"
+func ValidCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// ToMinor turns a major amount such as 12.34 into minor units, 1234 for a currency with 2 decimals
+func ToMinor(amount float64, minorUnits int) int64 {
+	return int64(math.Round(amount * math.Pow10(minorUnits)))
+}
+
+// ToMajor turns minor units back into a major amount
+func ToMajor(amountMinor int64, minorUnits int) float64 {
+	return float64(amountMinor) / math.Pow10(minorUnits)
+}
+
+// Convert changes an amount in minor units between currencies. Rates are units of each
+// currency per one unit of a shared base currency.
+func Convert(amountMinor int64, fromUnits int, fromRate float64, toUnits int, toRate float64) int64 {
+	base := ToMajor(amountMinor, fromUnits) / fromRate
+	return ToMinor(base*toRate, toUnits)
+}
@@ -0,0 +1,141 @@
+package money
+
+import "sort"
+
+// Split divides total minor units in proportion to weights so the parts add up to total exactly.
+// What is left after rounding down goes one unit at a time to the parts with the largest
+// remainders, earlier parts first on ties. Weights must be positive.
+func Split(total int64, weights []int64) []int64 {
+	parts := make([]int64, len(weights))
+	var sum int64
+	for _, w := range weights {
+		sum += w
+	}
+	if sum == 0 {
+		return parts
+	}
+
+	remainders := make([]int64, len(weights))
+	left := total
+	for i, w := range weights {
+		parts[i] = total * w / sum
+		remainders[i] = total * w % sum
+		left -= parts[i]
+	}
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for i := 0; left > 0; i++ {
+		parts[order[i%len(order)]]++
+		left--
+	}
+	return parts
+}
+
+// SplitEqual divides total into n parts differing by at most one minor unit
+func SplitEqual(total int64, n int) []int64 {
+	weights := make([]int64, n)
+	for i := range weights {
+		weights[i] = 1
+	}
+	return Split(total, weights)
+}
+
+// Transfer is a payment of AmountMinor from one person to another
+type Transfer struct {
+	From        int
+	To          int
+	AmountMinor int64
+}
+
+// exactSettleLimit is the most people with a balance settled with the fewest possible transfers,
+// the search over every subset doubles in cost with each person
+const exactSettleLimit = 16
+
+// Settle works out who pays whom to bring every balance to zero, balances being what each person
+// is owed (positive) or owes (negative) and adding up to zero. Up to exactSettleLimit people it
+// finds the fewest transfers by splitting the group into as many subgroups that settle among
+// themselves as possible, larger groups are settled greedily in at most one transfer less than
+// there are people.
+func Settle(balances map[int]int64) []Transfer {
+	var people []int
+	for id, b := range balances {
+		if b != 0 {
+			people = append(people, id)
+		}
+	}
+	sort.Ints(people)
+	if len(people) > exactSettleLimit {
+		return settleGreedy(people, balances)
+	}
+
+	// best[mask] is the most subgroups summing to zero the people in mask split into
+	n := len(people)
+	full := 1<<n - 1
+	sums := make([]int64, full+1)
+	best := make([]int, full+1)
+	from := make([]int, full+1)
+	for mask := 1; mask <= full; mask++ {
+		low := mask & -mask
+		i := 0
+		for 1<<i != low {
+			i++
+		}
+		sums[mask] = sums[mask&^low] + balances[people[i]]
+		best[mask] = -1
+		for j := 0; j < n; j++ {
+			if mask&(1<<j) == 0 {
+				continue
+			}
+			if b := best[mask&^(1<<j)]; b > best[mask] {
+				best[mask], from[mask] = b, j
+			}
+		}
+		if sums[mask] == 0 {
+			best[mask]++
+		}
+	}
+
+	// walking back removes people one at a time, each time the rest sums to zero a subgroup closes
+	var transfers []Transfer
+	var group []int
+	for mask := full; mask != 0; {
+		j := from[mask]
+		group = append(group, people[j])
+		mask &^= 1 << j
+		if sums[mask] == 0 {
+			transfers = append(transfers, settleGreedy(group, balances)...)
+			group = nil
+		}
+	}
+	return transfers
+}
+
+// settleGreedy has the biggest debtor pay the biggest creditor until everyone is square
+func settleGreedy(people []int, balances map[int]int64) []Transfer {
+	left := make(map[int]int64, len(people))
+	for _, id := range people {
+		left[id] = balances[id]
+	}
+	var transfers []Transfer
+	for {
+		debtor, creditor := 0, 0
+		var owes, owed int64
+		for _, id := range people {
+			if b := left[id]; b < owes {
+				debtor, owes = id, b
+			} else if b > owed {
+				creditor, owed = id, b
+			}
+		}
+		if owes == 0 || owed == 0 {
+			return transfers
+		}
+		amount := min(-owes, owed)
+		transfers = append(transfers, Transfer{From: debtor, To: creditor, AmountMinor: amount})
+		left[debtor] += amount
+		left[creditor] -= amount
+	}
+}
@@ -1,116 +1,518 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/geo"
 	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/money"
 	"github.com/lib/pq"
 )
 
 type ActivityRepository struct {
-	db *sql.DB
+	db *database.Router
 }
 
-func NewActivityRepository(db *sql.DB) *ActivityRepository {
+func NewActivityRepository(db *database.Router) *ActivityRepository {
 	return &ActivityRepository{db: db}
 
 }
 
-func (r *ActivityRepository) CreateActivity(name, title string, price int, address string, image_url string, country_id int) (models.Activity, error) {
-	var activity models.Activity
+// activityColumns selects an activity with its country, scan it with scanActivity.
+// Columns selected after these are scanned into scanActivity's extra destinations.
+const activityColumns = `a.id, a.name, a.title, a.price_minor, a.currency, (SELECT minor_units FROM currency WHERE code = a.currency), COALESCE(a.rating, 0)::FLOAT8, a.review_count, COALESCE(a.address, ''), COALESCE(a.imageurl, ''), COALESCE(a.country_id, 0),
+	a.latitude, a.longitude, c.id, c.name, c.iso_code, c.currency, c.timezone, c.languages,
+	ARRAY(SELECT cat.slug FROM activity_category ac JOIN category cat ON cat.id = ac.category_id WHERE ac.activity_id = a.id ORDER BY cat.slug),
+	ARRAY(SELECT t.name FROM activity_tag atg JOIN tag t ON t.id = atg.tag_id WHERE atg.activity_id = a.id ORDER BY t.name),
+	(SELECT json_object_agg(v.width || 'w', v.object_key) FROM images i JOIN image_variant v ON v.image_id = i.id WHERE i.object_key = a.imageurl)`
+
+const activityFrom = `activity a LEFT JOIN country c ON c.id = a.country_id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanActivity(row rowScanner, extra ...interface{}) (models.Activity, error) {
+	var a models.Activity
+	var countryID sql.NullInt64
+	var countryName, isoCode, currency, timezone sql.NullString
+	var languages, categories, tags pq.StringArray
+	var srcset []byte
+	var minorUnits int
+	dest := []interface{}{
+		&a.ID,
+		&a.Name,
+		&a.Title,
+		&a.PriceMinor,
+		&a.Currency,
+		&minorUnits,
+		&a.Rating,
+		&a.ReviewCount,
+		&a.Address,
+		&a.ImageURL,
+		&a.CountryID,
+		&a.Latitude,
+		&a.Longitude,
+		&countryID,
+		&countryName,
+		&isoCode,
+		&currency,
+		&timezone,
+		&languages,
+		&categories,
+		&tags,
+		&srcset,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return a, err
+	}
+	a.Price = money.ToMajor(a.PriceMinor, minorUnits)
+	a.Categories = categories
+	a.Tags = tags
+	if srcset != nil {
+		if err := json.Unmarshal(srcset, &a.ImageSrcset); err != nil {
+			return a, err
+		}
+	}
+	if countryID.Valid {
+		a.Country = &models.Country{
+			ID:        int(countryID.Int64),
+			Name:      countryName.String,
+			ISOCode:   isoCode.String,
+			Currency:  currency.String,
+			Timezone:  timezone.String,
+			Languages: languages,
+		}
+	}
+	return a, nil
+}
+
+func scanActivities(rows *sql.Rows) ([]models.Activity, error) {
+	defer rows.Close()
+	var activities []models.Activity
+	for rows.Next() {
+		a, err := scanActivity(rows)
+		if err != nil {
+			return nil, err
+		}
+		activities = append(activities, a)
+	}
+	return activities, rows.Err()
+}
+
+// taxonomyConditions turns a category/tag filter into AND clauses on the activity alias a,
+// adding its parameters through arg
+func taxonomyConditions(filter models.ActivityTaxonomyFilter, arg func(interface{}) string) string {
+	var conditions string
+	if filter.Category != "" {
+		conditions += ` AND EXISTS (SELECT 1 FROM activity_category ac WHERE ac.activity_id = a.id AND ac.category_id IN (` + categorySubtree(arg(filter.Category)) + `))`
+	}
+	if len(filter.Tags) > 0 {
+		conditions += ` AND (SELECT COUNT(*) FROM activity_tag atg JOIN tag t ON t.id = atg.tag_id WHERE atg.activity_id = a.id AND t.name = ANY(` + arg(pq.Array(filter.Tags)) + `)) = ` + arg(len(filter.Tags))
+	}
+	return conditions
+}
+
+// queryArgs collects query parameters, returning the placeholder for each one added
+func queryArgs(args *[]interface{}) func(interface{}) string {
+	return func(v interface{}) string {
+		*args = append(*args, v)
+		return "$" + strconv.Itoa(len(*args))
+	}
+}
+
+// setTaxonomy replaces the activity's categories and tags inside tx, creating tags that do not exist yet
+func setTaxonomy(tx *sql.Tx, activityID int, categories, tags []string) error {
+	if _, err := tx.Exec(`DELETE FROM activity_category WHERE activity_id = $1`, activityID); err != nil {
+		return err
+	}
+	if len(categories) > 0 {
+		res, err := tx.Exec(`
+		INSERT INTO activity_category (activity_id, category_id)
+		SELECT $1, id FROM category WHERE slug = ANY($2)`, activityID, pq.Array(categories))
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n != int64(len(categories)) {
+			return ErrUnknownCategory
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM activity_tag WHERE activity_id = $1`, activityID); err != nil {
+		return err
+	}
+	if len(tags) > 0 {
+		if _, err := tx.Exec(`INSERT INTO tag (name) SELECT UNNEST($1::TEXT[]) ON CONFLICT (name) DO NOTHING`, pq.Array(tags)); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+		INSERT INTO activity_tag (activity_id, tag_id)
+		SELECT $1, id FROM tag WHERE name = ANY($2)`, activityID, pq.Array(tags)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nullableID stores a zero id as NULL for optional foreign keys
+func nullableID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+func (r *ActivityRepository) CreateActivity(ctx context.Context, input models.Activity) (activity models.Activity, err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return activity, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
 
 	query := `
-	INSERT INTO activity (name, title, price, address, image_url, country_id) VALUES ($1, $2, $3, $4, $5) RETURNING id
+	INSERT INTO activity (name, title, price_minor, currency, address, imageurl, country_id, latitude, longitude) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id
 	`
-	err := r.db.QueryRow(query, name, title, price, address, image_url, country_id).Scan(
-		&activity.ID,
-		&activity.Name,
-		&activity.Title,
-		&activity.Price,
-		&activity.ImageURL,
-		&activity.CountryID,
-	)
+	var id int
+	err = tx.QueryRow(query, input.Name, input.Title, input.PriceMinor, input.Currency, input.Address, input.ImageURL, nullableID(input.CountryID), input.Latitude, input.Longitude).Scan(&id)
 	if err != nil {
 		return models.Activity{}, err
 	}
+	if err = setTaxonomy(tx, id, input.Categories, input.Tags); err != nil {
+		return models.Activity{}, err
+	}
+
+	activity, err = scanActivity(tx.QueryRow(`SELECT `+activityColumns+` FROM `+activityFrom+` WHERE a.id = $1`, id))
+	if err != nil {
+		return models.Activity{}, err
+	}
+
+	if err = recordAudit(ctx, tx, "activity", &activity.ID, AuditCreate, nil, activity); err != nil {
+		return models.Activity{}, err
+	}
 	return activity, nil
 
 }
 
-func (r *ActivityRepository) GetActivityById(activityID int) (*models.Activity, error) {
-	activity := &models.Activity{}
-	query := `SELECT name, title, price, address, imageurl, country_id FROM activity WHERE id + $1`
+// UpsertActivityByTitle creates the activity or, when one with the same title exists, overwrites
+// it. A soft deleted match is restored. An empty image url keeps the existing cover image.
+func (r *ActivityRepository) UpsertActivityByTitle(ctx context.Context, input models.Activity) (activity models.Activity, created bool, err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return activity, false, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var before *models.Activity
+	existing, err := scanActivity(tx.QueryRow(`SELECT `+activityColumns+` FROM `+activityFrom+` WHERE a.title = $1 FOR UPDATE OF a`, input.Title))
+	if err == nil {
+		before = &existing
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return activity, false, err
+	}
 
-	err := r.db.QueryRow(query, activityID).Scan(
-		&activity.ID,
-		&activity.Name,
-		&activity.Title,
-		&activity.Price,
-		&activity.Address,
-		&activity.ImageURL,
-		&activity.CountryID,
-	)
+	query := `
+	INSERT INTO activity (name, title, price_minor, currency, address, imageurl, country_id, latitude, longitude) VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9)
+	ON CONFLICT (title) DO UPDATE SET
+		name = EXCLUDED.name, price_minor = EXCLUDED.price_minor, currency = EXCLUDED.currency, address = EXCLUDED.address,
+		imageurl = COALESCE(EXCLUDED.imageurl, activity.imageurl), country_id = EXCLUDED.country_id,
+		latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude, deleted_at = NULL
+	RETURNING id, xmax = 0`
+	var id int
+	err = tx.QueryRow(query, input.Name, input.Title, input.PriceMinor, input.Currency, input.Address, input.ImageURL, nullableID(input.CountryID), input.Latitude, input.Longitude).Scan(&id, &created)
+	if err != nil {
+		return activity, false, err
+	}
+	if err = setTaxonomy(tx, id, input.Categories, input.Tags); err != nil {
+		return activity, false, err
+	}
+	if activity, err = scanActivity(tx.QueryRow(`SELECT `+activityColumns+` FROM `+activityFrom+` WHERE a.id = $1`, id)); err != nil {
+		return activity, false, err
+	}
+
+	if created {
+		err = recordAudit(ctx, tx, "activity", &id, AuditCreate, nil, activity)
+	} else {
+		err = recordAudit(ctx, tx, "activity", &id, AuditUpdate, before, activity)
+	}
+	return activity, created, err
+}
+
+// ExportActivities streams every live activity ordered by title to fn, stopping at the first error fn returns
+func (r *ActivityRepository) ExportActivities(ctx context.Context, fn func(models.Activity) error) error {
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, `SELECT `+activityColumns+` FROM `+activityFrom+` WHERE a.deleted_at IS NULL ORDER BY a.title`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		activity, err := scanActivity(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(activity); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *ActivityRepository) GetActivityById(ctx context.Context, activityID int) (*models.Activity, error) {
+	query := `SELECT ` + activityColumns + ` FROM ` + activityFrom + ` WHERE a.id = $1 AND a.deleted_at IS NULL`
+
+	activity, err := scanActivity(r.db.Reader(ctx).QueryRow(query, activityID))
 	if err != nil {
 		return nil, err
 
 	}
-	return activity, nil
+	return &activity, nil
 }
 
-func (r *ActivityRepository) GetActivitiesByIds(ids []string) ([]models.Activity, error) {
+func (r *ActivityRepository) GetActivitiesByIds(ctx context.Context, ids []string) ([]models.Activity, error) {
 	if len(ids) == 0 {
 		return nil, nil
 	}
 
-	query := `SELECT id, name, title, price, address, imageurl, country_id FROM activity WHERE id = ANY($1)`
-	rows, err := r.db.Query(query, pq.Array(ids))
+	query := `SELECT ` + activityColumns + ` FROM ` + activityFrom + ` WHERE a.id::TEXT = ANY($1) AND a.deleted_at IS NULL`
+	rows, err := r.db.Reader(ctx).Query(query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	return scanActivities(rows)
+}
+
+func (r *ActivityRepository) GetActivitiesByCountry(ctx context.Context, countryID int, filter models.ActivityTaxonomyFilter) ([]models.Activity, error) {
+	args := []interface{}{countryID}
+	query := `SELECT ` + activityColumns + ` FROM ` + activityFrom + ` WHERE a.country_id = $1 AND a.deleted_at IS NULL` +
+		taxonomyConditions(filter, queryArgs(&args)) + ` ORDER BY a.title`
+
+	rows, err := r.db.Reader(ctx).Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanActivities(rows)
+}
+
+// ListActivities pages through live activities matching the category and tag filter
+func (r *ActivityRepository) ListActivities(ctx context.Context, filter models.ActivityListFilter) ([]models.Activity, error) {
+	var args []interface{}
+	arg := queryArgs(&args)
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	query := `SELECT ` + activityColumns + ` FROM ` + activityFrom + ` WHERE a.deleted_at IS NULL` +
+		taxonomyConditions(filter.ActivityTaxonomyFilter, arg)
+	if filter.AvailableFrom != nil && filter.AvailableTo != nil {
+		query += ` AND activity_available(a.id, ` + arg(filter.AvailableFrom.Format("2006-01-02")) + `, ` + arg(filter.AvailableTo.Format("2006-01-02")) + `)`
+	}
+	query += ` ORDER BY a.title, a.id LIMIT ` + arg(limit) + ` OFFSET ` + arg(filter.Offset)
+
+	rows, err := r.db.Reader(ctx).Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanActivities(rows)
+}
+
+// SetActivityTaxonomy replaces the categories and tags of a live activity, returning
+// ErrUnknownCategory if any slug does not exist and sql.ErrNoRows if the activity does not
+func (r *ActivityRepository) SetActivityTaxonomy(ctx context.Context, activityID int, categories, tags []string) (activity models.Activity, err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return activity, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := `SELECT ` + activityColumns + ` FROM ` + activityFrom + ` WHERE a.id = $1 AND a.deleted_at IS NULL FOR UPDATE OF a`
+	before, err := scanActivity(tx.QueryRow(query, activityID))
+	if err != nil {
+		return activity, err
+	}
+	if err = setTaxonomy(tx, activityID, categories, tags); err != nil {
+		return activity, err
+	}
+	if activity, err = scanActivity(tx.QueryRow(query, activityID)); err != nil {
+		return activity, err
+	}
+	if err = recordAudit(ctx, tx, "activity", &activityID, AuditUpdate, before, activity); err != nil {
+		return activity, err
+	}
+	return activity, nil
+}
+
+// GetCategoryLineage returns the activity's categories together with all of their ancestors,
+// so a recommendation scoped to "adventure" also covers activities filed under "hiking"
+func (r *ActivityRepository) GetCategoryLineage(ctx context.Context, activityID int) ([]string, error) {
+	query := `
+	WITH RECURSIVE lineage AS (
+		SELECT cat.id, cat.slug, cat.parent_id
+		FROM activity_category ac JOIN category cat ON cat.id = ac.category_id
+		WHERE ac.activity_id = $1
+		UNION
+		SELECT p.id, p.slug, p.parent_id FROM category p JOIN lineage l ON p.id = l.parent_id
+	)
+	SELECT slug FROM lineage ORDER BY slug
+	`
+	rows, err := r.db.Reader(ctx).Query(query, activityID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var activities []models.Activity
+	slugs := []string{}
 	for rows.Next() {
-		var a models.Activity
-		if err := rows.Scan(&a.ID, &a.Name, &a.Title, &a.Price, &a.Address, &a.ImageURL, &a.CountryID); err != nil {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
 			return nil, err
 		}
-		activities = append(activities, a)
+		slugs = append(slugs, slug)
 	}
-	return activities, nil
+	return slugs, rows.Err()
 }
 
-func (r *ActivityRepository) GetActivitiesByItinerary(itineraryID int) ([]models.Activity, error) {
+func (r *ActivityRepository) DeleteActivity(ctx context.Context, activityID int) error {
+	return softDelete(ctx, r.db.Writer(ctx), "activity", activityID, true)
+}
+
+func (r *ActivityRepository) RestoreActivity(ctx context.Context, activityID int) error {
+	return softDelete(ctx, r.db.Writer(ctx), "activity", activityID, false)
+}
+
+// PurgeDeleted permanently removes activities deleted before the cutoff.
+// Activities still linked to an itinerary (even a trashed one) are kept until that itinerary is purged.
+func (r *ActivityRepository) PurgeDeleted(before time.Time) (int64, error) {
+	where := `deleted_at IS NOT NULL AND deleted_at < $1
+	AND NOT EXISTS (SELECT 1 FROM itinerary_activity ia WHERE ia.activity_id = activity.id)`
+	return purgeDeleted(r.db.Primary(), "activity", where, before)
+}
+
+// SearchActivities ranks activities by weighted full-text match, falling back to trigram
+// similarity on the name and title so misspelt queries still find something
+func (r *ActivityRepository) SearchActivities(ctx context.Context, filter models.ActivitySearchFilter) ([]models.ActivitySearchResult, error) {
+	args := []interface{}{filter.Query}
+	arg := queryArgs(&args)
 
 	query := `
+	SELECT ` + activityColumns + `,
+		ts_rank_cd(a.search_vector, q.tsq) + GREATEST(word_similarity($1, a.title), word_similarity($1, a.name)) * 0.5 AS rank,
+		ts_headline('english', a.title || ' — ' || COALESCE(a.address, ''), q.tsq, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MinWords=3, MaxWords=20') AS snippet
+	FROM ` + activityFrom + `, websearch_to_tsquery('english', $1) AS q(tsq)
+	WHERE a.deleted_at IS NULL
+	AND (a.search_vector @@ q.tsq OR $1 <% a.title OR $1 <% a.name)`
 
-	SELECT a.id, a.name, a.title, a.price, a.address, a.rating, a.imageurl, a.country_id
-	FROM activity a
-	JOIN itinerary_activity ia ON a.id = ia.activity_id
-	WHERE ia.itinerary_id = $1
-	`
+	if filter.MinPrice != nil || filter.MaxPrice != nil {
+		// compare in the currency the bounds are given in, activities without a rate never match
+		price := `convert_price(a.price_minor, a.currency, ` + arg(filter.PriceCurrency) + `)`
+		if filter.MinPrice != nil {
+			query += ` AND ` + price + ` >= ` + arg(*filter.MinPrice)
+		}
+		if filter.MaxPrice != nil {
+			query += ` AND ` + price + ` <= ` + arg(*filter.MaxPrice)
+		}
+	}
+	if filter.MinRating != nil {
+		query += ` AND a.rating >= ` + arg(*filter.MinRating)
+	}
+	if filter.CountryID != 0 {
+		query += ` AND a.country_id = ` + arg(filter.CountryID)
+	}
+	if filter.CountryCode != "" {
+		query += ` AND c.iso_code = ` + arg(filter.CountryCode)
+	}
+	query += taxonomyConditions(filter.ActivityTaxonomyFilter, arg)
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	query += ` ORDER BY rank DESC, a.id LIMIT ` + arg(limit) + ` OFFSET ` + arg(filter.Offset)
 
-	rows, err := r.db.Query(query, itineraryID)
+	rows, err := r.db.Reader(ctx).Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var activities []models.Activity
+
+	var results []models.ActivitySearchResult
 	for rows.Next() {
-		var a models.Activity
-		if err := rows.Scan(
-			&a.ID,
-			&a.Name,
-			&a.Title,
-			&a.Price,
-			&a.Address,
-			&a.ImageURL,
-			&a.CountryID,
-		); err != nil {
+		var result models.ActivitySearchResult
+		activity, err := scanActivity(rows, &result.Rank, &result.Snippet)
+		if err != nil {
 			return nil, err
-
 		}
-		activities = append(activities, a)
+		result.Activity = activity
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
 
+// GetActivitiesNearby returns activities within radiusMeters of center, closest first.
+// A bounding box on the indexed latitude/longitude narrows the rows before the exact haversine distance is computed.
+func (r *ActivityRepository) GetActivitiesNearby(ctx context.Context, center geo.Point, radiusMeters float64, limit int, filter models.ActivityTaxonomyFilter) ([]models.NearbyActivity, error) {
+	min, max := geo.BoundingBox(center, radiusMeters)
+	args := []interface{}{center.Lat, center.Lng, min.Lat, max.Lat, min.Lng, max.Lng, radiusMeters, limit, geo.EarthRadiusMeters}
+	longitude := `a.longitude BETWEEN $5 AND $6`
+	if min.Lng > max.Lng {
+		// the box wraps at the antimeridian, look on both sides of it
+		longitude = `(a.longitude BETWEEN $5 AND 180 OR a.longitude BETWEEN -180 AND $6)`
+	}
+
+	query := `
+	SELECT * FROM (
+		SELECT ` + activityColumns + `,
+			2 * $9 * ASIN(LEAST(1, SQRT(
+				POWER(SIN(RADIANS(a.latitude - $1) / 2), 2) +
+				COS(RADIANS($1)) * COS(RADIANS(a.latitude)) * POWER(SIN(RADIANS(a.longitude - $2) / 2), 2)
+			))) AS distance
+		FROM ` + activityFrom + `
+		WHERE a.deleted_at IS NULL
+		AND a.latitude BETWEEN $3 AND $4
+		AND ` + longitude + taxonomyConditions(filter, queryArgs(&args)) + `
+	) nearby
+	WHERE distance <= $7
+	ORDER BY distance
+	LIMIT $8
+	`
+	rows, err := r.db.Reader(ctx).Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activities []models.NearbyActivity
+	for rows.Next() {
+		var nearby models.NearbyActivity
+		activity, err := scanActivity(rows, &nearby.DistanceMeters)
+		if err != nil {
+			return nil, err
+		}
+		nearby.Activity = activity
+		activities = append(activities, nearby)
 	}
 	return activities, rows.Err()
 }
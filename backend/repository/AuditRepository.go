@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
+
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// recordAudit appends an entry to the audit log inside the caller's transaction so the
+// entry is only kept if the change itself commits. before and after may be nil.
+func recordAudit(ctx context.Context, tx *sql.Tx, entity string, entityID *int, action string, before, after interface{}) error {
+	beforeMap, err := toAuditMap(before)
+	if err != nil {
+		return err
+	}
+	afterMap, err := toAuditMap(after)
+	if err != nil {
+		return err
+	}
+
+	beforeJSON, err := auditJSON(beforeMap)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditJSON(afterMap)
+	if err != nil {
+		return err
+	}
+	diffJSON, err := json.Marshal(auditDiff(beforeMap, afterMap))
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO audit_log (actor, entity, entity_id, action, before, after, diff) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = tx.Exec(query, auth.ActorFromContext(ctx), entity, entityID, action, beforeJSON, afterJSON, diffJSON)
+	return err
+}
+
+// toAuditMap round trips a value through json so structs and maps can be compared field by field
+func toAuditMap(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// auditJSON keeps a missing snapshot as SQL NULL rather than a json null
+func auditJSON(m map[string]interface{}) (interface{}, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
+
+// recordPurge logs a bulk purge by a background job as a single entry without an entity id
+func recordPurge(tx *sql.Tx, entity string, count int64, cutoff time.Time) error {
+	ctx := auth.WithActor(context.Background(), auth.SystemActor)
+	return recordAudit(ctx, tx, entity, nil, AuditPurge, nil, map[string]interface{}{"count": count, "cutoff": cutoff})
+}
+
+// auditDiff returns {field: {before, after}} for every field whose value changed
+func auditDiff(before, after map[string]interface{}) map[string]map[string]interface{} {
+	diff := make(map[string]map[string]interface{})
+	for key, afterValue := range after {
+		beforeValue, ok := before[key]
+		if !ok || !reflect.DeepEqual(beforeValue, afterValue) {
+			diff[key] = map[string]interface{}{"before": beforeValue, "after": afterValue}
+		}
+	}
+	for key, beforeValue := range before {
+		if _, ok := after[key]; !ok {
+			diff[key] = map[string]interface{}{"before": beforeValue, "after": nil}
+		}
+	}
+	return diff
+}
+
+func (r *AuditRepository) GetAuditLogs(filter models.AuditFilter) ([]models.AuditLog, error) {
+	query := `SELECT id, actor, entity, entity_id, action, before, after, diff, created_at FROM audit_log WHERE TRUE`
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if filter.Entity != "" {
+		query += ` AND entity = ` + arg(filter.Entity)
+	}
+	if filter.EntityID != 0 {
+		query += ` AND entity_id = ` + arg(filter.EntityID)
+	}
+	if filter.Actor != "" {
+		query += ` AND actor = ` + arg(filter.Actor)
+	}
+	if !filter.From.IsZero() {
+		query += ` AND created_at >= ` + arg(filter.From)
+	}
+	if !filter.To.IsZero() {
+		query += ` AND created_at < ` + arg(filter.To)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ` + arg(limit) + ` OFFSET ` + arg(filter.Offset)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []models.AuditLog
+	for rows.Next() {
+		var l models.AuditLog
+		var before, after, diff []byte
+		if err := rows.Scan(&l.ID, &l.Actor, &l.Entity, &l.EntityID, &l.Action, &before, &after, &diff, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		l.Before = before
+		l.After = after
+		l.Diff = diff
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
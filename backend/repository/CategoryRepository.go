@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
+
+var (
+	// ErrUnknownCategory is returned when a category slug does not exist
+	ErrUnknownCategory = errors.New("unknown category")
+	// ErrCategoryCycle is returned when moving a category under one of its own descendants
+	ErrCategoryCycle = errors.New("category cannot be its own ancestor")
+)
+
+// categorySubtree selects the ids of the category whose slug is the given placeholder and everything below it
+func categorySubtree(slugParam string) string {
+	return `
+	WITH RECURSIVE subtree AS (
+		SELECT id FROM category WHERE slug = ` + slugParam + `
+		UNION ALL
+		SELECT c.id FROM category c JOIN subtree s ON c.parent_id = s.id
+	)
+	SELECT id FROM subtree`
+}
+
+type CategoryRepository struct {
+	db *database.Router
+}
+
+func NewCategoryRepository(db *database.Router) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+const categoryColumns = `id, slug, name, parent_id`
+
+func scanCategory(row rowScanner) (models.Category, error) {
+	var c models.Category
+	var parentID sql.NullInt64
+	if err := row.Scan(&c.ID, &c.Slug, &c.Name, &parentID); err != nil {
+		return c, err
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		c.ParentID = &id
+	}
+	return c, nil
+}
+
+func (r *CategoryRepository) GetAllCategories(ctx context.Context) ([]models.Category, error) {
+	rows, err := r.db.Reader(ctx).Query(`SELECT ` + categoryColumns + ` FROM category ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+// GetCategoryTree returns the top level categories with their descendants nested under them
+func (r *CategoryRepository) GetCategoryTree(ctx context.Context) ([]*models.Category, error) {
+	categories, err := r.GetAllCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]*models.Category, len(categories))
+	for i := range categories {
+		byID[categories[i].ID] = &categories[i]
+	}
+	roots := []*models.Category{}
+	for i := range categories {
+		category := &categories[i]
+		if parent, ok := byID[valueOrZero(category.ParentID)]; ok {
+			parent.Children = append(parent.Children, category)
+		} else {
+			roots = append(roots, category)
+		}
+	}
+	return roots, nil
+}
+
+func valueOrZero(id *int) int {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+func (r *CategoryRepository) GetCategoryBySlug(ctx context.Context, slug string) (*models.Category, error) {
+	category, err := scanCategory(r.db.Reader(ctx).QueryRow(`SELECT `+categoryColumns+` FROM category WHERE slug = $1`, slug))
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// resolveParent looks up the parent category by slug inside tx, an empty slug means top level
+func resolveParent(tx *sql.Tx, slug string) (*int, error) {
+	if slug == "" {
+		return nil, nil
+	}
+	var id int
+	err := tx.QueryRow(`SELECT id FROM category WHERE slug = $1`, slug).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrUnknownCategory
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// CreateCategory inserts a category under the category with slug parentSlug, or at the top level if it is empty
+func (r *CategoryRepository) CreateCategory(ctx context.Context, category *models.Category, parentSlug string) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if category.ParentID, err = resolveParent(tx, parentSlug); err != nil {
+		return err
+	}
+	query := `INSERT INTO category (slug, name, parent_id) VALUES ($1, $2, $3) RETURNING id`
+	if err = tx.QueryRow(query, category.Slug, category.Name, category.ParentID).Scan(&category.ID); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "category", &category.ID, AuditCreate, nil, category)
+}
+
+// UpdateCategory renames the category with the given slug and moves it under parentSlug.
+// Slugs are fixed once created since gorse items and clients refer to them.
+func (r *CategoryRepository) UpdateCategory(ctx context.Context, category *models.Category, parentSlug string) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	before, err := scanCategory(tx.QueryRow(`SELECT `+categoryColumns+` FROM category WHERE slug = $1 FOR UPDATE`, category.Slug))
+	if err != nil {
+		return err
+	}
+	category.ID = before.ID
+
+	if category.ParentID, err = resolveParent(tx, parentSlug); err != nil {
+		return err
+	}
+	if category.ParentID != nil {
+		var cycle bool
+		if err = tx.QueryRow(`SELECT $2 IN (`+categorySubtree("$1")+`)`, category.Slug, *category.ParentID).Scan(&cycle); err != nil {
+			return err
+		}
+		if cycle {
+			return ErrCategoryCycle
+		}
+	}
+
+	if _, err = tx.Exec(`UPDATE category SET name = $1, parent_id = $2 WHERE id = $3`, category.Name, category.ParentID, category.ID); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "category", &category.ID, AuditUpdate, before, category)
+}
+
+// DeleteCategory removes a category, this fails while it still has children or activities
+func (r *CategoryRepository) DeleteCategory(ctx context.Context, slug string) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	before, err := scanCategory(tx.QueryRow(`SELECT `+categoryColumns+` FROM category WHERE slug = $1 FOR UPDATE`, slug))
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM category WHERE id = $1`, before.ID); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "category", &before.ID, AuditDelete, before, nil)
+}
+
+// GetActivityIdsInCategory lists the live activities filed under the category or any of its descendants
+func (r *CategoryRepository) GetActivityIdsInCategory(ctx context.Context, slug string) ([]int, error) {
+	query := `
+	SELECT DISTINCT ac.activity_id
+	FROM activity_category ac
+	JOIN activity a ON a.id = ac.activity_id
+	WHERE a.deleted_at IS NULL AND ac.category_id IN (` + categorySubtree("$1") + `)
+	ORDER BY ac.activity_id`
+	rows, err := r.db.Reader(ctx).Query(query, slug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/lib/pq"
+)
+
+type CountryRepository struct {
+	db *database.Router
+}
+
+func NewCountryRepository(db *database.Router) *CountryRepository {
+	return &CountryRepository{db: db}
+}
+
+const countryColumns = `id, name, COALESCE(iso_code, ''), COALESCE(currency, ''), COALESCE(timezone, ''), languages`
+
+func scanCountry(row rowScanner) (models.Country, error) {
+	var c models.Country
+	var languages pq.StringArray
+	if err := row.Scan(&c.ID, &c.Name, &c.ISOCode, &c.Currency, &c.Timezone, &languages); err != nil {
+		return c, err
+	}
+	c.Languages = languages
+	return c, nil
+}
+
+func (r *CountryRepository) CreateCountry(ctx context.Context, country *models.Country) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := `INSERT INTO country (name, iso_code, currency, timezone, languages) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	err = tx.QueryRow(query, country.Name, country.ISOCode, country.Currency, country.Timezone, pq.Array(country.Languages)).Scan(&country.ID)
+	if err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "country", &country.ID, AuditCreate, nil, country)
+}
+
+func (r *CountryRepository) GetCountryById(ctx context.Context, id int) (*models.Country, error) {
+	country, err := scanCountry(r.db.Reader(ctx).QueryRow(`SELECT `+countryColumns+` FROM country WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &country, nil
+}
+
+func (r *CountryRepository) GetCountryByCode(ctx context.Context, isoCode string) (*models.Country, error) {
+	country, err := scanCountry(r.db.Reader(ctx).QueryRow(`SELECT `+countryColumns+` FROM country WHERE iso_code = $1`, isoCode))
+	if err != nil {
+		return nil, err
+	}
+	return &country, nil
+}
+
+// GetCountryLookup maps lower cased country names and ISO codes to their country
+func (r *CountryRepository) GetCountryLookup(ctx context.Context) (map[string]models.Country, error) {
+	rows, err := r.db.Reader(ctx).Query(`SELECT ` + countryColumns + ` FROM country`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lookup := make(map[string]models.Country)
+	for rows.Next() {
+		country, err := scanCountry(rows)
+		if err != nil {
+			return nil, err
+		}
+		lookup[strings.ToLower(country.Name)] = country
+		if country.ISOCode != "" {
+			lookup[strings.ToLower(country.ISOCode)] = country
+		}
+	}
+	return lookup, rows.Err()
+}
+
+func (r *CountryRepository) GetAllCountries(ctx context.Context) ([]models.Country, error) {
+	rows, err := r.db.Reader(ctx).Query(`SELECT ` + countryColumns + ` FROM country ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var countries []models.Country
+	for rows.Next() {
+		country, err := scanCountry(rows)
+		if err != nil {
+			return nil, err
+		}
+		countries = append(countries, country)
+	}
+	return countries, rows.Err()
+}
+
+// UpdateCountry overwrites every field of the country, returning sql.ErrNoRows if it does not exist
+func (r *CountryRepository) UpdateCountry(ctx context.Context, country *models.Country) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	before, err := scanCountry(tx.QueryRow(`SELECT `+countryColumns+` FROM country WHERE id = $1 FOR UPDATE`, country.ID))
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE country SET name = $1, iso_code = $2, currency = $3, timezone = $4, languages = $5 WHERE id = $6`
+	if _, err = tx.Exec(query, country.Name, country.ISOCode, country.Currency, country.Timezone, pq.Array(country.Languages), country.ID); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "country", &country.ID, AuditUpdate, before, country)
+}
+
+// UpsertCountry inserts or updates a country keyed by its ISO code, used when seeding the catalogue.
+// Countries created before ISO codes existed are matched by name instead.
+func (r *CountryRepository) UpsertCountry(ctx context.Context, country *models.Country) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	before, err := scanCountry(tx.QueryRow(`SELECT `+countryColumns+` FROM country WHERE iso_code = $1 OR (iso_code IS NULL AND name = $2) FOR UPDATE`, country.ISOCode, country.Name))
+	if err == sql.ErrNoRows {
+		query := `INSERT INTO country (name, iso_code, currency, timezone, languages) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+		err = tx.QueryRow(query, country.Name, country.ISOCode, country.Currency, country.Timezone, pq.Array(country.Languages)).Scan(&country.ID)
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, "country", &country.ID, AuditCreate, nil, country)
+	}
+	if err != nil {
+		return err
+	}
+
+	country.ID = before.ID
+	query := `UPDATE country SET name = $1, iso_code = $2, currency = $3, timezone = $4, languages = $5 WHERE id = $6`
+	if _, err = tx.Exec(query, country.Name, country.ISOCode, country.Currency, country.Timezone, pq.Array(country.Languages), country.ID); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "country", &country.ID, AuditUpdate, before, country)
+}
+
+// DeleteCountry removes a country, this fails while activities still reference it
+func (r *CountryRepository) DeleteCountry(ctx context.Context, id int) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	before, err := scanCountry(tx.QueryRow(`SELECT `+countryColumns+` FROM country WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM country WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "country", &id, AuditDelete, before, nil)
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
+
+type CurrencyRepository struct {
+	db *database.Router
+}
+
+func NewCurrencyRepository(db *database.Router) *CurrencyRepository {
+	return &CurrencyRepository{db: db}
+}
+
+// GetCurrencies maps every supported currency code to its number of decimal places
+func (r *CurrencyRepository) GetCurrencies(ctx context.Context) (map[string]int, error) {
+	rows, err := r.db.Reader(ctx).Query(`SELECT code, minor_units FROM currency`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	currencies := make(map[string]int)
+	for rows.Next() {
+		var c models.Currency
+		if err := rows.Scan(&c.Code, &c.MinorUnits); err != nil {
+			return nil, err
+		}
+		currencies[c.Code] = c.MinorUnits
+	}
+	return currencies, rows.Err()
+}
+
+// SaveExchangeRates stores a snapshot, replacing any earlier snapshot for the same date.
+// Rates for currencies that are not supported are skipped. Snapshots come from the exchange rate
+// job, so the change is audited as the system with the replaced snapshot as before.
+func (r *CurrencyRepository) SaveExchangeRates(ctx context.Context, rates models.ExchangeRates) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	before, err := exchangeRateSnapshot(tx, rates.Date)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM exchange_rate WHERE rate_date = $1`, rates.Date); err != nil {
+		return err
+	}
+	query := `
+	INSERT INTO exchange_rate (rate_date, currency, base, rate)
+	SELECT $1, code, $3, $4 FROM currency WHERE code = $2`
+	// the base itself is always worth exactly one unit of the base
+	if _, err = tx.Exec(query, rates.Date, rates.Base, rates.Base, 1); err != nil {
+		return err
+	}
+	for code, rate := range rates.Rates {
+		if code == rates.Base {
+			continue
+		}
+		if _, err = tx.Exec(query, rates.Date, code, rates.Base, rate); err != nil {
+			return err
+		}
+	}
+	after, err := exchangeRateSnapshot(tx, rates.Date)
+	if err != nil {
+		return err
+	}
+	action := AuditCreate
+	if before != nil {
+		action = AuditUpdate
+	}
+	return recordAudit(auth.WithActor(ctx, auth.SystemActor), tx, "exchange_rate", nil, action, before, after)
+}
+
+// exchangeRateSnapshot reads the rates stored for date inside tx, nil when there are none
+func exchangeRateSnapshot(tx *sql.Tx, date string) (interface{}, error) {
+	rows, err := tx.Query(`SELECT base, currency, rate::FLOAT8 FROM exchange_rate WHERE rate_date = $1`, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshot := models.ExchangeRates{Date: date, Rates: make(map[string]float64)}
+	for rows.Next() {
+		var code string
+		var rate float64
+		if err := rows.Scan(&snapshot.Base, &code, &rate); err != nil {
+			return nil, err
+		}
+		snapshot.Rates[code] = rate
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(snapshot.Rates) == 0 {
+		return nil, nil
+	}
+	return snapshot, nil
+}
+
+// GetLatestExchangeRates returns the most recent snapshot, or sql.ErrNoRows when none was loaded yet
+func (r *CurrencyRepository) GetLatestExchangeRates(ctx context.Context) (models.ExchangeRates, error) {
+	rates := models.ExchangeRates{Rates: make(map[string]float64)}
+	rows, err := r.db.Reader(ctx).Query(`
+	SELECT TO_CHAR(rate_date, 'YYYY-MM-DD'), base, currency, rate::FLOAT8 FROM exchange_rate
+	WHERE rate_date = (SELECT MAX(rate_date) FROM exchange_rate)`)
+	if err != nil {
+		return rates, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var code string
+		var rate float64
+		if err := rows.Scan(&rates.Date, &rates.Base, &code, &rate); err != nil {
+			return rates, err
+		}
+		rates.Rates[code] = rate
+	}
+	if err := rows.Err(); err != nil {
+		return rates, err
+	}
+	if len(rates.Rates) == 0 {
+		return rates, sql.ErrNoRows
+	}
+	return rates, nil
+}
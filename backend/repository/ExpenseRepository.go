@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/money"
+	"github.com/lib/pq"
+)
+
+type ExpenseRepository struct {
+	db *database.Router
+}
+
+func NewExpenseRepository(db *database.Router) *ExpenseRepository {
+	return &ExpenseRepository{db: db}
+}
+
+const expenseColumns = `e.id, e.itinerary_id, e.category, e.description, e.amount_minor, e.currency, cur.minor_units,
+	e.paid_by, u.username, e.split_method, TO_CHAR(e.spent_on, 'YYYY-MM-DD'), e.created_at, e.updated_at`
+
+const expenseFrom = `itinerary_expense e JOIN currency cur ON cur.code = e.currency JOIN users u ON u.id = e.paid_by`
+
+func scanExpense(row rowScanner) (models.Expense, error) {
+	var e models.Expense
+	var minorUnits int
+	err := row.Scan(&e.ID, &e.ItineraryID, &e.Category, &e.Description, &e.AmountMinor, &e.Currency, &minorUnits,
+		&e.PaidBy, &e.PaidByName, &e.SplitMethod, &e.Date, &e.CreatedAt, &e.UpdatedAt)
+	e.Amount = money.ToMajor(e.AmountMinor, minorUnits)
+	return e, err
+}
+
+// loadExpenseShares fills in how each expense is split between members
+func loadExpenseShares(db queryer, expenses []models.Expense) error {
+	if len(expenses) == 0 {
+		return nil
+	}
+	byID := make(map[int]*models.Expense, len(expenses))
+	ids := make([]int, len(expenses))
+	for i := range expenses {
+		expenses[i].Shares = []models.ExpenseShare{}
+		byID[expenses[i].ID] = &expenses[i]
+		ids[i] = expenses[i].ID
+	}
+
+	rows, err := db.Query(`
+	SELECT s.expense_id, s.user_id, u.username, s.shares, s.amount_minor, cur.minor_units
+	FROM itinerary_expense_share s
+	JOIN users u ON u.id = s.user_id
+	JOIN itinerary_expense e ON e.id = s.expense_id
+	JOIN currency cur ON cur.code = e.currency
+	WHERE s.expense_id = ANY($1)
+	ORDER BY s.user_id`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var expenseID, minorUnits int
+		var share models.ExpenseShare
+		if err := rows.Scan(&expenseID, &share.UserID, &share.Username, &share.Shares, &share.AmountMinor, &minorUnits); err != nil {
+			return err
+		}
+		share.Amount = money.ToMajor(share.AmountMinor, minorUnits)
+		expense := byID[expenseID]
+		expense.Shares = append(expense.Shares, share)
+	}
+	return rows.Err()
+}
+
+// saveExpenseShares replaces the split of an expense, everyone involved has to be a member of the itinerary
+func saveExpenseShares(tx *sql.Tx, expense *models.Expense) error {
+	shares := expense.Shares
+	userIDs := []int{expense.PaidBy}
+	var total int64
+	for _, share := range shares {
+		userIDs = append(userIDs, share.UserID)
+		total += share.AmountMinor
+	}
+	if len(shares) == 0 || total != expense.AmountMinor {
+		return errors.New("expense shares must add up to the expense amount")
+	}
+	if err := checkMembers(tx, expense.ItineraryID, userIDs); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM itinerary_expense_share WHERE expense_id = $1`, expense.ID); err != nil {
+		return err
+	}
+	for _, share := range shares {
+		if _, err := tx.Exec(`INSERT INTO itinerary_expense_share (expense_id, user_id, shares, amount_minor) VALUES ($1, $2, $3, $4)`,
+			expense.ID, share.UserID, max(share.Shares, 1), share.AmountMinor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reloadExpense reads an expense back with its shares inside tx
+func reloadExpense(tx *sql.Tx, id int) (models.Expense, error) {
+	expense, err := scanExpense(tx.QueryRow(`SELECT `+expenseColumns+` FROM `+expenseFrom+` WHERE e.id = $1`, id))
+	if err != nil {
+		return expense, err
+	}
+	expenses := []models.Expense{expense}
+	err = loadExpenseShares(tx, expenses)
+	return expenses[0], err
+}
+
+// lockLiveItinerary keeps a live itinerary from being deleted for the rest of tx, sql.ErrNoRows if there is none
+func lockLiveItinerary(tx *sql.Tx, id int) error {
+	var found int
+	return tx.QueryRow(`SELECT id FROM itinerary WHERE id = $1 AND deleted_at IS NULL FOR SHARE`, id).Scan(&found)
+}
+
+// GetBudget returns the budget of a live itinerary, sql.ErrNoRows if there is no such itinerary
+func (r *ExpenseRepository) GetBudget(ctx context.Context, itineraryID int) (models.ItineraryBudget, error) {
+	budget := models.ItineraryBudget{ItineraryID: itineraryID}
+	var amount sql.NullInt64
+	err := r.db.Reader(ctx).QueryRow(`SELECT budget_minor, COALESCE(budget_currency, '') FROM itinerary WHERE id = $1 AND deleted_at IS NULL`, itineraryID).
+		Scan(&amount, &budget.Currency)
+	if amount.Valid {
+		budget.AmountMinor = &amount.Int64
+	}
+	return budget, err
+}
+
+// SetBudget sets or, with a nil amount, clears the budget of a live itinerary
+func (r *ExpenseRepository) SetBudget(ctx context.Context, budget models.ItineraryBudget) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	before := models.ItineraryBudget{ItineraryID: budget.ItineraryID}
+	var amount sql.NullInt64
+	var currency sql.NullString
+	if err = tx.QueryRow(`SELECT budget_minor, budget_currency FROM itinerary WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, budget.ItineraryID).
+		Scan(&amount, &currency); err != nil {
+		return err
+	}
+	if amount.Valid {
+		before.AmountMinor = &amount.Int64
+		before.Currency = currency.String
+	}
+	if budget.AmountMinor == nil {
+		budget.Currency = ""
+	}
+	if _, err = tx.Exec(`UPDATE itinerary SET budget_minor = $2, budget_currency = NULLIF($3, '') WHERE id = $1`,
+		budget.ItineraryID, budget.AmountMinor, budget.Currency); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "itinerary_budget", &budget.ItineraryID, AuditUpdate, before, budget)
+}
+
+// GetExpensesByItinerary lists a live itinerary's expenses by date, sql.ErrNoRows if there is no such itinerary
+func (r *ExpenseRepository) GetExpensesByItinerary(ctx context.Context, itineraryID int) ([]models.Expense, error) {
+	db := r.db.Reader(ctx)
+	var found int
+	if err := db.QueryRow(`SELECT id FROM itinerary WHERE id = $1 AND deleted_at IS NULL`, itineraryID).Scan(&found); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`SELECT `+expenseColumns+` FROM `+expenseFrom+` WHERE e.itinerary_id = $1 ORDER BY e.spent_on, e.id`, itineraryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	expenses := []models.Expense{}
+	for rows.Next() {
+		expense, err := scanExpense(rows)
+		if err != nil {
+			return nil, err
+		}
+		expenses = append(expenses, expense)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return expenses, loadExpenseShares(db, expenses)
+}
+
+func (r *ExpenseRepository) GetExpenseById(ctx context.Context, id int) (*models.Expense, error) {
+	db := r.db.Reader(ctx)
+	expense, err := scanExpense(db.QueryRow(`SELECT `+expenseColumns+` FROM `+expenseFrom+` WHERE e.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	expenses := []models.Expense{expense}
+	if err := loadExpenseShares(db, expenses); err != nil {
+		return nil, err
+	}
+	return &expenses[0], nil
+}
+
+// CreateExpense records an expense and its split on a live itinerary, sql.ErrNoRows if there is no
+// such itinerary and ErrNotMember if the payer or anyone sharing it is not on the itinerary
+func (r *ExpenseRepository) CreateExpense(ctx context.Context, expense *models.Expense) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if err = lockLiveItinerary(tx, expense.ItineraryID); err != nil {
+		return err
+	}
+	query := `
+	INSERT INTO itinerary_expense (itinerary_id, category, description, amount_minor, currency, paid_by, split_method, spent_on)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+	if err = tx.QueryRow(query, expense.ItineraryID, expense.Category, expense.Description, expense.AmountMinor, expense.Currency, expense.PaidBy, expense.SplitMethod, expense.Date).Scan(&expense.ID); err != nil {
+		return err
+	}
+	if err = saveExpenseShares(tx, expense); err != nil {
+		return err
+	}
+	if *expense, err = reloadExpense(tx, expense.ID); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "itinerary_expense", &expense.ID, AuditCreate, nil, expense)
+}
+
+// UpdateExpense replaces everything but the itinerary of an expense, sql.ErrNoRows if it does not
+// exist and ErrNotMember if the payer or anyone sharing it is not on the itinerary
+func (r *ExpenseRepository) UpdateExpense(ctx context.Context, expense *models.Expense) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	before, err := scanExpense(tx.QueryRow(`SELECT `+expenseColumns+` FROM `+expenseFrom+` WHERE e.id = $1 FOR UPDATE OF e`, expense.ID))
+	if err != nil {
+		return err
+	}
+	if err = lockLiveItinerary(tx, before.ItineraryID); err != nil {
+		return err
+	}
+	befores := []models.Expense{before}
+	if err = loadExpenseShares(tx, befores); err != nil {
+		return err
+	}
+	before = befores[0]
+	query := `
+	UPDATE itinerary_expense SET category = $2, description = $3, amount_minor = $4, currency = $5, paid_by = $6, split_method = $7, spent_on = $8, updated_at = NOW()
+	WHERE id = $1`
+	if _, err = tx.Exec(query, expense.ID, expense.Category, expense.Description, expense.AmountMinor, expense.Currency, expense.PaidBy, expense.SplitMethod, expense.Date); err != nil {
+		return err
+	}
+	expense.ItineraryID = before.ItineraryID
+	if err = saveExpenseShares(tx, expense); err != nil {
+		return err
+	}
+	if *expense, err = reloadExpense(tx, expense.ID); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "itinerary_expense", &expense.ID, AuditUpdate, before, expense)
+}
+
+// DeleteExpense removes an expense and returns the itinerary it was on, sql.ErrNoRows if it does not exist
+func (r *ExpenseRepository) DeleteExpense(ctx context.Context, id int) (itineraryID int, err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return itineraryID, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	before, err := scanExpense(tx.QueryRow(`SELECT `+expenseColumns+` FROM `+expenseFrom+` WHERE e.id = $1 FOR UPDATE OF e`, id))
+	if err != nil {
+		return itineraryID, err
+	}
+	itineraryID = before.ItineraryID
+	if _, err = tx.Exec(`DELETE FROM itinerary_expense WHERE id = $1`, id); err != nil {
+		return itineraryID, err
+	}
+	err = recordAudit(ctx, tx, "itinerary_expense", &id, AuditDelete, before, nil)
+	return itineraryID, err
+}
@@ -0,0 +1,360 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/lib/pq"
+)
+
+type ImageRepository struct {
+	db *database.Router
+}
+
+func NewImageRepository(db *database.Router) *ImageRepository {
+	return &ImageRepository{db: db}
+}
+
+const imageColumns = `id, object_key, owner_type, owner_id, content_type, size_bytes, status, uploaded_by, created_at, confirmed_at, processed_at`
+
+// processing that has not finished within this long is assumed to have crashed and is retried
+const imageProcessingLease = `10 minutes`
+
+// MaxImageProcessingAttempts is how often the worker tries an image before giving up on it
+const MaxImageProcessingAttempts = 5
+
+func scanImage(row rowScanner) (models.Image, error) {
+	var img models.Image
+	err := row.Scan(&img.ID, &img.ObjectKey, &img.OwnerType, &img.OwnerID, &img.ContentType, &img.SizeBytes, &img.Status, &img.UploadedBy, &img.CreatedAt, &img.ConfirmedAt, &img.ProcessedAt)
+	return img, err
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// loadVariants fills in the variants of each image
+func loadVariants(db queryer, images []models.Image) error {
+	if len(images) == 0 {
+		return nil
+	}
+	byID := make(map[int]*models.Image, len(images))
+	ids := make([]int, len(images))
+	for i := range images {
+		byID[images[i].ID] = &images[i]
+		ids[i] = images[i].ID
+	}
+
+	rows, err := db.Query(`SELECT image_id, name, object_key, width, height, size_bytes, content_type FROM image_variant WHERE image_id = ANY($1) ORDER BY width`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var imageID int
+		var v models.ImageVariant
+		if err := rows.Scan(&imageID, &v.Name, &v.ObjectKey, &v.Width, &v.Height, &v.SizeBytes, &v.ContentType); err != nil {
+			return err
+		}
+		image := byID[imageID]
+		image.Variants = append(image.Variants, v)
+	}
+	return rows.Err()
+}
+
+// ownerTables maps image owner types to the table holding the owner
+var ownerTables = map[string]string{
+	models.ImageOwnerActivity:  "activity",
+	models.ImageOwnerItinerary: "itinerary",
+}
+
+// CreatePendingImage records an upload the client is about to make, returning sql.ErrNoRows
+// if the owner does not exist or is deleted
+func (r *ImageRepository) CreatePendingImage(ctx context.Context, image *models.Image) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var ownerID int
+	if err = tx.QueryRow(`SELECT id FROM `+ownerTables[image.OwnerType]+` WHERE id = $1 AND deleted_at IS NULL`, image.OwnerID).Scan(&ownerID); err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO images (object_key, owner_type, owner_id, content_type, size_bytes, uploaded_by)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING ` + imageColumns
+	*image, err = scanImage(tx.QueryRow(query, image.ObjectKey, image.OwnerType, image.OwnerID, image.ContentType, image.SizeBytes, image.UploadedBy))
+	return err
+}
+
+// GetImageById reads from the primary since it is used right after the pending row is written
+func (r *ImageRepository) GetImageById(ctx context.Context, id int) (*models.Image, error) {
+	image, err := scanImage(r.db.Reader(database.WithPrimary(ctx)).QueryRow(`SELECT `+imageColumns+` FROM images WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+// GetImagesByOwner lists the confirmed images of an activity or itinerary, newest first
+func (r *ImageRepository) GetImagesByOwner(ctx context.Context, ownerType string, ownerID int) ([]models.Image, error) {
+	query := `SELECT ` + imageColumns + ` FROM images WHERE owner_type = $1 AND owner_id = $2 AND status = 'ready' ORDER BY confirmed_at DESC, id DESC`
+	db := r.db.Reader(ctx)
+	rows, err := db.Query(query, ownerType, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := []models.Image{}
+	for rows.Next() {
+		image, err := scanImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return images, loadVariants(db, images)
+}
+
+// ConfirmImage marks a verified pending upload as ready. An activity's newest image becomes its cover image.
+func (r *ImageRepository) ConfirmImage(ctx context.Context, image *models.Image) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := `UPDATE images SET status = 'ready', confirmed_at = NOW() WHERE id = $1 AND status = 'pending' RETURNING ` + imageColumns
+	if *image, err = scanImage(tx.QueryRow(query, image.ID)); err != nil {
+		return err
+	}
+	if image.OwnerType == models.ImageOwnerActivity {
+		if _, err = tx.Exec(`UPDATE activity SET imageurl = $1 WHERE id = $2`, image.ObjectKey, image.OwnerID); err != nil {
+			return err
+		}
+	}
+	return recordAudit(ctx, tx, "images", &image.ID, AuditCreate, nil, image)
+}
+
+// DeleteImage removes the image row and its variants, clearing the activity cover if it pointed at
+// this image. The returned image lists the variants so the caller can remove every object from the bucket.
+func (r *ImageRepository) DeleteImage(ctx context.Context, id int) (image models.Image, err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return image, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if image, err = scanImage(tx.QueryRow(`SELECT `+imageColumns+` FROM images WHERE id = $1 FOR UPDATE`, id)); err != nil {
+		return image, err
+	}
+	images := []models.Image{image}
+	if err = loadVariants(tx, images); err != nil {
+		return image, err
+	}
+	image = images[0]
+	if _, err = tx.Exec(`DELETE FROM images WHERE id = $1`, id); err != nil {
+		return image, err
+	}
+	if image.OwnerType == models.ImageOwnerActivity {
+		if _, err = tx.Exec(`UPDATE activity SET imageurl = NULL WHERE id = $1 AND imageurl = $2`, image.OwnerID, image.ObjectKey); err != nil {
+			return image, err
+		}
+	}
+	if image.Status != models.ImageReady {
+		return image, nil
+	}
+	return image, recordAudit(ctx, tx, "images", &image.ID, AuditDelete, image, nil)
+}
+
+// DiscardPendingImage drops a pending upload that failed verification
+func (r *ImageRepository) DiscardPendingImage(ctx context.Context, id int) error {
+	res, err := r.db.Writer(ctx).Exec(`DELETE FROM images WHERE id = $1 AND status = 'pending'`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ClaimUnprocessedImage leases the next confirmed image that still needs variants, returning
+// sql.ErrNoRows when there is none. SKIP LOCKED lets several workers claim images side by side.
+func (r *ImageRepository) ClaimUnprocessedImage(ctx context.Context) (*models.Image, error) {
+	query := `
+	UPDATE images SET processing_started_at = NOW(), processing_attempts = processing_attempts + 1
+	WHERE id = (
+		SELECT id FROM images
+		WHERE status = 'ready' AND processed_at IS NULL AND processing_attempts < $1
+		AND (processing_started_at IS NULL OR processing_started_at < NOW() - INTERVAL '` + imageProcessingLease + `')
+		ORDER BY id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + imageColumns
+	image, err := scanImage(r.db.Primary().QueryRow(query, MaxImageProcessingAttempts))
+	if err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+// SaveImageVariants replaces the image's variants and marks it processed. sizeBytes is the size
+// of the original after its metadata was stripped.
+func (r *ImageRepository) SaveImageVariants(ctx context.Context, imageID int, sizeBytes int64, variants []models.ImageVariant) (err error) {
+	tx, err := r.db.Primary().Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM image_variant WHERE image_id = $1`, imageID); err != nil {
+		return err
+	}
+	for _, v := range variants {
+		query := `INSERT INTO image_variant (image_id, name, object_key, width, height, size_bytes, content_type) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+		if _, err = tx.Exec(query, imageID, v.Name, v.ObjectKey, v.Width, v.Height, v.SizeBytes, v.ContentType); err != nil {
+			return err
+		}
+	}
+	res, err := tx.Exec(`UPDATE images SET processed_at = NOW(), processing_error = NULL, size_bytes = $1 WHERE id = $2`, sizeBytes, imageID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		// the image was deleted while it was being processed
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MarkImageFailed records why processing failed and releases the lease so it is retried
+func (r *ImageRepository) MarkImageFailed(ctx context.Context, imageID int, reason string) error {
+	_, err := r.db.Primary().Exec(`UPDATE images SET processing_error = $1, processing_started_at = NULL WHERE id = $2`, reason, imageID)
+	return err
+}
+
+// GetUnreferencedImages finds image rows the storage gc should discard: uploads still pending
+// since before pendingBefore, and images whose activity or itinerary has been purged
+func (r *ImageRepository) GetUnreferencedImages(ctx context.Context, pendingBefore time.Time) ([]models.Image, error) {
+	query := `
+	SELECT ` + imageColumns + ` FROM images i
+	WHERE (i.status = 'pending' AND i.created_at < $1)
+	OR (i.owner_type = 'activity' AND NOT EXISTS (SELECT 1 FROM activity a WHERE a.id = i.owner_id))
+	OR (i.owner_type = 'itinerary' AND NOT EXISTS (SELECT 1 FROM itinerary it WHERE it.id = i.owner_id))
+	ORDER BY i.id`
+	db := r.db.Primary()
+	rows, err := db.Query(query, pendingBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := []models.Image{}
+	for rows.Next() {
+		image, err := scanImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return images, loadVariants(db, images)
+}
+
+// DiscardImages deletes image rows found by GetUnreferencedImages, auditing the confirmed ones
+// as removed by the system. Their objects become unreferenced and are collected afterwards.
+func (r *ImageRepository) DiscardImages(ctx context.Context, images []models.Image) (err error) {
+	tx, err := r.db.Primary().Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	systemCtx := auth.WithActor(ctx, auth.SystemActor)
+	for i := range images {
+		image := images[i]
+		if _, err = tx.Exec(`DELETE FROM images WHERE id = $1`, image.ID); err != nil {
+			return err
+		}
+		if image.Status == models.ImageReady {
+			if err = recordAudit(systemCtx, tx, "images", &image.ID, AuditDelete, image, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetReferencedObjectKeys returns every object key the database still points at
+func (r *ImageRepository) GetReferencedObjectKeys(ctx context.Context) (map[string]bool, error) {
+	rows, err := r.db.Primary().Query(`
+	SELECT object_key FROM images
+	UNION SELECT object_key FROM image_variant
+	UNION SELECT imageurl FROM activity WHERE imageurl IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys[key] = true
+	}
+	return keys, rows.Err()
+}
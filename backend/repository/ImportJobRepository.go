@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
+
+// MaxImportRowErrors caps how many row errors a job keeps, failed_rows still counts them all
+const MaxImportRowErrors = 1000
+
+// ImportJobRepository keeps import progress. Job rows are not audited, they are bookkeeping rewritten
+// every few hundred rows. Every activity an import writes is audited as the admin who started it, who
+// the job records as created_by.
+type ImportJobRepository struct {
+	db *database.Router
+}
+
+func NewImportJobRepository(db *database.Router) *ImportJobRepository {
+	return &ImportJobRepository{db: db}
+}
+
+const importJobColumns = `id, format, status, processed_rows, created_rows, updated_rows, failed_rows, row_errors, COALESCE(error, ''), created_by, created_at, started_at, finished_at`
+
+func scanImportJob(row rowScanner) (models.ImportJob, error) {
+	var job models.ImportJob
+	var rowErrors []byte
+	err := row.Scan(&job.ID, &job.Format, &job.Status, &job.Processed, &job.Created, &job.Updated, &job.Failed, &rowErrors, &job.Error, &job.CreatedBy, &job.CreatedAt, &job.StartedAt, &job.FinishedAt)
+	if err != nil {
+		return job, err
+	}
+	return job, json.Unmarshal(rowErrors, &job.RowErrors)
+}
+
+func (r *ImportJobRepository) CreateImportJob(ctx context.Context, format, createdBy string) (*models.ImportJob, error) {
+	query := `INSERT INTO import_job (format, created_by) VALUES ($1, $2) RETURNING ` + importJobColumns
+	job, err := scanImportJob(r.db.Writer(ctx).QueryRow(query, format, createdBy))
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetImportJobById reads from the primary since progress is written there while the job runs
+func (r *ImportJobRepository) GetImportJobById(ctx context.Context, id int) (*models.ImportJob, error) {
+	job, err := scanImportJob(r.db.Primary().QueryRow(`SELECT `+importJobColumns+` FROM import_job WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *ImportJobRepository) StartImportJob(ctx context.Context, id int) error {
+	_, err := r.db.Primary().Exec(`UPDATE import_job SET status = 'running', started_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// UpdateImportJob saves the job's counters and row errors, and its status once it has finished
+func (r *ImportJobRepository) UpdateImportJob(ctx context.Context, job *models.ImportJob) error {
+	rowErrors := job.RowErrors
+	if len(rowErrors) > MaxImportRowErrors {
+		rowErrors = rowErrors[:MaxImportRowErrors]
+	}
+	if rowErrors == nil {
+		rowErrors = []models.ImportRowError{}
+	}
+	data, err := json.Marshal(rowErrors)
+	if err != nil {
+		return err
+	}
+	query := `
+	UPDATE import_job SET status = $1, processed_rows = $2, created_rows = $3, updated_rows = $4, failed_rows = $5,
+		row_errors = $6, error = NULLIF($7, ''),
+		finished_at = CASE WHEN $1 IN ('succeeded', 'failed') THEN NOW() END
+	WHERE id = $8`
+	_, err = r.db.Primary().Exec(query, job.Status, job.Processed, job.Created, job.Updated, job.Failed, data, job.Error, job.ID)
+	return err
+}
+
+// FailInterruptedImportJobs fails jobs a previous server process left unfinished, their
+// uploaded files did not survive the restart
+func (r *ImportJobRepository) FailInterruptedImportJobs(ctx context.Context) (int64, error) {
+	res, err := r.db.Primary().Exec(`UPDATE import_job SET status = 'failed', error = 'interrupted by a server restart', finished_at = NOW() WHERE status IN ('queued', 'running')`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
+
+// ErrAlreadyMember is returned when inviting someone who is already on the itinerary
+var ErrAlreadyMember = errors.New("user is already a member of the itinerary")
+
+type InvitationRepository struct {
+	db *database.Router
+}
+
+func NewInvitationRepository(db *database.Router) *InvitationRepository {
+	return &InvitationRepository{db: db}
+}
+
+const invitationColumns = `n.id, n.itinerary_id, i.title, n.user_id, u.username, n.role, n.invited_by, COALESCE(b.username, ''),
+	n.status, n.created_at, n.responded_at`
+
+const invitationFrom = `itinerary_invitation n
+	JOIN itinerary i ON i.id = n.itinerary_id
+	JOIN users u ON u.id = n.user_id
+	LEFT JOIN users b ON b.id = n.invited_by`
+
+func scanInvitation(row rowScanner) (models.ItineraryInvitation, error) {
+	var n models.ItineraryInvitation
+	err := row.Scan(&n.ID, &n.ItineraryID, &n.ItineraryTitle, &n.UserID, &n.Username, &n.Role, &n.InvitedBy, &n.InvitedByName,
+		&n.Status, &n.CreatedAt, &n.RespondedAt)
+	return n, err
+}
+
+func (r *InvitationRepository) listInvitations(ctx context.Context, where string, arg interface{}) ([]models.ItineraryInvitation, error) {
+	rows, err := r.db.Reader(ctx).Query(`SELECT `+invitationColumns+` FROM `+invitationFrom+`
+	WHERE `+where+` AND n.status = 'pending' AND i.deleted_at IS NULL ORDER BY n.created_at DESC, n.id DESC`, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []models.ItineraryInvitation{}
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+	return invitations, rows.Err()
+}
+
+// GetInvitationsByItinerary lists the invitations to an itinerary still waiting for an answer
+func (r *InvitationRepository) GetInvitationsByItinerary(ctx context.Context, itineraryID int) ([]models.ItineraryInvitation, error) {
+	return r.listInvitations(ctx, `n.itinerary_id = $1`, itineraryID)
+}
+
+// GetInvitationsByUser lists the invitations a user has not answered yet, newest first
+func (r *InvitationRepository) GetInvitationsByUser(ctx context.Context, userID int) ([]models.ItineraryInvitation, error) {
+	return r.listInvitations(ctx, `n.user_id = $1`, userID)
+}
+
+func (r *InvitationRepository) GetInvitationById(ctx context.Context, id int) (*models.ItineraryInvitation, error) {
+	invitation, err := scanInvitation(r.db.Reader(ctx).QueryRow(`SELECT `+invitationColumns+` FROM `+invitationFrom+` WHERE n.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// CreateInvitation invites a user to a live itinerary, sql.ErrNoRows if there is no such itinerary,
+// ErrAlreadyMember if they are on it and a unique violation if they already have a pending invitation
+func (r *InvitationRepository) CreateInvitation(ctx context.Context, invitation *models.ItineraryInvitation) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if err = lockLiveItinerary(tx, invitation.ItineraryID); err != nil {
+		return err
+	}
+	var member bool
+	if err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM itinerary_member WHERE itinerary_id = $1 AND user_id = $2)`,
+		invitation.ItineraryID, invitation.UserID).Scan(&member); err != nil {
+		return err
+	}
+	if member {
+		return ErrAlreadyMember
+	}
+	var id int
+	if err = tx.QueryRow(`INSERT INTO itinerary_invitation (itinerary_id, user_id, role, invited_by) VALUES ($1, $2, $3, $4) RETURNING id`,
+		invitation.ItineraryID, invitation.UserID, invitation.Role, invitation.InvitedBy).Scan(&id); err != nil {
+		return err
+	}
+	if *invitation, err = scanInvitation(tx.QueryRow(`SELECT `+invitationColumns+` FROM `+invitationFrom+` WHERE n.id = $1`, id)); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "itinerary_invitation", &invitation.ID, AuditCreate, nil, invitation)
+}
+
+// RespondToInvitation accepts or declines a pending invitation for the user it was sent to, accepting
+// makes them a member with the invited role. sql.ErrNoRows means there is no such pending invitation
+// for the user or the itinerary is gone.
+func (r *InvitationRepository) RespondToInvitation(ctx context.Context, id, userID int, accept bool) (invitation models.ItineraryInvitation, err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return invitation, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	before, err := scanInvitation(tx.QueryRow(`SELECT `+invitationColumns+` FROM `+invitationFrom+`
+	WHERE n.id = $1 AND n.user_id = $2 AND n.status = 'pending' FOR UPDATE OF n`, id, userID))
+	if err != nil {
+		return invitation, err
+	}
+	if err = lockLiveItinerary(tx, before.ItineraryID); err != nil {
+		return invitation, err
+	}
+	status := models.InvitationDeclined
+	if accept {
+		status = models.InvitationAccepted
+		if _, err = tx.Exec(`INSERT INTO itinerary_member (itinerary_id, user_id, role) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+			before.ItineraryID, userID, before.Role); err != nil {
+			return invitation, err
+		}
+	}
+	if _, err = tx.Exec(`UPDATE itinerary_invitation SET status = $2, responded_at = NOW() WHERE id = $1`, id, status); err != nil {
+		return invitation, err
+	}
+	if invitation, err = scanInvitation(tx.QueryRow(`SELECT `+invitationColumns+` FROM `+invitationFrom+` WHERE n.id = $1`, id)); err != nil {
+		return invitation, err
+	}
+	err = recordAudit(ctx, tx, "itinerary_invitation", &id, AuditUpdate, before, invitation)
+	return invitation, err
+}
+
+// CancelInvitation withdraws a pending invitation, sql.ErrNoRows if there is none with that id
+func (r *InvitationRepository) CancelInvitation(ctx context.Context, id int) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	before, err := scanInvitation(tx.QueryRow(`SELECT `+invitationColumns+` FROM `+invitationFrom+` WHERE n.id = $1 AND n.status = 'pending' FOR UPDATE OF n`, id))
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`UPDATE itinerary_invitation SET status = 'cancelled', responded_at = NOW() WHERE id = $1`, id); err != nil {
+		return err
+	}
+	after := before
+	after.Status = models.InvitationCancelled
+	return recordAudit(ctx, tx, "itinerary_invitation", &id, AuditUpdate, before, after)
+}
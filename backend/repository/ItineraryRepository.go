@@ -1,25 +1,31 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"time"
 
+	"github.com/Joshua-Pok/FYP-backend/database"
 	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/lib/pq"
 )
 
+// ErrVersionConflict is returned when an itinerary was changed by someone else since the caller last read it
+var ErrVersionConflict = errors.New("itinerary version conflict")
+
 type ItineraryRepository struct {
-	db *sql.DB
+	db *database.Router
 }
 
-func NewItineraryRepository(db *sql.DB) *ItineraryRepository {
+func NewItineraryRepository(db *database.Router) *ItineraryRepository {
 	return &ItineraryRepository{db: db}
 }
 
-func (r *ItineraryRepository) CreateItinerary(userId int, title, description string, startDate, endDate time.Time, activities []models.Activity) (models.Itinerary, error) {
-
-	var itinerary models.Itinerary
-
-	tx, err := r.db.Begin()
+// CreateItinerary saves a new itinerary with unscheduled activities followed by already scheduled
+// items, such as those of a generated draft. It returns ErrOutsideTrip for items outside the dates.
+func (r *ItineraryRepository) CreateItinerary(ctx context.Context, userId int, title, description string, startDate, endDate time.Time, activities []models.Activity, items []models.ItineraryItem) (itinerary models.Itinerary, err error) {
+	tx, err := r.db.Writer(ctx).Begin()
 	if err != nil {
 		return itinerary, err
 	}
@@ -27,15 +33,18 @@ func (r *ItineraryRepository) CreateItinerary(userId int, title, description str
 		if err != nil {
 			tx.Rollback()
 		} else {
-			tx.Commit()
+			err = tx.Commit()
 		}
 	}()
 
-	query := `INSERT INTO itinerary (user_id, title, description, start_date, end_date) VALUES ($1, $2, $3, $4, $5) RETURNING id`
-	err = tx.QueryRow(query, userId, title, description, startDate, endDate).Scan(&itinerary.Id)
+	query := `INSERT INTO itinerary (user_id, title, description, start_date, end_date) VALUES ($1, $2, $3, $4, $5) RETURNING id, version`
+	err = tx.QueryRow(query, userId, title, description, startDate, endDate).Scan(&itinerary.Id, &itinerary.Version)
 	if err != nil {
 		return itinerary, err
 	}
+	if _, err = tx.Exec(`INSERT INTO itinerary_member (itinerary_id, user_id, role) VALUES ($1, $2, 'owner')`, itinerary.Id, userId); err != nil {
+		return itinerary, err
+	}
 	itinerary.User_id = userId
 	itinerary.Title = title
 	itinerary.Description = description
@@ -43,25 +52,96 @@ func (r *ItineraryRepository) CreateItinerary(userId int, title, description str
 	itinerary.End_date = endDate
 	itinerary.Activities = activities
 
-	stmt, err := tx.Prepare(`INSERT INTO itinerary_activity(itinerary_id, activity_id) VALUES ($1, $2)`)
+	// activities start out unscheduled, in the order they were given
+	stmt, err := tx.Prepare(`INSERT INTO itinerary_activity(itinerary_id, activity_id, position) VALUES ($1, $2, $3)`)
 	if err != nil {
 		return itinerary, err
 	}
 	defer stmt.Close()
 
-	for _, activity := range activities {
-		if _, err := stmt.Exec(itinerary.Id, activity.ID); err != nil {
+	var activityIDs []int
+	for i, activity := range activities {
+		if _, err = stmt.Exec(itinerary.Id, activity.ID, i); err != nil {
 			return itinerary, err
 		}
+		activityIDs = append(activityIDs, activity.ID)
+	}
+
+	itemStmt, err := tx.Prepare(`
+	INSERT INTO itinerary_activity (itinerary_id, activity_id, day, start_time, end_time, notes, position)
+	VALUES ($1, $2, $3, NULLIF($4, '')::TIME, NULLIF($5, '')::TIME, $6,
+		(SELECT COALESCE(MAX(position) + 1, 0) FROM itinerary_activity WHERE itinerary_id = $1 AND day IS NOT DISTINCT FROM $3::DATE))`)
+	if err != nil {
+		return itinerary, err
+	}
+	defer itemStmt.Close()
+	for _, item := range items {
+		if err = checkTripDay(item.Day, startDate, endDate); err != nil {
+			return itinerary, err
+		}
+		if _, err = itemStmt.Exec(itinerary.Id, item.ActivityID, item.Day, item.StartTime, item.EndTime, item.Notes); err != nil {
+			return itinerary, err
+		}
+		activityIDs = append(activityIDs, item.ActivityID)
+	}
+
+	err = recordAudit(ctx, tx, "itinerary", &itinerary.Id, AuditCreate, nil, itineraryAudit(itinerary, activityIDs))
+	return itinerary, err
+
+}
+
+func (r *ItineraryRepository) GetItineraryById(ctx context.Context, id int) (*models.Itinerary, error) {
+	db := r.db.Reader(ctx)
+	itinerary := &models.Itinerary{}
+	query := `SELECT id, user_id, title, description, start_date, end_date, version FROM itinerary WHERE id = $1 AND deleted_at IS NULL`
+
+	err := db.QueryRow(query, id).Scan(
+		&itinerary.Id,
+		&itinerary.User_id,
+		&itinerary.Title,
+		&itinerary.Description,
+		&itinerary.Start_date,
+		&itinerary.End_date,
+		&itinerary.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	activityQuery := `
+	SELECT ` + activityColumns + `
+	FROM ` + activityFrom + `
+	JOIN itinerary_activity ia ON a.id = ia.activity_id
+	WHERE ia.itinerary_id = $1 AND a.deleted_at IS NULL
+	ORDER BY ia.day NULLS LAST, ia.position, ia.id
+	`
+	rows, err := db.Query(activityQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	itinerary.Activities, err = scanActivities(rows)
+	if err != nil {
+		return nil, err
 	}
 	return itinerary, nil
+}
 
+// GetItineraryDates returns the first and last day of a live itinerary
+func (r *ItineraryRepository) GetItineraryDates(ctx context.Context, id int) (start, end time.Time, err error) {
+	query := `SELECT start_date, end_date FROM itinerary WHERE id = $1 AND deleted_at IS NULL`
+	err = r.db.Reader(ctx).QueryRow(query, id).Scan(&start, &end)
+	return start, end, err
 }
 
-func (r *ItineraryRepository) GetItinerariesByUser(userId int) ([]models.Itinerary, error) {
-	query := `SELECT id, title, description, start_date, end_date FROM itinerary WHERE user_id = $1`
+// GetItinerariesByUser lists the live itineraries a user is a member of along with their role on each
+func (r *ItineraryRepository) GetItinerariesByUser(ctx context.Context, userId int) ([]models.Itinerary, error) {
+	query := `
+	SELECT i.id, i.user_id, i.title, i.description, i.start_date, i.end_date, i.version, m.role
+	FROM itinerary i JOIN itinerary_member m ON m.itinerary_id = i.id
+	WHERE m.user_id = $1 AND i.deleted_at IS NULL
+	ORDER BY i.start_date, i.id`
 
-	rows, err := r.db.Query(query, userId)
+	rows, err := r.db.Reader(ctx).Query(query, userId)
 	if err != nil {
 		return nil, err
 	}
@@ -70,7 +150,16 @@ func (r *ItineraryRepository) GetItinerariesByUser(userId int) ([]models.Itinera
 	var itineraries []models.Itinerary
 	for rows.Next() {
 		var itinerary models.Itinerary
-		if err := rows.Scan(&itinerary.Activities, &itinerary.Activities); err != nil {
+		if err := rows.Scan(
+			&itinerary.Id,
+			&itinerary.User_id,
+			&itinerary.Title,
+			&itinerary.Description,
+			&itinerary.Start_date,
+			&itinerary.End_date,
+			&itinerary.Version,
+			&itinerary.Role,
+		); err != nil {
 			return nil, err
 		}
 		itineraries = append(itineraries, itinerary)
@@ -83,10 +172,12 @@ func (r *ItineraryRepository) GetItinerariesByUser(userId int) ([]models.Itinera
 	return itineraries, nil
 }
 
-func (r *ItineraryRepository) ModifyItinerary(id int, title string, description string, startDate string, endDate string, activities []models.Activity) (models.Itinerary, error) {
-	var itinerary models.Itinerary
-
-	tx, err := r.db.Begin()
+// ModifyItinerary updates an itinerary only if it is still at the given version. activities replaces the
+// unscheduled items, items planned on a day are left to the item endpoints and kept with their times and notes.
+// It returns ErrVersionConflict if another edit got there first, ErrOutsideTrip if scheduled items would fall
+// outside the new dates, and sql.ErrNoRows if the itinerary does not exist.
+func (r *ItineraryRepository) ModifyItinerary(ctx context.Context, id int, version int, title string, description string, startDate string, endDate string, activities []models.Activity) (itinerary models.Itinerary, err error) {
+	tx, err := r.db.Writer(ctx).Begin()
 	if err != nil {
 		return itinerary, err
 	}
@@ -94,12 +185,32 @@ func (r *ItineraryRepository) ModifyItinerary(id int, title string, description
 		if err != nil {
 			tx.Rollback()
 		} else {
-			tx.Commit()
+			err = tx.Commit()
 		}
 	}()
 
-	query := `UPDATE itineraries SET title = $1, description = $2, start_date = $3, end_date = $4 WHERE id = $5
-RETURNING id, user_id, title, description, start_date, end_date
+	// lock the row so the version check and the update see the same state
+	var before models.Itinerary
+	err = tx.QueryRow(`SELECT id, user_id, title, description, start_date, end_date, version FROM itinerary WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, id).Scan(
+		&before.Id,
+		&before.User_id,
+		&before.Title,
+		&before.Description,
+		&before.Start_date,
+		&before.End_date,
+		&before.Version,
+	)
+	if err != nil {
+		return itinerary, err
+	}
+	if before.Version != version {
+		err = ErrVersionConflict
+		return itinerary, err
+	}
+
+	query := `UPDATE itinerary SET title = $1, description = $2, start_date = $3, end_date = $4, version = version + 1
+	WHERE id = $5
+	RETURNING id, user_id, title, description, start_date, end_date, version
 	`
 
 	err = tx.QueryRow(query, title, description, startDate, endDate, id).Scan(
@@ -109,30 +220,435 @@ RETURNING id, user_id, title, description, start_date, end_date
 		&itinerary.Description,
 		&itinerary.Start_date,
 		&itinerary.End_date,
+		&itinerary.Version,
 	)
 	if err != nil {
 		return itinerary, err
 	}
 
-	// Update itinerary activities (optional: delete old ones first)
-	_, err = tx.Exec(`DELETE FROM itinerary_activity WHERE itinerary_id = $1`, id)
+	// scheduled items have to stay within the new dates
+	var outside bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM itinerary_activity WHERE itinerary_id = $1 AND day NOT BETWEEN $2 AND $3)`, id, itinerary.Start_date, itinerary.End_date).Scan(&outside)
 	if err != nil {
 		return itinerary, err
 	}
+	if outside {
+		err = ErrOutsideTrip
+		return itinerary, err
+	}
 
-	stmt, err := tx.Prepare(`INSERT INTO itinerary_activity(itinerary_id, activity_id) VALUES ($1, $2)`)
+	// only touch the activity rows that actually changed
+	rows, err := tx.Query(`SELECT activity_id FROM itinerary_activity WHERE itinerary_id = $1`, id)
 	if err != nil {
 		return itinerary, err
 	}
-	defer stmt.Close()
+	existing := make(map[int]bool)
+	var beforeIDs []int
+	for rows.Next() {
+		var activityID int
+		if err = rows.Scan(&activityID); err != nil {
+			rows.Close()
+			return itinerary, err
+		}
+		existing[activityID] = true
+		beforeIDs = append(beforeIDs, activityID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return itinerary, err
+	}
 
+	wanted := make(map[int]bool)
+	var toAdd, afterIDs []int
 	for _, activity := range activities {
-		if _, err := stmt.Exec(itinerary.Id, activity.ID); err != nil {
+		if wanted[activity.ID] {
+			continue
+		}
+		wanted[activity.ID] = true
+		afterIDs = append(afterIDs, activity.ID)
+		if !existing[activity.ID] {
+			toAdd = append(toAdd, activity.ID)
+		}
+	}
+	var toRemove []int
+	for activityID := range existing {
+		if !wanted[activityID] {
+			toRemove = append(toRemove, activityID)
+		}
+	}
+
+	if len(toRemove) > 0 {
+		_, err = tx.Exec(`DELETE FROM itinerary_activity WHERE itinerary_id = $1 AND day IS NULL AND activity_id = ANY($2)`, id, pq.Array(toRemove))
+		if err != nil {
 			return itinerary, err
 		}
 	}
+
+	if len(toAdd) > 0 {
+		var stmt *sql.Stmt
+		stmt, err = tx.Prepare(`
+		INSERT INTO itinerary_activity(itinerary_id, activity_id, position)
+		SELECT $1, $2, COALESCE(MAX(position) + 1, 0) FROM itinerary_activity WHERE itinerary_id = $1 AND day IS NULL`)
+		if err != nil {
+			return itinerary, err
+		}
+		defer stmt.Close()
+
+		for _, activityID := range toAdd {
+			if _, err = stmt.Exec(itinerary.Id, activityID); err != nil {
+				return itinerary, err
+			}
+		}
+	}
 	itinerary.Activities = activities
 
-	return itinerary, nil
+	err = recordAudit(ctx, tx, "itinerary", &itinerary.Id, AuditUpdate, itineraryAudit(before, beforeIDs), itineraryAudit(itinerary, afterIDs))
+	return itinerary, err
+
+}
+
+// GetDeletedItinerariesByUser lists the itineraries a user has moved to the trash, most recently deleted first
+func (r *ItineraryRepository) GetDeletedItinerariesByUser(ctx context.Context, userId int) ([]models.Itinerary, error) {
+	query := `SELECT id, user_id, title, description, start_date, end_date, version, deleted_at FROM itinerary
+	WHERE user_id = $1 AND deleted_at IS NOT NULL
+	ORDER BY deleted_at DESC`
+
+	rows, err := r.db.Reader(ctx).Query(query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
+	var itineraries []models.Itinerary
+	for rows.Next() {
+		var itinerary models.Itinerary
+		if err := rows.Scan(
+			&itinerary.Id,
+			&itinerary.User_id,
+			&itinerary.Title,
+			&itinerary.Description,
+			&itinerary.Start_date,
+			&itinerary.End_date,
+			&itinerary.Version,
+			&itinerary.Deleted_at,
+		); err != nil {
+			return nil, err
+		}
+		itineraries = append(itineraries, itinerary)
+	}
+
+	return itineraries, rows.Err()
+}
+
+func (r *ItineraryRepository) DeleteItinerary(ctx context.Context, id int) error {
+	return softDelete(ctx, r.db.Writer(ctx), "itinerary", id, true)
+}
+
+func (r *ItineraryRepository) RestoreItinerary(ctx context.Context, id int) error {
+	return softDelete(ctx, r.db.Writer(ctx), "itinerary", id, false)
+}
+
+// PurgeDeleted permanently removes itineraries that were deleted before the cutoff, their activity links go with them
+func (r *ItineraryRepository) PurgeDeleted(before time.Time) (int64, error) {
+	return purgeDeleted(r.db.Primary(), "itinerary", `deleted_at IS NOT NULL AND deleted_at < $1`, before)
+}
+
+// itineraryAudit is the snapshot of an itinerary written to the audit log,
+// activities are reduced to their ids so the diff only shows membership changes
+func itineraryAudit(itinerary models.Itinerary, activityIDs []int) map[string]interface{} {
+	if activityIDs == nil {
+		activityIDs = []int{}
+	}
+	return map[string]interface{}{
+		"user_id":      itinerary.User_id,
+		"title":        itinerary.Title,
+		"description":  itinerary.Description,
+		"start_date":   itinerary.Start_date,
+		"end_date":     itinerary.End_date,
+		"version":      itinerary.Version,
+		"activity_ids": activityIDs,
+	}
+}
+
+var (
+	// ErrOutsideTrip is returned when an item is scheduled on a day outside the itinerary's dates
+	ErrOutsideTrip = errors.New("day is outside the trip dates")
+	// ErrInvalidOrder is returned when a reorder does not list exactly the items of that day
+	ErrInvalidOrder = errors.New("item ids must list every item of the day exactly once")
+)
+
+const itineraryItemColumns = `ia.id, ia.itinerary_id, ia.activity_id, TO_CHAR(ia.day, 'YYYY-MM-DD'),
+	COALESCE(TO_CHAR(ia.start_time, 'HH24:MI'), ''), COALESCE(TO_CHAR(ia.end_time, 'HH24:MI'), ''), ia.position, ia.notes`
+
+func itineraryItemDest(item *models.ItineraryItem, day *sql.NullString) []interface{} {
+	return []interface{}{&item.ID, &item.ItineraryID, &item.ActivityID, day, &item.StartTime, &item.EndTime, &item.Position, &item.Notes}
+}
+
+func scanItineraryItem(row rowScanner) (models.ItineraryItem, error) {
+	var item models.ItineraryItem
+	var day sql.NullString
+	if err := row.Scan(itineraryItemDest(&item, &day)...); err != nil {
+		return item, err
+	}
+	if day.Valid {
+		item.Day = &day.String
+	}
+	return item, nil
+}
+
+// lockItinerary locks a live itinerary for an item change and bumps its version, so clients
+// holding an older ETag see the schedule changed. It returns the trip dates.
+func lockItinerary(tx *sql.Tx, id int) (start, end time.Time, err error) {
+	err = tx.QueryRow(`UPDATE itinerary SET version = version + 1 WHERE id = $1 AND deleted_at IS NULL RETURNING start_date, end_date`, id).Scan(&start, &end)
+	return start, end, err
+}
+
+// checkTripDay returns ErrOutsideTrip unless day is nil or falls within start and end
+func checkTripDay(day *string, start, end time.Time) error {
+	if day == nil {
+		return nil
+	}
+	d, err := time.Parse("2006-01-02", *day)
+	if err != nil {
+		return err
+	}
+	if d.Before(start) || d.After(end) {
+		return ErrOutsideTrip
+	}
+	return nil
+}
+
+// GetItinerarySchedule groups the items of a live itinerary by day in their planned order
+func (r *ItineraryRepository) GetItinerarySchedule(ctx context.Context, id int) (models.ItinerarySchedule, error) {
+	db := r.db.Reader(ctx)
+	schedule := models.ItinerarySchedule{ItineraryID: id, Days: []models.ItineraryDay{}, Unscheduled: []models.ItineraryItem{}}
+	var start, end time.Time
+	if err := db.QueryRow(`SELECT start_date, end_date FROM itinerary WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&start, &end); err != nil {
+		return schedule, err
+	}
+	schedule.StartDate = start.Format("2006-01-02")
+	schedule.EndDate = end.Format("2006-01-02")
+
+	byDay := make(map[string]int)
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		byDay[date] = len(schedule.Days)
+		schedule.Days = append(schedule.Days, models.ItineraryDay{Date: date, Items: []models.ItineraryItem{}})
+	}
+
+	query := `
+	SELECT ` + activityColumns + `, ` + itineraryItemColumns + `
+	FROM ` + activityFrom + `
+	JOIN itinerary_activity ia ON a.id = ia.activity_id
+	WHERE ia.itinerary_id = $1 AND a.deleted_at IS NULL
+	ORDER BY ia.day NULLS LAST, ia.position, ia.id`
+	rows, err := db.Query(query, id)
+	if err != nil {
+		return schedule, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.ItineraryItem
+		var day sql.NullString
+		activity, err := scanActivity(rows, itineraryItemDest(&item, &day)...)
+		if err != nil {
+			return schedule, err
+		}
+		item.Activity = &activity
+		if i, ok := byDay[day.String]; day.Valid && ok {
+			item.Day = &day.String
+			schedule.Days[i].Items = append(schedule.Days[i].Items, item)
+		} else {
+			// items left outside the trip by older data are listed with the unscheduled ones
+			schedule.Unscheduled = append(schedule.Unscheduled, item)
+		}
+	}
+	return schedule, rows.Err()
+}
+
+// AddItineraryItem appends an activity to the end of its day, or to the unscheduled items when
+// Day is nil. It returns sql.ErrNoRows when the itinerary or activity does not exist.
+func (r *ItineraryRepository) AddItineraryItem(ctx context.Context, item models.ItineraryItem) (added models.ItineraryItem, err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return added, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	start, end, err := lockItinerary(tx, item.ItineraryID)
+	if err != nil {
+		return added, err
+	}
+	if err = checkTripDay(item.Day, start, end); err != nil {
+		return added, err
+	}
+	var exists bool
+	if err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM activity WHERE id = $1 AND deleted_at IS NULL)`, item.ActivityID).Scan(&exists); err != nil {
+		return added, err
+	}
+	if !exists {
+		err = sql.ErrNoRows
+		return added, err
+	}
+
+	query := `
+	INSERT INTO itinerary_activity AS ia (itinerary_id, activity_id, day, start_time, end_time, notes, position)
+	VALUES ($1, $2, $3, NULLIF($4, '')::TIME, NULLIF($5, '')::TIME, $6,
+		(SELECT COALESCE(MAX(position) + 1, 0) FROM itinerary_activity WHERE itinerary_id = $1 AND day IS NOT DISTINCT FROM $3::DATE))
+	RETURNING ` + itineraryItemColumns
+	if added, err = scanItineraryItem(tx.QueryRow(query, item.ItineraryID, item.ActivityID, item.Day, item.StartTime, item.EndTime, item.Notes)); err != nil {
+		return added, err
+	}
+	err = recordAudit(ctx, tx, "itinerary_item", &added.ID, AuditCreate, nil, added)
+	return added, err
+}
+
+// UpdateItineraryItem changes the day, times and notes of an item. An item moved to another
+// day goes to the end of that day. It returns sql.ErrNoRows when the item does not exist.
+func (r *ItineraryRepository) UpdateItineraryItem(ctx context.Context, item models.ItineraryItem) (updated models.ItineraryItem, err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return updated, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var itineraryID int
+	if err = tx.QueryRow(`SELECT itinerary_id FROM itinerary_activity WHERE id = $1`, item.ID).Scan(&itineraryID); err != nil {
+		return updated, err
+	}
+	start, end, err := lockItinerary(tx, itineraryID)
+	if err != nil {
+		return updated, err
+	}
+	before, err := scanItineraryItem(tx.QueryRow(`SELECT `+itineraryItemColumns+` FROM itinerary_activity ia WHERE ia.id = $1`, item.ID))
+	if err != nil {
+		return updated, err
+	}
+	if err = checkTripDay(item.Day, start, end); err != nil {
+		return updated, err
+	}
+
+	query := `
+	UPDATE itinerary_activity ia SET day = $2, start_time = NULLIF($3, '')::TIME, end_time = NULLIF($4, '')::TIME, notes = $5,
+		position = CASE WHEN ia.day IS NOT DISTINCT FROM $2::DATE THEN ia.position
+			ELSE (SELECT COALESCE(MAX(o.position) + 1, 0) FROM itinerary_activity o WHERE o.itinerary_id = ia.itinerary_id AND o.day IS NOT DISTINCT FROM $2::DATE) END
+	WHERE ia.id = $1
+	RETURNING ` + itineraryItemColumns
+	if updated, err = scanItineraryItem(tx.QueryRow(query, item.ID, item.Day, item.StartTime, item.EndTime, item.Notes)); err != nil {
+		return updated, err
+	}
+	err = recordAudit(ctx, tx, "itinerary_item", &updated.ID, AuditUpdate, before, updated)
+	return updated, err
+}
+
+// ReorderItineraryItems sets the order of the items on one day, or of the unscheduled items
+// when day is nil. itemIDs must hold every item of that day exactly once.
+func (r *ItineraryRepository) ReorderItineraryItems(ctx context.Context, itineraryID int, day *string, itemIDs []int) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if _, _, err = lockItinerary(tx, itineraryID); err != nil {
+		return err
+	}
+	rows, err := tx.Query(`SELECT id FROM itinerary_activity WHERE itinerary_id = $1 AND day IS NOT DISTINCT FROM $2::DATE ORDER BY position, id`, itineraryID, day)
+	if err != nil {
+		return err
+	}
+	var beforeIDs []int
+	current := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		current[id] = true
+		beforeIDs = append(beforeIDs, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if len(itemIDs) != len(current) {
+		err = ErrInvalidOrder
+		return err
+	}
+	seen := make(map[int]bool)
+	for _, id := range itemIDs {
+		if !current[id] || seen[id] {
+			err = ErrInvalidOrder
+			return err
+		}
+		seen[id] = true
+	}
+
+	for position, id := range itemIDs {
+		if _, err = tx.Exec(`UPDATE itinerary_activity SET position = $2 WHERE id = $1`, id, position); err != nil {
+			return err
+		}
+	}
+	err = recordAudit(ctx, tx, "itinerary", &itineraryID, AuditUpdate,
+		map[string]interface{}{"day": day, "item_ids": beforeIDs},
+		map[string]interface{}{"day": day, "item_ids": itemIDs})
+	return err
+}
+
+// GetItineraryIdByItem returns the itinerary an item is in, sql.ErrNoRows when the item does not exist
+func (r *ItineraryRepository) GetItineraryIdByItem(ctx context.Context, itemID int) (int, error) {
+	var itineraryID int
+	err := r.db.Reader(ctx).QueryRow(`SELECT itinerary_id FROM itinerary_activity WHERE id = $1`, itemID).Scan(&itineraryID)
+	return itineraryID, err
+}
+
+// RemoveItineraryItem deletes an item and returns the itinerary it was in, sql.ErrNoRows when it does not exist
+func (r *ItineraryRepository) RemoveItineraryItem(ctx context.Context, itemID int) (itineraryID int, err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return itineraryID, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if err = tx.QueryRow(`SELECT itinerary_id FROM itinerary_activity WHERE id = $1`, itemID).Scan(&itineraryID); err != nil {
+		return itineraryID, err
+	}
+	if _, _, err = lockItinerary(tx, itineraryID); err != nil {
+		return itineraryID, err
+	}
+	before, err := scanItineraryItem(tx.QueryRow(`SELECT `+itineraryItemColumns+` FROM itinerary_activity ia WHERE ia.id = $1`, itemID))
+	if err != nil {
+		return itineraryID, err
+	}
+	if _, err = tx.Exec(`DELETE FROM itinerary_activity WHERE id = $1`, itemID); err != nil {
+		return itineraryID, err
+	}
+	err = recordAudit(ctx, tx, "itinerary_item", &itemID, AuditDelete, before, nil)
+	return itineraryID, err
 }
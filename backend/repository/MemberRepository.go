@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotMember is returned when an expense is paid by or split with someone outside the itinerary
+	ErrNotMember = errors.New("user is not a member of the itinerary")
+	// ErrMemberHasExpenses keeps members who paid for or share in expenses from being removed
+	ErrMemberHasExpenses = errors.New("member still has expenses on the itinerary")
+	ErrRemoveOwner       = errors.New("the owner cannot be removed from the itinerary, transfer ownership first")
+	// ErrOwnerRole is returned when changing the owner's role, which only changes by transferring ownership
+	ErrOwnerRole = errors.New("the owner's role changes only by transferring ownership")
+)
+
+type MemberRepository struct {
+	db *database.Router
+}
+
+func NewMemberRepository(db *database.Router) *MemberRepository {
+	return &MemberRepository{db: db}
+}
+
+const memberColumns = `m.itinerary_id, m.user_id, u.username, m.role, m.joined_at`
+
+const memberFrom = `itinerary_member m JOIN users u ON u.id = m.user_id`
+
+func scanMember(row rowScanner) (models.ItineraryMember, error) {
+	var m models.ItineraryMember
+	err := row.Scan(&m.ItineraryID, &m.UserID, &m.Username, &m.Role, &m.JoinedAt)
+	return m, err
+}
+
+// checkMembers returns ErrNotMember unless every user in userIDs is a member of the itinerary
+func checkMembers(tx *sql.Tx, itineraryID int, userIDs []int) error {
+	distinct := make(map[int]bool)
+	for _, id := range userIDs {
+		distinct[id] = true
+	}
+	var found int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM itinerary_member WHERE itinerary_id = $1 AND user_id = ANY($2)`,
+		itineraryID, pq.Array(userIDs)).Scan(&found); err != nil {
+		return err
+	}
+	if found != len(distinct) {
+		return ErrNotMember
+	}
+	return nil
+}
+
+// GetMembers lists the members of a live itinerary in the order they joined, sql.ErrNoRows if there is none
+func (r *MemberRepository) GetMembers(ctx context.Context, itineraryID int) ([]models.ItineraryMember, error) {
+	db := r.db.Reader(ctx)
+	var found int
+	if err := db.QueryRow(`SELECT id FROM itinerary WHERE id = $1 AND deleted_at IS NULL`, itineraryID).Scan(&found); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`SELECT `+memberColumns+` FROM `+memberFrom+` WHERE m.itinerary_id = $1 ORDER BY m.joined_at, m.user_id`, itineraryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.ItineraryMember{}
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// GetMemberByEmail returns the user with email as a member of an itinerary, sql.ErrNoRows if they are
+// not on it or their account is deleted
+func (r *MemberRepository) GetMemberByEmail(ctx context.Context, itineraryID int, email string) (models.ItineraryMember, error) {
+	return scanMember(r.db.Reader(ctx).QueryRow(`SELECT `+memberColumns+` FROM `+memberFrom+`
+	WHERE m.itinerary_id = $1 AND u.email = $2 AND u.deleted_at IS NULL`, itineraryID, email))
+}
+
+// getMemberForUpdate locks a member of an itinerary for the rest of tx, sql.ErrNoRows if there is none
+func getMemberForUpdate(tx *sql.Tx, itineraryID, userID int) (models.ItineraryMember, error) {
+	return scanMember(tx.QueryRow(`SELECT `+memberColumns+` FROM `+memberFrom+` WHERE m.itinerary_id = $1 AND m.user_id = $2 FOR UPDATE OF m`, itineraryID, userID))
+}
+
+// SetMemberRole makes a member an editor or a viewer, sql.ErrNoRows if they are not on a live
+// itinerary and ErrOwnerRole if they own it
+func (r *MemberRepository) SetMemberRole(ctx context.Context, itineraryID, userID int, role string) (member models.ItineraryMember, err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return member, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if err = lockLiveItinerary(tx, itineraryID); err != nil {
+		return member, err
+	}
+	before, err := getMemberForUpdate(tx, itineraryID, userID)
+	if err != nil {
+		return member, err
+	}
+	if before.Role == models.RoleOwner {
+		return member, ErrOwnerRole
+	}
+	member = before
+	member.Role = role
+	if _, err = tx.Exec(`UPDATE itinerary_member SET role = $3 WHERE itinerary_id = $1 AND user_id = $2`, itineraryID, userID, role); err != nil {
+		return member, err
+	}
+	err = recordAudit(ctx, tx, "itinerary_member", &itineraryID, AuditUpdate, before, member)
+	return member, err
+}
+
+// TransferOwnership hands a live itinerary to another member, the previous owner stays on as an
+// editor. sql.ErrNoRows means there is no such itinerary and ErrNotMember that the new owner is not on it.
+func (r *MemberRepository) TransferOwnership(ctx context.Context, itineraryID, userID int) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var ownerID int
+	if err = tx.QueryRow(`SELECT user_id FROM itinerary WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, itineraryID).Scan(&ownerID); err != nil {
+		return err
+	}
+	if ownerID == userID {
+		return nil
+	}
+	if _, err = getMemberForUpdate(tx, itineraryID, userID); errors.Is(err, sql.ErrNoRows) {
+		return ErrNotMember
+	} else if err != nil {
+		return err
+	}
+
+	return handOver(ctx, tx, itineraryID, ownerID, userID)
+}
+
+// handOver makes userID, already a member, the owner of an itinerary in place of ownerID, who stays on as an editor
+func handOver(ctx context.Context, tx *sql.Tx, itineraryID, ownerID, userID int) error {
+	// the old owner steps down first, there can only be one
+	if _, err := tx.Exec(`UPDATE itinerary_member SET role = 'editor' WHERE itinerary_id = $1 AND user_id = $2`, itineraryID, ownerID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE itinerary_member SET role = 'owner' WHERE itinerary_id = $1 AND user_id = $2`, itineraryID, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE itinerary SET user_id = $2, version = version + 1 WHERE id = $1`, itineraryID, userID); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "itinerary", &itineraryID, AuditUpdate, map[string]interface{}{"user_id": ownerID}, map[string]interface{}{"user_id": userID})
+}
+
+// RemoveMember takes a user off an itinerary, sql.ErrNoRows if they are not on it. The owner and
+// members with expenses stay, ErrRemoveOwner and ErrMemberHasExpenses say why.
+func (r *MemberRepository) RemoveMember(ctx context.Context, itineraryID, userID int) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var ownerID int
+	if err = tx.QueryRow(`SELECT user_id FROM itinerary WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, itineraryID).Scan(&ownerID); err != nil {
+		return err
+	}
+	if ownerID == userID {
+		return ErrRemoveOwner
+	}
+	before, err := getMemberForUpdate(tx, itineraryID, userID)
+	if err != nil {
+		return err
+	}
+	var involved bool
+	if err = tx.QueryRow(`
+	SELECT EXISTS (
+		SELECT 1 FROM itinerary_expense e LEFT JOIN itinerary_expense_share s ON s.expense_id = e.id
+		WHERE e.itinerary_id = $1 AND (e.paid_by = $2 OR s.user_id = $2))`, itineraryID, userID).Scan(&involved); err != nil {
+		return err
+	}
+	if involved {
+		return ErrMemberHasExpenses
+	}
+	if _, err = tx.Exec(`DELETE FROM itinerary_member WHERE itinerary_id = $1 AND user_id = $2`, itineraryID, userID); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "itinerary_member", &itineraryID, AuditDelete, before, nil)
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/lib/pq"
+)
+
+type OpeningHoursRepository struct {
+	db *database.Router
+}
+
+func NewOpeningHoursRepository(db *database.Router) *OpeningHoursRepository {
+	return &OpeningHoursRepository{db: db}
+}
+
+// loadOpeningHours reads the schedule of a live activity, sql.ErrNoRows if there is no such activity
+func loadOpeningHours(db queryer, activityID int) (models.OpeningHours, error) {
+	hours := models.OpeningHours{
+		ActivityID: activityID,
+		Weekly:     []models.WeeklyHours{},
+		Exceptions: []models.OpeningException{},
+		Closures:   []models.SeasonalClosure{},
+	}
+	query := `SELECT COALESCE(c.timezone, '') FROM activity a LEFT JOIN country c ON c.id = a.country_id WHERE a.id = $1 AND a.deleted_at IS NULL`
+	if err := db.QueryRow(query, activityID).Scan(&hours.Timezone); err != nil {
+		return hours, err
+	}
+
+	rows, err := db.Query(`
+	SELECT weekday, TO_CHAR(opens, 'HH24:MI'), TO_CHAR(closes, 'HH24:MI') FROM activity_hours
+	WHERE activity_id = $1 ORDER BY weekday, opens`, activityID)
+	if err != nil {
+		return hours, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var w models.WeeklyHours
+		if err := rows.Scan(&w.Weekday, &w.Opens, &w.Closes); err != nil {
+			return hours, err
+		}
+		hours.Weekly = append(hours.Weekly, w)
+	}
+	if err := rows.Err(); err != nil {
+		return hours, err
+	}
+
+	rows, err = db.Query(`
+	SELECT TO_CHAR(day, 'YYYY-MM-DD'), COALESCE(TO_CHAR(opens, 'HH24:MI'), ''), COALESCE(TO_CHAR(closes, 'HH24:MI'), ''), note
+	FROM activity_hours_exception WHERE activity_id = $1 ORDER BY day`, activityID)
+	if err != nil {
+		return hours, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e models.OpeningException
+		if err := rows.Scan(&e.Date, &e.Opens, &e.Closes, &e.Note); err != nil {
+			return hours, err
+		}
+		hours.Exceptions = append(hours.Exceptions, e)
+	}
+	if err := rows.Err(); err != nil {
+		return hours, err
+	}
+
+	rows, err = db.Query(`SELECT start_day, end_day, note FROM activity_closure WHERE activity_id = $1 ORDER BY start_day`, activityID)
+	if err != nil {
+		return hours, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c models.SeasonalClosure
+		if err := rows.Scan(&c.Start, &c.End, &c.Note); err != nil {
+			return hours, err
+		}
+		hours.Closures = append(hours.Closures, c)
+	}
+	return hours, rows.Err()
+}
+
+func (r *OpeningHoursRepository) GetOpeningHours(ctx context.Context, activityID int) (models.OpeningHours, error) {
+	return loadOpeningHours(r.db.Reader(ctx), activityID)
+}
+
+// GetOpeningHoursByActivities loads the hours of each live activity in ids, missing or deleted ones are left out
+func (r *OpeningHoursRepository) GetOpeningHoursByActivities(ctx context.Context, ids []int) (map[int]models.OpeningHours, error) {
+	db := r.db.Reader(ctx)
+	hours := make(map[int]models.OpeningHours, len(ids))
+	for _, id := range ids {
+		if _, ok := hours[id]; ok {
+			continue
+		}
+		h, err := loadOpeningHours(db, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		hours[id] = h
+	}
+	return hours, nil
+}
+
+// SetOpeningHours replaces the whole schedule of a live activity, sql.ErrNoRows if there is none
+func (r *OpeningHoursRepository) SetOpeningHours(ctx context.Context, activityID int, hours models.OpeningHours) (saved models.OpeningHours, err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return saved, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if _, err = tx.Exec(`SELECT 1 FROM activity WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, activityID); err != nil {
+		return saved, err
+	}
+	before, err := loadOpeningHours(tx, activityID)
+	if err != nil {
+		return saved, err
+	}
+
+	for _, table := range []string{"activity_hours", "activity_hours_exception", "activity_closure"} {
+		if _, err = tx.Exec(`DELETE FROM `+table+` WHERE activity_id = $1`, activityID); err != nil {
+			return saved, err
+		}
+	}
+	for _, w := range hours.Weekly {
+		if _, err = tx.Exec(`INSERT INTO activity_hours (activity_id, weekday, opens, closes) VALUES ($1, $2, $3, $4)`, activityID, w.Weekday, w.Opens, w.Closes); err != nil {
+			return saved, err
+		}
+	}
+	for _, e := range hours.Exceptions {
+		if _, err = tx.Exec(`INSERT INTO activity_hours_exception (activity_id, day, opens, closes, note) VALUES ($1, $2, NULLIF($3, '')::TIME, NULLIF($4, '')::TIME, $5)`, activityID, e.Date, e.Opens, e.Closes, e.Note); err != nil {
+			return saved, err
+		}
+	}
+	for _, c := range hours.Closures {
+		if _, err = tx.Exec(`INSERT INTO activity_closure (activity_id, start_day, end_day, note) VALUES ($1, $2, $3, $4)`, activityID, c.Start, c.End, c.Note); err != nil {
+			return saved, err
+		}
+	}
+
+	if saved, err = loadOpeningHours(tx, activityID); err != nil {
+		return saved, err
+	}
+	if err = recordAudit(ctx, tx, "activity_hours", &activityID, AuditUpdate, before, saved); err != nil {
+		return saved, err
+	}
+	return saved, nil
+}
+
+// FilterAvailable keeps the ids of activities that open on at least one local date between from and to
+func (r *OpeningHoursRepository) FilterAvailable(ctx context.Context, ids []int, from, to time.Time) (map[int]bool, error) {
+	rows, err := r.db.Reader(ctx).Query(`
+	SELECT id FROM activity WHERE id = ANY($1) AND activity_available(id, $2, $3)`,
+		pq.Array(ids), from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	available := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		available[id] = true
+	}
+	return available, rows.Err()
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 
 	"github.com/Joshua-Pok/FYP-backend/models"
@@ -14,10 +15,22 @@ func NewPersonalityRepository(db *sql.DB) *PersonalityRepository {
 	return &PersonalityRepository{db: db}
 }
 
-func (r *PersonalityRepository) CreatePersonality(p *models.Personality) error {
+func (r *PersonalityRepository) CreatePersonality(ctx context.Context, p *models.Personality) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
 	query :=
 		`INSERT INTO personality (user_id, openness, conscientiousness, extraversion, agreeableness, neuroticism) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
-	return r.db.QueryRow(
+	err = tx.QueryRow(
 		query,
 		p.User_id,
 		p.Openness,
@@ -26,6 +39,10 @@ func (r *PersonalityRepository) CreatePersonality(p *models.Personality) error {
 		p.Agreeableness,
 		p.Neuroticism,
 	).Scan(&p.Id)
+	if err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "personality", &p.Id, AuditCreate, nil, p)
 }
 
 func (r *PersonalityRepository) GetPersonalityByUser(userID int) (*models.Personality, error) {
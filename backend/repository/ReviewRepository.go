@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/lib/pq"
+)
+
+// ErrOwnReview is returned when a user votes on their own review
+var ErrOwnReview = errors.New("cannot vote on your own review")
+
+type ReviewRepository struct {
+	db *database.Router
+}
+
+func NewReviewRepository(db *database.Router) *ReviewRepository {
+	return &ReviewRepository{db: db}
+}
+
+const reviewColumns = `r.id, r.activity_id, r.user_id, u.username, r.rating, r.body, r.helpful_count, r.created_at, r.updated_at`
+
+const reviewFrom = `review r JOIN users u ON u.id = r.user_id`
+
+func scanReview(row rowScanner) (models.Review, error) {
+	var rv models.Review
+	err := row.Scan(&rv.ID, &rv.ActivityID, &rv.UserID, &rv.Username, &rv.Rating, &rv.Body, &rv.HelpfulCount, &rv.CreatedAt, &rv.UpdatedAt)
+	return rv, err
+}
+
+// refreshActivityRating recomputes the activity's average rating and review count from its reviews.
+// Callers lock the activity row first so concurrent reviews cannot interleave their updates.
+func refreshActivityRating(tx *sql.Tx, activityIDs ...int) error {
+	_, err := tx.Exec(`
+	UPDATE activity a SET
+		rating = (SELECT ROUND(AVG(rating), 2) FROM review WHERE activity_id = a.id),
+		review_count = (SELECT COUNT(*) FROM review WHERE activity_id = a.id)
+	WHERE a.id = ANY($1)`, pq.Array(activityIDs))
+	return err
+}
+
+// lockActivity locks a live activity for the rest of tx, returning sql.ErrNoRows if there is none
+func lockActivity(tx *sql.Tx, activityID int) error {
+	var id int
+	return tx.QueryRow(`SELECT id FROM activity WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, activityID).Scan(&id)
+}
+
+// CreateReview adds the user's review of an activity. A second review by the same user fails
+// with a unique violation, and sql.ErrNoRows means the activity does not exist.
+func (r *ReviewRepository) CreateReview(ctx context.Context, review *models.Review) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if err = lockActivity(tx, review.ActivityID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO review (activity_id, user_id, rating, body) VALUES ($1, $2, $3, $4) RETURNING id`
+	var id int
+	if err = tx.QueryRow(query, review.ActivityID, review.UserID, review.Rating, review.Body).Scan(&id); err != nil {
+		return err
+	}
+	if *review, err = scanReview(tx.QueryRow(`SELECT `+reviewColumns+` FROM `+reviewFrom+` WHERE r.id = $1`, id)); err != nil {
+		return err
+	}
+	if err = refreshActivityRating(tx, review.ActivityID); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "review", &review.ID, AuditCreate, nil, review)
+}
+
+func (r *ReviewRepository) GetReviewById(ctx context.Context, id int) (*models.Review, error) {
+	review, err := scanReview(r.db.Reader(ctx).QueryRow(`SELECT `+reviewColumns+` FROM `+reviewFrom+` WHERE r.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// GetReviewsByActivity returns a page of an activity's reviews along with the total number of reviews
+func (r *ReviewRepository) GetReviewsByActivity(ctx context.Context, filter models.ReviewFilter) ([]models.Review, int, error) {
+	orderBy := `r.created_at DESC, r.id DESC`
+	switch filter.Sort {
+	case models.ReviewSortHelpful:
+		orderBy = `r.helpful_count DESC, r.created_at DESC, r.id DESC`
+	case models.ReviewSortRatingHigh:
+		orderBy = `r.rating DESC, r.created_at DESC, r.id DESC`
+	case models.ReviewSortRatingLow:
+		orderBy = `r.rating ASC, r.created_at DESC, r.id DESC`
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+	db := r.db.Reader(ctx)
+
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM review WHERE activity_id = $1`, filter.ActivityID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + reviewColumns + ` FROM ` + reviewFrom + ` WHERE r.activity_id = $1 ORDER BY ` + orderBy + ` LIMIT $2 OFFSET $3`
+	rows, err := db.Query(query, filter.ActivityID, limit, filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	reviews := []models.Review{}
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, total, rows.Err()
+}
+
+// UpdateReview changes the rating and text of a review written by review.UserID,
+// returning sql.ErrNoRows if that user has no review with that id
+func (r *ReviewRepository) UpdateReview(ctx context.Context, review *models.Review) (err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var activityID int
+	if err = tx.QueryRow(`SELECT activity_id FROM review WHERE id = $1 AND user_id = $2`, review.ID, review.UserID).Scan(&activityID); err != nil {
+		return err
+	}
+	if err = lockActivity(tx, activityID); err != nil {
+		return err
+	}
+	before, err := scanReview(tx.QueryRow(`SELECT `+reviewColumns+` FROM `+reviewFrom+` WHERE r.id = $1 FOR UPDATE OF r`, review.ID))
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE review SET rating = $1, body = $2, updated_at = NOW() WHERE id = $3`
+	if _, err = tx.Exec(query, review.Rating, review.Body, review.ID); err != nil {
+		return err
+	}
+	if *review, err = scanReview(tx.QueryRow(`SELECT `+reviewColumns+` FROM `+reviewFrom+` WHERE r.id = $1`, review.ID)); err != nil {
+		return err
+	}
+	if err = refreshActivityRating(tx, activityID); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "review", &review.ID, AuditUpdate, before, review)
+}
+
+// DeleteReview removes a review written by userID, returning the deleted review
+func (r *ReviewRepository) DeleteReview(ctx context.Context, id, userID int) (review models.Review, err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return review, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var activityID int
+	if err = tx.QueryRow(`SELECT activity_id FROM review WHERE id = $1 AND user_id = $2`, id, userID).Scan(&activityID); err != nil {
+		return review, err
+	}
+	// the activity itself may already be soft deleted, lock it either way
+	if _, err = tx.Exec(`SELECT id FROM activity WHERE id = $1 FOR UPDATE`, activityID); err != nil {
+		return review, err
+	}
+	if review, err = scanReview(tx.QueryRow(`SELECT `+reviewColumns+` FROM `+reviewFrom+` WHERE r.id = $1`, id)); err != nil {
+		return review, err
+	}
+	if _, err = tx.Exec(`DELETE FROM review WHERE id = $1`, id); err != nil {
+		return review, err
+	}
+	if err = refreshActivityRating(tx, activityID); err != nil {
+		return review, err
+	}
+	return review, recordAudit(ctx, tx, "review", &id, AuditDelete, review, nil)
+}
+
+// SetHelpfulVote records or withdraws userID's helpful vote on a review, returning the new helpful count.
+// Voting twice or withdrawing a vote that was never cast leaves the count unchanged.
+func (r *ReviewRepository) SetHelpfulVote(ctx context.Context, reviewID, userID int, helpful bool) (count int, err error) {
+	tx, err := r.db.Writer(ctx).Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var authorID int
+	if err = tx.QueryRow(`SELECT user_id, helpful_count FROM review WHERE id = $1 FOR UPDATE`, reviewID).Scan(&authorID, &count); err != nil {
+		return 0, err
+	}
+	if authorID == userID {
+		return 0, ErrOwnReview
+	}
+
+	var res sql.Result
+	if helpful {
+		res, err = tx.Exec(`INSERT INTO review_vote (review_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, reviewID, userID)
+	} else {
+		res, err = tx.Exec(`DELETE FROM review_vote WHERE review_id = $1 AND user_id = $2`, reviewID, userID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	changed, err := res.RowsAffected()
+	if err != nil || changed == 0 {
+		return count, err
+	}
+
+	if err = tx.QueryRow(`UPDATE review SET helpful_count = (SELECT COUNT(*) FROM review_vote WHERE review_id = $1) WHERE id = $1 RETURNING helpful_count`, reviewID).Scan(&count); err != nil {
+		return 0, err
+	}
+	vote := map[string]interface{}{"review_id": reviewID, "user_id": userID}
+	if helpful {
+		err = recordAudit(ctx, tx, "review_vote", &reviewID, AuditCreate, nil, vote)
+	} else {
+		err = recordAudit(ctx, tx, "review_vote", &reviewID, AuditDelete, vote, nil)
+	}
+	return count, err
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
+
+type SuggestionRepository struct {
+	db *database.Router
+}
+
+func NewSuggestionRepository(db *database.Router) *SuggestionRepository {
+	return &SuggestionRepository{db: db}
+}
+
+// GetSuggestionCandidates returns every activity title and country name that autocomplete can offer
+func (r *SuggestionRepository) GetSuggestionCandidates(ctx context.Context) ([]models.Suggestion, error) {
+	query := `
+	SELECT 'activity', id, title FROM activity WHERE deleted_at IS NULL
+	UNION ALL
+	SELECT 'country', id, name FROM country
+	`
+	rows, err := r.db.Reader(ctx).Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suggestions []models.Suggestion
+	for rows.Next() {
+		var s models.Suggestion
+		if err := rows.Scan(&s.Type, &s.ID, &s.Text); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
@@ -1,7 +1,10 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"time"
+
 	"github.com/Joshua-Pok/FYP-backend/models"
 )
 
@@ -15,21 +18,100 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 
 func (r *UserRepository) GetUserById(id int) (*models.User, error) {
 	user := &models.User{}
-	query := `SELECT id, username, name, email FROM users WHERE id = $1`
-	err := r.db.QueryRow(query, id).Scan(&user.ID, &user.UserName, &user.Name, &user.Email)
+	query := `SELECT id, username, name, email, COALESCE(preferred_currency, '') FROM users WHERE id = $1 AND deleted_at IS NULL`
+	err := r.db.QueryRow(query, id).Scan(&user.ID, &user.UserName, &user.Name, &user.Email, &user.PreferredCurrency)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func (r *UserRepository) CreateUser(user *models.User) error {
+// GetUserByEmail looks up a live user by the email their token was issued for
+func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
+	user := &models.User{}
+	query := `SELECT id, username, name, email, COALESCE(preferred_currency, '') FROM users WHERE email = $1 AND deleted_at IS NULL`
+	err := r.db.QueryRow(query, email).Scan(&user.ID, &user.UserName, &user.Name, &user.Email, &user.PreferredCurrency)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetAccountIdByEmail returns the id of the account a token was issued for, deleted or not, so
+// a deleted user can still restore themselves
+func (r *UserRepository) GetAccountIdByEmail(email string) (int, error) {
+	var id int
+	err := r.db.QueryRow(`SELECT id FROM users WHERE email = $1`, email).Scan(&id)
+	return id, err
+}
+
+// GetUserByUsername looks up a live user by their username
+func (r *UserRepository) GetUserByUsername(username string) (*models.User, error) {
+	user := &models.User{}
+	query := `SELECT id, username, name, email, COALESCE(preferred_currency, '') FROM users WHERE username = $1 AND deleted_at IS NULL`
+	err := r.db.QueryRow(query, username).Scan(&user.ID, &user.UserName, &user.Name, &user.Email, &user.PreferredCurrency)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
 	query := "INSERT INTO users (username, name, email, password) VALUES ($1, $2, $3, $4) RETURNING id"
-	return r.db.QueryRow(query, user.UserName, user.Name, user.Email, user.Password).Scan(&user.ID)
+	if err = tx.QueryRow(query, user.UserName, user.Name, user.Email, user.Password).Scan(&user.ID); err != nil {
+		return err
+	}
+
+	// never write the password hash to the audit log
+	after := map[string]interface{}{
+		"username": user.UserName,
+		"name":     user.Name,
+		"email":    user.Email,
+	}
+	return recordAudit(ctx, tx, "users", &user.ID, AuditCreate, nil, after)
+}
+
+// SetPreferredCurrency changes the currency prices are shown in for the user, an empty code clears it
+func (r *UserRepository) SetPreferredCurrency(ctx context.Context, id int, code string) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var before string
+	query := `SELECT COALESCE(preferred_currency, '') FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+	if err = tx.QueryRow(query, id).Scan(&before); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`UPDATE users SET preferred_currency = NULLIF($2, '') WHERE id = $1`, id, code); err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, "users", &id, AuditUpdate,
+		map[string]interface{}{"preferred_currency": before},
+		map[string]interface{}{"preferred_currency": code})
 }
 
 func (r *UserRepository) GetAllUsers() ([]models.User, error) {
-	query := `SELECT id, username, name, email FROM users`
+	query := `SELECT id, username, name, email FROM users WHERE deleted_at IS NULL`
 	rows, err := r.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -39,7 +121,7 @@ func (r *UserRepository) GetAllUsers() ([]models.User, error) {
 	var users []models.User
 	for rows.Next() {
 		var user models.User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Email); err != nil {
+		if err := rows.Scan(&user.ID, &user.UserName, &user.Name, &user.Email); err != nil {
 			return nil, err
 		}
 		users = append(users, user)
@@ -47,3 +129,142 @@ func (r *UserRepository) GetAllUsers() ([]models.User, error) {
 
 	return users, rows.Err()
 }
+
+func (r *UserRepository) DeleteUser(ctx context.Context, id int) error {
+	return softDelete(ctx, r.db, "users", id, true)
+}
+
+func (r *UserRepository) RestoreUser(ctx context.Context, id int) error {
+	return softDelete(ctx, r.db, "users", id, false)
+}
+
+// DeletedUserID is the placeholder account, added by the deleted_user migration, that purged users'
+// expenses are handed to
+const DeletedUserID = 0
+
+// PurgeDeleted permanently removes users deleted before the cutoff along with their personality, itineraries
+// and reviews, keeping the ratings and helpful counts of what they reviewed or voted on in step. An itinerary
+// someone else is on is handed to its longest-standing member instead, as TransferOwnership would, so only
+// the ones they had to themselves go. Expenses they paid or share in on the trips that stay are kept and
+// handed to DeletedUserID, so the other travellers' balances are unchanged.
+func (r *UserRepository) PurgeDeleted(before time.Time) (n int64, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	purged := `SELECT id FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1 AND id <> $2`
+	successors, err := itinerarySuccessors(tx, purged, before, DeletedUserID)
+	if err != nil {
+		return 0, err
+	}
+	for _, s := range successors {
+		if err = handOver(context.Background(), tx, s.itineraryID, s.ownerID, s.userID); err != nil {
+			return 0, err
+		}
+	}
+	if _, err = tx.Exec(`DELETE FROM itinerary WHERE user_id IN (`+purged+`)`, before, DeletedUserID); err != nil {
+		return 0, err
+	}
+	// shares of several purged users in one expense merge into a single share of the placeholder
+	if _, err = tx.Exec(`
+	INSERT INTO itinerary_expense_share AS s (expense_id, user_id, shares, amount_minor)
+	SELECT expense_id, $2, SUM(shares), SUM(amount_minor) FROM itinerary_expense_share
+	WHERE user_id IN (`+purged+`) GROUP BY expense_id
+	ON CONFLICT (expense_id, user_id) DO UPDATE SET shares = s.shares + EXCLUDED.shares, amount_minor = s.amount_minor + EXCLUDED.amount_minor`,
+		before, DeletedUserID); err != nil {
+		return 0, err
+	}
+	if _, err = tx.Exec(`DELETE FROM itinerary_expense_share WHERE user_id IN (`+purged+`)`, before, DeletedUserID); err != nil {
+		return 0, err
+	}
+	if _, err = tx.Exec(`UPDATE itinerary_expense SET paid_by = $2 WHERE paid_by IN (`+purged+`)`, before, DeletedUserID); err != nil {
+		return 0, err
+	}
+	if _, err = tx.Exec(`DELETE FROM personality WHERE user_id IN (`+purged+`)`, before, DeletedUserID); err != nil {
+		return 0, err
+	}
+	if _, err = tx.Exec(`
+	WITH votes AS (DELETE FROM review_vote WHERE user_id IN (`+purged+`) RETURNING review_id)
+	UPDATE review SET helpful_count = helpful_count - v.n
+	FROM (SELECT review_id, COUNT(*) AS n FROM votes GROUP BY review_id) v
+	WHERE review.id = v.review_id`, before, DeletedUserID); err != nil {
+		return 0, err
+	}
+	reviewed, err := deletedReviewActivities(tx, `DELETE FROM review WHERE user_id IN (`+purged+`) RETURNING activity_id`, before, DeletedUserID)
+	if err != nil {
+		return 0, err
+	}
+	if err = refreshActivityRating(tx, reviewed...); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`DELETE FROM users WHERE id IN (`+purged+`)`, before, DeletedUserID)
+	if err != nil {
+		return 0, err
+	}
+	n, err = res.RowsAffected()
+	if err != nil || n == 0 {
+		return n, err
+	}
+	return n, recordPurge(tx, "users", n, before)
+}
+
+// successor is the member a purged owner's itinerary goes to
+type successor struct {
+	itineraryID, ownerID, userID int
+}
+
+// itinerarySuccessors finds the longest-standing live member of each live itinerary owned by one of the purged users
+func itinerarySuccessors(tx *sql.Tx, purged string, args ...interface{}) ([]successor, error) {
+	rows, err := tx.Query(`
+	SELECT DISTINCT ON (i.id) i.id, i.user_id, m.user_id
+	FROM itinerary i
+	JOIN itinerary_member m ON m.itinerary_id = i.id AND m.user_id <> i.user_id
+	JOIN users u ON u.id = m.user_id
+	WHERE i.user_id IN (`+purged+`) AND i.deleted_at IS NULL AND u.deleted_at IS NULL
+	ORDER BY i.id, m.joined_at, m.user_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var successors []successor
+	for rows.Next() {
+		var s successor
+		if err := rows.Scan(&s.itineraryID, &s.ownerID, &s.userID); err != nil {
+			return nil, err
+		}
+		successors = append(successors, s)
+	}
+	return successors, rows.Err()
+}
+
+// deletedReviewActivities runs a review DELETE ... RETURNING activity_id and collects the distinct activities
+func deletedReviewActivities(tx *sql.Tx, query string, args ...interface{}) ([]int, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[int]bool)
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
@@ -1,9 +1,57 @@
 package repository
 
-import "github.com/Joshua-Pok/FYP-backend/models"
+import (
+	"context"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/geo"
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
 
 type UserRepositoryInterface interface {
 	GetUserById(id int) (*models.User, error)
-	CreateUser(user *models.User) error
+	GetAccountIdByEmail(email string) (int, error)
+	CreateUser(ctx context.Context, user *models.User) error
 	GetAllUsers() ([]models.User, error)
+	DeleteUser(ctx context.Context, id int) error
+	RestoreUser(ctx context.Context, id int) error
+}
+
+type CountryRepositoryInterface interface {
+	CreateCountry(ctx context.Context, country *models.Country) error
+	GetCountryById(ctx context.Context, id int) (*models.Country, error)
+	GetCountryByCode(ctx context.Context, isoCode string) (*models.Country, error)
+	GetAllCountries(ctx context.Context) ([]models.Country, error)
+	UpdateCountry(ctx context.Context, country *models.Country) error
+	DeleteCountry(ctx context.Context, id int) error
+}
+
+type ActivityRepositoryInterface interface {
+	CreateActivity(ctx context.Context, input models.Activity) (models.Activity, error)
+	GetActivityById(ctx context.Context, activityID int) (*models.Activity, error)
+	GetActivitiesByIds(ctx context.Context, ids []string) ([]models.Activity, error)
+	ListActivities(ctx context.Context, filter models.ActivityListFilter) ([]models.Activity, error)
+	SetActivityTaxonomy(ctx context.Context, activityID int, categories, tags []string) (models.Activity, error)
+	GetCategoryLineage(ctx context.Context, activityID int) ([]string, error)
+	DeleteActivity(ctx context.Context, activityID int) error
+	RestoreActivity(ctx context.Context, activityID int) error
+	SearchActivities(ctx context.Context, filter models.ActivitySearchFilter) ([]models.ActivitySearchResult, error)
+	GetActivitiesNearby(ctx context.Context, center geo.Point, radiusMeters float64, limit int, filter models.ActivityTaxonomyFilter) ([]models.NearbyActivity, error)
+}
+
+type ItineraryRepositoryInterface interface {
+	CreateItinerary(ctx context.Context, userId int, title, description string, startDate, endDate time.Time, activities []models.Activity, items []models.ItineraryItem) (models.Itinerary, error)
+	GetItineraryById(ctx context.Context, id int) (*models.Itinerary, error)
+	GetItinerariesByUser(ctx context.Context, userId int) ([]models.Itinerary, error)
+	GetDeletedItinerariesByUser(ctx context.Context, userId int) ([]models.Itinerary, error)
+	GetItineraryDates(ctx context.Context, id int) (start, end time.Time, err error)
+	GetItinerarySchedule(ctx context.Context, id int) (models.ItinerarySchedule, error)
+	ModifyItinerary(ctx context.Context, id int, version int, title string, description string, startDate string, endDate string, activities []models.Activity) (models.Itinerary, error)
+	DeleteItinerary(ctx context.Context, id int) error
+	RestoreItinerary(ctx context.Context, id int) error
+	AddItineraryItem(ctx context.Context, item models.ItineraryItem) (models.ItineraryItem, error)
+	UpdateItineraryItem(ctx context.Context, item models.ItineraryItem) (models.ItineraryItem, error)
+	ReorderItineraryItems(ctx context.Context, itineraryID int, day *string, itemIDs []int) error
+	GetItineraryIdByItem(ctx context.Context, itemID int) (int, error)
+	RemoveItineraryItem(ctx context.Context, itemID int) (int, error)
 }
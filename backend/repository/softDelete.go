@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// softDelete sets or clears deleted_at on a single row of table and records the change in the audit log.
+// It reports sql.ErrNoRows when the row does not exist or is already in the requested state,
+// so callers can tell that apart from a database failure. table must be a trusted identifier.
+func softDelete(ctx context.Context, db *sql.DB, table string, id int, deleted bool) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var before *time.Time
+	err = tx.QueryRow(`SELECT deleted_at FROM `+table+` WHERE id = $1 FOR UPDATE`, id).Scan(&before)
+	if err != nil {
+		return err
+	}
+	if (before != nil) == deleted {
+		return sql.ErrNoRows
+	}
+
+	var after *time.Time
+	action := AuditRestore
+	if deleted {
+		action = AuditDelete
+		err = tx.QueryRow(`UPDATE `+table+` SET deleted_at = NOW() WHERE id = $1 RETURNING deleted_at`, id).Scan(&after)
+	} else {
+		_, err = tx.Exec(`UPDATE `+table+` SET deleted_at = NULL WHERE id = $1`, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	return recordAudit(ctx, tx, table, &id, action, map[string]interface{}{"deleted_at": before}, map[string]interface{}{"deleted_at": after})
+}
+
+// purgeDeleted permanently removes rows of table matching where (with $1 bound to the cutoff)
+// and logs the purge in the same transaction
+func purgeDeleted(db *sql.DB, table string, where string, before time.Time) (n int64, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	res, err := tx.Exec(`DELETE FROM `+table+` WHERE `+where, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err = res.RowsAffected()
+	if err != nil || n == 0 {
+		return n, err
+	}
+	return n, recordPurge(tx, table, n, before)
+}
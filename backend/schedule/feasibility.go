@@ -0,0 +1,139 @@
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/geo"
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
+
+// Limits bounds how much a single day of a trip should hold, zero disables a limit
+type Limits struct {
+	MaxItemsPerDay int
+	MaxHoursPerDay time.Duration
+	// average door to door speed used to estimate travel between activities
+	TravelSpeedKmh float64
+}
+
+// timedItem is an item with a start time, in minutes after midnight of its day
+type timedItem struct {
+	item       models.ItineraryItem
+	start, end int
+}
+
+func activityLocation(a *models.Activity) (geo.Point, bool) {
+	if a == nil || a.Latitude == nil || a.Longitude == nil {
+		return geo.Point{}, false
+	}
+	return geo.Point{Lat: *a.Latitude, Lng: *a.Longitude}, true
+}
+
+func activityTitle(item models.ItineraryItem) string {
+	if item.Activity != nil && item.Activity.Title != "" {
+		return item.Activity.Title
+	}
+	if item.Activity != nil && item.Activity.Name != "" {
+		return item.Activity.Name
+	}
+	return fmt.Sprintf("activity %d", item.ActivityID)
+}
+
+// Check reports overlapping items, visits outside opening hours, gaps too short to travel between
+// activities and days holding more than limits allow. hours is keyed by activity id, activities
+// missing from it count as always open. Unscheduled items are not checked.
+func Check(s models.ItinerarySchedule, hours map[int]models.OpeningHours, limits Limits) []models.ScheduleWarning {
+	warnings := []models.ScheduleWarning{}
+	for _, day := range s.Days {
+		warnings = append(warnings, checkDay(day, hours, limits)...)
+	}
+	return warnings
+}
+
+func checkDay(day models.ItineraryDay, hours map[int]models.OpeningHours, limits Limits) []models.ScheduleWarning {
+	var warnings []models.ScheduleWarning
+	warn := func(kind, message string, items ...models.ItineraryItem) {
+		ids := make([]int, len(items))
+		for i, item := range items {
+			ids[i] = item.ID
+		}
+		warnings = append(warnings, models.ScheduleWarning{Kind: kind, Day: day.Date, ItemIDs: ids, Message: message})
+	}
+	date, err := time.Parse(DateLayout, day.Date)
+	if err != nil {
+		return nil
+	}
+
+	var timed []timedItem
+	for _, item := range day.Items {
+		h := hours[item.ActivityID]
+		loc := Location(h)
+		local := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+		if item.StartTime == "" {
+			if !OpenOn(h, local) {
+				warn(models.WarningOutsideHours, activityTitle(item)+" is closed on "+day.Date, item)
+			}
+			continue
+		}
+
+		start, err := clockMinutes(item.StartTime)
+		if err != nil {
+			continue
+		}
+		end := start
+		if e, err := clockMinutes(item.EndTime); err == nil {
+			end = e
+		}
+		timed = append(timed, timedItem{item, start, end})
+
+		open := IsOpen(h, local.Add(time.Duration(start)*time.Minute))
+		if open && end > start {
+			// the last minute of the visit, closing time itself is exclusive
+			open = IsOpen(h, local.Add(time.Duration(end-1)*time.Minute))
+		}
+		if !open {
+			warn(models.WarningOutsideHours, activityTitle(item)+" is not open for the whole visit", item)
+		}
+	}
+
+	sort.SliceStable(timed, func(i, j int) bool { return timed[i].start < timed[j].start })
+	var busy time.Duration
+	// last is the visit ending latest so far, later visits have to start after it
+	var last timedItem
+	for i, t := range timed {
+		busy += time.Duration(t.end-t.start) * time.Minute
+		if i == 0 {
+			last = t
+			continue
+		}
+		if t.start < last.end {
+			warn(models.WarningOverlap, activityTitle(last.item)+" overlaps "+activityTitle(t.item), last.item, t.item)
+			if t.end > last.end {
+				last = t
+			}
+			continue
+		}
+		prev := last
+		last = t
+		from, ok := activityLocation(prev.item.Activity)
+		to, ok2 := activityLocation(t.item.Activity)
+		if !ok || !ok2 {
+			continue
+		}
+		travel := geo.TravelTime(from, to, limits.TravelSpeedKmh)
+		busy += travel
+		if gap := time.Duration(t.start-prev.end) * time.Minute; gap < travel {
+			warn(models.WarningTightGap, fmt.Sprintf("about %d minutes are needed to get from %s to %s but only %d are planned",
+				int(travel.Round(time.Minute).Minutes()), activityTitle(prev.item), activityTitle(t.item), int(gap.Minutes())), prev.item, t.item)
+		}
+	}
+
+	if limits.MaxItemsPerDay > 0 && len(day.Items) > limits.MaxItemsPerDay {
+		warn(models.WarningDayOverloaded, fmt.Sprintf("%d activities planned, more than the suggested %d", len(day.Items), limits.MaxItemsPerDay), day.Items...)
+	} else if limits.MaxHoursPerDay > 0 && busy > limits.MaxHoursPerDay {
+		warn(models.WarningDayOverloaded, fmt.Sprintf("%.1f hours of activities and travel planned, more than the suggested %.0f",
+			busy.Hours(), limits.MaxHoursPerDay.Hours()), day.Items...)
+	}
+	return warnings
+}
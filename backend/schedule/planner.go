@@ -0,0 +1,180 @@
+package schedule
+
+import (
+	"math"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/geo"
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
+
+// Pace is how full a generated day is
+type Pace string
+
+const (
+	PaceRelaxed  Pace = "relaxed"
+	PaceModerate Pace = "moderate"
+	PacePacked   Pace = "packed"
+)
+
+// pacing is the number of visits a pace plans per day and how long each takes
+type pacing struct {
+	items int
+	visit int
+}
+
+var paces = map[Pace]pacing{
+	PaceRelaxed:  {items: 2, visit: 180},
+	PaceModerate: {items: 3, visit: 120},
+	PacePacked:   {items: 5, visit: 90},
+}
+
+// ValidPace reports whether p is one of the known paces
+func ValidPace(p Pace) bool {
+	_, ok := paces[p]
+	return ok
+}
+
+const (
+	// generated days run from 09:00 to 21:00 local time with visits starting on the quarter hour
+	dayStart    = 9 * 60
+	dayEnd      = 21 * 60
+	slotMinutes = 15
+	// a day stays within this distance of its first activity while there are candidates close enough
+	clusterRadiusMeters = 5000
+)
+
+// Candidate is an activity the planner may use, Cost is its price in the budget's currency
+type Candidate struct {
+	Activity models.Activity
+	Hours    models.OpeningHours
+	Cost     int64
+}
+
+type PlanRequest struct {
+	StartDate time.Time
+	EndDate   time.Time
+	Pace      Pace
+	// total spend allowed in the candidates' cost currency, zero for no limit
+	Budget int64
+	Limits Limits
+}
+
+// Plan is a generated day by day schedule and what it costs
+type Plan struct {
+	Days []models.ItineraryDay
+	Cost int64
+}
+
+func clock(minutes int) string {
+	return time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(minutes) * time.Minute).Format(ClockLayout)
+}
+
+// slot finds the first quarter hour at or after earliest when the visit fits in both the day and the opening hours
+func slot(hours models.OpeningHours, day time.Time, earliest, visit int) (int, bool) {
+	loc := Location(hours)
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+	if !OpenOn(hours, midnight) {
+		return 0, false
+	}
+	start := (earliest + slotMinutes - 1) / slotMinutes * slotMinutes
+	for ; start+visit <= dayEnd; start += slotMinutes {
+		if IsOpen(hours, midnight.Add(time.Duration(start)*time.Minute)) &&
+			IsOpen(hours, midnight.Add(time.Duration(start+visit-1)*time.Minute)) {
+			return start, true
+		}
+	}
+	return 0, false
+}
+
+// Generate fills every day between the request's dates from candidates, which are ordered best first.
+// Each activity is used at most once. A day starts with the best candidate still unused and then
+// prefers candidates near it, every visit fits the activity's opening hours with time to travel
+// from the previous one, and nothing is picked that would take the plan over budget or a day
+// over its limits. Activities without coordinates are not clustered. Days nothing fits into are left empty.
+func Generate(req PlanRequest, candidates []Candidate) Plan {
+	pace, ok := paces[req.Pace]
+	if !ok {
+		pace = paces[PaceModerate]
+	}
+	perDay := pace.items
+	if req.Limits.MaxItemsPerDay > 0 && req.Limits.MaxItemsPerDay < perDay {
+		perDay = req.Limits.MaxItemsPerDay
+	}
+
+	plan := Plan{Days: []models.ItineraryDay{}}
+	used := make([]bool, len(candidates))
+	for day := req.StartDate; !day.After(req.EndDate); day = day.AddDate(0, 0, 1) {
+		date := day.Format(DateLayout)
+		planned := models.ItineraryDay{Date: date, Items: []models.ItineraryItem{}}
+
+		cursor := dayStart
+		var busy time.Duration
+		var prev, anchor *geo.Point
+		for len(planned.Items) < perDay {
+			pick, pickStart, pickTravel := -1, 0, time.Duration(0)
+			var fallback, fallbackStart int
+			var fallbackTravel time.Duration
+			fallbackDistance := -1.0
+			for i, c := range candidates {
+				if used[i] || (req.Budget > 0 && plan.Cost+c.Cost > req.Budget) {
+					continue
+				}
+				var travel time.Duration
+				at, located := activityLocation(&c.Activity)
+				if located && prev != nil {
+					travel = geo.TravelTime(*prev, at, req.Limits.TravelSpeedKmh)
+				}
+				if req.Limits.MaxHoursPerDay > 0 && busy+travel+time.Duration(pace.visit)*time.Minute > req.Limits.MaxHoursPerDay {
+					continue
+				}
+				start, ok := slot(c.Hours, day, cursor+int(math.Ceil(travel.Minutes())), pace.visit)
+				if !ok {
+					continue
+				}
+				if anchor == nil || !located {
+					pick, pickStart, pickTravel = i, start, travel
+					break
+				}
+				distance := geo.DistanceMeters(*anchor, at)
+				if distance <= clusterRadiusMeters {
+					pick, pickStart, pickTravel = i, start, travel
+					break
+				}
+				if fallbackDistance < 0 || distance < fallbackDistance {
+					fallback, fallbackStart, fallbackTravel, fallbackDistance = i, start, travel, distance
+				}
+			}
+			if pick < 0 && fallbackDistance >= 0 {
+				pick, pickStart, pickTravel = fallback, fallbackStart, fallbackTravel
+			}
+			if pick < 0 {
+				break
+			}
+
+			c := candidates[pick]
+			used[pick] = true
+			activity := c.Activity
+			itemDay := date
+			planned.Items = append(planned.Items, models.ItineraryItem{
+				ActivityID: activity.ID,
+				Day:        &itemDay,
+				StartTime:  clock(pickStart),
+				EndTime:    clock(pickStart + pace.visit),
+				Position:   len(planned.Items),
+				Activity:   &activity,
+			})
+			plan.Cost += c.Cost
+			cursor = pickStart + pace.visit
+			busy += pickTravel + time.Duration(pace.visit)*time.Minute
+			if at, ok := activityLocation(&activity); ok {
+				prev = &at
+				if anchor == nil {
+					anchor = &at
+				}
+			}
+		}
+		plan.Days = append(plan.Days, planned)
+	}
+	return plan
+}
@@ -0,0 +1,159 @@
+// Package schedule answers whether an activity is open from its opening hours. It mirrors the
+// activity_open_on SQL function used to filter listings, so both need to change together.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
+
+const (
+	ClockLayout    = "15:04"
+	DateLayout     = "2006-01-02"
+	MonthDayLayout = "01-02"
+
+	minutesPerDay = 24 * 60
+)
+
+func clockMinutes(s string) (int, error) {
+	t, err := time.Parse(ClockLayout, s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Validate checks the formats in hours, it does not reject overlapping periods
+func Validate(hours models.OpeningHours) error {
+	for _, w := range hours.Weekly {
+		if w.Weekday < 0 || w.Weekday > 6 {
+			return errors.New("weekday must be between 0 (sunday) and 6 (saturday)")
+		}
+		if _, err := clockMinutes(w.Opens); err != nil {
+			return fmt.Errorf("invalid opening time %q, expected HH:MM", w.Opens)
+		}
+		if _, err := clockMinutes(w.Closes); err != nil {
+			return fmt.Errorf("invalid closing time %q, expected HH:MM", w.Closes)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, e := range hours.Exceptions {
+		if _, err := time.Parse(DateLayout, e.Date); err != nil {
+			return fmt.Errorf("invalid exception date %q, expected YYYY-MM-DD", e.Date)
+		}
+		if seen[e.Date] {
+			return fmt.Errorf("more than one exception for %s", e.Date)
+		}
+		seen[e.Date] = true
+		if (e.Opens == "") != (e.Closes == "") {
+			return errors.New("exception opens and closes must be given together")
+		}
+		if e.Opens == "" {
+			continue
+		}
+		if _, err := clockMinutes(e.Opens); err != nil {
+			return fmt.Errorf("invalid opening time %q, expected HH:MM", e.Opens)
+		}
+		if _, err := clockMinutes(e.Closes); err != nil {
+			return fmt.Errorf("invalid closing time %q, expected HH:MM", e.Closes)
+		}
+	}
+	for _, c := range hours.Closures {
+		if _, err := time.Parse(MonthDayLayout, c.Start); err != nil {
+			return fmt.Errorf("invalid closure start %q, expected MM-DD", c.Start)
+		}
+		if _, err := time.Parse(MonthDayLayout, c.End); err != nil {
+			return fmt.Errorf("invalid closure end %q, expected MM-DD", c.End)
+		}
+	}
+	return nil
+}
+
+// Location is the timezone the hours are given in, UTC when the country has none
+func Location(hours models.OpeningHours) *time.Location {
+	if hours.Timezone != "" {
+		if loc, err := time.LoadLocation(hours.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// span is an opening period in minutes after the local midnight of the day it starts on,
+// the end goes past minutesPerDay for periods running overnight
+type span struct{ start, end int }
+
+func newSpan(opens, closes string) span {
+	o, _ := clockMinutes(opens)
+	c, _ := clockMinutes(closes)
+	if c <= o {
+		c += minutesPerDay
+	}
+	return span{o, c}
+}
+
+func closedForSeason(closures []models.SeasonalClosure, day time.Time) bool {
+	md := day.Format(MonthDayLayout)
+	for _, c := range closures {
+		if c.Start <= c.End {
+			if md >= c.Start && md <= c.End {
+				return true
+			}
+		} else if md >= c.Start || md <= c.End {
+			return true
+		}
+	}
+	return false
+}
+
+// spans lists the periods starting on the local date of day
+func spans(hours models.OpeningHours, day time.Time) []span {
+	date := day.Format(DateLayout)
+	for _, e := range hours.Exceptions {
+		if e.Date == date {
+			if e.Opens == "" {
+				return nil
+			}
+			return []span{newSpan(e.Opens, e.Closes)}
+		}
+	}
+	if closedForSeason(hours.Closures, day) {
+		return nil
+	}
+	if len(hours.Weekly) == 0 {
+		return []span{{0, minutesPerDay}}
+	}
+	var out []span
+	for _, w := range hours.Weekly {
+		if w.Weekday == int(day.Weekday()) {
+			out = append(out, newSpan(w.Opens, w.Closes))
+		}
+	}
+	return out
+}
+
+// OpenOn reports whether the activity opens at all on the date of day
+func OpenOn(hours models.OpeningHours, day time.Time) bool {
+	return len(spans(hours, day)) > 0
+}
+
+// IsOpen reports whether the activity is open at the wall clock time of at, which must already
+// be in the activity's Location
+func IsOpen(hours models.OpeningHours, at time.Time) bool {
+	minute := at.Hour()*60 + at.Minute()
+	for _, s := range spans(hours, at) {
+		if minute >= s.start && minute < s.end {
+			return true
+		}
+	}
+	// periods that started the day before and run past midnight
+	for _, s := range spans(hours, at.AddDate(0, 0, -1)) {
+		if minute+minutesPerDay >= s.start && minute+minutesPerDay < s.end {
+			return true
+		}
+	}
+	return false
+}
@@ -1,23 +1,27 @@
 package server
 
 import (
-	"database/sql"
+	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/Joshua-Pok/FYP-backend/config"
+	"github.com/Joshua-Pok/FYP-backend/database"
 	"github.com/Joshua-Pok/FYP-backend/handlers"
 	"github.com/Joshua-Pok/FYP-backend/middleware"
 	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/schedule"
 	"github.com/Joshua-Pok/FYP-backend/service"
 )
 
 type Server struct {
 	config *config.Config
-	db     *sql.DB
+	db     *database.Router
 }
 
-func New(cfg config.Config, db *sql.DB) *Server {
+func New(cfg config.Config, db *database.Router) *Server {
 	return &Server{
 		config: &cfg,
 		db:     db,
@@ -25,25 +29,136 @@ func New(cfg config.Config, db *sql.DB) *Server {
 }
 
 func (s *Server) Start() error {
-	minioService := service.NewMinIOService(s.config.MinIO.Endpoint, s.config.MinIO.AccessKey, s.config.MinIO.Secret, s.config.MinIO.BucketName, false)
+	blobStore, err := s.newBlobStore()
+	if err != nil {
+		return err
+	}
 	gorseService := service.NewGorseService(s.config.Gorse.URL)
 	cacheService := service.NewCacheService(s.config.Cache.Addr, s.config.Cache.Password, s.config.Cache.Db)
-	userRepo := repository.NewUserRepository(s.db)
-	personalityRepo := repository.NewPersonalityRepository(s.db)
+	// users, personality and audit are light and always read from the primary
+	userRepo := repository.NewUserRepository(s.db.Primary())
+	personalityRepo := repository.NewPersonalityRepository(s.db.Primary())
 	itineraryRepo := repository.NewItineraryRepository(s.db)
 	activityRepo := repository.NewActivityRepository(s.db)
-	activityHandler := handlers.NewActivityhandler(*activityRepo, minioService, gorseService, cacheService)
-	userHandler := handlers.NewUserHandler(userRepo)
-	itineraryHandler := handlers.NewItineraryHandler(*itineraryRepo)
+	auditRepo := repository.NewAuditRepository(s.db.Primary())
+	countryRepo := repository.NewCountryRepository(s.db)
+	suggestionRepo := repository.NewSuggestionRepository(s.db)
+	suggestService := service.NewSuggestService(suggestionRepo, cacheService)
+	suggestService.Start(5 * time.Minute)
+	// local storage serves its own presigned urls
+	if local, ok := blobStore.(*service.LocalStore); ok {
+		http.Handle(service.LocalBlobPath, local)
+	}
+	geocoder, err := s.newGeocoder()
+	if err != nil {
+		return err
+	}
+	rateProvider, err := s.newRateProvider()
+	if err != nil {
+		return err
+	}
+	currencyRepo := repository.NewCurrencyRepository(s.db)
+	exchangeService := service.NewExchangeService(rateProvider, currencyRepo)
+	// load the stored rates before serving so the first requests can already convert
+	if err := exchangeService.Refresh(context.Background()); err != nil {
+		log.Printf("warning: failed to load exchange rates: %v", err)
+	}
+	exchangeService.Start(s.config.Exchange.Interval)
+	prices := handlers.NewPriceConverter(exchangeService, userRepo)
+	currencyHandler := handlers.NewCurrencyHandler(exchangeService, userRepo)
+	hoursRepo := repository.NewOpeningHoursRepository(s.db)
+	hoursHandler := handlers.NewOpeningHoursHandler(hoursRepo)
+	limits := schedule.Limits{
+		MaxItemsPerDay: s.config.Planner.MaxItemsPerDay,
+		MaxHoursPerDay: s.config.Planner.MaxHoursPerDay,
+		TravelSpeedKmh: s.config.Planner.TravelSpeedKmh,
+	}
+	validator := service.NewScheduleValidator(itineraryRepo, hoursRepo, limits)
+	generator := service.NewItineraryGenerator(gorseService, activityRepo, hoursRepo, exchangeService, limits)
+	generatorHandler := handlers.NewItineraryGeneratorHandler(generator, exchangeService, userRepo, countryRepo)
+	expenseRepo := repository.NewExpenseRepository(s.db)
+	memberRepo := repository.NewMemberRepository(s.db)
+	access := service.NewItineraryAccess(memberRepo)
+	memberHandler := handlers.NewMemberHandler(memberRepo, repository.NewInvitationRepository(s.db), userRepo, access)
+	budgetService := service.NewBudgetService(expenseRepo, itineraryRepo, memberRepo, exchangeService)
+	budgetHandler := handlers.NewBudgetHandler(budgetService, expenseRepo, memberRepo, exchangeService, prices, userRepo, access)
+	routeHandler := handlers.NewRouteHandler(service.NewRouteOptimiser(itineraryRepo, limits.TravelSpeedKmh), access)
+	activityHandler := handlers.NewActivityhandler(activityRepo, blobStore, gorseService, cacheService, suggestService, countryRepo, geocoder, prices, hoursRepo, itineraryRepo, validator, access)
+	userHandler := handlers.NewUserHandler(userRepo, s.config.Admin.Usernames)
+	itineraryHub := service.NewItineraryHub(cacheService)
+	itineraryHub.Start(make(chan struct{}))
+	itineraryHandler := handlers.NewItineraryHandler(itineraryRepo, prices, validator, access, userRepo, itineraryHub)
+	itineraryEventsHandler := handlers.NewItineraryEventsHandler(itineraryHub, access, s.config.Cache.EventHeartbeat)
 	personalityHandler := handlers.NewPersonalityHandler(personalityRepo)
+	auditHandler := handlers.NewAuditHandler(auditRepo)
+	countryHandler := handlers.NewCountryHandler(countryRepo, activityRepo, suggestService, prices)
+	categoryRepo := repository.NewCategoryRepository(s.db)
+	categoryHandler := handlers.NewCategoryHandler(categoryRepo, activityRepo, gorseService)
+	reviewRepo := repository.NewReviewRepository(s.db)
+	reviewHandler := handlers.NewReviewHandler(reviewRepo, userRepo, gorseService)
+	imageRepo := repository.NewImageRepository(s.db)
+	imageWorker := service.NewImageWorker(imageRepo, blobStore)
+	imageWorker.Start(s.config.MinIO.WorkerInterval)
+	imageHandler := handlers.NewImageHandler(imageRepo, blobStore, imageWorker, s.config.MinIO.UploadExpiry, s.config.MinIO.MaxUploadBytes, access, s.config.Admin.Usernames)
+	storageGC := service.NewStorageGC(imageRepo, blobStore, s.config.Storage.GCGracePeriod, s.config.Storage.GCInterval, s.config.Storage.GCDryRun)
+	storageGC.Start(make(chan struct{}))
+	storageHandler := handlers.NewStorageHandler(storageGC)
+	importRepo := repository.NewImportJobRepository(s.db)
+	if n, err := importRepo.FailInterruptedImportJobs(context.Background()); err != nil {
+		log.Printf("warning: failed to clean up interrupted import jobs: %v", err)
+	} else if n > 0 {
+		log.Printf("marked %d interrupted import jobs as failed", n)
+	}
+	importHandler := handlers.NewImportHandler(importRepo, activityRepo, countryRepo, currencyRepo, gorseService, suggestService)
+	suggestHandler := handlers.NewSuggestHandler(suggestService)
+	purgeService := service.NewPurgeService(s.config.Purge.Retention, s.config.Purge.Interval)
+	purgeService.Register("itineraries", itineraryRepo)
+	purgeService.Register("activities", activityRepo)
+	purgeService.Register("users", userRepo)
+	purgeService.Start(make(chan struct{}))
+
 	http.Handle("/users", middleware.JWTAuth(s.handleUsers(userHandler)))
-	http.HandleFunc("/personality", s.handlePersonality(personalityHandler))
-	http.HandleFunc("/itinerary", s.handleItinerary(itineraryHandler))
-	http.HandleFunc("/activity", s.handleActivity(activityHandler))
+	http.Handle("/users/currency", middleware.JWTAuth(s.handlePreferredCurrency(currencyHandler)))
+	http.Handle("/users/restore", middleware.JWTAuth(s.handleRestore(userHandler.RestoreUser)))
+	http.Handle("/personality", middleware.OptionalJWTAuth(s.handlePersonality(personalityHandler)))
+	http.Handle("/itinerary", middleware.OptionalJWTAuth(s.handleItinerary(itineraryHandler)))
+	http.Handle("/itinerary/restore", middleware.OptionalJWTAuth(s.handleRestore(itineraryHandler.RestoreItinerary)))
+	http.Handle("/itinerary/trash", middleware.OptionalJWTAuth(s.handleTrash(itineraryHandler)))
+	http.Handle("/itinerary/items", middleware.OptionalJWTAuth(s.handleItineraryItems(itineraryHandler)))
+	http.Handle("/itinerary/items/order", middleware.OptionalJWTAuth(s.handleItineraryItemOrder(itineraryHandler)))
+	http.Handle("/itinerary/events", middleware.StreamJWTAuth(s.handleItineraryEvents(itineraryEventsHandler)))
+	http.Handle("/itinerary/budget", middleware.OptionalJWTAuth(s.handleBudget(budgetHandler)))
+	http.Handle("/itinerary/expenses", middleware.OptionalJWTAuth(s.handleExpenses(budgetHandler)))
+	http.Handle("/itinerary/members", middleware.OptionalJWTAuth(s.handleMembers(memberHandler)))
+	http.Handle("/itinerary/owner", middleware.JWTAuth(s.handleOwner(memberHandler)))
+	http.Handle("/itinerary/invitations", middleware.JWTAuth(s.handleInvitations(memberHandler)))
+	http.Handle("/itinerary/balances", middleware.OptionalJWTAuth(s.handleBalances(budgetHandler)))
+	http.Handle("/itinerary/route", middleware.OptionalJWTAuth(s.handleItineraryRoute(routeHandler)))
+	http.Handle("/itineraries/generate", middleware.JWTAuth(s.handleGenerateItinerary(generatorHandler)))
+	s.RegisterActivityRoutes(http.DefaultServeMux, activityHandler, hoursHandler)
+	http.Handle("/activity/reviews", middleware.OptionalJWTAuth(s.handleReviews(reviewHandler)))
+	http.Handle("/activity/reviews/helpful", middleware.JWTAuth(s.handleHelpfulVotes(reviewHandler)))
+	http.Handle("/admin/audit", s.adminOnly(s.handleAudit(auditHandler)))
+	http.Handle("/admin/storage/gc", s.adminOnly(s.handleStorageGC(storageHandler)))
+	http.HandleFunc("/countries", s.handleCountries(countryHandler))
+	http.HandleFunc("/currencies", s.handleCurrencies(currencyHandler))
+	http.Handle("/images", middleware.OptionalJWTAuth(s.handleImages(imageHandler)))
+	http.Handle("/images/uploads", middleware.JWTAuth(s.handleImageUploads(imageHandler.RequestUpload)))
+	http.Handle("/images/confirm", middleware.JWTAuth(s.handleImageUploads(imageHandler.ConfirmUpload)))
+	http.HandleFunc("/categories", s.handleCategories(categoryHandler))
+	http.Handle("/activities", middleware.OptionalJWTAuth(s.handleActivities(activityHandler)))
+	http.Handle("/activities/import", s.adminOnly(s.handleActivityImport(importHandler)))
+	http.Handle("/activities/export", s.adminOnly(s.handleActivityExport(importHandler)))
+	http.Handle("/activities/country", middleware.OptionalJWTAuth(s.handleCountryActivities(countryHandler)))
+	http.Handle("/activities/search", middleware.OptionalJWTAuth(s.handleActivitySearch(activityHandler)))
+	http.HandleFunc("/activities/suggest", s.handleSuggest(suggestHandler))
+	http.Handle("/activities/nearby", middleware.OptionalJWTAuth(s.handleNearby(activityHandler)))
+	http.Handle("/activities/recommended", middleware.OptionalJWTAuth(s.handleActivityFeed(activityHandler.GetRecommendedActivities)))
+	http.Handle("/activities/popular", middleware.OptionalJWTAuth(s.handleActivityFeed(activityHandler.GetPopularActivities)))
 
 	addr := ":" + s.config.Server.Port
 	log.Println("Server started successfully", s.config.Server.Port)
-	return http.ListenAndServe(addr, nil)
+	return http.ListenAndServe(addr, middleware.ReadYourWrites(s.config.Database.ReadYourWritesWindow, http.DefaultServeMux))
 }
 
 func (s *Server) handleUsers(handler *handlers.UserHandler) http.HandlerFunc {
@@ -53,6 +168,8 @@ func (s *Server) handleUsers(handler *handlers.UserHandler) http.HandlerFunc {
 			handler.GetUser(w, r)
 		case http.MethodPost:
 			handler.CreateUser(w, r)
+		case http.MethodDelete:
+			handler.DeleteUser(w, r)
 		default:
 			http.Error(w, "Method not available", http.StatusMethodNotAllowed)
 		}
@@ -78,7 +195,15 @@ func (s *Server) handleItinerary(handler *handlers.ItineraryHandler) http.Handle
 		case http.MethodPost:
 			handler.CreateItinerary(w, r)
 		case http.MethodGet:
+			if r.URL.Query().Has("itinerary_id") {
+				handler.GetItinerary(w, r)
+				return
+			}
 			handler.GetItinerariesByUser(w, r)
+		case http.MethodPut:
+			handler.ModifyItinerary(w, r)
+		case http.MethodDelete:
+			handler.DeleteItinerary(w, r)
 		default:
 			http.Error(w, "Method Not available", http.StatusInternalServerError)
 
@@ -86,15 +211,498 @@ func (s *Server) handleItinerary(handler *handlers.ItineraryHandler) http.Handle
 	}
 }
 
+func (s *Server) handleItineraryItems(handler *handlers.ItineraryHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			handler.AddItineraryItem(w, r)
+		case http.MethodPut:
+			handler.UpdateItineraryItem(w, r)
+		case http.MethodDelete:
+			handler.RemoveItineraryItem(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleItineraryItemOrder(handler *handlers.ItineraryHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			handler.ReorderItineraryItems(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleItineraryEvents(handler *handlers.ItineraryEventsHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.StreamEvents(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleBudget(handler *handlers.BudgetHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetBudget(w, r)
+		case http.MethodPut:
+			handler.SetBudget(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleExpenses(handler *handlers.BudgetHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetExpenses(w, r)
+		case http.MethodPost:
+			handler.CreateExpense(w, r)
+		case http.MethodPut:
+			handler.UpdateExpense(w, r)
+		case http.MethodDelete:
+			handler.DeleteExpense(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleMembers(handler *handlers.MemberHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetMembers(w, r)
+		case http.MethodPut:
+			handler.SetMemberRole(w, r)
+		case http.MethodDelete:
+			handler.RemoveMember(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleOwner(handler *handlers.MemberHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			handler.TransferOwnership(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleInvitations(handler *handlers.MemberHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetInvitations(w, r)
+		case http.MethodPost:
+			handler.CreateInvitation(w, r)
+		case http.MethodPut:
+			handler.RespondToInvitation(w, r)
+		case http.MethodDelete:
+			handler.CancelInvitation(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleBalances(handler *handlers.BudgetHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetBalances(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleItineraryRoute(handler *handlers.RouteHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.OptimiseRoute(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleGenerateItinerary(handler *handlers.ItineraryGeneratorHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			handler.GenerateItinerary(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// RegisterActivityRoutes adds the routes managing a single activity to mux. Reading is public,
+// creating, deleting, restoring, retagging and setting opening hours is for admins only.
+func (s *Server) RegisterActivityRoutes(mux *http.ServeMux, activityHandler *handlers.ActivityHandler, hoursHandler *handlers.OpeningHoursHandler) {
+	mux.Handle("/activity", middleware.OptionalJWTAuth(s.handleActivity(activityHandler)))
+	mux.Handle("/activity/taxonomy", s.adminOnly(s.handleActivityTaxonomy(activityHandler)))
+	mux.Handle("/activity/hours", middleware.OptionalJWTAuth(s.handleOpeningHours(hoursHandler)))
+	mux.HandleFunc("/activity/open", s.handleActivityOpen(hoursHandler))
+	mux.Handle("/activity/restore", s.adminOnly(s.handleRestore(activityHandler.RestoreActivity)))
+}
+
 func (s *Server) handleActivity(handler *handlers.ActivityHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodPost:
-			handler.CreateActivity(w, r)
+			s.adminOnly(handler.CreateActivity).ServeHTTP(w, r)
 		case http.MethodGet:
 			handler.GetActivitiesByItinerary(w, r)
+		case http.MethodDelete:
+			s.adminOnly(handler.DeleteActivity).ServeHTTP(w, r)
 		default:
 			http.Error(w, "Method Not available", http.StatusInternalServerError)
 		}
 	}
 }
+
+func (s *Server) handleRestore(restore http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			restore(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleTrash(handler *handlers.ItineraryHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetTrash(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleAudit(handler *handlers.AuditHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetAuditLogs(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleStorageGC(handler *handlers.StorageHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetGCReport(w, r)
+		case http.MethodPost:
+			handler.RunGC(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleActivityImport(handler *handlers.ImportHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetImport(w, r)
+		case http.MethodPost:
+			handler.StartImport(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleActivityExport(handler *handlers.ImportHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.ExportActivities(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// adminOnly requires a valid token belonging to one of the configured admins
+func (s *Server) adminOnly(handler http.HandlerFunc) http.Handler {
+	return middleware.JWTAuth(middleware.RequireAdmin(s.config.Admin.Usernames, handler))
+}
+
+func (s *Server) handleCountries(handler *handlers.CountryHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetCountries(w, r)
+		case http.MethodPost:
+			s.adminOnly(handler.CreateCountry).ServeHTTP(w, r)
+		case http.MethodPut:
+			s.adminOnly(handler.UpdateCountry).ServeHTTP(w, r)
+		case http.MethodDelete:
+			s.adminOnly(handler.DeleteCountry).ServeHTTP(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleCountryActivities(handler *handlers.CountryHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetCountryActivities(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleActivitySearch(handler *handlers.ActivityHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.SearchActivities(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleSuggest(handler *handlers.SuggestHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.Suggest(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleNearby(handler *handlers.ActivityHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.NearbyActivities(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// handleActivityFeed serves a gorse backed activity list, filtered by ?category= and ?itinerary_id=
+func (s *Server) handleActivityFeed(feed http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			feed(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// newBlobStore picks the storage backend from config
+func (s *Server) newBlobStore() (service.BlobStore, error) {
+	switch s.config.Storage.Backend {
+	case "minio":
+		return service.NewMinIOService(s.config.MinIO.Endpoint, s.config.MinIO.AccessKey, s.config.MinIO.Secret, s.config.MinIO.BucketName, s.config.MinIO.UseSSL, s.config.MinIO.URLExpiry)
+	case "local":
+		return service.NewLocalStore(s.config.Storage.LocalDir, s.config.Storage.PublicURL, []byte(s.config.Storage.SigningKey), s.config.MinIO.URLExpiry, s.config.MinIO.MaxUploadBytes)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", s.config.Storage.Backend)
+	}
+}
+
+// newGeocoder picks the geocoder backend from config, returning nil when geocoding is turned off
+func (s *Server) newGeocoder() (service.Geocoder, error) {
+	switch s.config.Geocoder.Provider {
+	case "nominatim":
+		if s.config.Geocoder.URL == "" {
+			return nil, fmt.Errorf("GEOCODER_URL is required for the nominatim geocoder")
+		}
+		return service.NewNominatimGeocoder(s.config.Geocoder.URL), nil
+	case "gazetteer":
+		return service.LoadGazetteerFile(s.config.Geocoder.GazetteerFile)
+	case "", "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown geocoder %q", s.config.Geocoder.Provider)
+	}
+}
+
+// newRateProvider picks where exchange rates come from, returning nil to only use rates already stored
+func (s *Server) newRateProvider() (service.RateProvider, error) {
+	switch s.config.Exchange.Provider {
+	case "http":
+		return service.NewHTTPRateProvider(s.config.Exchange.URL), nil
+	case "file":
+		return &service.FileRateProvider{Path: s.config.Exchange.File}, nil
+	case "", "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown exchange rate provider %q", s.config.Exchange.Provider)
+	}
+}
+
+func (s *Server) handleCurrencies(handler *handlers.CurrencyHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetCurrencies(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handlePreferredCurrency(handler *handlers.CurrencyHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			handler.SetPreferredCurrency(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleOpeningHours(handler *handlers.OpeningHoursHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetOpeningHours(w, r)
+		case http.MethodPut:
+			s.adminOnly(handler.SetOpeningHours).ServeHTTP(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleActivityOpen(handler *handlers.OpeningHoursHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.IsOpen(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleActivityTaxonomy(handler *handlers.ActivityHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			handler.SetActivityTaxonomy(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleActivities(handler *handlers.ActivityHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.ListActivities(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleCategories(handler *handlers.CategoryHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetCategories(w, r)
+		case http.MethodPost:
+			s.adminOnly(handler.CreateCategory).ServeHTTP(w, r)
+		case http.MethodPut:
+			s.adminOnly(handler.UpdateCategory).ServeHTTP(w, r)
+		case http.MethodDelete:
+			s.adminOnly(handler.DeleteCategory).ServeHTTP(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleReviews(handler *handlers.ReviewHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetReviews(w, r)
+		case http.MethodPost:
+			handler.CreateReview(w, r)
+		case http.MethodPut:
+			handler.UpdateReview(w, r)
+		case http.MethodDelete:
+			handler.DeleteReview(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleHelpfulVotes(handler *handlers.ReviewHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodDelete:
+			handler.VoteHelpful(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleImages(handler *handlers.ImageHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetImages(w, r)
+		case http.MethodDelete:
+			middleware.JWTAuth(http.HandlerFunc(handler.DeleteImage)).ServeHTTP(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) handleImageUploads(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			handler(w, r)
+		default:
+			http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		}
+	}
+}
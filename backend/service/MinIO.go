@@ -1,9 +1,14 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -13,35 +18,121 @@ type MinIOService struct {
 	Client     *minio.Client
 	BucketName string
 	Endpoint   string
+	// how long presigned GET urls stay valid
+	URLExpiry time.Duration
 }
 
-func NewMinIOService(endpoint, accessKey, secretKey, BucketName string, useSSL bool) *MinIOService {
+var _ BlobStore = (*MinIOService)(nil)
+
+// NewMinIOService connects to MinIO and creates the bucket if it is missing
+func NewMinIOService(endpoint, accessKey, secretKey, BucketName string, useSSL bool, urlExpiry time.Duration) (*MinIOService, error) {
 	client, err := minio.New(endpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
 		Secure: useSSL,
 	})
-
 	if err != nil {
-		log.Fatalf("Failed to initialize Minio client: %v", err)
+		return nil, fmt.Errorf("failed to initialize MinIO client: %w", err)
 	}
 
 	ctx := context.Background()
 	exists, err := client.BucketExists(ctx, BucketName)
 	if err != nil {
-		log.Fatalf("error checking bucket: %v", err)
+		return nil, fmt.Errorf("error checking bucket: %w", err)
 	}
 	if !exists {
-		err = client.MakeBucket(ctx, BucketName, minio.MakeBucketOptions{})
-		if err != nil {
-			log.Fatalf("failed to reate bucket: %v", err)
+		if err := client.MakeBucket(ctx, BucketName, minio.MakeBucketOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to create bucket: %w", err)
 		}
 	}
 	return &MinIOService{
 		Client:     client,
 		BucketName: BucketName,
+		Endpoint:   client.EndpointURL().String(),
+		URLExpiry:  urlExpiry,
+	}, nil
+}
+
+// GetObjectURL returns a presigned GET url for the object that expires after URLExpiry.
+// Empty names and values that are already absolute urls are returned unchanged.
+func (s *MinIOService) GetObjectURL(ctx context.Context, objectName string) string {
+	if objectName == "" || strings.HasPrefix(objectName, "http://") || strings.HasPrefix(objectName, "https://") {
+		return objectName
+	}
+	u, err := s.Client.PresignedGetObject(ctx, s.BucketName, objectName, s.URLExpiry, nil)
+	if err != nil {
+		log.Printf("warning: failed to presign %s: %v", objectName, err)
+		return ""
+	}
+	return u.String()
+}
+
+// PresignUpload returns a url the client can PUT the object body to directly until expiry passes
+func (s *MinIOService) PresignUpload(ctx context.Context, objectName string, expiry time.Duration) (string, error) {
+	u, err := s.Client.PresignedPutObject(ctx, s.BucketName, objectName, expiry)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// StatObject returns the size and content type of an uploaded object, or ErrObjectNotFound
+func (s *MinIOService) StatObject(ctx context.Context, objectName string) (ObjectInfo, error) {
+	info, err := s.Client.StatObject(ctx, s.BucketName, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return ObjectInfo{}, ErrObjectNotFound
+		}
+		return ObjectInfo{}, err
 	}
+	return ObjectInfo{Key: info.Key, Size: info.Size, ContentType: info.ContentType, LastModified: info.LastModified}, nil
 }
 
-func (s *MinIOService) GetPublicURL(objectName string) string {
-	return fmt.Sprintf("%s/%s/%s", s.Endpoint, s.BucketName, objectName)
+// ReadHead returns up to the first n bytes of the object, enough to sniff its real type
+func (s *MinIOService) ReadHead(ctx context.Context, objectName string, n int64) ([]byte, error) {
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(0, n-1); err != nil {
+		return nil, err
+	}
+	obj, err := s.Client.GetObject(ctx, s.BucketName, objectName, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return io.ReadAll(io.LimitReader(obj, n))
+}
+
+// ReadObject returns the whole object, or ErrObjectNotFound
+func (s *MinIOService) ReadObject(ctx context.Context, objectName string) ([]byte, error) {
+	obj, err := s.Client.GetObject(ctx, s.BucketName, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	data, err := io.ReadAll(obj)
+	if err != nil && minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+		return nil, ErrObjectNotFound
+	}
+	return data, err
+}
+
+// WriteObject stores data under objectName, replacing any existing object
+func (s *MinIOService) WriteObject(ctx context.Context, objectName string, data []byte, contentType string) error {
+	_, err := s.Client.PutObject(ctx, s.BucketName, objectName, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *MinIOService) RemoveObject(ctx context.Context, objectName string) error {
+	return s.Client.RemoveObject(ctx, s.BucketName, objectName, minio.RemoveObjectOptions{})
+}
+
+// ListObjects returns every object whose key starts with prefix
+func (s *MinIOService) ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for obj := range s.Client.ListObjects(ctx, s.BucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		objects = append(objects, ObjectInfo{Key: obj.Key, Size: obj.Size, ContentType: obj.ContentType, LastModified: obj.LastModified})
+	}
+	return objects, nil
 }
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrObjectNotFound is returned when an object the caller expects to exist is missing from the store
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo is what the store reports about a stored object
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+// BlobStore holds uploaded files. MinIOService and LocalStore implement it, the backend is
+// picked by STORAGE_BACKEND so local development does not need a MinIO container.
+type BlobStore interface {
+	// GetObjectURL returns a time limited download url, empty keys and absolute urls pass through unchanged
+	GetObjectURL(ctx context.Context, objectName string) string
+	// PresignUpload returns a url the client can PUT the object body to until expiry passes
+	PresignUpload(ctx context.Context, objectName string, expiry time.Duration) (string, error)
+	StatObject(ctx context.Context, objectName string) (ObjectInfo, error)
+	ReadHead(ctx context.Context, objectName string, n int64) ([]byte, error)
+	ReadObject(ctx context.Context, objectName string) ([]byte, error)
+	WriteObject(ctx context.Context, objectName string, data []byte, contentType string) error
+	RemoveObject(ctx context.Context, objectName string) error
+	ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
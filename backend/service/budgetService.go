@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/money"
+)
+
+// ExpenseStore loads what an itinerary may cost and what was spent on it
+type ExpenseStore interface {
+	GetBudget(ctx context.Context, itineraryID int) (models.ItineraryBudget, error)
+	GetExpensesByItinerary(ctx context.Context, itineraryID int) ([]models.Expense, error)
+}
+
+// MemberSource lists who is travelling on an itinerary
+type MemberSource interface {
+	GetMembers(ctx context.Context, itineraryID int) ([]models.ItineraryMember, error)
+}
+
+// BudgetService compares an itinerary's budget with its estimated and actual spend and works out
+// what the members owe each other
+type BudgetService struct {
+	expenses  ExpenseStore
+	schedules ScheduleSource
+	members   MemberSource
+	exchange  CurrencyConverter
+}
+
+func NewBudgetService(expenses ExpenseStore, schedules ScheduleSource, members MemberSource, exchange CurrencyConverter) *BudgetService {
+	return &BudgetService{expenses: expenses, schedules: schedules, members: members, exchange: exchange}
+}
+
+// Summary totals the itinerary per day and category in the budget's currency, or in currency when
+// it has no budget. Scheduled activities make up the estimate, unscheduled ones are not counted.
+func (s *BudgetService) Summary(ctx context.Context, itineraryID int, currency string) (models.BudgetSummary, error) {
+	summary := models.BudgetSummary{ItineraryID: itineraryID, Alerts: []models.BudgetAlert{}}
+	budget, err := s.expenses.GetBudget(ctx, itineraryID)
+	if err != nil {
+		return summary, err
+	}
+	if budget.AmountMinor != nil {
+		currency = budget.Currency
+		summary.BudgetMinor = budget.AmountMinor
+	}
+	summary.Currency = currency
+	if summary.MinorUnits, err = s.exchange.MinorUnits(currency); err != nil {
+		return summary, err
+	}
+	summary.RateDate = s.exchange.RatesDate()
+
+	plan, err := s.schedules.GetItinerarySchedule(ctx, itineraryID)
+	if err != nil {
+		return summary, err
+	}
+	expenses, err := s.expenses.GetExpensesByItinerary(ctx, itineraryID)
+	if err != nil {
+		return summary, err
+	}
+
+	days := make(map[string]*models.BudgetLine)
+	for _, day := range plan.Days {
+		days[day.Date] = &models.BudgetLine{Key: day.Date}
+	}
+	categories := make(map[string]*models.BudgetLine)
+	for _, c := range models.ExpenseCategories {
+		categories[c] = &models.BudgetLine{Key: c}
+	}
+	line := func(lines map[string]*models.BudgetLine, key string) *models.BudgetLine {
+		if lines[key] == nil {
+			lines[key] = &models.BudgetLine{Key: key}
+		}
+		return lines[key]
+	}
+
+	missing := 0
+	for _, day := range plan.Days {
+		for _, item := range day.Items {
+			if item.Activity == nil || item.Activity.PriceMinor == 0 {
+				continue
+			}
+			price, err := s.exchange.Convert(item.Activity.PriceMinor, item.Activity.Currency, currency)
+			if err != nil {
+				missing++
+				continue
+			}
+			line(days, day.Date).EstimatedMinor += price.AmountMinor
+			line(categories, models.ExpenseActivities).EstimatedMinor += price.AmountMinor
+			summary.EstimatedMinor += price.AmountMinor
+		}
+	}
+	for _, e := range expenses {
+		price, err := s.exchange.Convert(e.AmountMinor, e.Currency, currency)
+		if err != nil {
+			missing++
+			continue
+		}
+		line(days, e.Date).ActualMinor += price.AmountMinor
+		line(categories, e.Category).ActualMinor += price.AmountMinor
+		summary.ActualMinor += price.AmountMinor
+	}
+
+	// expenses can fall before or after the trip, dates sort as strings
+	summary.Days = make([]models.BudgetLine, 0, len(days))
+	for _, l := range days {
+		summary.Days = append(summary.Days, *l)
+	}
+	sort.Slice(summary.Days, func(i, j int) bool { return summary.Days[i].Key < summary.Days[j].Key })
+	summary.Categories = make([]models.BudgetLine, 0, len(categories))
+	for _, c := range models.ExpenseCategories {
+		l := categories[c]
+		summary.Categories = append(summary.Categories, *l)
+		summary.PlannedMinor += max(l.EstimatedMinor, l.ActualMinor)
+	}
+
+	format := func(amount int64) string {
+		return fmt.Sprintf("%.*f %s", summary.MinorUnits, money.ToMajor(amount, summary.MinorUnits), currency)
+	}
+	if missing > 0 {
+		summary.Alerts = append(summary.Alerts, models.BudgetAlert{
+			Kind:    models.BudgetAlertMissingRate,
+			Message: fmt.Sprintf("%d of the prices could not be converted to %s and are left out", missing, currency),
+		})
+	}
+	if b := summary.BudgetMinor; b != nil {
+		if summary.PlannedMinor > *b {
+			summary.Alerts = append(summary.Alerts, models.BudgetAlert{
+				Kind:    models.BudgetAlertPlanned,
+				Message: "planned spend of " + format(summary.PlannedMinor) + " is over the budget of " + format(*b),
+			})
+		}
+		if summary.ActualMinor > *b {
+			summary.Alerts = append(summary.Alerts, models.BudgetAlert{
+				Kind:    models.BudgetAlertActual,
+				Message: "spent " + format(summary.ActualMinor) + " of a budget of " + format(*b),
+			})
+		}
+	}
+	return summary, nil
+}
+
+// Balances totals what each member paid and owes in currency and the fewest payments that settle
+// the group. Each share is converted on its own and the payer is credited with the converted
+// shares, so rounding never leaves the balances off zero. Expenses without a rate are skipped.
+func (s *BudgetService) Balances(ctx context.Context, itineraryID int, currency string) (models.ItineraryBalances, error) {
+	result := models.ItineraryBalances{ItineraryID: itineraryID, Currency: currency, Settlements: []models.Settlement{}}
+	var err error
+	if result.MinorUnits, err = s.exchange.MinorUnits(currency); err != nil {
+		return result, err
+	}
+	result.RateDate = s.exchange.RatesDate()
+
+	members, err := s.members.GetMembers(ctx, itineraryID)
+	if err != nil {
+		return result, err
+	}
+	expenses, err := s.expenses.GetExpensesByItinerary(ctx, itineraryID)
+	if err != nil {
+		return result, err
+	}
+
+	balances := make(map[int]*models.MemberBalance)
+	var order []int
+	member := func(id int, name string) *models.MemberBalance {
+		if balances[id] == nil {
+			balances[id] = &models.MemberBalance{UserID: id, Username: name}
+			order = append(order, id)
+		}
+		return balances[id]
+	}
+	for _, m := range members {
+		member(m.UserID, m.Username)
+	}
+
+	for _, e := range expenses {
+		owed := make([]int64, len(e.Shares))
+		converted := true
+		for i, share := range e.Shares {
+			price, err := s.exchange.Convert(share.AmountMinor, e.Currency, currency)
+			if err != nil {
+				converted = false
+				break
+			}
+			owed[i] = price.AmountMinor
+		}
+		if !converted {
+			result.Skipped++
+			continue
+		}
+		for i, share := range e.Shares {
+			member(share.UserID, share.Username).OwedMinor += owed[i]
+			member(e.PaidBy, e.PaidByName).PaidMinor += owed[i]
+		}
+	}
+
+	net := make(map[int]int64, len(balances))
+	result.Balances = make([]models.MemberBalance, 0, len(order))
+	for _, id := range order {
+		b := balances[id]
+		b.BalanceMinor = b.PaidMinor - b.OwedMinor
+		net[id] = b.BalanceMinor
+		result.Balances = append(result.Balances, *b)
+	}
+	for _, t := range money.Settle(net) {
+		result.Settlements = append(result.Settlements, models.Settlement{
+			FromUserID:   t.From,
+			FromUsername: balances[t.From].Username,
+			ToUserID:     t.To,
+			ToUsername:   balances[t.To].Username,
+			AmountMinor:  t.AmountMinor,
+			Amount:       money.ToMajor(t.AmountMinor, result.MinorUnits),
+		})
+	}
+	return result, nil
+}
@@ -32,3 +32,29 @@ func (c *CacheService) Set(key string, value []byte, ttl time.Duration) error {
 func (c *CacheService) Get(key string) ([]byte, error) {
 	return c.Client.Get(c.Ctx, key).Bytes()
 }
+
+func (c *CacheService) Delete(keys ...string) error {
+	return c.Client.Del(c.Ctx, keys...).Err()
+}
+
+// DeletePrefix removes every key starting with prefix, scanning in batches so redis is not blocked
+func (c *CacheService) DeletePrefix(prefix string) error {
+	iter := c.Client.Scan(c.Ctx, 0, prefix+"*", 100).Iterator()
+	var keys []string
+	for iter.Next(c.Ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == 100 {
+			if err := c.Delete(keys...); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return c.Delete(keys...)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/money"
+)
+
+var (
+	ErrUnknownCurrency = errors.New("unsupported currency")
+	ErrNoExchangeRate  = errors.New("no exchange rate available")
+)
+
+// RateProvider fetches the latest exchange rates from somewhere outside the database
+type RateProvider interface {
+	FetchRates(ctx context.Context) (models.ExchangeRates, error)
+}
+
+// RateStore persists rate snapshots so conversions survive restarts and provider outages
+type RateStore interface {
+	GetCurrencies(ctx context.Context) (map[string]int, error)
+	SaveExchangeRates(ctx context.Context, rates models.ExchangeRates) error
+	GetLatestExchangeRates(ctx context.Context) (models.ExchangeRates, error)
+}
+
+// decodeRates reads the {"base", "date", "rates"} JSON shared by the file and http providers
+func decodeRates(r io.Reader) (models.ExchangeRates, error) {
+	var rates models.ExchangeRates
+	if err := json.NewDecoder(r).Decode(&rates); err != nil {
+		return rates, fmt.Errorf("failed to decode exchange rates: %w", err)
+	}
+	rates.Base = strings.ToUpper(rates.Base)
+	if !money.ValidCode(rates.Base) {
+		return rates, errors.New("exchange rates have no valid base currency")
+	}
+	if _, err := time.Parse("2006-01-02", rates.Date); err != nil {
+		return rates, errors.New("exchange rates have no valid date")
+	}
+	normalized := make(map[string]float64, len(rates.Rates))
+	for code, rate := range rates.Rates {
+		if rate > 0 {
+			normalized[strings.ToUpper(code)] = rate
+		}
+	}
+	rates.Rates = normalized
+	return rates, nil
+}
+
+// FileRateProvider reads rates from a JSON file, handy offline and for pinning rates in tests
+type FileRateProvider struct {
+	Path string
+}
+
+func (p *FileRateProvider) FetchRates(ctx context.Context) (models.ExchangeRates, error) {
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return models.ExchangeRates{}, err
+	}
+	defer f.Close()
+	return decodeRates(f)
+}
+
+// HTTPRateProvider fetches rates from an API answering in the frankfurter format
+type HTTPRateProvider struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewHTTPRateProvider(url string) *HTTPRateProvider {
+	return &HTTPRateProvider{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (p *HTTPRateProvider) FetchRates(ctx context.Context) (models.ExchangeRates, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return models.ExchangeRates{}, err
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return models.ExchangeRates{}, fmt.Errorf("error connecting to exchange rate provider: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return models.ExchangeRates{}, fmt.Errorf("exchange rate provider returned status %d", resp.StatusCode)
+	}
+	return decodeRates(resp.Body)
+}
+
+// ExchangeService converts prices using the latest stored snapshot, which it keeps in memory
+type ExchangeService struct {
+	provider RateProvider
+	store    RateStore
+
+	mu         sync.RWMutex
+	currencies map[string]int
+	rates      models.ExchangeRates
+}
+
+// NewExchangeService takes an optional provider, without one only rates already stored are used
+func NewExchangeService(provider RateProvider, store RateStore) *ExchangeService {
+	return &ExchangeService{provider: provider, store: store, currencies: map[string]int{}}
+}
+
+// Start refreshes the rates now and then on every interval
+func (s *ExchangeService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := s.Refresh(context.Background()); err != nil {
+				log.Printf("warning: failed to refresh exchange rates: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// Refresh fetches and stores new rates, then reloads the latest snapshot. A provider failure
+// still reloads whatever is stored so the service keeps working on older rates.
+func (s *ExchangeService) Refresh(ctx context.Context) error {
+	var fetchErr error
+	if s.provider != nil {
+		rates, err := s.provider.FetchRates(ctx)
+		if err == nil {
+			err = s.store.SaveExchangeRates(ctx, rates)
+		}
+		fetchErr = err
+	}
+
+	currencies, err := s.store.GetCurrencies(ctx)
+	if err != nil {
+		return err
+	}
+	rates, err := s.store.GetLatestExchangeRates(ctx)
+	s.mu.Lock()
+	s.currencies = currencies
+	if err == nil {
+		s.rates = rates
+	}
+	s.mu.Unlock()
+	if fetchErr != nil {
+		return fetchErr
+	}
+	if err != nil {
+		return fmt.Errorf("no exchange rates stored: %w", err)
+	}
+	return nil
+}
+
+// MinorUnits returns the number of decimal places of a supported currency
+func (s *ExchangeService) MinorUnits(code string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	units, ok := s.currencies[code]
+	if !ok {
+		return 0, ErrUnknownCurrency
+	}
+	return units, nil
+}
+
+// Supported reports whether prices can be given in code
+func (s *ExchangeService) Supported(code string) bool {
+	_, err := s.MinorUnits(code)
+	return err == nil
+}
+
+// Currencies lists the supported currency codes with their decimal places
+func (s *ExchangeService) Currencies() []models.Currency {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	currencies := make([]models.Currency, 0, len(s.currencies))
+	for code, units := range s.currencies {
+		currencies = append(currencies, models.Currency{Code: code, MinorUnits: units})
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i].Code < currencies[j].Code })
+	return currencies
+}
+
+// RatesDate is the publication date of the rates in use, empty before any were loaded
+func (s *ExchangeService) RatesDate() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rates.Date
+}
+
+// Convert turns amountMinor of from into the to currency
+func (s *ExchangeService) Convert(amountMinor int64, from, to string) (models.ConvertedPrice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fromUnits, okFrom := s.currencies[from]
+	toUnits, okTo := s.currencies[to]
+	if !okFrom || !okTo {
+		return models.ConvertedPrice{}, ErrUnknownCurrency
+	}
+	fromRate, toRate := 1.0, 1.0
+	if from != to {
+		var ok bool
+		if fromRate, ok = s.rates.Rates[from]; !ok {
+			return models.ConvertedPrice{}, ErrNoExchangeRate
+		}
+		if toRate, ok = s.rates.Rates[to]; !ok {
+			return models.ConvertedPrice{}, ErrNoExchangeRate
+		}
+	}
+
+	converted := money.Convert(amountMinor, fromUnits, fromRate, toUnits, toRate)
+	return models.ConvertedPrice{
+		Currency:    to,
+		Amount:      money.ToMajor(converted, toUnits),
+		AmountMinor: converted,
+		Rate:        toRate / fromRate,
+		RateDate:    s.rates.Date,
+	}, nil
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/geo"
+)
+
+var ErrAddressNotFound = errors.New("address not found")
+
+// Geocoder turns a free-text address into coordinates. countryCode is an optional ISO-3166
+// alpha-2 code used to narrow the search.
+type Geocoder interface {
+	Geocode(ctx context.Context, address, countryCode string) (geo.Point, error)
+}
+
+// NominatimGeocoder uses an OpenStreetMap Nominatim server
+type NominatimGeocoder struct {
+	BaseURL   string
+	UserAgent string
+	Client    *http.Client
+}
+
+func NewNominatimGeocoder(baseURL string) *NominatimGeocoder {
+	return &NominatimGeocoder{
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		UserAgent: "FYP-backend/1.0",
+		Client: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+func (g *NominatimGeocoder) Geocode(ctx context.Context, address, countryCode string) (geo.Point, error) {
+	params := url.Values{}
+	params.Set("q", address)
+	params.Set("format", "jsonv2")
+	params.Set("limit", "1")
+	if countryCode != "" {
+		params.Set("countrycodes", strings.ToLower(countryCode))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.BaseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return geo.Point{}, err
+	}
+	// nominatim's usage policy requires an identifying user agent
+	req.Header.Set("User-Agent", g.UserAgent)
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return geo.Point{}, fmt.Errorf("error connecting to geocoder: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return geo.Point{}, fmt.Errorf("geocoder returned status %d", resp.StatusCode)
+	}
+
+	var results []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return geo.Point{}, fmt.Errorf("failed to decode geocoder response: %w", err)
+	}
+	if len(results) == 0 {
+		return geo.Point{}, ErrAddressNotFound
+	}
+
+	lat, err := strconv.ParseFloat(results[0].Lat, 64)
+	if err != nil {
+		return geo.Point{}, fmt.Errorf("invalid latitude from geocoder: %w", err)
+	}
+	lng, err := strconv.ParseFloat(results[0].Lon, 64)
+	if err != nil {
+		return geo.Point{}, fmt.Errorf("invalid longitude from geocoder: %w", err)
+	}
+	return geo.Point{Lat: lat, Lng: lng}, nil
+}
+
+type GazetteerEntry struct {
+	Name        string
+	CountryCode string
+	Point       geo.Point
+}
+
+// GazetteerGeocoder resolves addresses offline against a fixed list of place names,
+// picking the longest name that appears in the address. It is meant for tests and local development.
+type GazetteerGeocoder struct {
+	entries []GazetteerEntry
+}
+
+func NewGazetteerGeocoder(entries []GazetteerEntry) *GazetteerGeocoder {
+	normalized := make([]GazetteerEntry, len(entries))
+	for i, entry := range entries {
+		entry.Name = normalizeSuggestText(entry.Name)
+		entry.CountryCode = strings.ToUpper(entry.CountryCode)
+		normalized[i] = entry
+	}
+	return &GazetteerGeocoder{entries: normalized}
+}
+
+// LoadGazetteerFile reads a CSV of name,country_code,latitude,longitude rows
+func LoadGazetteerFile(path string) (*GazetteerGeocoder, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var entries []GazetteerEntry
+	for i, record := range records {
+		if len(record) != 4 {
+			return nil, fmt.Errorf("gazetteer line %d: expected 4 columns", i+1)
+		}
+		lat, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: %w", i+1, err)
+		}
+		lng, err := strconv.ParseFloat(record[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: %w", i+1, err)
+		}
+		entries = append(entries, GazetteerEntry{Name: record[0], CountryCode: record[1], Point: geo.Point{Lat: lat, Lng: lng}})
+	}
+	return NewGazetteerGeocoder(entries), nil
+}
+
+func (g *GazetteerGeocoder) Geocode(ctx context.Context, address, countryCode string) (geo.Point, error) {
+	// pad with spaces so names only match whole words
+	text := " " + normalizeSuggestText(address) + " "
+	countryCode = strings.ToUpper(countryCode)
+
+	var best *GazetteerEntry
+	for i := range g.entries {
+		entry := &g.entries[i]
+		if countryCode != "" && entry.CountryCode != "" && entry.CountryCode != countryCode {
+			continue
+		}
+		if !strings.Contains(text, " "+entry.Name+" ") {
+			continue
+		}
+		if best == nil || len(entry.Name) > len(best.Name) {
+			best = entry
+		}
+	}
+	if best == nil {
+		return geo.Point{}, ErrAddressNotFound
+	}
+	return best.Point, nil
+}
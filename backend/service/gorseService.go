@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	neturl "net/url"
 	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
 )
 
 type GorseService struct {
@@ -22,8 +25,12 @@ func NewGorseService(baseURL string) *GorseService {
 	}
 }
 
-func (g *GorseService) GetRecommendations(userId string, limit int) ([]string, error) {
-	url := fmt.Sprintf("%s/api/recommend/user/%s?number=%d", g.BaseURL, userId, limit)
+// GetRecommendations recommends items for the user, only from the given category unless it is empty
+func (g *GorseService) GetRecommendations(userId string, limit int, category string) ([]string, error) {
+	url := fmt.Sprintf("%s/api/recommend/user/%s?number=%d", g.BaseURL, neturl.PathEscape(userId), limit)
+	if category != "" {
+		url = fmt.Sprintf("%s/api/recommend/user/%s/%s?number=%d", g.BaseURL, neturl.PathEscape(userId), neturl.PathEscape(category), limit)
+	}
 
 	resp, err := g.Client.Get(url)
 	if err != nil {
@@ -42,8 +49,11 @@ func (g *GorseService) GetRecommendations(userId string, limit int) ([]string, e
 	return ids, nil
 }
 
-func (g *GorseService) GetPopularActivities(limit int) ([]string, error) {
+func (g *GorseService) GetPopularActivities(limit int, category string) ([]string, error) {
 	url := fmt.Sprintf("%s/api/item/popular?number=%d", g.BaseURL, limit)
+	if category != "" {
+		url = fmt.Sprintf("%s/api/item/popular/%s?number=%d", g.BaseURL, neturl.PathEscape(category), limit)
+	}
 
 	resp, err := g.Client.Get(url)
 	if err != nil {
@@ -116,3 +126,76 @@ func (g *GorseService) UpdateUserLabels(userId string, labels map[string]string)
 	}
 	return nil
 }
+
+// UpsertItem inserts the item or overwrites it if gorse already has one with that id
+func (g *GorseService) UpsertItem(item models.GorseItem) error {
+	jsonBody, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/api/item", g.BaseURL)
+	resp, err := g.Client.Post(url, "application/json", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return fmt.Errorf("failed to upsert item in gorse: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Gorse returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// InsertFeedback records that the user interacted with an item, value is used by weighted feedback types like star
+func (g *GorseService) InsertFeedback(feedbackType, userId, itemId string, value float64, timestamp time.Time) error {
+	payload := []map[string]any{{
+		"FeedbackType": feedbackType,
+		"UserId":       userId,
+		"ItemId":       itemId,
+		"Value":        value,
+		"Timestamp":    timestamp.UTC().Format(time.RFC3339),
+	}}
+	jsonBody, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/api/feedback", g.BaseURL)
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to insert feedback into gorse: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Gorse returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// DeleteFeedback removes the user's feedback of that type on the item
+func (g *GorseService) DeleteFeedback(feedbackType, userId, itemId string) error {
+	url := fmt.Sprintf("%s/api/feedback/%s/%s/%s", g.BaseURL, neturl.PathEscape(feedbackType), neturl.PathEscape(userId), neturl.PathEscape(itemId))
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete feedback from gorse: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("Gorse returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/imaging"
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
+
+// ImageStore tracks which confirmed images still need processing
+type ImageStore interface {
+	ClaimUnprocessedImage(ctx context.Context) (*models.Image, error)
+	SaveImageVariants(ctx context.Context, imageID int, sizeBytes int64, variants []models.ImageVariant) error
+	MarkImageFailed(ctx context.Context, imageID int, reason string) error
+}
+
+// ImageWorker strips metadata from confirmed uploads and stores their resized variants
+// next to the original. Work is claimed from the database, so images confirmed while the
+// worker was down are picked up on the next pass.
+type ImageWorker struct {
+	store    ImageStore
+	blobs    BlobStore
+	variants []imaging.Variant
+	wake     chan struct{}
+}
+
+func NewImageWorker(store ImageStore, blobs BlobStore) *ImageWorker {
+	return &ImageWorker{
+		store:    store,
+		blobs:    blobs,
+		variants: imaging.DefaultVariants,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes the worker early, typically right after an upload is confirmed
+func (w *ImageWorker) Notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start processes every pending image now and then again on each interval or notification
+func (w *ImageWorker) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			w.ProcessPending(context.Background())
+			select {
+			case <-ticker.C:
+			case <-w.wake:
+			}
+		}
+	}()
+}
+
+// ProcessPending works through unprocessed images until none are left
+func (w *ImageWorker) ProcessPending(ctx context.Context) {
+	for {
+		image, err := w.store.ClaimUnprocessedImage(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return
+		}
+		if err != nil {
+			log.Printf("warning: failed to claim image for processing: %v", err)
+			return
+		}
+		if err := w.process(ctx, image); err != nil {
+			log.Printf("warning: failed to process image %d: %v", image.ID, err)
+			if err := w.store.MarkImageFailed(ctx, image.ID, err.Error()); err != nil {
+				log.Printf("warning: failed to record image %d failure: %v", image.ID, err)
+			}
+		}
+	}
+}
+
+// variantKey names a variant after its original, photo.jpg becomes photo_thumbnail.jpg
+func variantKey(objectKey, name, contentType string) string {
+	ext := ".jpg"
+	if contentType == "image/png" {
+		ext = ".png"
+	}
+	return strings.TrimSuffix(objectKey, path.Ext(objectKey)) + "_" + name + ext
+}
+
+func (w *ImageWorker) process(ctx context.Context, image *models.Image) error {
+	original, err := w.blobs.ReadObject(ctx, image.ObjectKey)
+	if err != nil {
+		return err
+	}
+	stripped, orientation, err := imaging.StripMetadata(original, image.ContentType)
+	if err != nil {
+		return err
+	}
+	img, err := imaging.Decode(stripped)
+	if err != nil {
+		return err
+	}
+	img = imaging.Orient(img, orientation)
+
+	// stripping the EXIF block also drops the rotation, so a rotated JPEG is re-encoded upright
+	if orientation > 1 && image.ContentType == "image/jpeg" {
+		if stripped, _, err = imaging.Encode(img); err != nil {
+			return err
+		}
+	}
+	if len(stripped) != len(original) {
+		if err := w.blobs.WriteObject(ctx, image.ObjectKey, stripped, image.ContentType); err != nil {
+			return err
+		}
+	}
+
+	rendered, err := imaging.Render(img, w.variants)
+	if err != nil {
+		return err
+	}
+	variants := make([]models.ImageVariant, 0, len(rendered))
+	for _, r := range rendered {
+		v := models.ImageVariant{
+			Name:        r.Name,
+			ObjectKey:   variantKey(image.ObjectKey, r.Name, r.ContentType),
+			Width:       r.Width,
+			Height:      r.Height,
+			SizeBytes:   int64(len(r.Data)),
+			ContentType: r.ContentType,
+		}
+		if err := w.blobs.WriteObject(ctx, v.ObjectKey, r.Data, v.ContentType); err != nil {
+			return err
+		}
+		variants = append(variants, v)
+	}
+
+	if err := w.store.SaveImageVariants(ctx, image.ID, int64(len(stripped)), variants); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// deleted mid-processing, drop what was just written
+			for _, v := range variants {
+				w.blobs.RemoveObject(ctx, v.ObjectKey)
+			}
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
+
+var (
+	ErrSignInRequired = errors.New("sign in required")
+	// ErrForbidden is returned to members whose role does not allow what they asked for
+	ErrForbidden = errors.New("your role on the itinerary does not allow this")
+)
+
+// MembershipSource looks up a user's membership of an itinerary by the email they signed in with
+type MembershipSource interface {
+	GetMemberByEmail(ctx context.Context, itineraryID int, email string) (models.ItineraryMember, error)
+}
+
+// ItineraryAccess checks the signed in user's role on an itinerary
+type ItineraryAccess struct {
+	members MembershipSource
+}
+
+func NewItineraryAccess(members MembershipSource) *ItineraryAccess {
+	return &ItineraryAccess{members: members}
+}
+
+// Require returns the signed in user's membership when their role is at least need. Anonymous
+// requests get ErrSignInRequired, users who are not members sql.ErrNoRows so the itinerary stays
+// hidden, and members with a lesser role ErrForbidden.
+func (a *ItineraryAccess) Require(ctx context.Context, itineraryID int, need string) (models.ItineraryMember, error) {
+	actor := auth.ActorFromContext(ctx)
+	if actor == auth.AnonymousActor || actor == auth.SystemActor {
+		return models.ItineraryMember{}, ErrSignInRequired
+	}
+	member, err := a.members.GetMemberByEmail(ctx, itineraryID, actor)
+	if err != nil {
+		return member, err
+	}
+	if !models.RoleAllows(member.Role, need) {
+		return member, ErrForbidden
+	}
+	return member, nil
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/money"
+	"github.com/Joshua-Pok/FYP-backend/schedule"
+)
+
+// how many recommended and popular activities are asked for before filtering to the destination
+const generatorCandidatePool = 200
+
+var ErrNoCandidates = errors.New("no activities found for the destination")
+
+// Recommender ranks activities for a user, and for everyone when there is no history to go on
+type Recommender interface {
+	GetRecommendations(userId string, limit int, category string) ([]string, error)
+	GetPopularActivities(limit int, category string) ([]string, error)
+}
+
+// ActivitySource loads the activities a generated itinerary is built from
+type ActivitySource interface {
+	GetActivitiesByIds(ctx context.Context, ids []string) ([]models.Activity, error)
+	GetActivitiesByCountry(ctx context.Context, countryID int, filter models.ActivityTaxonomyFilter) ([]models.Activity, error)
+}
+
+// CurrencyConverter prices activities in the budget's currency
+type CurrencyConverter interface {
+	MinorUnits(code string) (int, error)
+	Convert(amountMinor int64, from, to string) (models.ConvertedPrice, error)
+	RatesDate() string
+}
+
+type GenerateOptions struct {
+	// gorse user id, empty to plan from popular activities only
+	UserID    string
+	CountryID int
+	StartDate time.Time
+	EndDate   time.Time
+	Pace      schedule.Pace
+	Category  string
+	// Budget is in minor units of Currency, zero for no limit
+	Budget   int64
+	Currency string
+}
+
+// ItineraryGenerator drafts itineraries from a user's recommendations
+type ItineraryGenerator struct {
+	recommender Recommender
+	activities  ActivitySource
+	hours       OpeningHoursSource
+	exchange    CurrencyConverter
+	limits      schedule.Limits
+}
+
+func NewItineraryGenerator(recommender Recommender, activities ActivitySource, hours OpeningHoursSource, exchange CurrencyConverter, limits schedule.Limits) *ItineraryGenerator {
+	return &ItineraryGenerator{recommender: recommender, activities: activities, hours: hours, exchange: exchange, limits: limits}
+}
+
+// candidates ranks the destination's activities: the user's recommendations first, then popular
+// ones, then the rest by rating. Gorse being unavailable only costs the personalisation.
+func (g *ItineraryGenerator) candidates(ctx context.Context, opts GenerateOptions) ([]models.Activity, error) {
+	var ranked []string
+	if opts.UserID != "" {
+		ids, err := g.recommender.GetRecommendations(opts.UserID, generatorCandidatePool, opts.Category)
+		if err != nil {
+			log.Printf("warning: failed to get recommendations for user %s: %v", opts.UserID, err)
+		}
+		ranked = append(ranked, ids...)
+	}
+	ids, err := g.recommender.GetPopularActivities(generatorCandidatePool, opts.Category)
+	if err != nil {
+		log.Printf("warning: failed to get popular activities: %v", err)
+	}
+	ranked = append(ranked, ids...)
+
+	rank := make(map[int]int, len(ranked))
+	for i, id := range ranked {
+		n, err := strconv.Atoi(id)
+		if _, seen := rank[n]; err == nil && !seen {
+			rank[n] = i
+		}
+	}
+	listed, err := g.activities.GetActivitiesByIds(ctx, ranked)
+	if err != nil {
+		return nil, err
+	}
+	var out []models.Activity
+	seen := make(map[int]bool)
+	for _, a := range listed {
+		if a.CountryID == opts.CountryID && !seen[a.ID] {
+			out = append(out, a)
+			seen[a.ID] = true
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return rank[out[i].ID] < rank[out[j].ID] })
+
+	rest, err := g.activities.GetActivitiesByCountry(ctx, opts.CountryID, models.ActivityTaxonomyFilter{Category: opts.Category})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rest, func(i, j int) bool { return rest[i].Rating > rest[j].Rating })
+	for _, a := range rest {
+		if !seen[a.ID] {
+			out = append(out, a)
+			seen[a.ID] = true
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrNoCandidates
+	}
+	return out, nil
+}
+
+// Generate plans a draft for the destination and dates, ErrNoCandidates when it has no activities.
+// With a budget, activities whose price cannot be converted to the budget's currency are left out.
+func (g *ItineraryGenerator) Generate(ctx context.Context, opts GenerateOptions) (models.ItineraryDraft, error) {
+	draft := models.ItineraryDraft{
+		StartDate: opts.StartDate.Format(schedule.DateLayout),
+		EndDate:   opts.EndDate.Format(schedule.DateLayout),
+	}
+	units, err := g.exchange.MinorUnits(opts.Currency)
+	if err != nil {
+		return draft, err
+	}
+	activities, err := g.candidates(ctx, opts)
+	if err != nil {
+		return draft, err
+	}
+
+	ids := make([]int, len(activities))
+	for i, a := range activities {
+		ids[i] = a.ID
+	}
+	hours, err := g.hours.GetOpeningHoursByActivities(ctx, ids)
+	if err != nil {
+		return draft, err
+	}
+	candidates := make([]schedule.Candidate, 0, len(activities))
+	for _, a := range activities {
+		c := schedule.Candidate{Activity: a, Hours: hours[a.ID]}
+		if a.PriceMinor > 0 {
+			price, err := g.exchange.Convert(a.PriceMinor, a.Currency, opts.Currency)
+			if err != nil && opts.Budget > 0 {
+				continue
+			}
+			c.Cost = price.AmountMinor
+		}
+		candidates = append(candidates, c)
+	}
+
+	plan := schedule.Generate(schedule.PlanRequest{
+		StartDate: opts.StartDate,
+		EndDate:   opts.EndDate,
+		Pace:      opts.Pace,
+		Budget:    opts.Budget,
+		Limits:    g.limits,
+	}, candidates)
+
+	draft.Days = plan.Days
+	draft.TotalCost = models.ConvertedPrice{Currency: opts.Currency, AmountMinor: plan.Cost, Amount: money.ToMajor(plan.Cost, units), RateDate: g.exchange.RatesDate()}
+	if opts.Budget > 0 {
+		draft.Budget = &models.ConvertedPrice{Currency: opts.Currency, AmountMinor: opts.Budget, Amount: money.ToMajor(opts.Budget, units)}
+	}
+	draft.Warnings = schedule.Check(models.ItinerarySchedule{Days: plan.Days}, hours, g.limits)
+	return draft, nil
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	eventChannelPrefix = "itinerary:events:"
+	presenceKeyPrefix  = "itinerary:presence:"
+	// a connection not refreshed for this long counts as gone, its instance has most likely died
+	presenceTTL = time.Minute
+	// events a subscriber may fall behind by before it is disconnected
+	subscriberBuffer = 64
+)
+
+// ItineraryHub fans itinerary events out to the members streaming them. Events go through redis
+// pub/sub so members connected to other instances get them too, and presence is a sorted set per
+// itinerary of connections scored by when they expire, which each instance refreshes for its own.
+// Without a cache the hub only reaches this instance.
+type ItineraryHub struct {
+	cache       *CacheService
+	mu          sync.Mutex
+	subscribers map[int]map[*eventSubscriber]struct{}
+}
+
+type eventSubscriber struct {
+	events chan models.ItineraryEvent
+	viewer models.ItineraryViewer
+	// user_id:connection:username in the presence set
+	presence string
+}
+
+func NewItineraryHub(cache *CacheService) *ItineraryHub {
+	return &ItineraryHub{cache: cache, subscribers: make(map[int]map[*eventSubscriber]struct{})}
+}
+
+func eventChannel(itineraryID int) string {
+	return eventChannelPrefix + strconv.Itoa(itineraryID)
+}
+
+func presenceKey(itineraryID int) string {
+	return presenceKeyPrefix + strconv.Itoa(itineraryID)
+}
+
+// Start relays the events published by every instance to this instance's subscribers and keeps
+// their presence alive until stop is closed
+func (h *ItineraryHub) Start(stop <-chan struct{}) {
+	if h.cache == nil {
+		return
+	}
+	go func() {
+		pubsub := h.cache.Client.PSubscribe(h.cache.Ctx, eventChannelPrefix+"*")
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		ticker := time.NewTicker(presenceTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var event models.ItineraryEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					log.Printf("warning: ignoring malformed event on %s: %v", msg.Channel, err)
+					continue
+				}
+				h.deliver(event)
+			case <-ticker.C:
+				h.refreshPresence()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Publish sends an event to everyone viewing its itinerary. Events are best effort, when redis
+// cannot be reached the failure is logged and only members on this instance get it.
+func (h *ItineraryHub) Publish(ctx context.Context, event models.ItineraryEvent) {
+	if h == nil {
+		return
+	}
+	if event.At.IsZero() {
+		event.At = time.Now().UTC()
+	}
+	if h.cache != nil {
+		payload, err := json.Marshal(event)
+		if err == nil {
+			err = h.cache.Client.Publish(ctx, eventChannel(event.ItineraryID), payload).Err()
+		}
+		if err == nil {
+			return
+		}
+		log.Printf("warning: failed to publish %s event for itinerary %d: %v", event.Type, event.ItineraryID, err)
+	}
+	h.deliver(event)
+}
+
+// deliver hands an event to this instance's subscribers of its itinerary
+func (h *ItineraryHub) deliver(event models.ItineraryEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subscribers := h.subscribers[event.ItineraryID]
+	for sub := range subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			// a member this far behind reconnects and reloads the itinerary rather than holding up the rest
+			delete(subscribers, sub)
+			close(sub.events)
+		}
+	}
+	if len(subscribers) == 0 {
+		delete(h.subscribers, event.ItineraryID)
+	}
+}
+
+// Join subscribes a member to an itinerary's events and tells everyone viewing it they arrived.
+// Calling leave unsubscribes and announces they left, the events channel is closed early if the
+// member falls too far behind.
+func (h *ItineraryHub) Join(itineraryID int, member models.ItineraryMember) (events <-chan models.ItineraryEvent, leave func(), err error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, nil, err
+	}
+	sub := &eventSubscriber{
+		events:   make(chan models.ItineraryEvent, subscriberBuffer),
+		viewer:   models.ItineraryViewer{UserID: member.UserID, Username: member.Username, Connections: 1},
+		presence: fmt.Sprintf("%d:%s:%s", member.UserID, hex.EncodeToString(b), member.Username),
+	}
+
+	h.mu.Lock()
+	if h.subscribers[itineraryID] == nil {
+		h.subscribers[itineraryID] = make(map[*eventSubscriber]struct{})
+	}
+	h.subscribers[itineraryID][sub] = struct{}{}
+	h.mu.Unlock()
+	h.touchPresence(itineraryID, []string{sub.presence})
+	h.announce(itineraryID)
+
+	var once sync.Once
+	leave = func() {
+		once.Do(func() {
+			h.mu.Lock()
+			if subscribers, ok := h.subscribers[itineraryID]; ok {
+				if _, ok := subscribers[sub]; ok {
+					delete(subscribers, sub)
+					close(sub.events)
+				}
+				if len(subscribers) == 0 {
+					delete(h.subscribers, itineraryID)
+				}
+			}
+			h.mu.Unlock()
+			if h.cache != nil {
+				if err := h.cache.Client.ZRem(h.cache.Ctx, presenceKey(itineraryID), sub.presence).Err(); err != nil {
+					log.Printf("warning: failed to clear presence on itinerary %d: %v", itineraryID, err)
+				}
+			}
+			h.announce(itineraryID)
+		})
+	}
+	return sub.events, leave, nil
+}
+
+// announce publishes who is viewing an itinerary
+func (h *ItineraryHub) announce(itineraryID int) {
+	h.Publish(context.Background(), models.ItineraryEvent{
+		Type:        models.EventPresence,
+		ItineraryID: itineraryID,
+		Viewers:     h.Viewers(itineraryID),
+	})
+}
+
+// touchPresence marks connections to an itinerary as alive for another presenceTTL and drops the
+// ones nobody refreshed in time
+func (h *ItineraryHub) touchPresence(itineraryID int, presence []string) {
+	if h.cache == nil || len(presence) == 0 {
+		return
+	}
+	key := presenceKey(itineraryID)
+	now := time.Now()
+	expires := float64(now.Add(presenceTTL).UnixMilli())
+	members := make([]redis.Z, len(presence))
+	for i, p := range presence {
+		members[i] = redis.Z{Score: expires, Member: p}
+	}
+	pipe := h.cache.Client.TxPipeline()
+	pipe.ZAdd(h.cache.Ctx, key, members...)
+	pipe.ZRemRangeByScore(h.cache.Ctx, key, "-inf", "("+strconv.FormatInt(now.UnixMilli(), 10))
+	pipe.Expire(h.cache.Ctx, key, presenceTTL)
+	if _, err := pipe.Exec(h.cache.Ctx); err != nil {
+		log.Printf("warning: failed to refresh presence on itinerary %d: %v", itineraryID, err)
+	}
+}
+
+// refreshPresence keeps this instance's connections in the presence sets
+func (h *ItineraryHub) refreshPresence() {
+	h.mu.Lock()
+	presence := make(map[int][]string, len(h.subscribers))
+	for itineraryID, subscribers := range h.subscribers {
+		for sub := range subscribers {
+			presence[itineraryID] = append(presence[itineraryID], sub.presence)
+		}
+	}
+	h.mu.Unlock()
+	for itineraryID, p := range presence {
+		h.touchPresence(itineraryID, p)
+	}
+}
+
+// Viewers lists who is viewing an itinerary on any instance, falling back to the members connected
+// to this one when redis cannot be reached
+func (h *ItineraryHub) Viewers(itineraryID int) []models.ItineraryViewer {
+	var connections []models.ItineraryViewer
+	if h.cache != nil {
+		presence, err := h.cache.Client.ZRangeByScore(h.cache.Ctx, presenceKey(itineraryID), &redis.ZRangeBy{
+			Min: strconv.FormatInt(time.Now().UnixMilli(), 10),
+			Max: "+inf",
+		}).Result()
+		if err == nil {
+			for _, p := range presence {
+				parts := strings.SplitN(p, ":", 3)
+				if len(parts) != 3 {
+					continue
+				}
+				userID, err := strconv.Atoi(parts[0])
+				if err != nil {
+					continue
+				}
+				connections = append(connections, models.ItineraryViewer{UserID: userID, Username: parts[2], Connections: 1})
+			}
+			return mergeViewers(connections)
+		}
+		log.Printf("warning: failed to load presence on itinerary %d: %v", itineraryID, err)
+	}
+
+	h.mu.Lock()
+	for sub := range h.subscribers[itineraryID] {
+		connections = append(connections, sub.viewer)
+	}
+	h.mu.Unlock()
+	return mergeViewers(connections)
+}
+
+// mergeViewers counts the connections of each member, ordered by username
+func mergeViewers(connections []models.ItineraryViewer) []models.ItineraryViewer {
+	byUser := make(map[int]int)
+	viewers := []models.ItineraryViewer{}
+	for _, c := range connections {
+		if i, ok := byUser[c.UserID]; ok {
+			viewers[i].Connections += c.Connections
+			continue
+		}
+		byUser[c.UserID] = len(viewers)
+		viewers = append(viewers, c)
+	}
+	sort.Slice(viewers, func(i, j int) bool {
+		if viewers[i].Username != viewers[j].Username {
+			return viewers[i].Username < viewers[j].Username
+		}
+		return viewers[i].UserID < viewers[j].UserID
+	})
+	return viewers
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalBlobPath is where the server mounts LocalStore to serve its presigned urls
+const LocalBlobPath = "/blobs/"
+
+// temporary files are written under this prefix and renamed into place once complete
+const localTempPrefix = ".upload-"
+
+var errInvalidKey = errors.New("invalid object key")
+
+// LocalStore keeps objects as files under a directory. Presigned urls point back at this server
+// and carry an HMAC signature, so they behave like MinIO's: anyone holding one can GET or PUT
+// that object until it expires. The disk keeps no metadata, so content types come from the key's extension.
+type LocalStore struct {
+	Root      string
+	BaseURL   string
+	URLExpiry time.Duration
+	// largest body accepted through a presigned PUT
+	MaxUploadBytes int64
+	signingKey     []byte
+}
+
+var _ BlobStore = (*LocalStore)(nil)
+
+// NewLocalStore creates root if needed. Without a signing key a random one is used, which
+// invalidates every outstanding url when the server restarts.
+func NewLocalStore(root, baseURL string, signingKey []byte, urlExpiry time.Duration, maxUploadBytes int64) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	if len(signingKey) == 0 {
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, err
+		}
+		log.Println("warning: STORAGE_SIGNING_KEY is not set, local storage urls will not survive a restart")
+	}
+	return &LocalStore{
+		Root:           root,
+		BaseURL:        strings.TrimRight(baseURL, "/"),
+		URLExpiry:      urlExpiry,
+		MaxUploadBytes: maxUploadBytes,
+		signingKey:     signingKey,
+	}, nil
+}
+
+// filePath maps a key to its file, rejecting keys that would escape the root
+func (s *LocalStore) filePath(objectName string) (string, error) {
+	if objectName == "" || strings.HasPrefix(objectName, "/") || path.Clean(objectName) != objectName || strings.HasPrefix(objectName, "../") || objectName == ".." {
+		return "", errInvalidKey
+	}
+	if strings.HasPrefix(path.Base(objectName), localTempPrefix) {
+		return "", errInvalidKey
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(objectName)), nil
+}
+
+func contentTypeOf(objectName string) string {
+	if t := mime.TypeByExtension(path.Ext(objectName)); t != "" {
+		// drop parameters such as charset so types compare cleanly
+		return strings.TrimSpace(strings.SplitN(t, ";", 2)[0])
+	}
+	return "application/octet-stream"
+}
+
+func (s *LocalStore) signature(method, objectName string, expires int64) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(method + "\n" + objectName + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *LocalStore) presign(method, objectName string, expiry time.Duration) string {
+	expires := time.Now().Add(expiry).Unix()
+	u := url.URL{Path: LocalBlobPath + objectName}
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("signature", s.signature(method, objectName, expires))
+	return s.BaseURL + u.EscapedPath() + "?" + q.Encode()
+}
+
+func (s *LocalStore) GetObjectURL(ctx context.Context, objectName string) string {
+	if objectName == "" || strings.HasPrefix(objectName, "http://") || strings.HasPrefix(objectName, "https://") {
+		return objectName
+	}
+	return s.presign(http.MethodGet, objectName, s.URLExpiry)
+}
+
+func (s *LocalStore) PresignUpload(ctx context.Context, objectName string, expiry time.Duration) (string, error) {
+	if _, err := s.filePath(objectName); err != nil {
+		return "", err
+	}
+	return s.presign(http.MethodPut, objectName, expiry), nil
+}
+
+func (s *LocalStore) StatObject(ctx context.Context, objectName string) (ObjectInfo, error) {
+	p, err := s.filePath(objectName)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return ObjectInfo{}, ErrObjectNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{Key: objectName, Size: info.Size(), ContentType: contentTypeOf(objectName), LastModified: info.ModTime()}, nil
+}
+
+func (s *LocalStore) open(objectName string) (*os.File, error) {
+	p, err := s.filePath(objectName)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return f, err
+}
+
+func (s *LocalStore) ReadHead(ctx context.Context, objectName string, n int64) ([]byte, error) {
+	f, err := s.open(objectName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, n))
+}
+
+func (s *LocalStore) ReadObject(ctx context.Context, objectName string) ([]byte, error) {
+	f, err := s.open(objectName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// write streams r into a temporary file and renames it over the object, so readers never see a partial file
+func (s *LocalStore) write(objectName string, r io.Reader) error {
+	p, err := s.filePath(objectName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), localTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// WriteObject ignores contentType since the extension already determines it
+func (s *LocalStore) WriteObject(ctx context.Context, objectName string, data []byte, contentType string) error {
+	return s.write(objectName, bytes.NewReader(data))
+}
+
+// RemoveObject succeeds when the object is already gone, like MinIO
+func (s *LocalStore) RemoveObject(ctx context.Context, objectName string) error {
+	p, err := s.filePath(objectName)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStore) ListObjects(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	err := filepath.WalkDir(s.Root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), localTempPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.Root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), ContentType: contentTypeOf(key), LastModified: info.ModTime()})
+		return nil
+	})
+	return objects, err
+}
+
+// ServeHTTP answers the presigned GET and PUT urls handed out by GetObjectURL and PresignUpload
+func (s *LocalStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	objectName := strings.TrimPrefix(r.URL.Path, LocalBlobPath)
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	if method != http.MethodGet && method != http.MethodPut {
+		http.Error(w, "Method Not available", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		http.Error(w, "Url has expired", http.StatusForbidden)
+		return
+	}
+	if !hmac.Equal([]byte(q.Get("signature")), []byte(s.signature(method, objectName, expires))) {
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	}
+
+	if method == http.MethodGet {
+		f, err := s.open(objectName)
+		if err != nil {
+			http.Error(w, "Object not found", http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			http.Error(w, "Object not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", contentTypeOf(objectName))
+		http.ServeContent(w, r, "", info.ModTime(), f)
+		return
+	}
+
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.EqualFold(ct, contentTypeOf(objectName)) {
+		http.Error(w, "Content-Type does not match the object", http.StatusBadRequest)
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, s.MaxUploadBytes)
+	if err := s.write(objectName, body); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Upload is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if errors.Is(err, errInvalidKey) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to store upload", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"log"
+	"time"
+)
+
+// Purger is implemented by repositories whose soft deleted rows can be permanently removed
+type Purger interface {
+	PurgeDeleted(before time.Time) (int64, error)
+}
+
+type PurgeService struct {
+	Retention time.Duration
+	Interval  time.Duration
+	purgers   map[string]Purger
+	order     []string
+}
+
+func NewPurgeService(retention, interval time.Duration) *PurgeService {
+	return &PurgeService{
+		Retention: retention,
+		Interval:  interval,
+		purgers:   make(map[string]Purger),
+	}
+}
+
+// Register adds a purger, purgers run in the order they were registered
+func (p *PurgeService) Register(name string, purger Purger) {
+	p.purgers[name] = purger
+	p.order = append(p.order, name)
+}
+
+// Start purges once immediately and then on every interval until stop is closed
+func (p *PurgeService) Start(stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(p.Interval)
+		defer ticker.Stop()
+		for {
+			p.PurgeOnce()
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (p *PurgeService) PurgeOnce() {
+	cutoff := time.Now().Add(-p.Retention)
+	for _, name := range p.order {
+		n, err := p.purgers[name].PurgeDeleted(cutoff)
+		if err != nil {
+			log.Printf("warning: failed to purge deleted %s: %v", name, err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("purged %d deleted %s", n, name)
+		}
+	}
+}
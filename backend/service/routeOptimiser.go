@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/geo"
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
+
+var ErrDayNotInTrip = errors.New("day is not part of the trip")
+
+// RouteOptimiser proposes the shortest order to visit a day's activities in
+type RouteOptimiser struct {
+	schedules ScheduleSource
+	speedKmh  float64
+}
+
+func NewRouteOptimiser(schedules ScheduleSource, speedKmh float64) *RouteOptimiser {
+	return &RouteOptimiser{schedules: schedules, speedKmh: speedKmh}
+}
+
+func itemPoint(item models.ItineraryItem) *geo.Point {
+	if a := item.Activity; a != nil && a.Latitude != nil && a.Longitude != nil {
+		return &geo.Point{Lat: *a.Latitude, Lng: *a.Longitude}
+	}
+	return nil
+}
+
+// Optimise proposes an order for the items of day starting from start, or from the first stop when
+// start is nil. Items with a start time stay in time order, the rest are fitted around them.
+func (o *RouteOptimiser) Optimise(ctx context.Context, itineraryID int, day string, start *geo.Point) (models.RouteProposal, error) {
+	proposal := models.RouteProposal{ItineraryID: itineraryID, Day: day, ItemIDs: []int{}, Stops: []models.RouteStop{}}
+	s, err := o.schedules.GetItinerarySchedule(ctx, itineraryID)
+	if err != nil {
+		return proposal, err
+	}
+	var items []models.ItineraryItem
+	found := false
+	for _, d := range s.Days {
+		if d.Date == day {
+			items, found = d.Items, true
+		}
+	}
+	if !found {
+		return proposal, ErrDayNotInTrip
+	}
+
+	current := make([]geo.Stop, len(items))
+	identity := make([]int, len(items))
+	for i, item := range items {
+		current[i] = geo.Stop{Point: itemPoint(item), Fixed: item.StartTime != ""}
+		identity[i] = i
+	}
+	proposal.CurrentMeters = geo.RouteLength(start, current, identity)
+
+	// fixed items are handed to the optimiser in time order, in the slots fixed items hold now
+	var fixed []int
+	for i, item := range items {
+		if item.StartTime != "" {
+			fixed = append(fixed, i)
+		}
+	}
+	byTime := append([]int(nil), fixed...)
+	sort.SliceStable(byTime, func(a, b int) bool { return items[byTime[a]].StartTime < items[byTime[b]].StartTime })
+	input := append([]int(nil), identity...)
+	for k, slot := range fixed {
+		input[slot] = byTime[k]
+	}
+	stops := make([]geo.Stop, len(input))
+	for i, idx := range input {
+		stops[i] = current[idx]
+	}
+
+	order, meters, exact := geo.OptimiseRoute(start, stops)
+	proposal.TotalMeters = meters
+	proposal.Exact = exact
+
+	var travel time.Duration
+	prev := start
+	for _, i := range order {
+		item := items[input[i]]
+		stop := models.RouteStop{ItemID: item.ID, ActivityID: item.ActivityID, Fixed: item.StartTime != ""}
+		if item.Activity != nil {
+			stop.Title = item.Activity.Title
+		}
+		if p := stops[i].Point; p != nil {
+			if prev != nil {
+				stop.LegMeters = geo.DistanceMeters(*prev, *p)
+				travel += geo.TravelTime(*prev, *p, o.speedKmh)
+			}
+			prev = p
+		}
+		proposal.ItemIDs = append(proposal.ItemIDs, item.ID)
+		proposal.Stops = append(proposal.Stops, stop)
+	}
+	proposal.TravelMinutes = int(travel.Round(time.Minute).Minutes())
+	return proposal, nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/schedule"
+)
+
+// ScheduleSource loads an itinerary laid out day by day
+type ScheduleSource interface {
+	GetItinerarySchedule(ctx context.Context, id int) (models.ItinerarySchedule, error)
+}
+
+// OpeningHoursSource loads the opening hours of several activities at once
+type OpeningHoursSource interface {
+	GetOpeningHoursByActivities(ctx context.Context, ids []int) (map[int]models.OpeningHours, error)
+}
+
+// ScheduleValidator checks itineraries for conflicts and days that cannot work in practice
+type ScheduleValidator struct {
+	schedules ScheduleSource
+	hours     OpeningHoursSource
+	limits    schedule.Limits
+}
+
+func NewScheduleValidator(schedules ScheduleSource, hours OpeningHoursSource, limits schedule.Limits) *ScheduleValidator {
+	return &ScheduleValidator{schedules: schedules, hours: hours, limits: limits}
+}
+
+// Validate loads the itinerary and returns its warnings
+func (v *ScheduleValidator) Validate(ctx context.Context, itineraryID int) ([]models.ScheduleWarning, error) {
+	s, err := v.schedules.GetItinerarySchedule(ctx, itineraryID)
+	if err != nil {
+		return nil, err
+	}
+	return v.Check(ctx, s)
+}
+
+// Check returns the warnings of an already loaded schedule
+func (v *ScheduleValidator) Check(ctx context.Context, s models.ItinerarySchedule) ([]models.ScheduleWarning, error) {
+	var ids []int
+	for _, day := range s.Days {
+		for _, item := range day.Items {
+			ids = append(ids, item.ActivityID)
+		}
+	}
+	hours, err := v.hours.GetOpeningHoursByActivities(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return schedule.Check(s, hours, v.limits), nil
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
+
+// ObjectReferences is how the storage gc learns which objects the database still needs
+type ObjectReferences interface {
+	GetUnreferencedImages(ctx context.Context, pendingBefore time.Time) ([]models.Image, error)
+	DiscardImages(ctx context.Context, images []models.Image) error
+	GetReferencedObjectKeys(ctx context.Context) (map[string]bool, error)
+}
+
+// gcPrefixes are the key prefixes images are uploaded under, the gc never looks at anything else
+// in the bucket so objects written by other tools are safe
+var gcPrefixes = []string{models.ImageOwnerActivity + "/", models.ImageOwnerItinerary + "/"}
+
+// ErrGCRunning is returned when a collection is requested while another is in progress
+var ErrGCRunning = errors.New("storage gc is already running")
+
+// StorageGC removes objects nothing in the database refers to. Abandoned uploads and images of
+// purged activities or itineraries are discarded first so their objects are collected too.
+// Only objects older than GracePeriod are touched, which protects uploads still in flight.
+type StorageGC struct {
+	refs        ObjectReferences
+	blobs       BlobStore
+	GracePeriod time.Duration
+	Interval    time.Duration
+	DryRun      bool
+
+	running sync.Mutex
+	mu      sync.RWMutex
+	last    *models.StorageGCReport
+}
+
+func NewStorageGC(refs ObjectReferences, blobs BlobStore, gracePeriod, interval time.Duration, dryRun bool) *StorageGC {
+	return &StorageGC{refs: refs, blobs: blobs, GracePeriod: gracePeriod, Interval: interval, DryRun: dryRun}
+}
+
+// Start collects once immediately and then on every interval until stop is closed
+func (g *StorageGC) Start(stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(g.Interval)
+		defer ticker.Stop()
+		for {
+			if _, err := g.Run(context.Background(), g.DryRun); err != nil {
+				log.Printf("warning: storage gc failed: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// LastReport returns the report of the most recent run, or nil before the first one
+func (g *StorageGC) LastReport() *models.StorageGCReport {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.last
+}
+
+// Run performs one collection. Failures to remove single objects are listed in the report
+// rather than aborting the run.
+func (g *StorageGC) Run(ctx context.Context, dryRun bool) (*models.StorageGCReport, error) {
+	if !g.running.TryLock() {
+		return nil, ErrGCRunning
+	}
+	defer g.running.Unlock()
+
+	report := &models.StorageGCReport{DryRun: dryRun, StartedAt: time.Now(), DiscardedImages: []int{}, Removed: []models.RemovedObject{}}
+	cutoff := report.StartedAt.Add(-g.GracePeriod)
+
+	// list before reading references, anything uploaded in between is newer than the cutoff
+	var objects []ObjectInfo
+	for _, prefix := range gcPrefixes {
+		listed, err := g.blobs.ListObjects(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, listed...)
+	}
+	report.Scanned = len(objects)
+
+	discard, err := g.refs.GetUnreferencedImages(ctx, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun && len(discard) > 0 {
+		if err := g.refs.DiscardImages(ctx, discard); err != nil {
+			return nil, err
+		}
+	}
+	referenced, err := g.refs.GetReferencedObjectKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// in a dry run the discarded rows still exist, treat their objects as unreferenced anyway
+	for _, image := range discard {
+		report.DiscardedImages = append(report.DiscardedImages, image.ID)
+		delete(referenced, image.ObjectKey)
+		for _, v := range image.Variants {
+			delete(referenced, v.ObjectKey)
+		}
+	}
+
+	for _, obj := range objects {
+		if referenced[obj.Key] {
+			report.Referenced++
+			continue
+		}
+		if !obj.LastModified.Before(cutoff) {
+			continue
+		}
+		if !dryRun {
+			if err := g.blobs.RemoveObject(ctx, obj.Key); err != nil {
+				report.Failed = append(report.Failed, obj.Key+": "+err.Error())
+				continue
+			}
+		}
+		report.Removed = append(report.Removed, models.RemovedObject{Key: obj.Key, Size: obj.Size, LastModified: obj.LastModified})
+		report.RemovedBytes += obj.Size
+	}
+	report.FinishedAt = time.Now()
+
+	verb := "removed"
+	if dryRun {
+		verb = "would remove"
+	}
+	log.Printf("storage gc %s %d of %d objects (%d bytes), discarded %d images", verb, len(report.Removed), report.Scanned, report.RemovedBytes, len(report.DiscardedImages))
+
+	g.mu.Lock()
+	g.last = report
+	g.mu.Unlock()
+	return report, nil
+}
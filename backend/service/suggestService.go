@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
+
+const (
+	suggestCachePrefix = "suggest:"
+	// prefixes up to this length are shared by most users and worth caching in redis
+	suggestCacheMaxPrefix = 4
+	suggestCacheTTL       = 5 * time.Minute
+	// stop collecting matches once this many are found, they are then ranked and trimmed
+	suggestMaxCandidates = 200
+)
+
+// SuggestionSource loads everything the autocomplete index should contain
+type SuggestionSource interface {
+	GetSuggestionCandidates(ctx context.Context) ([]models.Suggestion, error)
+}
+
+type trieNode struct {
+	children map[rune]*trieNode
+	entries  []int
+}
+
+// SuggestService answers prefix queries from an in-memory trie. Every word of a title is
+// indexed, so "tow" suggests "Eiffel Tower" as well as "Tower of London".
+type SuggestService struct {
+	source SuggestionSource
+	cache  *CacheService
+
+	mu          sync.RWMutex
+	root        *trieNode
+	suggestions []models.Suggestion
+
+	rebuilding sync.Mutex
+}
+
+func NewSuggestService(source SuggestionSource, cache *CacheService) *SuggestService {
+	return &SuggestService{
+		source: source,
+		cache:  cache,
+		root:   &trieNode{},
+	}
+}
+
+// normalizeSuggestText lower cases and drops everything but letters, digits and single spaces
+func normalizeSuggestText(text string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteRune(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		} else {
+			space = true
+		}
+	}
+	return b.String()
+}
+
+func (n *trieNode) insert(key string, entry int) {
+	node := n
+	for _, r := range key {
+		if node.children == nil {
+			node.children = make(map[rune]*trieNode)
+		}
+		child, ok := node.children[r]
+		if !ok {
+			child = &trieNode{}
+			node.children[r] = child
+		}
+		node = child
+	}
+	node.entries = append(node.entries, entry)
+}
+
+func (n *trieNode) find(prefix string) *trieNode {
+	node := n
+	for _, r := range prefix {
+		child, ok := node.children[r]
+		if !ok {
+			return nil
+		}
+		node = child
+	}
+	return node
+}
+
+// collect gathers entry indexes under n until limit distinct entries are seen
+func (n *trieNode) collect(seen map[int]bool, limit int) {
+	for _, entry := range n.entries {
+		if len(seen) >= limit {
+			return
+		}
+		seen[entry] = true
+	}
+	for _, child := range n.children {
+		if len(seen) >= limit {
+			return
+		}
+		child.collect(seen, limit)
+	}
+}
+
+// Rebuild reloads the source and swaps in a fresh index, queries keep using the old one meanwhile
+func (s *SuggestService) Rebuild(ctx context.Context) error {
+	s.rebuilding.Lock()
+	defer s.rebuilding.Unlock()
+
+	suggestions, err := s.source.GetSuggestionCandidates(ctx)
+	if err != nil {
+		return err
+	}
+
+	root := &trieNode{}
+	for i, suggestion := range suggestions {
+		words := strings.Split(normalizeSuggestText(suggestion.Text), " ")
+		for w := range words {
+			root.insert(strings.Join(words[w:], " "), i)
+		}
+	}
+
+	s.mu.Lock()
+	s.root = root
+	s.suggestions = suggestions
+	s.mu.Unlock()
+
+	if s.cache != nil {
+		if err := s.cache.DeletePrefix(suggestCachePrefix); err != nil {
+			log.Printf("warning: failed to clear suggestion cache: %v", err)
+		}
+	}
+	return nil
+}
+
+// RebuildAsync rebuilds in the background after an activity or country changes
+func (s *SuggestService) RebuildAsync() {
+	go func() {
+		if err := s.Rebuild(context.Background()); err != nil {
+			log.Printf("warning: failed to rebuild suggestion index: %v", err)
+		}
+	}()
+}
+
+// Start builds the index and then rebuilds it on every interval so changes made through
+// other backend instances are picked up
+func (s *SuggestService) Start(interval time.Duration) {
+	if err := s.Rebuild(context.Background()); err != nil {
+		log.Printf("warning: failed to build suggestion index: %v", err)
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := s.Rebuild(context.Background()); err != nil {
+				log.Printf("warning: failed to rebuild suggestion index: %v", err)
+			}
+		}
+	}()
+}
+
+// Suggest returns up to limit activities and countries with a word starting with prefix.
+// Whole-text prefix matches rank first, then shorter texts, then alphabetical.
+func (s *SuggestService) Suggest(prefix string, limit int) []models.Suggestion {
+	prefix = normalizeSuggestText(prefix)
+	if prefix == "" {
+		return []models.Suggestion{}
+	}
+
+	cacheKey := suggestCachePrefix + strconv.Itoa(limit) + ":" + prefix
+	useCache := s.cache != nil && len([]rune(prefix)) <= suggestCacheMaxPrefix
+	if useCache {
+		if data, err := s.cache.Get(cacheKey); err == nil {
+			var cached []models.Suggestion
+			if err := json.Unmarshal(data, &cached); err == nil {
+				return cached
+			}
+		}
+	}
+
+	s.mu.RLock()
+	node := s.root.find(prefix)
+	seen := make(map[int]bool)
+	if node != nil {
+		node.collect(seen, suggestMaxCandidates)
+	}
+	results := make([]models.Suggestion, 0, len(seen))
+	for entry := range seen {
+		results = append(results, s.suggestions[entry])
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		iStarts := strings.HasPrefix(normalizeSuggestText(results[i].Text), prefix)
+		jStarts := strings.HasPrefix(normalizeSuggestText(results[j].Text), prefix)
+		if iStarts != jStarts {
+			return iStarts
+		}
+		if len(results[i].Text) != len(results[j].Text) {
+			return len(results[i].Text) < len(results[j].Text)
+		}
+		return results[i].Text < results[j].Text
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	if useCache {
+		if data, err := json.Marshal(results); err == nil {
+			_ = s.cache.Set(cacheKey, data, suggestCacheTTL)
+		}
+	}
+	return results
+}
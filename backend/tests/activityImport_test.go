@@ -0,0 +1,220 @@
+package tests
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Joshua-Pok/FYP-backend/handlers"
+	"github.com/Joshua-Pok/FYP-backend/models"
+)
+
+// readRows drains a row reader, returning the rows and the error of each row, nil when it parsed
+func readRows(t *testing.T, rows handlers.ActivityRowReader) ([]models.ActivityImportRow, []error) {
+	var read []models.ActivityImportRow
+	var errs []error
+	for {
+		row, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			return read, errs
+		}
+		var bad handlers.RowError
+		if err != nil && !errors.As(err, &bad) {
+			t.Fatalf("row %d: expected a row error, got %v", len(read)+1, err)
+		}
+		read = append(read, row)
+		errs = append(errs, err)
+	}
+}
+
+func floatPtr(f float64) *float64 { return &f }
+
+func TestSplitListCell(t *testing.T) {
+	cases := map[string][]string{
+		"":                                nil,
+		"   ":                             nil,
+		"museum":                          {"museum"},
+		"museum; art":                     {"museum", "art"},
+		"museum, art":                     {"museum", "art"},
+		"['adventure', 'extreme-sports']": {"adventure", "extreme-sports"},
+		`["a", "b"]`:                      {"a", "b"},
+		"[]":                              nil,
+		"a;;b, ,":                         {"a", "b"},
+	}
+	for cell, want := range cases {
+		if got := handlers.SplitListCell(cell); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: expected %q, got %q", cell, want, got)
+		}
+	}
+}
+
+func TestCSVRowReader(t *testing.T) {
+	rows, err := handlers.NewCSVRowReader(strings.NewReader("\ufeffTags, title ,price,latitude,longitude,categories\n" +
+		"night; food,Night Safari,55.5,1.4,103.8,wildlife\n" +
+		"outdoor,Botanic Gardens,,,,\n" +
+		",Bad Price,cheap,,,\n" +
+		",Bad Latitude,1,north,103.8,\n" +
+		"Short Row,1\n"))
+	if err != nil {
+		t.Fatalf("expected the header to parse, got %v", err)
+	}
+	read, errs := readRows(t, rows)
+	if len(read) != 5 {
+		t.Fatalf("expected 5 rows, got %d", len(read))
+	}
+	want := models.ActivityImportRow{Title: "Night Safari", Price: 55.5, Latitude: floatPtr(1.4), Longitude: floatPtr(103.8), Categories: []string{"wildlife"}, Tags: []string{"night", "food"}}
+	if errs[0] != nil || !reflect.DeepEqual(read[0], want) {
+		t.Errorf("expected %+v, got %+v (%v)", want, read[0], errs[0])
+	}
+	if errs[1] != nil || read[1].Title != "Botanic Gardens" || read[1].Price != 0 || read[1].Latitude != nil {
+		t.Errorf("expected empty cells to stay unset, got %+v (%v)", read[1], errs[1])
+	}
+	for i, want := range []string{"invalid price", "invalid latitude", "expected 6 fields, got 2"} {
+		if err := errs[i+2]; err == nil || err.Error() != want {
+			t.Errorf("row %d: expected %q, got %v", i+3, want, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"unknown column":   "title,colour\n",
+		"duplicate column": "title,Title\n",
+		"missing title":    "name,price\n",
+		"empty file":       "",
+	}
+	for name, input := range invalid {
+		if _, err := handlers.NewCSVRowReader(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCSVRowReader_GorseItems(t *testing.T) {
+	rows, err := handlers.NewCSVRowReader(strings.NewReader(`"ItemId","IsHidden","Categories","Timestamp","Labels","Comment"
+"bungee_jumping","False","['adventure', 'extreme-sports']","","bungee_jumping","Activity: Bungee Jumping"
+`))
+	if err != nil {
+		t.Fatalf("expected the gorse columns to be mapped, got %v", err)
+	}
+	read, errs := readRows(t, rows)
+	want := models.ActivityImportRow{Title: "bungee_jumping", Name: "Activity: Bungee Jumping", Categories: []string{"adventure", "extreme-sports"}, Tags: []string{"bungee_jumping"}}
+	if len(read) != 1 || errs[0] != nil || !reflect.DeepEqual(read[0], want) {
+		t.Errorf("expected %+v, got %+v (%v)", want, read, errs)
+	}
+
+	f, err := os.Open("../../scripts/gorse_items.csv")
+	if err != nil {
+		t.Fatalf("failed to open gorse items: %v", err)
+	}
+	defer f.Close()
+	if rows, err = handlers.NewCSVRowReader(f); err != nil {
+		t.Fatalf("expected scripts/gorse_items.csv to be importable, got %v", err)
+	}
+	read, errs = readRows(t, rows)
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("row %d: %v", i+1, err)
+		}
+	}
+	if len(read) == 0 {
+		t.Error("expected rows in scripts/gorse_items.csv")
+	}
+}
+
+func TestJSONRowReader(t *testing.T) {
+	rows, err := handlers.NewJSONRowReader(strings.NewReader(`[
+		{"title": "Night Safari", "price": 55.5, "latitude": 1.4, "longitude": 103.8, "tags": ["night"]},
+		{"title": "Bad Price", "price": "cheap"},
+		{"title": ["not", "a", "string"]},
+		{"title": "Botanic Gardens"}
+	]`))
+	if err != nil {
+		t.Fatalf("expected the array to open, got %v", err)
+	}
+	read, errs := readRows(t, rows)
+	if len(read) != 4 {
+		t.Fatalf("expected a wrongly typed value to skip only its row, got %d rows", len(read))
+	}
+	if errs[0] != nil || read[0].Title != "Night Safari" || *read[0].Latitude != 1.4 || read[0].Tags[0] != "night" {
+		t.Errorf("unexpected first row %+v (%v)", read[0], errs[0])
+	}
+	for i, want := range []string{"invalid price", "invalid title"} {
+		if err := errs[i+1]; err == nil || err.Error() != want {
+			t.Errorf("row %d: expected %q, got %v", i+2, want, err)
+		}
+	}
+	if errs[3] != nil || read[3].Title != "Botanic Gardens" {
+		t.Errorf("expected the row after the bad ones to be read, got %+v (%v)", read[3], errs[3])
+	}
+
+	// broken JSON aborts the import instead of skipping a row
+	rows, err = handlers.NewJSONRowReader(strings.NewReader(`[{"title": "Night Safari"}, {"title": `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Next()
+	var bad handlers.RowError
+	if _, err := rows.Next(); err == nil || errors.Is(err, io.EOF) || errors.As(err, &bad) {
+		t.Errorf("expected a fatal error for truncated JSON, got %v", err)
+	}
+
+	for _, input := range []string{`{"title": "Night Safari"}`, ``, `"activities"`} {
+		if _, err := handlers.NewJSONRowReader(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestToActivity(t *testing.T) {
+	countries := map[string]models.Country{
+		"sg":        {ID: 1, Name: "Singapore", ISOCode: "SG", Currency: "SGD"},
+		"singapore": {ID: 1, Name: "Singapore", ISOCode: "SG", Currency: "SGD"},
+		"jp":        {ID: 2, Name: "Japan", ISOCode: "JP", Currency: "JPY"},
+	}
+	currencies := map[string]int{"USD": 2, "SGD": 2, "JPY": 0}
+
+	activity, err := handlers.ToActivity(models.ActivityImportRow{
+		Title: " Night Safari ", Price: 55.5, Country: "Singapore", Latitude: floatPtr(1.4), Longitude: floatPtr(103.8),
+		Categories: []string{"Wildlife", "wildlife"}, Tags: []string{" Night  Tour "},
+	}, countries, currencies)
+	if err != nil {
+		t.Fatalf("expected a valid row, got %v", err)
+	}
+	if activity.Title != "Night Safari" || activity.Name != "Night Safari" || activity.CountryID != 1 {
+		t.Errorf("expected the title as name and Singapore, got %+v", activity)
+	}
+	if activity.Currency != "SGD" || activity.PriceMinor != 5550 {
+		t.Errorf("expected 5550 SGD cents from the country, got %d %s", activity.PriceMinor, activity.Currency)
+	}
+	if !reflect.DeepEqual(activity.Categories, []string{"wildlife"}) || !reflect.DeepEqual(activity.Tags, []string{"night tour"}) {
+		t.Errorf("expected normalised taxonomy, got %q %q", activity.Categories, activity.Tags)
+	}
+
+	activity, err = handlers.ToActivity(models.ActivityImportRow{Title: "Ramen", Price: 980, Country: "jp"}, countries, currencies)
+	if err != nil || activity.Currency != "JPY" || activity.PriceMinor != 980 {
+		t.Errorf("expected 980 JPY with no minor units, got %d %s (%v)", activity.PriceMinor, activity.Currency, err)
+	}
+	activity, err = handlers.ToActivity(models.ActivityImportRow{Title: "Walk", Price: 1.25}, countries, currencies)
+	if err != nil || activity.Currency != "USD" || activity.PriceMinor != 125 || activity.CountryID != 0 {
+		t.Errorf("expected 125 USD cents without a country, got %+v (%v)", activity, err)
+	}
+
+	invalid := map[string]models.ActivityImportRow{
+		"missing title":      {Title: "  "},
+		"long name":          {Title: "Walk", Name: strings.Repeat("a", 256)},
+		"negative price":     {Title: "Walk", Price: -1},
+		"unknown country":    {Title: "Walk", Country: "Atlantis"},
+		"unknown currency":   {Title: "Walk", Currency: "XYZ"},
+		"latitude only":      {Title: "Walk", Latitude: floatPtr(1.4)},
+		"latitude too large": {Title: "Walk", Latitude: floatPtr(91), Longitude: floatPtr(0)},
+		"bad category":       {Title: "Walk", Categories: []string{"Not A Slug!"}},
+		"long tag":           {Title: "Walk", Tags: []string{strings.Repeat("a", 51)}},
+	}
+	for name, row := range invalid {
+		if _, err := handlers.ToActivity(row, countries, currencies); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
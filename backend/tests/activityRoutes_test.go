@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/config"
+	"github.com/Joshua-Pok/FYP-backend/handlers"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/server"
+)
+
+func TestActivityRoutes_WritesAreAdminOnly(t *testing.T) {
+	srv := server.New(config.Config{Admin: config.AdminConfig{Usernames: []string{"admin@example.com"}}}, nil)
+	mux := http.NewServeMux()
+	activityHandler := handlers.NewActivityhandler(&repository.ActivityRepository{}, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, openAccess())
+	srv.RegisterActivityRoutes(mux, activityHandler, handlers.NewOpeningHoursHandler(nil))
+
+	bearer := func(username string) string {
+		token, err := auth.CreateToken(username)
+		if err != nil {
+			t.Fatalf("failed to create token: %v", err)
+		}
+		return "Bearer " + token
+	}
+	callers := map[string]struct {
+		authorization string
+		want          int
+	}{
+		"anonymous": {"", http.StatusUnauthorized},
+		"non admin": {bearer("someone@example.com"), http.StatusForbidden},
+		// an admin gets past the gate to the handler, which rejects the missing activity id or body
+		"admin": {bearer("admin@example.com"), http.StatusBadRequest},
+	}
+	writes := []struct{ method, target string }{
+		{http.MethodPost, "/activity"},
+		{http.MethodDelete, "/activity"},
+		{http.MethodPost, "/activity/restore"},
+		{http.MethodPut, "/activity/taxonomy"},
+		{http.MethodPut, "/activity/hours"},
+	}
+	for _, route := range writes {
+		for name, caller := range callers {
+			req := httptest.NewRequest(route.method, route.target, strings.NewReader("not json"))
+			if caller.authorization != "" {
+				req.Header.Set("Authorization", caller.authorization)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != caller.want {
+				t.Errorf("%s %s as %s: expected %d, got %d", route.method, route.target, name, caller.want, rec.Code)
+			}
+		}
+	}
+}
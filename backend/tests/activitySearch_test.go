@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Joshua-Pok/FYP-backend/handlers"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+)
+
+func TestParseActivitySearch(t *testing.T) {
+	filter, err := handlers.ParseActivitySearch(url.Values{
+		"q":          {"  night market "},
+		"min_price":  {"5"},
+		"max_price":  {"20.5"},
+		"min_rating": {"4"},
+		"country":    {"sg"},
+		"limit":      {"10"},
+		"offset":     {"30"},
+	}, "")
+	if err != nil {
+		t.Fatalf("expected a valid search, got %v", err)
+	}
+	if filter.Query != "night market" || *filter.MinPrice != 5 || *filter.MaxPrice != 20.5 || *filter.MinRating != 4 {
+		t.Errorf("unexpected filter %+v", filter)
+	}
+	if filter.CountryCode != "SG" || filter.CountryID != 0 {
+		t.Errorf("expected an ISO code country, got id %d code %q", filter.CountryID, filter.CountryCode)
+	}
+	if filter.Limit != 10 || filter.Offset != 30 || filter.PriceCurrency != "USD" {
+		t.Errorf("expected limit 10, offset 30 and prices in USD, got %+v", filter)
+	}
+
+	filter, err = handlers.ParseActivitySearch(url.Values{"q": {"museum"}, "country": {"42"}}, "EUR")
+	if err != nil {
+		t.Fatalf("expected a valid search, got %v", err)
+	}
+	if filter.CountryID != 42 || filter.CountryCode != "" || filter.PriceCurrency != "EUR" {
+		t.Errorf("expected country id 42 with prices in EUR, got %+v", filter)
+	}
+	if filter.MinPrice != nil || filter.MaxPrice != nil || filter.MinRating != nil {
+		t.Errorf("expected no bounds, got %+v", filter)
+	}
+
+	invalid := map[string]url.Values{
+		"missing q":         {},
+		"blank q":           {"q": {"   "}},
+		"long q":            {"q": {string(make([]byte, 201))}},
+		"bad min_price":     {"q": {"museum"}, "min_price": {"cheap"}},
+		"NaN max_price":     {"q": {"museum"}, "max_price": {"NaN"}},
+		"infinite price":    {"q": {"museum"}, "max_price": {"Inf"}},
+		"negative price":    {"q": {"museum"}, "min_price": {"-1"}},
+		"min above max":     {"q": {"museum"}, "min_price": {"50"}, "max_price": {"10"}},
+		"rating below 0":    {"q": {"museum"}, "min_rating": {"-0.5"}},
+		"rating above 5":    {"q": {"museum"}, "min_rating": {"5.5"}},
+		"bad rating":        {"q": {"museum"}, "min_rating": {"good"}},
+		"bad limit":         {"q": {"museum"}, "limit": {"ten"}},
+		"negative offset":   {"q": {"museum"}, "offset": {"-10"}},
+		"bad offset":        {"q": {"museum"}, "offset": {"1.5"}},
+		"bad category slug": {"q": {"museum"}, "category": {"Not A Slug!"}},
+	}
+	for name, q := range invalid {
+		if _, err := handlers.ParseActivitySearch(q, ""); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestActivityHandler_SearchRejectsBadParameters(t *testing.T) {
+	handler := handlers.NewActivityhandler(&repository.ActivityRepository{}, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, openAccess())
+
+	for _, query := range []string{"", "q=museum&min_price=x", "q=museum&min_rating=9", "q=museum&offset=-1"} {
+		rec := httptest.NewRecorder()
+		handler.SearchActivities(rec, httptest.NewRequest(http.MethodGet, "/activities/search?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+)
+
+// latestAudit returns the newest audit entry for an entity, failing the test when there is none
+func latestAudit(t *testing.T, audits *repository.AuditRepository, entity string, entityID int) models.AuditLog {
+	t.Helper()
+	logs, err := audits.GetAuditLogs(models.AuditFilter{Entity: entity, EntityID: entityID, Limit: 1})
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	if len(logs) == 0 {
+		t.Fatalf("expected an audit entry for %s %d", entity, entityID)
+	}
+	return logs[0]
+}
+
+func TestAudit_ItineraryUpdateRecordsDiff(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, "audited")
+	ctx := auth.WithActor(context.Background(), user.Email)
+	itineraryRepo := repository.NewItineraryRepository(db)
+	start := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	itinerary, err := itineraryRepo.CreateItinerary(ctx, user.ID, "Trip", "Beaches", start, start.AddDate(0, 0, 3), nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create itinerary: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Primary().Exec(`DELETE FROM itinerary WHERE id = $1`, itinerary.Id)
+	})
+
+	if _, err := itineraryRepo.ModifyItinerary(ctx, itinerary.Id, itinerary.Version, "Renamed", "Beaches", "2026-07-01", "2026-07-04", nil); err != nil {
+		t.Fatalf("failed to modify itinerary: %v", err)
+	}
+
+	entry := latestAudit(t, repository.NewAuditRepository(db.Primary()), "itinerary", itinerary.Id)
+	if entry.Action != repository.AuditUpdate || entry.Actor != user.Email {
+		t.Errorf("expected an update by %s, got %s by %s", user.Email, entry.Action, entry.Actor)
+	}
+	var before, after map[string]interface{}
+	json.Unmarshal(entry.Before, &before)
+	json.Unmarshal(entry.After, &after)
+	if before["title"] != "Trip" || after["title"] != "Renamed" {
+		t.Errorf("expected the snapshots to hold both titles, got %v and %v", before["title"], after["title"])
+	}
+	var diff map[string]map[string]interface{}
+	if err := json.Unmarshal(entry.Diff, &diff); err != nil {
+		t.Fatalf("failed to decode diff: %v", err)
+	}
+	if !reflect.DeepEqual(diff["title"], map[string]interface{}{"before": "Trip", "after": "Renamed"}) {
+		t.Errorf("expected the title change in the diff, got %v", diff["title"])
+	}
+	if _, ok := diff["version"]; !ok {
+		t.Error("expected the version bump in the diff")
+	}
+	if _, ok := diff["description"]; ok {
+		t.Errorf("expected unchanged fields to be left out of the diff, got %v", diff)
+	}
+}
+
+func TestAudit_HelpfulVote(t *testing.T) {
+	db := openTestDB(t)
+	author := createTestUser(t, db, "author")
+	voter := createTestUser(t, db, "voter")
+	activity := createTestActivity(t, db, "Museum")
+	reviews := repository.NewReviewRepository(db)
+	review := &models.Review{ActivityID: activity.ID, UserID: author.ID, Rating: 4, Body: "Worth it"}
+	if err := reviews.CreateReview(auth.WithActor(context.Background(), author.Email), review); err != nil {
+		t.Fatalf("failed to create review: %v", err)
+	}
+	audits := repository.NewAuditRepository(db.Primary())
+	ctx := auth.WithActor(context.Background(), voter.Email)
+
+	for _, helpful := range []bool{true, false} {
+		if _, err := reviews.SetHelpfulVote(ctx, review.ID, voter.ID, helpful); err != nil {
+			t.Fatalf("failed to vote: %v", err)
+		}
+		entry := latestAudit(t, audits, "review_vote", review.ID)
+		want, snapshot := repository.AuditCreate, entry.After
+		if !helpful {
+			want, snapshot = repository.AuditDelete, entry.Before
+		}
+		var vote map[string]float64
+		json.Unmarshal(snapshot, &vote)
+		if entry.Action != want || entry.Actor != voter.Email || int(vote["user_id"]) != voter.ID {
+			t.Errorf("expected a %s of %s's vote, got %s by %s: %s", want, voter.Email, entry.Action, entry.Actor, snapshot)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+func newTestLocalStore(t *testing.T) (*service.LocalStore, *httptest.Server) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	store, err := service.NewLocalStore(t.TempDir(), srv.URL, []byte("test-key"), time.Minute, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Handle(service.LocalBlobPath, store)
+	return store, srv
+}
+
+func TestLocalStore_PresignedUploadAndDownload(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	ctx := context.Background()
+
+	uploadURL, err := store.PresignUpload(ctx, "activity/1/photo.png", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodPut, uploadURL, strings.NewReader("not really a png"))
+	req.Header.Set("Content-Type", "image/png")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected upload to succeed, got %d", resp.StatusCode)
+	}
+
+	info, err := store.StatObject(ctx, "activity/1/photo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != 16 || info.ContentType != "image/png" {
+		t.Errorf("unexpected object info %+v", info)
+	}
+	objects, err := store.ListObjects(ctx, "activity/")
+	if err != nil || len(objects) != 1 || objects[0].Key != "activity/1/photo.png" {
+		t.Errorf("expected to list the uploaded object, got %+v (%v)", objects, err)
+	}
+
+	resp, err = http.Get(store.GetObjectURL(ctx, "activity/1/photo.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "not really a png" {
+		t.Errorf("expected to download the upload, got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestLocalStore_RejectsTamperedURLs(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	ctx := context.Background()
+	if err := store.WriteObject(ctx, "activity/1/a.jpg", []byte("a"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a download url must not work for another key or for uploads
+	u := store.GetObjectURL(ctx, "activity/1/a.jpg")
+	resp, err := http.Get(strings.Replace(u, "a.jpg", "b.jpg", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for another key, got %d", resp.StatusCode)
+	}
+	req, _ := http.NewRequest(http.MethodPut, u, strings.NewReader("overwrite"))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 when uploading with a download url, got %d", resp.StatusCode)
+	}
+
+	if _, err := store.PresignUpload(ctx, "../escape.jpg", time.Minute); err == nil {
+		t.Error("expected keys outside the root to be rejected")
+	}
+	if err := store.RemoveObject(ctx, "activity/1/a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.StatObject(ctx, "activity/1/a.jpg"); !errors.Is(err, service.ErrObjectNotFound) {
+		t.Errorf("expected ErrObjectNotFound after removal, got %v", err)
+	}
+}
+
+type fakeReferences struct {
+	unreferenced []models.Image
+	referenced   map[string]bool
+	discarded    []models.Image
+}
+
+func (f *fakeReferences) GetUnreferencedImages(ctx context.Context, pendingBefore time.Time) ([]models.Image, error) {
+	return f.unreferenced, nil
+}
+
+func (f *fakeReferences) DiscardImages(ctx context.Context, images []models.Image) error {
+	f.discarded = append(f.discarded, images...)
+	for _, image := range images {
+		delete(f.referenced, image.ObjectKey)
+	}
+	return nil
+}
+
+func (f *fakeReferences) GetReferencedObjectKeys(ctx context.Context) (map[string]bool, error) {
+	keys := make(map[string]bool)
+	for k := range f.referenced {
+		keys[k] = true
+	}
+	return keys, nil
+}
+
+func TestStorageGC_RemovesOldUnreferencedObjects(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	ctx := context.Background()
+	old := time.Now().Add(-48 * time.Hour)
+	for _, key := range []string{"activity/1/kept.jpg", "activity/1/orphan.jpg", "activity/1/abandoned.jpg", "activity/1/fresh.jpg", "itinerary/2/orphan.png", "backups/db.sql"} {
+		if err := store.WriteObject(ctx, key, []byte("x"), "image/jpeg"); err != nil {
+			t.Fatal(err)
+		}
+		if key != "activity/1/fresh.jpg" {
+			os.Chtimes(filepath.Join(store.Root, filepath.FromSlash(key)), old, old)
+		}
+	}
+	refs := &fakeReferences{
+		unreferenced: []models.Image{{ID: 7, ObjectKey: "activity/1/abandoned.jpg", Status: models.ImagePending}},
+		referenced:   map[string]bool{"activity/1/kept.jpg": true, "activity/1/abandoned.jpg": true},
+	}
+	gc := service.NewStorageGC(refs, store, 24*time.Hour, time.Hour, false)
+
+	report, err := gc.Run(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Removed) != 3 || len(refs.discarded) != 0 {
+		t.Fatalf("expected a dry run to report 3 objects and discard nothing, got %+v", report)
+	}
+	if _, err := store.StatObject(ctx, "activity/1/orphan.jpg"); err != nil {
+		t.Errorf("dry run removed an object: %v", err)
+	}
+
+	if report, err = gc.Run(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Removed) != 3 || len(report.DiscardedImages) != 1 || report.Referenced != 1 || report.Scanned != 5 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	for key, want := range map[string]bool{"activity/1/kept.jpg": true, "activity/1/orphan.jpg": false, "activity/1/abandoned.jpg": false, "activity/1/fresh.jpg": true, "itinerary/2/orphan.png": false, "backups/db.sql": true} {
+		_, err := store.StatObject(ctx, key)
+		if exists := err == nil; exists != want {
+			t.Errorf("%s: expected exists=%v", key, want)
+		}
+	}
+	if gc.LastReport() != report {
+		t.Error("expected the last report to be kept")
+	}
+}
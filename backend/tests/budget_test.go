@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+type memoryExpenses struct {
+	budget   models.ItineraryBudget
+	expenses []models.Expense
+	schedule models.ItinerarySchedule
+}
+
+func (m *memoryExpenses) GetBudget(ctx context.Context, itineraryID int) (models.ItineraryBudget, error) {
+	return m.budget, nil
+}
+
+func (m *memoryExpenses) GetExpensesByItinerary(ctx context.Context, itineraryID int) ([]models.Expense, error) {
+	return m.expenses, nil
+}
+
+func (m *memoryExpenses) GetItinerarySchedule(ctx context.Context, id int) (models.ItinerarySchedule, error) {
+	return m.schedule, nil
+}
+
+func TestBudgetService_Summary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"base": "USD", "date": "2026-01-02", "rates": {"EUR": 0.5}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	exchange := service.NewExchangeService(&service.FileRateProvider{Path: path}, &memoryRates{currencies: map[string]int{"USD": 2, "EUR": 2, "GBP": 2}})
+	if err := exchange.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	day1, day2 := "2026-07-06", "2026-07-07"
+	budget := int64(10000)
+	store := &memoryExpenses{
+		budget: models.ItineraryBudget{ItineraryID: 1, AmountMinor: &budget, Currency: "USD"},
+		schedule: models.ItinerarySchedule{Days: []models.ItineraryDay{
+			{Date: day1, Items: []models.ItineraryItem{
+				{Activity: &models.Activity{PriceMinor: 3000, Currency: "EUR"}},
+				// no rate for pounds
+				{Activity: &models.Activity{PriceMinor: 500, Currency: "GBP"}},
+			}},
+			{Date: day2, Items: []models.ItineraryItem{}},
+		}},
+		expenses: []models.Expense{
+			{Category: models.ExpenseActivities, AmountMinor: 2000, Currency: "USD", Date: day1},
+			{Category: models.ExpenseAccommodation, AmountMinor: 2500, Currency: "EUR", Date: "2026-07-01"},
+		},
+	}
+	summary, err := service.NewBudgetService(store, store, nil, exchange).Summary(context.Background(), 1, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 30.00 EUR estimated is 60.00 USD, the 25.00 EUR hotel is 50.00 USD
+	if summary.Currency != "USD" || summary.EstimatedMinor != 6000 || summary.ActualMinor != 7000 {
+		t.Errorf("unexpected totals %+v", summary)
+	}
+	// activities count the larger of 60 estimated and 20 spent, plus the hotel
+	if summary.PlannedMinor != 11000 {
+		t.Errorf("expected planned 11000, got %d", summary.PlannedMinor)
+	}
+	if len(summary.Days) != 3 || summary.Days[0].Key != "2026-07-01" || summary.Days[1].EstimatedMinor != 6000 || summary.Days[1].ActualMinor != 2000 {
+		t.Errorf("unexpected days %+v", summary.Days)
+	}
+	kinds := make(map[string]bool)
+	for _, a := range summary.Alerts {
+		kinds[a.Kind] = true
+	}
+	if len(summary.Alerts) != 2 || !kinds[models.BudgetAlertPlanned] || !kinds[models.BudgetAlertMissingRate] {
+		t.Errorf("expected planned and missing rate alerts, got %+v", summary.Alerts)
+	}
+}
+
+type memoryMembers []models.ItineraryMember
+
+func (m memoryMembers) GetMembers(ctx context.Context, itineraryID int) ([]models.ItineraryMember, error) {
+	return m, nil
+}
+
+func TestBudgetService_Balances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"base": "USD", "date": "2026-01-02", "rates": {"EUR": 0.5}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	exchange := service.NewExchangeService(&service.FileRateProvider{Path: path}, &memoryRates{currencies: map[string]int{"USD": 2, "EUR": 2, "GBP": 2}})
+	if err := exchange.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	members := memoryMembers{{UserID: 1, Username: "ann"}, {UserID: 2, Username: "bob"}, {UserID: 3, Username: "cat"}}
+	store := &memoryExpenses{expenses: []models.Expense{
+		// ann pays 100.00 USD for everyone
+		{AmountMinor: 10000, Currency: "USD", PaidBy: 1, Shares: []models.ExpenseShare{
+			{UserID: 1, AmountMinor: 3334}, {UserID: 2, AmountMinor: 3333}, {UserID: 3, AmountMinor: 3333},
+		}},
+		// bob pays 20.00 EUR, 40.00 USD, for himself and cat
+		{AmountMinor: 2000, Currency: "EUR", PaidBy: 2, Shares: []models.ExpenseShare{
+			{UserID: 2, AmountMinor: 1000}, {UserID: 3, AmountMinor: 1000},
+		}},
+		// no rate for pounds
+		{AmountMinor: 500, Currency: "GBP", PaidBy: 3, Shares: []models.ExpenseShare{{UserID: 1, AmountMinor: 500}}},
+	}}
+	balances, err := service.NewBudgetService(store, store, members, exchange).Balances(context.Background(), 1, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balances.Skipped != 1 {
+		t.Errorf("expected the pound expense to be skipped, got %d", balances.Skipped)
+	}
+	want := map[int]int64{1: 6666, 2: -1333, 3: -5333}
+	var sum int64
+	for _, b := range balances.Balances {
+		sum += b.BalanceMinor
+		if b.BalanceMinor != want[b.UserID] {
+			t.Errorf("expected %s to have %d, got %d", b.Username, want[b.UserID], b.BalanceMinor)
+		}
+	}
+	if sum != 0 {
+		t.Errorf("balances add up to %d", sum)
+	}
+	if len(balances.Settlements) != 2 {
+		t.Errorf("expected bob and cat to pay ann, got %+v", balances.Settlements)
+	}
+	for _, s := range balances.Settlements {
+		if s.ToUsername != "ann" || s.AmountMinor != -want[s.FromUserID] {
+			t.Errorf("unexpected settlement %+v", s)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	_ "time/tzdata"
+
+	"github.com/Joshua-Pok/FYP-backend/handlers"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+// memoryCountries is a country catalogue keyed by id, err fails every call
+type memoryCountries struct {
+	countries map[int]models.Country
+	err       error
+}
+
+func (m *memoryCountries) CreateCountry(ctx context.Context, country *models.Country) error {
+	return m.err
+}
+
+func (m *memoryCountries) GetCountryById(ctx context.Context, id int) (*models.Country, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	country, ok := m.countries[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &country, nil
+}
+
+func (m *memoryCountries) GetCountryByCode(ctx context.Context, isoCode string) (*models.Country, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	for _, country := range m.countries {
+		if country.ISOCode == isoCode {
+			return &country, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *memoryCountries) GetAllCountries(ctx context.Context) ([]models.Country, error) {
+	return nil, m.err
+}
+
+func (m *memoryCountries) UpdateCountry(ctx context.Context, country *models.Country) error {
+	return m.err
+}
+
+func (m *memoryCountries) DeleteCountry(ctx context.Context, id int) error {
+	return m.err
+}
+
+func TestValidateCountry(t *testing.T) {
+	country := models.Country{Name: " France ", ISOCode: "fr", Currency: "eur", Timezone: "Europe/Paris"}
+	if err := service.ValidateCountry(&country); err != nil {
+		t.Fatalf("expected a valid country, got %v", err)
+	}
+	if country.Name != "France" || country.ISOCode != "FR" || country.Currency != "EUR" || country.Languages == nil {
+		t.Errorf("expected normalised fields, got %+v", country)
+	}
+
+	invalid := map[string]models.Country{
+		"missing name":     {ISOCode: "FR"},
+		"alpha-3 code":     {Name: "France", ISOCode: "FRA"},
+		"numeric code":     {Name: "France", ISOCode: "25"},
+		"short currency":   {Name: "France", ISOCode: "FR", Currency: "EU"},
+		"numeric currency": {Name: "France", ISOCode: "FR", Currency: "978"},
+		"unknown timezone": {Name: "France", ISOCode: "FR", Timezone: "Europe/Atlantis"},
+	}
+	for name, country := range invalid {
+		if err := service.ValidateCountry(&country); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParseCountriesCSV(t *testing.T) {
+	const header = "iso_code,name,currency,timezone,languages\n"
+	countries, err := service.ParseCountriesCSV(strings.NewReader(header +
+		"fr,France,eur,Europe/Paris,fr\n" +
+		"CH,Switzerland,CHF,Europe/Zurich,de|fr|it|rm\n" +
+		"AQ,Antarctica,,,\n"))
+	if err != nil {
+		t.Fatalf("expected the rows to parse, got %v", err)
+	}
+	if len(countries) != 3 || countries[0].ISOCode != "FR" || len(countries[1].Languages) != 4 || len(countries[2].Languages) != 0 {
+		t.Errorf("unexpected countries %+v", countries)
+	}
+
+	bad := map[string]string{
+		"wrong header":       "code,name,currency,timezone,languages\nFR,France,EUR,Europe/Paris,fr\n",
+		"missing column":     header + "FR,France,EUR,Europe/Paris\n",
+		"bad iso code":       header + "FRA,France,EUR,Europe/Paris,fr\n",
+		"bad currency":       header + "FR,France,EURO,Europe/Paris,fr\n",
+		"bad timezone":       header + "FR,France,EUR,Paris,fr\n",
+		"missing name":       header + "FR,,EUR,Europe/Paris,fr\n",
+		"duplicate iso code": header + "FR,France,EUR,Europe/Paris,fr\nfr,France again,EUR,Europe/Paris,fr\n",
+		"empty file":         "",
+	}
+	for name, input := range bad {
+		if _, err := service.ParseCountriesCSV(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	_, err = service.ParseCountriesCSV(strings.NewReader(header + "FR,France,EUR,Europe/Paris,fr\nDE,Germany,EURO,Europe/Berlin,de\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("expected the error to name line 3, got %v", err)
+	}
+}
+
+func TestParseCountriesCSV_SeedFile(t *testing.T) {
+	f, err := os.Open("../cmd/seedcountries/countries.csv")
+	if err != nil {
+		t.Fatalf("failed to open seed file: %v", err)
+	}
+	defer f.Close()
+	countries, err := service.ParseCountriesCSV(f)
+	if err != nil {
+		t.Fatalf("expected the seed file to be valid, got %v", err)
+	}
+	if len(countries) < 200 {
+		t.Errorf("expected the full ISO-3166 list, got %d countries", len(countries))
+	}
+}
+
+func TestCountryHandler_LookupCountry(t *testing.T) {
+	repo := &memoryCountries{countries: map[int]models.Country{1: {ID: 1, Name: "France", ISOCode: "FR"}}}
+	handler := handlers.NewCountryHandler(repo, nil, nil, nil)
+
+	cases := map[string]int{
+		"/countries?id=1":    http.StatusOK,
+		"/countries?code=fr": http.StatusOK,
+		"/countries?id=2":    http.StatusNotFound,
+		"/countries?code=DE": http.StatusNotFound,
+		"/countries?id=abc":  http.StatusBadRequest,
+		"/countries?id=":     http.StatusBadRequest,
+	}
+	for target, want := range cases {
+		rec := httptest.NewRecorder()
+		handler.GetCountries(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected %d, got %d", target, want, rec.Code)
+		}
+		if want == http.StatusOK && !strings.Contains(rec.Body.String(), `"iso_code":"FR"`) {
+			t.Errorf("%s: expected France, got %s", target, rec.Body)
+		}
+	}
+
+	repo.err = errors.New("connection refused")
+	rec := httptest.NewRecorder()
+	handler.GetCountries(rec, httptest.NewRequest(http.MethodGet, "/countries?code=FR", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 when the lookup fails, got %d", rec.Code)
+	}
+}
+
+func TestCountryHandler_CreateRejectsInvalid(t *testing.T) {
+	handler := handlers.NewCountryHandler(&memoryCountries{}, nil, nil, nil)
+	for _, body := range []string{`{"name": "France", "iso_code": "FRA"}`, `{"iso_code": "FR"}`, `{"name": "France", "iso_code": "FR", "currency": "euro"}`, `not json`} {
+		rec := httptest.NewRecorder()
+		handler.CreateCountry(rec, httptest.NewRequest(http.MethodPost, "/countries", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	_ "github.com/lib/pq"
+)
+
+// openTestDB connects to TEST_DATABASE_URL, a database with init.sql and every migration applied.
+// Tests that need postgres are skipped when it is not set.
+func openTestDB(t *testing.T) *database.Router {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatalf("failed to reach db: %v", err)
+	}
+	router := database.NewRouter(db, nil, 0)
+	t.Cleanup(func() { router.Close() })
+	return router
+}
+
+// createTestUser adds a user with a unique name, removed again when the test ends if nothing still
+// points at it
+func createTestUser(t *testing.T, db *database.Router, name string) *models.User {
+	t.Helper()
+	unique := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	user := &models.User{UserName: unique, Name: name, Email: unique + "@example.com", Password: "x"}
+	if err := repository.NewUserRepository(db.Primary()).CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Primary().Exec(`DELETE FROM users WHERE id = $1`, user.ID)
+	})
+	return user
+}
+
+// createTestActivity adds an activity with a unique title, removed again when the test ends
+func createTestActivity(t *testing.T, db *database.Router, title string) models.Activity {
+	t.Helper()
+	unique := fmt.Sprintf("%s-%d", title, time.Now().UnixNano())
+	activity, err := repository.NewActivityRepository(db).CreateActivity(context.Background(), models.Activity{Title: unique, Name: title, Currency: "USD"})
+	if err != nil {
+		t.Fatalf("failed to create activity: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Primary().Exec(`DELETE FROM activity WHERE id = $1`, activity.ID)
+	})
+	return activity
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/schedule"
+)
+
+func scheduledItem(id int, lat, lng float64, start, end string) models.ItineraryItem {
+	return models.ItineraryItem{
+		ID:         id,
+		ActivityID: id,
+		StartTime:  start,
+		EndTime:    end,
+		Activity:   &models.Activity{ID: id, Title: "stop", Latitude: &lat, Longitude: &lng},
+	}
+}
+
+func TestSchedule_CheckFeasibility(t *testing.T) {
+	day := "2026-07-06" // a monday
+	s := models.ItinerarySchedule{Days: []models.ItineraryDay{{Date: day, Items: []models.ItineraryItem{
+		scheduledItem(1, 1.2868, 103.8545, "09:00", "11:00"),
+		// starts before the first visit ends
+		scheduledItem(2, 1.2868, 103.8545, "10:30", "12:00"),
+		// roughly 14km away with five minutes to get there
+		scheduledItem(3, 1.3521, 103.9700, "12:05", "13:00"),
+		// the museum closes at 17:00
+		scheduledItem(4, 1.3521, 103.9700, "16:00", "18:00"),
+	}}}}
+	hours := map[int]models.OpeningHours{
+		4: {Timezone: "Asia/Singapore", Weekly: []models.WeeklyHours{{Weekday: 1, Opens: "09:00", Closes: "17:00"}}},
+	}
+
+	warnings := schedule.Check(s, hours, schedule.Limits{MaxItemsPerDay: 3, MaxHoursPerDay: 10 * time.Hour, TravelSpeedKmh: 25})
+	kinds := make(map[string][]int)
+	for _, w := range warnings {
+		if w.Day != day {
+			t.Errorf("expected warnings for %s, got %s", day, w.Day)
+		}
+		kinds[w.Kind] = w.ItemIDs
+	}
+	expected := map[string][]int{
+		models.WarningOverlap:       {1, 2},
+		models.WarningTightGap:      {2, 3},
+		models.WarningOutsideHours:  {4},
+		models.WarningDayOverloaded: {1, 2, 3, 4},
+	}
+	if len(warnings) != len(expected) {
+		t.Fatalf("expected %d warnings, got %+v", len(expected), warnings)
+	}
+	for kind, ids := range expected {
+		got, ok := kinds[kind]
+		if !ok || len(got) != len(ids) {
+			t.Errorf("%s: expected items %v, got %v", kind, ids, got)
+			continue
+		}
+		for i := range ids {
+			if got[i] != ids[i] {
+				t.Errorf("%s: expected items %v, got %v", kind, ids, got)
+				break
+			}
+		}
+	}
+
+	// a relaxed day with time to travel has nothing to report
+	relaxed := models.ItinerarySchedule{Days: []models.ItineraryDay{{Date: day, Items: []models.ItineraryItem{
+		scheduledItem(1, 1.2868, 103.8545, "09:00", "11:00"),
+		scheduledItem(3, 1.3521, 103.9700, "12:30", "14:00"),
+	}}}}
+	if warnings := schedule.Check(relaxed, hours, schedule.Limits{MaxItemsPerDay: 3, TravelSpeedKmh: 25}); len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %+v", warnings)
+	}
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/geo"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+func TestDistanceMeters(t *testing.T) {
+	paris := geo.Point{Lat: 48.8566, Lng: 2.3522}
+	london := geo.Point{Lat: 51.5074, Lng: -0.1278}
+
+	got := geo.DistanceMeters(paris, london)
+	if math.Abs(got-343500) > 2000 {
+		t.Errorf("expected about 343.5km between Paris and London, got %.0fm", got)
+	}
+	if d := geo.DistanceMeters(paris, paris); d != 0 {
+		t.Errorf("expected zero distance to self, got %f", d)
+	}
+}
+
+func TestBoundingBox_ContainsRadius(t *testing.T) {
+	center := geo.Point{Lat: 1.2834, Lng: 103.8607}
+	min, max := geo.BoundingBox(center, 5000)
+
+	// points 5km due north and due east must fall inside the box
+	north := geo.Point{Lat: center.Lat + 5000/geo.EarthRadiusMeters*180/math.Pi, Lng: center.Lng}
+	if north.Lat > max.Lat {
+		t.Errorf("box max lat %f excludes point %f", max.Lat, north.Lat)
+	}
+	if d := geo.DistanceMeters(center, geo.Point{Lat: center.Lat, Lng: max.Lng}); d < 4999 {
+		t.Errorf("box only reaches %.0fm east", d)
+	}
+	if min.Lat >= center.Lat || min.Lng >= center.Lng {
+		t.Errorf("box min %+v not below center %+v", min, center)
+	}
+}
+
+func TestBoundingBox_WrapsAtAntimeridian(t *testing.T) {
+	// Taveuni in Fiji sits right on the 180th meridian
+	center := geo.Point{Lat: -16.8, Lng: 179.99}
+	min, max := geo.BoundingBox(center, 5000)
+	if min.Lng <= max.Lng {
+		t.Fatalf("expected a wrapped box, got min %f max %f", min.Lng, max.Lng)
+	}
+	if min.Lng >= center.Lng || max.Lng < -180 || max.Lng > -179.9 {
+		t.Errorf("expected the box to cover both sides of the antimeridian, got min %f max %f", min.Lng, max.Lng)
+	}
+	farSide := geo.Point{Lat: -16.8, Lng: -179.99}
+	if d := geo.DistanceMeters(center, farSide); d > 5000 || farSide.Lng > max.Lng {
+		t.Errorf("expected the point %.0fm away across the antimeridian inside the box", d)
+	}
+
+	min, max = geo.BoundingBox(geo.Point{Lat: 10, Lng: -179.99}, 5000)
+	if min.Lng <= max.Lng || min.Lng < 179.9 {
+		t.Errorf("expected a box wrapping westwards, got min %f max %f", min.Lng, max.Lng)
+	}
+}
+
+func TestActivityRepository_NearbyAcrossAntimeridian(t *testing.T) {
+	db := openTestDB(t)
+	lat, lng := -16.8, -179.99
+	activity, err := repository.NewActivityRepository(db).CreateActivity(context.Background(), models.Activity{
+		Title: fmt.Sprintf("Taveuni-%d", time.Now().UnixNano()), Name: "Taveuni", Currency: "USD", Latitude: &lat, Longitude: &lng,
+	})
+	if err != nil {
+		t.Fatalf("failed to create activity: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Primary().Exec(`DELETE FROM activity WHERE id = $1`, activity.ID)
+	})
+
+	nearby, err := repository.NewActivityRepository(db).GetActivitiesNearby(context.Background(), geo.Point{Lat: -16.8, Lng: 179.99}, 5000, 50, models.ActivityTaxonomyFilter{})
+	if err != nil {
+		t.Fatalf("failed to search nearby: %v", err)
+	}
+	for _, n := range nearby {
+		if n.Activity.ID == activity.ID {
+			return
+		}
+	}
+	t.Errorf("expected the activity across the antimeridian in %+v", nearby)
+}
+
+func TestGazetteerGeocoder(t *testing.T) {
+	geocoder := service.NewGazetteerGeocoder([]service.GazetteerEntry{
+		{Name: "Paris", CountryCode: "FR", Point: geo.Point{Lat: 48.8566, Lng: 2.3522}},
+		{Name: "Paris", CountryCode: "US", Point: geo.Point{Lat: 33.6609, Lng: -95.5555}},
+		{Name: "Marina Bay", CountryCode: "SG", Point: geo.Point{Lat: 1.2834, Lng: 103.8607}},
+		{Name: "Bay", CountryCode: "SG", Point: geo.Point{Lat: 0, Lng: 0}},
+	})
+
+	point, err := geocoder.Geocode(context.Background(), "Champ de Mars, 5 Av. Anatole France, Paris", "fr")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if point.Lat != 48.8566 {
+		t.Errorf("expected the French Paris, got %+v", point)
+	}
+
+	point, err = geocoder.Geocode(context.Background(), "10 Bayfront Ave, Marina Bay", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if point.Lat != 1.2834 {
+		t.Errorf("expected the longest match Marina Bay, got %+v", point)
+	}
+
+	if _, err := geocoder.Geocode(context.Background(), "Parisian Cafe", ""); !errors.Is(err, service.ErrAddressNotFound) {
+		t.Errorf("expected ErrAddressNotFound for a partial word, got %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/handlers"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+// memoryImages keeps image records by id
+type memoryImages struct {
+	images    map[int]*models.Image
+	discarded []int
+}
+
+func (m *memoryImages) CreatePendingImage(ctx context.Context, image *models.Image) error {
+	image.ID = len(m.images) + 1
+	image.Status = models.ImagePending
+	m.images[image.ID] = image
+	return nil
+}
+
+func (m *memoryImages) GetImageById(ctx context.Context, id int) (*models.Image, error) {
+	image, ok := m.images[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *image
+	return &copied, nil
+}
+
+func (m *memoryImages) GetImagesByOwner(ctx context.Context, ownerType string, ownerID int) ([]models.Image, error) {
+	return nil, nil
+}
+
+func (m *memoryImages) ConfirmImage(ctx context.Context, image *models.Image) error {
+	image.Status = models.ImageReady
+	m.images[image.ID].Status = models.ImageReady
+	return nil
+}
+
+func (m *memoryImages) DeleteImage(ctx context.Context, id int) (models.Image, error) {
+	image, ok := m.images[id]
+	if !ok {
+		return models.Image{}, sql.ErrNoRows
+	}
+	delete(m.images, id)
+	return *image, nil
+}
+
+func (m *memoryImages) DiscardPendingImage(ctx context.Context, id int) error {
+	m.discarded = append(m.discarded, id)
+	delete(m.images, id)
+	return nil
+}
+
+const (
+	uploader   = "joshua@example.com"
+	imageAdmin = "admin@example.com"
+)
+
+var (
+	pngBytes  = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01")
+	jpegBytes = []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")
+)
+
+func newTestImageHandler(t *testing.T) (*handlers.ImageHandler, *memoryImages, *service.LocalStore) {
+	store, _ := newTestLocalStore(t)
+	images := &memoryImages{images: make(map[int]*models.Image)}
+	worker := service.NewImageWorker(nil, store)
+	// the uploader curates activities too, so the upload tests can add activity images
+	return handlers.NewImageHandler(images, store, worker, time.Minute, 1024, openAccess(), []string{uploader, imageAdmin}), images, store
+}
+
+func asUploader(req *http.Request) *http.Request {
+	return req.WithContext(auth.WithActor(req.Context(), uploader))
+}
+
+func TestImageHandler_RequestUploadRejects(t *testing.T) {
+	handler, images, _ := newTestImageHandler(t)
+
+	cases := map[string]struct {
+		body string
+		want int
+	}{
+		"owner type":   {`{"owner_type": "user", "owner_id": 1, "content_type": "image/png", "size": 100}`, http.StatusBadRequest},
+		"content type": {`{"owner_type": "activity", "owner_id": 1, "content_type": "image/svg+xml", "size": 100}`, http.StatusUnsupportedMediaType},
+		"pdf":          {`{"owner_type": "activity", "owner_id": 1, "content_type": "application/pdf", "size": 100}`, http.StatusUnsupportedMediaType},
+		"oversized":    {`{"owner_type": "activity", "owner_id": 1, "content_type": "image/png", "size": 1025}`, http.StatusRequestEntityTooLarge},
+		"empty":        {`{"owner_type": "activity", "owner_id": 1, "content_type": "image/png", "size": 0}`, http.StatusRequestEntityTooLarge},
+		"bad json":     {`{"owner_type":`, http.StatusBadRequest},
+	}
+	for name, tc := range cases {
+		rec := httptest.NewRecorder()
+		handler.RequestUpload(rec, asUploader(httptest.NewRequest(http.MethodPost, "/images/uploads", strings.NewReader(tc.body))))
+		if rec.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", name, tc.want, rec.Code)
+		}
+	}
+	if len(images.images) != 0 {
+		t.Errorf("expected no pending images, got %d", len(images.images))
+	}
+
+	rec := httptest.NewRecorder()
+	handler.RequestUpload(rec, asUploader(httptest.NewRequest(http.MethodPost, "/images/uploads",
+		strings.NewReader(`{"owner_type": "activity", "owner_id": 1, "content_type": " IMAGE/PNG ", "size": 1024}`))))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for a valid upload, got %d: %s", rec.Code, rec.Body)
+	}
+	if image := images.images[1]; image == nil || image.ContentType != "image/png" || !strings.HasSuffix(image.ObjectKey, ".png") || image.UploadedBy != uploader {
+		t.Errorf("unexpected pending image %+v", images.images[1])
+	}
+}
+
+func TestImageHandler_ConfirmUploadVerifiesFile(t *testing.T) {
+	cases := map[string]struct {
+		key      string
+		declared int64
+		data     []byte
+		want     int
+	}{
+		"matching upload":  {"activity/1/a.png", int64(len(pngBytes)), pngBytes, http.StatusOK},
+		"size mismatch":    {"activity/1/a.png", int64(len(pngBytes)) + 1, pngBytes, http.StatusUnprocessableEntity},
+		"sniffed as jpeg":  {"activity/1/a.png", int64(len(jpegBytes)), jpegBytes, http.StatusUnprocessableEntity},
+		"stored as a gif":  {"activity/1/a.gif", int64(len(pngBytes)), pngBytes, http.StatusUnprocessableEntity},
+		"not uploaded yet": {"activity/1/a.png", int64(len(pngBytes)), nil, http.StatusConflict},
+	}
+	for name, tc := range cases {
+		handler, images, store := newTestImageHandler(t)
+		images.images[1] = &models.Image{ID: 1, ObjectKey: tc.key, OwnerType: models.ImageOwnerActivity, OwnerID: 1,
+			ContentType: "image/png", SizeBytes: tc.declared, UploadedBy: uploader, Status: models.ImagePending}
+		if tc.data != nil {
+			if err := store.WriteObject(context.Background(), tc.key, tc.data, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ConfirmUpload(rec, asUploader(httptest.NewRequest(http.MethodPost, "/images/confirm?image_id=1", nil)))
+		if rec.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d: %s", name, tc.want, rec.Code, rec.Body)
+		}
+		if tc.want != http.StatusUnprocessableEntity {
+			continue
+		}
+		// a rejected file is removed and the upload has to start over
+		if _, err := store.StatObject(context.Background(), tc.key); err != service.ErrObjectNotFound {
+			t.Errorf("%s: expected the rejected object to be removed, got %v", name, err)
+		}
+		if len(images.discarded) != 1 {
+			t.Errorf("%s: expected the pending image to be discarded", name)
+		}
+	}
+}
+
+func TestImageHandler_ConfirmUploadChecksUploader(t *testing.T) {
+	handler, images, store := newTestImageHandler(t)
+	images.images[1] = &models.Image{ID: 1, ObjectKey: "activity/1/a.png", OwnerType: models.ImageOwnerActivity, OwnerID: 1,
+		ContentType: "image/png", SizeBytes: int64(len(pngBytes)), UploadedBy: uploader, Status: models.ImagePending}
+	if err := store.WriteObject(context.Background(), "activity/1/a.png", pngBytes, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/images/confirm?image_id=1", nil)
+	rec := httptest.NewRecorder()
+	handler.ConfirmUpload(rec, req.WithContext(auth.WithActor(req.Context(), "someone@example.com")))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for someone else, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ConfirmUpload(rec, asUploader(httptest.NewRequest(http.MethodPost, "/images/confirm?image_id=1", nil)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for the uploader, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handler.ConfirmUpload(rec, asUploader(httptest.NewRequest(http.MethodPost, "/images/confirm?image_id=1", nil)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 confirming twice, got %d", rec.Code)
+	}
+}
+
+func TestImageHandler_ActivityImagesNeedAnAdmin(t *testing.T) {
+	handler, images, _ := newTestImageHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/images/uploads",
+		strings.NewReader(`{"owner_type": "activity", "owner_id": 1, "content_type": "image/png", "size": 100}`))
+	rec := httptest.NewRecorder()
+	handler.RequestUpload(rec, req.WithContext(auth.WithActor(req.Context(), "someone@example.com")))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a non admin adding an activity image, got %d", rec.Code)
+	}
+	if len(images.images) != 0 {
+		t.Errorf("expected no pending images, got %d", len(images.images))
+	}
+}
+
+func TestImageHandler_DeleteImageByUploaderOrAdmin(t *testing.T) {
+	handler, images, store := newTestImageHandler(t)
+	for id := 1; id <= 2; id++ {
+		key := "itinerary/1/" + strconv.Itoa(id) + ".png"
+		images.images[id] = &models.Image{ID: id, ObjectKey: key, OwnerType: models.ImageOwnerItinerary, OwnerID: 1,
+			ContentType: "image/png", UploadedBy: "traveller@example.com", Status: models.ImageReady}
+		if err := store.WriteObject(context.Background(), key, pngBytes, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deleteAs := func(actor string, id int) int {
+		req := httptest.NewRequest(http.MethodDelete, "/images?image_id="+strconv.Itoa(id), nil)
+		rec := httptest.NewRecorder()
+		handler.DeleteImage(rec, req.WithContext(auth.WithActor(req.Context(), actor)))
+		return rec.Code
+	}
+
+	if code := deleteAs("someone@example.com", 1); code != http.StatusForbidden {
+		t.Errorf("expected 403 for someone else, got %d", code)
+	}
+	if code := deleteAs("traveller@example.com", 1); code != http.StatusOK {
+		t.Errorf("expected the uploader to delete their image, got %d", code)
+	}
+	if code := deleteAs(imageAdmin, 2); code != http.StatusOK {
+		t.Errorf("expected an admin to delete any image, got %d", code)
+	}
+	for id := 1; id <= 2; id++ {
+		if _, err := store.StatObject(context.Background(), "itinerary/1/"+strconv.Itoa(id)+".png"); err != service.ErrObjectNotFound {
+			t.Errorf("expected image %d's object removed, got %v", id, err)
+		}
+	}
+	if len(images.images) != 0 {
+		t.Errorf("expected both images deleted, got %d left", len(images.images))
+	}
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/Joshua-Pok/FYP-backend/imaging"
+)
+
+// exifSegment builds an APP1 segment holding a big endian TIFF with just an orientation tag and a GPS pointer
+func exifSegment(orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(2))
+	// orientation, SHORT, count 1
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	// GPS IFD pointer, LONG, count 1
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x8825, 4})
+	binary.Write(&tiff, binary.BigEndian, []uint32{1, 0})
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestStripMetadata_RemovesExifAndReportsOrientation(t *testing.T) {
+	plain := testJPEG(t, 40, 20)
+	// insert the EXIF segment right after SOI
+	withExif := append(append([]byte{}, plain[:2]...), exifSegment(6)...)
+	withExif = append(withExif, plain[2:]...)
+
+	stripped, orientation, err := imaging.StripMetadata(withExif, "image/jpeg")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if orientation != 6 {
+		t.Errorf("expected orientation 6, got %d", orientation)
+	}
+	if bytes.Contains(stripped, []byte("Exif\x00\x00")) {
+		t.Error("expected the EXIF block to be removed")
+	}
+	if _, err := imaging.Decode(stripped); err != nil {
+		t.Errorf("stripped JPEG no longer decodes: %v", err)
+	}
+
+	img, _ := imaging.Decode(stripped)
+	// orientation 6 is a quarter turn, width and height swap
+	if b := imaging.Orient(img, orientation).Bounds(); b.Dx() != 20 || b.Dy() != 40 {
+		t.Errorf("expected a 20x40 image after orienting, got %dx%d", b.Dx(), b.Dy())
+	}
+}
+
+func TestRender_SkipsUpscaling(t *testing.T) {
+	img, err := imaging.Decode(testJPEG(t, 1000, 500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered, err := imaging.Render(img, imaging.DefaultVariants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only thumbnail (320) and medium (800) are narrower than the original
+	if len(rendered) != 2 {
+		t.Fatalf("expected 2 variants, got %d", len(rendered))
+	}
+	if r := rendered[0]; r.Name != "thumbnail" || r.Width != 320 || r.Height != 160 || r.ContentType != "image/jpeg" {
+		t.Errorf("unexpected thumbnail %s %dx%d %s", r.Name, r.Width, r.Height, r.ContentType)
+	}
+}
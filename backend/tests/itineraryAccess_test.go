@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/handlers"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+// memoryRoles maps email to role on every itinerary
+type memoryRoles map[string]string
+
+func (m memoryRoles) GetMemberByEmail(ctx context.Context, itineraryID int, email string) (models.ItineraryMember, error) {
+	role, ok := m[email]
+	if !ok {
+		return models.ItineraryMember{}, sql.ErrNoRows
+	}
+	return models.ItineraryMember{ItineraryID: itineraryID, Role: role}, nil
+}
+
+// everyoneOwns makes every signed in user the owner of every itinerary
+type everyoneOwns struct{}
+
+func (everyoneOwns) GetMemberByEmail(ctx context.Context, itineraryID int, email string) (models.ItineraryMember, error) {
+	return models.ItineraryMember{ItineraryID: itineraryID, Role: models.RoleOwner}, nil
+}
+
+// openAccess is an access checker for tests that are not about access, signed in users may do anything
+func openAccess() *service.ItineraryAccess {
+	return service.NewItineraryAccess(everyoneOwns{})
+}
+
+func TestItineraryAccess_Require(t *testing.T) {
+	access := service.NewItineraryAccess(memoryRoles{
+		"owner@example.com":  models.RoleOwner,
+		"editor@example.com": models.RoleEditor,
+		"viewer@example.com": models.RoleViewer,
+	})
+
+	tests := []struct {
+		actor string
+		need  string
+		want  error
+	}{
+		{"", models.RoleViewer, service.ErrSignInRequired},
+		{"stranger@example.com", models.RoleViewer, sql.ErrNoRows},
+		{"viewer@example.com", models.RoleViewer, nil},
+		{"viewer@example.com", models.RoleEditor, service.ErrForbidden},
+		{"editor@example.com", models.RoleEditor, nil},
+		{"editor@example.com", models.RoleOwner, service.ErrForbidden},
+		{"owner@example.com", models.RoleOwner, nil},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.actor != "" {
+			ctx = auth.WithActor(ctx, tt.actor)
+		}
+		if _, err := access.Require(ctx, 1, tt.need); !errors.Is(err, tt.want) {
+			t.Errorf("%q needing %s: expected %v, got %v", tt.actor, tt.need, tt.want, err)
+		}
+	}
+}
+
+func TestItineraryHandler_ChecksRole(t *testing.T) {
+	access := service.NewItineraryAccess(memoryRoles{"viewer@example.com": models.RoleViewer})
+	handler := handlers.NewItineraryHandler(&repository.ItineraryRepository{}, nil, nil, access, nil, nil)
+
+	cases := map[string]int{
+		"":                     http.StatusUnauthorized,
+		"stranger@example.com": http.StatusNotFound,
+		"viewer@example.com":   http.StatusForbidden,
+	}
+	for actor, want := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/itinerary/items?itinerary_id=1", strings.NewReader(`{"activity_id": 1}`))
+		if actor != "" {
+			req = req.WithContext(auth.WithActor(req.Context(), actor))
+		}
+		rec := httptest.NewRecorder()
+		handler.AddItineraryItem(rec, req)
+		if rec.Code != want {
+			t.Errorf("%q: expected %d, got %d", actor, want, rec.Code)
+		}
+	}
+}
+
+func TestItineraryHandler_RequiresAccessChecker(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected building a handler without an access checker to panic")
+		}
+	}()
+	handlers.NewItineraryHandler(&repository.ItineraryRepository{}, nil, nil, nil, nil, nil)
+}
@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/handlers"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+// nextEvent waits briefly for an event so a missing one fails instead of hanging
+func nextEvent(t *testing.T, events <-chan models.ItineraryEvent) models.ItineraryEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for an event")
+		return models.ItineraryEvent{}
+	}
+}
+
+func TestItineraryHub_BroadcastsAndTracksPresence(t *testing.T) {
+	hub := service.NewItineraryHub(nil)
+
+	ann, leaveAnn, err := hub.Join(1, models.ItineraryMember{UserID: 1, Username: "ann"})
+	if err != nil {
+		t.Fatalf("join failed: %v", err)
+	}
+	if event := nextEvent(t, ann); event.Type != models.EventPresence || len(event.Viewers) != 1 {
+		t.Fatalf("expected ann alone in presence, got %+v", event)
+	}
+	bob, leaveBob, err := hub.Join(1, models.ItineraryMember{UserID: 2, Username: "bob"})
+	if err != nil {
+		t.Fatalf("join failed: %v", err)
+	}
+	other, leaveOther, err := hub.Join(2, models.ItineraryMember{UserID: 3, Username: "cat"})
+	if err != nil {
+		t.Fatalf("join failed: %v", err)
+	}
+	defer leaveOther()
+	nextEvent(t, other)
+
+	event := nextEvent(t, ann)
+	if event.Type != models.EventPresence || len(event.Viewers) != 2 || event.Viewers[0].Username != "ann" || event.Viewers[1].Username != "bob" {
+		t.Fatalf("expected ann and bob in presence, got %+v", event)
+	}
+	nextEvent(t, bob)
+
+	hub.Publish(context.Background(), models.ItineraryEvent{Type: models.EventItemRemoved, ItineraryID: 1, ItemID: 7, Username: "ann"})
+	for _, events := range []<-chan models.ItineraryEvent{ann, bob} {
+		if event := nextEvent(t, events); event.Type != models.EventItemRemoved || event.ItemID != 7 || event.At.IsZero() {
+			t.Errorf("expected item_removed for item 7, got %+v", event)
+		}
+	}
+	select {
+	case event := <-other:
+		t.Errorf("another itinerary got %+v", event)
+	default:
+	}
+
+	leaveBob()
+	leaveBob()
+	if event := nextEvent(t, ann); event.Type != models.EventPresence || len(event.Viewers) != 1 || event.Viewers[0].Username != "ann" {
+		t.Errorf("expected bob to have left, got %+v", event)
+	}
+	if _, ok := <-bob; ok {
+		t.Error("expected bob's events to be closed")
+	}
+	leaveAnn()
+	if viewers := hub.Viewers(1); len(viewers) != 0 {
+		t.Errorf("expected nobody viewing, got %+v", viewers)
+	}
+}
+
+func TestItineraryHub_DropsSlowSubscriber(t *testing.T) {
+	hub := service.NewItineraryHub(nil)
+	events, leave, err := hub.Join(1, models.ItineraryMember{UserID: 1, Username: "ann"})
+	if err != nil {
+		t.Fatalf("join failed: %v", err)
+	}
+	defer leave()
+
+	for i := 0; i < 100; i++ {
+		hub.Publish(context.Background(), models.ItineraryEvent{Type: models.EventItemUpdated, ItineraryID: 1})
+	}
+	received := 0
+	for range events {
+		received++
+	}
+	if received == 0 || received >= 100 {
+		t.Errorf("expected the buffered events then a closed channel, got %d events", received)
+	}
+}
+
+func TestItineraryEventsHandler_Streams(t *testing.T) {
+	hub := service.NewItineraryHub(nil)
+	handler := handlers.NewItineraryEventsHandler(hub, openAccess(), time.Minute)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.StreamEvents(w, r.WithContext(auth.WithActor(r.Context(), "viewer@example.com")))
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/itinerary/events?itinerary_id=1")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", ct)
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	waitFor := func(prefix string) string {
+		t.Helper()
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					t.Fatalf("stream ended waiting for %q", prefix)
+				}
+				if strings.HasPrefix(line, prefix) {
+					return line
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("timed out waiting for %q", prefix)
+			}
+		}
+	}
+
+	waitFor("event: presence")
+	hub.Publish(context.Background(), models.ItineraryEvent{Type: models.EventItemAdded, ItineraryID: 1, Item: &models.ItineraryItem{ID: 5}})
+	waitFor("event: item_added")
+	if data := waitFor("data: "); !strings.Contains(data, `"type":"item_added"`) {
+		t.Errorf("unexpected event data %s", data)
+	}
+}
@@ -0,0 +1,366 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/handlers"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+// memoryItinerary is a single itinerary whose items are placed the way ItineraryRepository places them
+type memoryItinerary struct {
+	id         int
+	start, end time.Time
+	activities map[int]bool
+	items      map[int]*models.ItineraryItem
+	nextID     int
+}
+
+func newMemoryItinerary(activityIDs ...int) *memoryItinerary {
+	m := &memoryItinerary{id: 1, start: time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC), end: time.Date(2026, 7, 3, 0, 0, 0, 0, time.UTC),
+		activities: make(map[int]bool), items: make(map[int]*models.ItineraryItem)}
+	for _, id := range activityIDs {
+		m.activities[id] = true
+	}
+	return m
+}
+
+func sameDay(a, b *string) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// nextPosition is the position at the end of day
+func (m *memoryItinerary) nextPosition(day *string) int {
+	position := 0
+	for _, item := range m.items {
+		if sameDay(item.Day, day) && item.Position >= position {
+			position = item.Position + 1
+		}
+	}
+	return position
+}
+
+// dayOrder lists the ids of day's items in their order
+func (m *memoryItinerary) dayOrder(day *string) []int {
+	var items []*models.ItineraryItem
+	for _, item := range m.items {
+		if sameDay(item.Day, day) {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Position < items[j].Position })
+	ids := []int{}
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+func (m *memoryItinerary) checkTripDay(day *string) error {
+	if day == nil {
+		return nil
+	}
+	d, _ := time.Parse("2006-01-02", *day)
+	if d.Before(m.start) || d.After(m.end) {
+		return repository.ErrOutsideTrip
+	}
+	return nil
+}
+
+func (m *memoryItinerary) CreateItinerary(ctx context.Context, userId int, title, description string, startDate, endDate time.Time, activities []models.Activity, items []models.ItineraryItem) (models.Itinerary, error) {
+	return models.Itinerary{}, nil
+}
+
+func (m *memoryItinerary) GetItineraryById(ctx context.Context, id int) (*models.Itinerary, error) {
+	return nil, sql.ErrNoRows
+}
+
+func (m *memoryItinerary) GetItinerariesByUser(ctx context.Context, userId int) ([]models.Itinerary, error) {
+	return nil, nil
+}
+
+func (m *memoryItinerary) GetDeletedItinerariesByUser(ctx context.Context, userId int) ([]models.Itinerary, error) {
+	return nil, nil
+}
+
+func (m *memoryItinerary) GetItineraryDates(ctx context.Context, id int) (time.Time, time.Time, error) {
+	return m.start, m.end, nil
+}
+
+func (m *memoryItinerary) GetItinerarySchedule(ctx context.Context, id int) (models.ItinerarySchedule, error) {
+	return models.ItinerarySchedule{}, nil
+}
+
+func (m *memoryItinerary) ModifyItinerary(ctx context.Context, id int, version int, title string, description string, startDate string, endDate string, activities []models.Activity) (models.Itinerary, error) {
+	return models.Itinerary{}, nil
+}
+
+func (m *memoryItinerary) DeleteItinerary(ctx context.Context, id int) error {
+	return nil
+}
+
+func (m *memoryItinerary) RestoreItinerary(ctx context.Context, id int) error {
+	return nil
+}
+
+func (m *memoryItinerary) AddItineraryItem(ctx context.Context, item models.ItineraryItem) (models.ItineraryItem, error) {
+	if item.ItineraryID != m.id || !m.activities[item.ActivityID] {
+		return models.ItineraryItem{}, sql.ErrNoRows
+	}
+	if err := m.checkTripDay(item.Day); err != nil {
+		return models.ItineraryItem{}, err
+	}
+	m.nextID++
+	item.ID = m.nextID
+	item.Position = m.nextPosition(item.Day)
+	m.items[item.ID] = &item
+	return item, nil
+}
+
+func (m *memoryItinerary) UpdateItineraryItem(ctx context.Context, item models.ItineraryItem) (models.ItineraryItem, error) {
+	stored, ok := m.items[item.ID]
+	if !ok {
+		return models.ItineraryItem{}, sql.ErrNoRows
+	}
+	if err := m.checkTripDay(item.Day); err != nil {
+		return models.ItineraryItem{}, err
+	}
+	if !sameDay(stored.Day, item.Day) {
+		stored.Position = m.nextPosition(item.Day)
+	}
+	stored.Day, stored.StartTime, stored.EndTime, stored.Notes = item.Day, item.StartTime, item.EndTime, item.Notes
+	return *stored, nil
+}
+
+func (m *memoryItinerary) ReorderItineraryItems(ctx context.Context, itineraryID int, day *string, itemIDs []int) error {
+	if itineraryID != m.id {
+		return sql.ErrNoRows
+	}
+	current := m.dayOrder(day)
+	sorted := append([]int{}, itemIDs...)
+	sort.Ints(sorted)
+	sort.Ints(current)
+	if !reflect.DeepEqual(sorted, current) {
+		return repository.ErrInvalidOrder
+	}
+	for position, id := range itemIDs {
+		m.items[id].Position = position
+	}
+	return nil
+}
+
+func (m *memoryItinerary) GetItineraryIdByItem(ctx context.Context, itemID int) (int, error) {
+	if _, ok := m.items[itemID]; !ok {
+		return 0, sql.ErrNoRows
+	}
+	return m.id, nil
+}
+
+func (m *memoryItinerary) RemoveItineraryItem(ctx context.Context, itemID int) (int, error) {
+	if _, ok := m.items[itemID]; !ok {
+		return 0, sql.ErrNoRows
+	}
+	delete(m.items, itemID)
+	return m.id, nil
+}
+
+func TestItineraryHandler_RejectsBadItems(t *testing.T) {
+	handler := handlers.NewItineraryHandler(&repository.ItineraryRepository{}, nil, nil, openAccess(), nil, nil)
+
+	cases := map[string]string{
+		"missing activity":      `{"day": "2026-07-01"}`,
+		"bad day":               `{"activity_id": 1, "day": "01/07/2026"}`,
+		"bad time":              `{"activity_id": 1, "day": "2026-07-01", "start_time": "9am"}`,
+		"time without a day":    `{"activity_id": 1, "start_time": "09:00"}`,
+		"ends before it starts": `{"activity_id": 1, "day": "2026-07-01", "start_time": "11:00", "end_time": "10:00"}`,
+	}
+	for name, body := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/itinerary/items?itinerary_id=1", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.AddItineraryItem(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rec.Code)
+		}
+	}
+}
+
+func TestItineraryHandler_SchedulesItems(t *testing.T) {
+	itinerary := newMemoryItinerary(1, 2, 3)
+	access := service.NewItineraryAccess(memoryRoles{"editor@example.com": models.RoleEditor})
+	handler := handlers.NewItineraryHandler(itinerary, nil, nil, access, nil, nil)
+	call := func(serve http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		serve(rec, req.WithContext(auth.WithActor(req.Context(), "editor@example.com")))
+		return rec
+	}
+	add := func(body string) models.ItineraryItem {
+		t.Helper()
+		rec := call(handler.AddItineraryItem, http.MethodPost, "/itinerary/items?itinerary_id=1", body)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected 201 adding %s, got %d: %s", body, rec.Code, rec.Body)
+		}
+		var res struct {
+			Data models.ItineraryItem `json:"data"`
+		}
+		json.NewDecoder(rec.Body).Decode(&res)
+		return res.Data
+	}
+	day1, day2 := "2026-07-01", "2026-07-02"
+
+	museum := add(`{"activity_id": 1, "day": "2026-07-01", "start_time": "09:00", "end_time": "11:00"}`)
+	market := add(`{"activity_id": 2, "day": "2026-07-01", "notes": " lunch "}`)
+	park := add(`{"activity_id": 3}`)
+	// each item goes to the end of its own day
+	if museum.Position != 0 || market.Position != 1 || park.Position != 0 || park.Day != nil || market.Notes != "lunch" {
+		t.Errorf("unexpected placement %+v, %+v, %+v", museum, market, park)
+	}
+	if rec := call(handler.AddItineraryItem, http.MethodPost, "/itinerary/items?itinerary_id=1", `{"activity_id": 1, "day": "2026-08-01"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a day outside the trip, got %d", rec.Code)
+	}
+	if rec := call(handler.AddItineraryItem, http.MethodPost, "/itinerary/items?itinerary_id=1", `{"activity_id": 9}`); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing activity, got %d", rec.Code)
+	}
+
+	if rec := call(handler.ReorderItineraryItems, http.MethodPut, "/itinerary/items/order?itinerary_id=1", `{"day": "2026-07-01", "item_ids": [2, 1]}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 reordering, got %d: %s", rec.Code, rec.Body)
+	}
+	if order := itinerary.dayOrder(&day1); !reflect.DeepEqual(order, []int{market.ID, museum.ID}) {
+		t.Errorf("expected the market first, got %v", order)
+	}
+	for name, body := range map[string]string{
+		"missing item":   `{"day": "2026-07-01", "item_ids": [2]}`,
+		"repeated item":  `{"day": "2026-07-01", "item_ids": [2, 2]}`,
+		"other day item": `{"day": "2026-07-01", "item_ids": [2, 3]}`,
+	} {
+		if rec := call(handler.ReorderItineraryItems, http.MethodPut, "/itinerary/items/order?itinerary_id=1", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rec.Code)
+		}
+	}
+
+	if rec := call(handler.UpdateItineraryItem, http.MethodPut, "/itinerary/items?item_id=3", `{"day": "2026-07-02", "start_time": "15:00"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 scheduling the park, got %d: %s", rec.Code, rec.Body)
+	}
+	// moving the market to the second day puts it after the park and leaves the museum's visit alone
+	if rec := call(handler.UpdateItineraryItem, http.MethodPut, "/itinerary/items?item_id=2", `{"day": "2026-07-02", "notes": "lunch"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 moving the market, got %d: %s", rec.Code, rec.Body)
+	}
+	if order := itinerary.dayOrder(&day2); !reflect.DeepEqual(order, []int{park.ID, market.ID}) {
+		t.Errorf("expected the park then the market on the second day, got %v", order)
+	}
+	if order := itinerary.dayOrder(&day1); !reflect.DeepEqual(order, []int{museum.ID}) || itinerary.items[museum.ID].StartTime != "09:00" {
+		t.Errorf("expected only the museum left on the first day, got %v", order)
+	}
+	if order := itinerary.dayOrder(nil); len(order) != 0 {
+		t.Errorf("expected nothing unscheduled, got %v", order)
+	}
+	if rec := call(handler.UpdateItineraryItem, http.MethodPut, "/itinerary/items?item_id=2", `{"day": "2026-07-04"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 moving past the end of the trip, got %d", rec.Code)
+	}
+
+	if rec := call(handler.RemoveItineraryItem, http.MethodDelete, "/itinerary/items?item_id=3", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 removing the park, got %d", rec.Code)
+	}
+	if rec := call(handler.RemoveItineraryItem, http.MethodDelete, "/itinerary/items?item_id=3", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 removing it twice, got %d", rec.Code)
+	}
+	if order := itinerary.dayOrder(&day2); !reflect.DeepEqual(order, []int{market.ID}) {
+		t.Errorf("expected only the market left on the second day, got %v", order)
+	}
+}
+
+func TestItineraryRepository_ItemScheduling(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	user := createTestUser(t, db, "planner")
+	museum := createTestActivity(t, db, "Museum")
+	market := createTestActivity(t, db, "Market")
+	park := createTestActivity(t, db, "Park")
+	itineraries := repository.NewItineraryRepository(db)
+	start := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	itinerary, err := itineraries.CreateItinerary(ctx, user.ID, "Trip", "", start, start.AddDate(0, 0, 2), nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create itinerary: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Primary().Exec(`DELETE FROM itinerary WHERE id = $1`, itinerary.Id)
+	})
+	day1, day2 := "2026-07-01", "2026-07-02"
+	add := func(activityID int, day *string) models.ItineraryItem {
+		t.Helper()
+		item, err := itineraries.AddItineraryItem(ctx, models.ItineraryItem{ItineraryID: itinerary.Id, ActivityID: activityID, Day: day})
+		if err != nil {
+			t.Fatalf("failed to add item: %v", err)
+		}
+		return item
+	}
+	order := func(day int) []int {
+		t.Helper()
+		schedule, err := itineraries.GetItinerarySchedule(ctx, itinerary.Id)
+		if err != nil {
+			t.Fatalf("failed to fetch schedule: %v", err)
+		}
+		ids := []int{}
+		for _, item := range schedule.Days[day].Items {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+
+	first, second, third := add(museum.ID, &day1), add(market.ID, &day1), add(park.ID, nil)
+	if first.Position != 0 || second.Position != 1 || third.Position != 0 {
+		t.Errorf("expected items appended to their day, got positions %d, %d and %d", first.Position, second.Position, third.Position)
+	}
+	outside := "2026-07-05"
+	if _, err := itineraries.AddItineraryItem(ctx, models.ItineraryItem{ItineraryID: itinerary.Id, ActivityID: park.ID, Day: &outside}); err != repository.ErrOutsideTrip {
+		t.Errorf("expected ErrOutsideTrip, got %v", err)
+	}
+
+	if err := itineraries.ReorderItineraryItems(ctx, itinerary.Id, &day1, []int{second.ID, first.ID}); err != nil {
+		t.Fatalf("failed to reorder: %v", err)
+	}
+	if got := order(0); !reflect.DeepEqual(got, []int{second.ID, first.ID}) {
+		t.Errorf("expected the reordered day, got %v", got)
+	}
+	if err := itineraries.ReorderItineraryItems(ctx, itinerary.Id, &day1, []int{second.ID, third.ID}); err != repository.ErrInvalidOrder {
+		t.Errorf("expected ErrInvalidOrder for an item of another day, got %v", err)
+	}
+
+	third.Day = &day2
+	if _, err := itineraries.UpdateItineraryItem(ctx, third); err != nil {
+		t.Fatalf("failed to schedule item: %v", err)
+	}
+	second.Day, second.StartTime = &day2, "13:00"
+	moved, err := itineraries.UpdateItineraryItem(ctx, second)
+	if err != nil {
+		t.Fatalf("failed to move item: %v", err)
+	}
+	if moved.Position != 1 || moved.StartTime != "13:00" {
+		t.Errorf("expected the moved item at the end of its new day, got %+v", moved)
+	}
+	if got := order(0); !reflect.DeepEqual(got, []int{first.ID}) {
+		t.Errorf("expected only the museum left on the first day, got %v", got)
+	}
+	if got := order(1); !reflect.DeepEqual(got, []int{third.ID, second.ID}) {
+		t.Errorf("expected the park then the market on the second day, got %v", got)
+	}
+
+	if _, err := itineraries.RemoveItineraryItem(ctx, third.ID); err != nil {
+		t.Fatalf("failed to remove item: %v", err)
+	}
+	if _, err := itineraries.GetItineraryIdByItem(ctx, third.ID); err != sql.ErrNoRows {
+		t.Errorf("expected the removed item to be gone, got %v", err)
+	}
+}
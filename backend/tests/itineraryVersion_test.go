@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/handlers"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+)
+
+func TestItineraryHandler_ModifyNeedsVersion(t *testing.T) {
+	handler := handlers.NewItineraryHandler(&repository.ItineraryRepository{}, nil, nil, openAccess(), nil, nil)
+
+	cases := map[string]struct {
+		ifMatch string
+		want    int
+	}{
+		"no version":   {"", http.StatusPreconditionRequired},
+		"bad if-match": {"not-a-version", http.StatusBadRequest},
+		"etag list":    {`"2", "3"`, http.StatusBadRequest},
+	}
+	for name, tc := range cases {
+		req := httptest.NewRequest(http.MethodPut, "/itinerary?itinerary_id=1", strings.NewReader(`{"title": "Trip"}`))
+		req = req.WithContext(auth.WithActor(req.Context(), "owner@example.com"))
+		if tc.ifMatch != "" {
+			req.Header.Set("If-Match", tc.ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		handler.ModifyItinerary(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", name, tc.want, rec.Code)
+		}
+	}
+}
+
+func TestItineraryHandler_VersionConflict(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db, "versions")
+	itineraryRepo := repository.NewItineraryRepository(db)
+	start := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	itinerary, err := itineraryRepo.CreateItinerary(context.Background(), user.ID, "Trip", "", start, start.AddDate(0, 0, 3), nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create itinerary: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Primary().Exec(`DELETE FROM itinerary WHERE id = $1`, itinerary.Id)
+	})
+	handler := handlers.NewItineraryHandler(itineraryRepo, nil, nil, openAccess(), nil, nil)
+	target := "/itinerary?itinerary_id=" + strconv.Itoa(itinerary.Id)
+
+	rec := httptest.NewRecorder()
+	handler.GetItinerary(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"1"` {
+		t.Fatalf("expected 200 with ETag \"1\", got %d %q", rec.Code, rec.Header().Get("ETag"))
+	}
+
+	modify := func(ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, target, strings.NewReader(`{"title": "Renamed", "startDate": "2026-07-01", "endDate": "2026-07-04"}`))
+		req.Header.Set("If-Match", ifMatch)
+		rec := httptest.NewRecorder()
+		handler.ModifyItinerary(rec, req)
+		return rec
+	}
+
+	if rec := modify(`"1"`); rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected 200 with ETag \"2\", got %d %q: %s", rec.Code, rec.Header().Get("ETag"), rec.Body)
+	}
+
+	// a second editor still holding version 1
+	rec = modify(`"1"`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a stale version, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("ETag") != `"2"` {
+		t.Errorf("expected the current ETag \"2\", got %q", rec.Header().Get("ETag"))
+	}
+	var body struct {
+		Current models.Itinerary `json:"current"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.Current.Version != 2 || body.Current.Title != "Renamed" {
+		t.Errorf("expected the current itinerary at version 2, got %+v", body.Current)
+	}
+}
+
+func TestItineraryRepository_ModifyKeepsScheduledItems(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	user := createTestUser(t, db, "schedule")
+	museum := createTestActivity(t, db, "Museum")
+	market := createTestActivity(t, db, "Market")
+	itineraryRepo := repository.NewItineraryRepository(db)
+	start := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	itinerary, err := itineraryRepo.CreateItinerary(ctx, user.ID, "Trip", "", start, start.AddDate(0, 0, 3), []models.Activity{museum, market}, nil)
+	if err != nil {
+		t.Fatalf("failed to create itinerary: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Primary().Exec(`DELETE FROM itinerary WHERE id = $1`, itinerary.Id)
+	})
+
+	// the museum is visited on two days besides sitting unscheduled in the list
+	for _, day := range []string{"2026-07-01", "2026-07-03"} {
+		if _, err := itineraryRepo.AddItineraryItem(ctx, models.ItineraryItem{ItineraryID: itinerary.Id, ActivityID: museum.ID, Day: &day, StartTime: "10:00", EndTime: "12:00", Notes: "tickets at the door"}); err != nil {
+			t.Fatalf("failed to schedule item: %v", err)
+		}
+	}
+
+	// drop the museum from the unscheduled list, keep the market
+	if _, err := itineraryRepo.ModifyItinerary(ctx, itinerary.Id, itinerary.Version, "Trip", "", "2026-07-01", "2026-07-04", []models.Activity{market}); err != nil {
+		t.Fatalf("failed to modify itinerary: %v", err)
+	}
+
+	schedule, err := itineraryRepo.GetItinerarySchedule(ctx, itinerary.Id)
+	if err != nil {
+		t.Fatalf("failed to get schedule: %v", err)
+	}
+	var scheduled []models.ItineraryItem
+	for _, day := range schedule.Days {
+		scheduled = append(scheduled, day.Items...)
+	}
+	if len(scheduled) != 2 {
+		t.Fatalf("expected both scheduled museum visits to be kept, got %+v", scheduled)
+	}
+	for _, item := range scheduled {
+		if item.ActivityID != museum.ID || item.StartTime != "10:00" || item.Notes != "tickets at the door" {
+			t.Errorf("expected the visit to keep its time and notes, got %+v", item)
+		}
+	}
+	if len(schedule.Unscheduled) != 1 || schedule.Unscheduled[0].ActivityID != market.ID {
+		t.Errorf("expected only the market to be left unscheduled, got %+v", schedule.Unscheduled)
+	}
+}
@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/middleware"
+)
+
+func TestOptionalJWTAuth_SetsActor(t *testing.T) {
+	var actor string
+	handler := middleware.OptionalJWTAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor = auth.ActorFromContext(r.Context())
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/itinerary", nil))
+	if actor != auth.AnonymousActor {
+		t.Errorf("expected anonymous actor without token, got %q", actor)
+	}
+
+	token, err := auth.CreateToken("joshua@example.com")
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/itinerary", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if actor != "joshua@example.com" {
+		t.Errorf("expected actor from token, got %q", actor)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/itinerary", nil)
+	req.Header.Set("Authorization", "Bearer not-a-token")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d for invalid token, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	handler := middleware.RequireAdmin([]string{"admin@example.com"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/audit", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req.WithContext(auth.WithActor(req.Context(), "someone@example.com")))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected status %d for non admin, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req.WithContext(auth.WithActor(req.Context(), "admin@example.com")))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d for admin, got %d", http.StatusOK, rec.Code)
+	}
+}
@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/money"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+// memoryRates keeps the latest saved snapshot the way CurrencyRepository does
+type memoryRates struct {
+	currencies map[string]int
+	latest     *models.ExchangeRates
+}
+
+func (m *memoryRates) GetCurrencies(ctx context.Context) (map[string]int, error) {
+	return m.currencies, nil
+}
+
+func (m *memoryRates) SaveExchangeRates(ctx context.Context, rates models.ExchangeRates) error {
+	saved := models.ExchangeRates{Base: rates.Base, Date: rates.Date, Rates: map[string]float64{rates.Base: 1}}
+	for code, rate := range rates.Rates {
+		if _, ok := m.currencies[code]; ok {
+			saved.Rates[code] = rate
+		}
+	}
+	m.latest = &saved
+	return nil
+}
+
+func (m *memoryRates) GetLatestExchangeRates(ctx context.Context) (models.ExchangeRates, error) {
+	if m.latest == nil {
+		return models.ExchangeRates{}, sql.ErrNoRows
+	}
+	return *m.latest, nil
+}
+
+func TestMoney_MinorUnits(t *testing.T) {
+	if got := money.ToMinor(12.345, 2); got != 1235 {
+		t.Errorf("expected 1235, got %d", got)
+	}
+	if got := money.ToMinor(1500, 0); got != 1500 {
+		t.Errorf("expected 1500 yen, got %d", got)
+	}
+	if got := money.ToMajor(1234, 3); got != 1.234 {
+		t.Errorf("expected 1.234, got %v", got)
+	}
+	// 10.00 EUR at 0.5 EUR per USD is 20.00 USD
+	if got := money.Convert(1000, 2, 0.5, 2, 1); got != 2000 {
+		t.Errorf("expected 2000, got %d", got)
+	}
+}
+
+func TestExchangeService_ConvertsWithFileRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	rates := `{"base": "usd", "date": "2026-01-02", "rates": {"EUR": 0.9, "JPY": 150, "XXX": 2}}`
+	if err := os.WriteFile(path, []byte(rates), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := &memoryRates{currencies: map[string]int{"USD": 2, "EUR": 2, "JPY": 0, "GBP": 2}}
+	exchange := service.NewExchangeService(&service.FileRateProvider{Path: path}, store)
+	if err := exchange.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	price, err := exchange.Convert(1800, "EUR", "JPY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 18.00 EUR is 20 USD which is 3000 JPY
+	if price.AmountMinor != 3000 || price.Amount != 3000 || price.RateDate != "2026-01-02" {
+		t.Errorf("unexpected conversion %+v", price)
+	}
+	if _, err := exchange.Convert(100, "USD", "GBP"); !errors.Is(err, service.ErrNoExchangeRate) {
+		t.Errorf("expected ErrNoExchangeRate for a currency without a rate, got %v", err)
+	}
+	if _, err := exchange.Convert(100, "USD", "XXX"); !errors.Is(err, service.ErrUnknownCurrency) {
+		t.Errorf("expected ErrUnknownCurrency, got %v", err)
+	}
+
+	// a broken provider keeps the rates already stored
+	exchange = service.NewExchangeService(&service.FileRateProvider{Path: filepath.Join(t.TempDir(), "missing.json")}, store)
+	if err := exchange.Refresh(context.Background()); err == nil {
+		t.Error("expected an error for a missing rates file")
+	}
+	if exchange.RatesDate() != "2026-01-02" {
+		t.Errorf("expected stored rates to still be used, got date %q", exchange.RatesDate())
+	}
+}
+
+func TestConvertPrice_UsesLatestRateForThePair(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	currencies := repository.NewCurrencyRepository(db)
+	// far in the future so these are the latest snapshots
+	older := models.ExchangeRates{Base: "USD", Date: "2999-01-01", Rates: map[string]float64{"JPY": 150, "EUR": 0.9}}
+	newer := models.ExchangeRates{Base: "EUR", Date: "2999-01-02", Rates: map[string]float64{"GBP": 0.8}}
+	for _, rates := range []models.ExchangeRates{older, newer} {
+		if err := currencies.SaveExchangeRates(ctx, rates); err != nil {
+			t.Fatalf("failed to save rates: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		db.Primary().Exec(`DELETE FROM exchange_rate WHERE rate_date >= '2999-01-01'`)
+	})
+
+	tests := []struct {
+		amountMinor int64
+		from, to    string
+		want        sql.NullFloat64
+	}{
+		// the newest snapshot has no yen, so the pair comes from the older one
+		{1000, "USD", "JPY", sql.NullFloat64{Float64: 1500, Valid: true}},
+		{1000, "EUR", "GBP", sql.NullFloat64{Float64: 8, Valid: true}},
+		{1000, "EUR", "USD", sql.NullFloat64{Float64: 1000.0 / 100 / 0.9, Valid: true}},
+		{1000, "GBP", "JPY", sql.NullFloat64{}},
+	}
+	for _, tt := range tests {
+		var got sql.NullFloat64
+		if err := db.Primary().QueryRow(`SELECT convert_price($1, $2, $3)::FLOAT8`, tt.amountMinor, tt.from, tt.to).Scan(&got); err != nil {
+			t.Fatalf("failed to convert: %v", err)
+		}
+		if got.Valid != tt.want.Valid || math.Abs(got.Float64-tt.want.Float64) > 1e-6 {
+			t.Errorf("%s to %s: expected %v, got %v", tt.from, tt.to, tt.want, got)
+		}
+	}
+}
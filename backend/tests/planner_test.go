@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/schedule"
+)
+
+func plannerCandidate(id int, lat, lng float64, cost int64, hours models.OpeningHours) schedule.Candidate {
+	return schedule.Candidate{
+		Activity: models.Activity{ID: id, Title: "stop", Latitude: &lat, Longitude: &lng},
+		Hours:    hours,
+		Cost:     cost,
+	}
+}
+
+func TestSchedule_GeneratePlan(t *testing.T) {
+	mondaysOnly := models.OpeningHours{Weekly: []models.WeeklyHours{{Weekday: 1, Opens: "10:00", Closes: "16:00"}}}
+	candidates := []schedule.Candidate{
+		plannerCandidate(1, 1.2868, 103.8545, 2000, models.OpeningHours{}),
+		// far from the first pick, only used once nothing closer fits
+		plannerCandidate(2, 1.4043, 103.7930, 1000, models.OpeningHours{}),
+		plannerCandidate(3, 1.2816, 103.8636, 1500, models.OpeningHours{}),
+		// over what is left of the budget
+		plannerCandidate(4, 1.2840, 103.8600, 9000, models.OpeningHours{}),
+		plannerCandidate(5, 1.2900, 103.8500, 500, mondaysOnly),
+	}
+	limits := schedule.Limits{MaxItemsPerDay: 6, MaxHoursPerDay: 10 * time.Hour, TravelSpeedKmh: 25}
+	start := time.Date(2026, 7, 5, 0, 0, 0, 0, time.UTC) // a sunday
+	plan := schedule.Generate(schedule.PlanRequest{
+		StartDate: start,
+		EndDate:   start.AddDate(0, 0, 1),
+		Pace:      schedule.PaceModerate,
+		Budget:    6000,
+		Limits:    limits,
+	}, candidates)
+
+	if len(plan.Days) != 2 {
+		t.Fatalf("expected 2 days, got %d", len(plan.Days))
+	}
+	var order [][]int
+	for _, day := range plan.Days {
+		var ids []int
+		for _, item := range day.Items {
+			ids = append(ids, item.ActivityID)
+		}
+		order = append(order, ids)
+	}
+	// sunday clusters around the first pick, monday gets what is left including the monday-only activity
+	if len(order[0]) != 3 || order[0][0] != 1 || order[0][1] != 3 || order[0][2] != 2 {
+		t.Errorf("unexpected sunday plan %v", order[0])
+	}
+	if len(order[1]) != 1 || order[1][0] != 5 {
+		t.Errorf("unexpected monday plan %v", order[1])
+	}
+	if plan.Cost != 5000 {
+		t.Errorf("expected a cost of 5000, got %d", plan.Cost)
+	}
+	if first := plan.Days[1].Items[0]; first.StartTime != "10:00" || first.EndTime != "12:00" {
+		t.Errorf("expected the monday visit to wait for opening, got %s-%s", first.StartTime, first.EndTime)
+	}
+
+	hours := map[int]models.OpeningHours{5: mondaysOnly}
+	if warnings := schedule.Check(models.ItinerarySchedule{Days: plan.Days}, hours, limits); len(warnings) != 0 {
+		t.Errorf("expected a generated plan without warnings, got %+v", warnings)
+	}
+}
@@ -0,0 +1,332 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/handlers"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+	"github.com/lib/pq"
+)
+
+// memoryUsers finds users by email
+type memoryUsers map[string]*models.User
+
+func (m memoryUsers) GetUserByEmail(email string) (*models.User, error) {
+	user, ok := m[email]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+// memoryReviews keeps reviews and helpful votes the way ReviewRepository does
+type memoryReviews struct {
+	activities map[int]bool
+	reviews    map[int]*models.Review
+	votes      map[int]map[int]bool
+	lastFilter models.ReviewFilter
+}
+
+func newMemoryReviews(activityIDs ...int) *memoryReviews {
+	m := &memoryReviews{activities: make(map[int]bool), reviews: make(map[int]*models.Review), votes: make(map[int]map[int]bool)}
+	for _, id := range activityIDs {
+		m.activities[id] = true
+	}
+	return m
+}
+
+func (m *memoryReviews) CreateReview(ctx context.Context, review *models.Review) error {
+	if !m.activities[review.ActivityID] {
+		return sql.ErrNoRows
+	}
+	for _, existing := range m.reviews {
+		if existing.ActivityID == review.ActivityID && existing.UserID == review.UserID {
+			return &pq.Error{Code: "23505"}
+		}
+	}
+	review.ID = len(m.reviews) + 1
+	review.CreatedAt, review.UpdatedAt = time.Now(), time.Now()
+	stored := *review
+	m.reviews[review.ID] = &stored
+	return nil
+}
+
+func (m *memoryReviews) GetReviewsByActivity(ctx context.Context, filter models.ReviewFilter) ([]models.Review, int, error) {
+	m.lastFilter = filter
+	var reviews []models.Review
+	for _, review := range m.reviews {
+		if review.ActivityID == filter.ActivityID {
+			reviews = append(reviews, *review)
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].ID < reviews[j].ID })
+	return reviews, len(reviews), nil
+}
+
+func (m *memoryReviews) UpdateReview(ctx context.Context, review *models.Review) error {
+	stored, ok := m.reviews[review.ID]
+	if !ok || stored.UserID != review.UserID {
+		return sql.ErrNoRows
+	}
+	stored.Rating, stored.Body, stored.UpdatedAt = review.Rating, review.Body, time.Now()
+	*review = *stored
+	return nil
+}
+
+func (m *memoryReviews) DeleteReview(ctx context.Context, id, userID int) (models.Review, error) {
+	stored, ok := m.reviews[id]
+	if !ok || stored.UserID != userID {
+		return models.Review{}, sql.ErrNoRows
+	}
+	delete(m.reviews, id)
+	delete(m.votes, id)
+	return *stored, nil
+}
+
+func (m *memoryReviews) SetHelpfulVote(ctx context.Context, reviewID, userID int, helpful bool) (int, error) {
+	review, ok := m.reviews[reviewID]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	if review.UserID == userID {
+		return 0, repository.ErrOwnReview
+	}
+	if m.votes[reviewID] == nil {
+		m.votes[reviewID] = make(map[int]bool)
+	}
+	if helpful {
+		m.votes[reviewID][userID] = true
+	} else {
+		delete(m.votes[reviewID], userID)
+	}
+	review.HelpfulCount = len(m.votes[reviewID])
+	return review.HelpfulCount, nil
+}
+
+// gorseFeedback records the feedback calls a fake gorse server receives
+func gorseFeedback(t *testing.T) (*service.GorseService, <-chan string) {
+	calls := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			calls <- "delete " + strings.TrimPrefix(r.URL.Path, "/api/feedback/")
+			return
+		}
+		var feedback []struct {
+			FeedbackType string
+			Value        float64
+		}
+		json.NewDecoder(r.Body).Decode(&feedback)
+		for _, f := range feedback {
+			calls <- fmt.Sprintf("%s %g", f.FeedbackType, f.Value)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return service.NewGorseService(server.URL), calls
+}
+
+func expectFeedback(t *testing.T, calls <-chan string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-calls:
+			if got != w {
+				t.Errorf("expected gorse feedback %q, got %q", w, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected gorse feedback %q, got none", w)
+		}
+	}
+}
+
+func asUser(req *http.Request, user *models.User) *http.Request {
+	return req.WithContext(auth.WithActor(req.Context(), user.Email))
+}
+
+func TestReviewHandler_RequiresUser(t *testing.T) {
+	handler := handlers.NewReviewHandler(nil, nil, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/activity/reviews?activity_id=1", strings.NewReader(`{"rating": 5, "body": "great"}`))
+	rec := httptest.NewRecorder()
+	handler.CreateReview(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an anonymous review, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/activity/reviews?activity_id=1&sort=best", nil)
+	rec = httptest.NewRecorder()
+	handler.GetReviews(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown sort, got %d", rec.Code)
+	}
+}
+
+func TestReviewHandler_ReviewLifecycle(t *testing.T) {
+	author := &models.User{ID: 1, Email: "author@example.com"}
+	reviews := newMemoryReviews(7)
+	gorse, feedback := gorseFeedback(t)
+	handler := handlers.NewReviewHandler(reviews, memoryUsers{author.Email: author}, gorse)
+
+	rec := httptest.NewRecorder()
+	handler.CreateReview(rec, asUser(httptest.NewRequest(http.MethodPost, "/activity/reviews?activity_id=7", strings.NewReader(`{"rating": 5, "body": "  Loved it "}`)), author))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	if review := reviews.reviews[1]; review == nil || review.UserID != author.ID || review.Body != "Loved it" {
+		t.Errorf("expected the trimmed review by the author, got %+v", reviews.reviews[1])
+	}
+	// a good rating is weighted star feedback
+	expectFeedback(t, feedback, "star 5")
+
+	rec = httptest.NewRecorder()
+	handler.CreateReview(rec, asUser(httptest.NewRequest(http.MethodPost, "/activity/reviews?activity_id=7", strings.NewReader(`{"rating": 4}`)), author))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 reviewing twice, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handler.CreateReview(rec, asUser(httptest.NewRequest(http.MethodPost, "/activity/reviews?activity_id=8", strings.NewReader(`{"rating": 4}`)), author))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing activity, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.UpdateReview(rec, asUser(httptest.NewRequest(http.MethodPut, "/activity/reviews?review_id=1", strings.NewReader(`{"rating": 2, "body": "Went downhill"}`)), author))
+	if rec.Code != http.StatusOK || reviews.reviews[1].Rating != 2 {
+		t.Fatalf("expected the rating to drop to 2, got %d: %s", rec.Code, rec.Body)
+	}
+	// a poor rating withdraws the star and only counts as a read
+	expectFeedback(t, feedback, "delete star/1/7", "read 1")
+
+	rec = httptest.NewRecorder()
+	handler.GetReviews(rec, httptest.NewRequest(http.MethodGet, "/activity/reviews?activity_id=7&sort=helpful&limit=5&offset=5", nil))
+	var listed struct {
+		Total   int             `json:"total"`
+		Reviews []models.Review `json:"reviews"`
+	}
+	json.NewDecoder(rec.Body).Decode(&listed)
+	if listed.Total != 1 || len(listed.Reviews) != 1 || listed.Reviews[0].Rating != 2 {
+		t.Errorf("expected the updated review listed, got %+v", listed)
+	}
+	if want := (models.ReviewFilter{ActivityID: 7, Sort: models.ReviewSortHelpful, Limit: 5, Offset: 5}); reviews.lastFilter != want {
+		t.Errorf("expected filter %+v, got %+v", want, reviews.lastFilter)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.DeleteReview(rec, asUser(httptest.NewRequest(http.MethodDelete, "/activity/reviews?review_id=1", nil), author))
+	if rec.Code != http.StatusOK || len(reviews.reviews) != 0 {
+		t.Fatalf("expected the review deleted, got %d", rec.Code)
+	}
+	expectFeedback(t, feedback, "delete star/1/7")
+}
+
+func TestReviewHandler_HelpfulVotes(t *testing.T) {
+	author := &models.User{ID: 1, Email: "author@example.com"}
+	voter := &models.User{ID: 2, Email: "voter@example.com"}
+	reviews := newMemoryReviews(7)
+	reviews.reviews[1] = &models.Review{ID: 1, ActivityID: 7, UserID: author.ID, Rating: 4}
+	handler := handlers.NewReviewHandler(reviews, memoryUsers{author.Email: author, voter.Email: voter}, nil)
+
+	vote := func(method string, user *models.User, reviewID string) (int, int) {
+		rec := httptest.NewRecorder()
+		handler.VoteHelpful(rec, asUser(httptest.NewRequest(method, "/activity/reviews/helpful?review_id="+reviewID, nil), user))
+		var body struct {
+			HelpfulCount int `json:"helpful_count"`
+		}
+		json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body.HelpfulCount
+	}
+
+	steps := []struct {
+		name      string
+		method    string
+		user      *models.User
+		reviewID  string
+		wantCode  int
+		wantCount int
+	}{
+		{"vote", http.MethodPost, voter, "1", http.StatusOK, 1},
+		{"vote twice", http.MethodPost, voter, "1", http.StatusOK, 1},
+		{"own review", http.MethodPost, author, "1", http.StatusForbidden, 0},
+		{"missing review", http.MethodPost, voter, "2", http.StatusNotFound, 0},
+		{"withdraw", http.MethodDelete, voter, "1", http.StatusOK, 0},
+		{"withdraw twice", http.MethodDelete, voter, "1", http.StatusOK, 0},
+	}
+	for _, step := range steps {
+		code, count := vote(step.method, step.user, step.reviewID)
+		if code != step.wantCode || (code == http.StatusOK && count != step.wantCount) {
+			t.Errorf("%s: expected %d with %d votes, got %d with %d", step.name, step.wantCode, step.wantCount, code, count)
+		}
+	}
+}
+
+func TestReviewRepository_AggregatesAndVotes(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	first := createTestUser(t, db, "first")
+	second := createTestUser(t, db, "second")
+	activity := createTestActivity(t, db, "Gallery")
+	reviews := repository.NewReviewRepository(db)
+	activities := repository.NewActivityRepository(db)
+
+	aggregate := func() (float64, int) {
+		t.Helper()
+		a, err := activities.GetActivityById(ctx, activity.ID)
+		if err != nil {
+			t.Fatalf("failed to fetch activity: %v", err)
+		}
+		return a.Rating, a.ReviewCount
+	}
+
+	good := &models.Review{ActivityID: activity.ID, UserID: first.ID, Rating: 5}
+	poor := &models.Review{ActivityID: activity.ID, UserID: second.ID, Rating: 2}
+	for _, review := range []*models.Review{good, poor} {
+		if err := reviews.CreateReview(ctx, review); err != nil {
+			t.Fatalf("failed to create review: %v", err)
+		}
+	}
+	if rating, count := aggregate(); rating != 3.5 || count != 2 {
+		t.Errorf("expected 3.5 from 2 reviews, got %v from %d", rating, count)
+	}
+
+	poor.Rating = 3
+	if err := reviews.UpdateReview(ctx, poor); err != nil {
+		t.Fatalf("failed to update review: %v", err)
+	}
+	if rating, _ := aggregate(); rating != 4 {
+		t.Errorf("expected the update to lift the rating to 4, got %v", rating)
+	}
+
+	if _, err := reviews.SetHelpfulVote(ctx, good.ID, first.ID, true); err != repository.ErrOwnReview {
+		t.Errorf("expected ErrOwnReview voting on your own review, got %v", err)
+	}
+	for _, helpful := range []bool{true, true} {
+		if count, err := reviews.SetHelpfulVote(ctx, good.ID, second.ID, helpful); err != nil || count != 1 {
+			t.Errorf("expected 1 helpful vote, got %d: %v", count, err)
+		}
+	}
+	listed, _, err := reviews.GetReviewsByActivity(ctx, models.ReviewFilter{ActivityID: activity.ID, Sort: models.ReviewSortHelpful})
+	if err != nil || len(listed) != 2 || listed[0].ID != good.ID {
+		t.Errorf("expected the voted review first, got %+v: %v", listed, err)
+	}
+	if count, err := reviews.SetHelpfulVote(ctx, good.ID, second.ID, false); err != nil || count != 0 {
+		t.Errorf("expected the vote withdrawn, got %d: %v", count, err)
+	}
+
+	if _, err := reviews.DeleteReview(ctx, good.ID, first.ID); err != nil {
+		t.Fatalf("failed to delete review: %v", err)
+	}
+	if rating, count := aggregate(); rating != 3 || count != 1 {
+		t.Errorf("expected 3 from the remaining review, got %v from %d", rating, count)
+	}
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/Joshua-Pok/FYP-backend/geo"
+)
+
+func stopAt(lat, lng float64, fixed bool) geo.Stop {
+	return geo.Stop{Point: &geo.Point{Lat: lat, Lng: lng}, Fixed: fixed}
+}
+
+func sameOrder(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGeo_OptimiseRoute(t *testing.T) {
+	start := &geo.Point{Lat: 0, Lng: 0}
+	// stops along the equator given out of order, plus one without a location
+	stops := []geo.Stop{
+		stopAt(0, 0.03, false),
+		stopAt(0, 0.01, false),
+		{},
+		stopAt(0, 0.02, false),
+	}
+	order, meters, exact := geo.OptimiseRoute(start, stops)
+	if !exact || !sameOrder(order, []int{1, 3, 0, 2}) {
+		t.Errorf("expected exact order [1 3 0 2], got %v (exact=%v)", order, exact)
+	}
+	if want := geo.DistanceMeters(*start, geo.Point{Lat: 0, Lng: 0.03}); meters < want-1 || meters > want+1 {
+		t.Errorf("expected %.0f meters, got %.0f", want, meters)
+	}
+
+	// fixed stops keep their order even when going back and forth is longer
+	stops = []geo.Stop{
+		stopAt(0, 0.03, true),
+		stopAt(0, 0.01, true),
+		stopAt(0, 0.02, false),
+	}
+	order, _, _ = geo.OptimiseRoute(start, stops)
+	// both shortest orders that keep stop 0 before stop 1 are the same length
+	if !sameOrder(order, []int{2, 0, 1}) && !sameOrder(order, []int{0, 2, 1}) {
+		t.Errorf("expected stop 0 before stop 1 with stop 2 on the way, got %v", order)
+	}
+
+	// past the exact limit 2-opt still untangles points on a line
+	n := geo.ExactRouteLimit + 5
+	stops = make([]geo.Stop, n)
+	for i, p := range rand.New(rand.NewSource(1)).Perm(n) {
+		stops[i] = stopAt(0, float64(p+1)*0.01, false)
+	}
+	order, meters, exact = geo.OptimiseRoute(start, stops)
+	if exact || len(order) != n {
+		t.Fatalf("expected an approximate route over %d stops, got %v", n, order)
+	}
+	if want := geo.DistanceMeters(*start, geo.Point{Lat: 0, Lng: float64(n) * 0.01}); meters > want+1 {
+		t.Errorf("expected the straight line of %.0f meters, got %.0f", want, meters)
+	}
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/auth"
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/middleware"
+	_ "github.com/lib/pq"
+)
+
+// openUnreachable returns a handle whose pings fail fast, nothing listens on port 1
+func openUnreachable(t *testing.T) *sql.DB {
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 user=test dbname=test sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	return db
+}
+
+func TestRouter_RoundRobinAndEjection(t *testing.T) {
+	primary := openUnreachable(t)
+	replicaA := openUnreachable(t)
+	replicaB := openUnreachable(t)
+	router := database.NewRouter(primary, []*sql.DB{replicaA, replicaB}, 0)
+	defer router.Close()
+
+	ctx := context.Background()
+	first, second := router.Reader(ctx), router.Reader(ctx)
+	if first == primary || second == primary || first == second {
+		t.Fatalf("expected reads to alternate between replicas")
+	}
+
+	if router.Writer(ctx) != primary {
+		t.Errorf("expected writes to go to the primary")
+	}
+
+	router.CheckReplicas()
+	if router.Reader(ctx) != primary {
+		t.Errorf("expected reads to fall back to the primary once every replica is ejected")
+	}
+}
+
+func TestRouter_ReadYourWrites(t *testing.T) {
+	primary := openUnreachable(t)
+	replica := openUnreachable(t)
+	router := database.NewRouter(primary, []*sql.DB{replica}, 50*time.Millisecond)
+	defer router.Close()
+
+	alice := auth.WithActor(context.Background(), "alice")
+	bob := auth.WithActor(context.Background(), "bob")
+
+	router.Writer(alice)
+	if router.Reader(alice) != primary {
+		t.Errorf("expected alice to be pinned to the primary after writing")
+	}
+	if router.Reader(bob) != replica {
+		t.Errorf("expected bob to keep reading from the replica")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if router.Reader(alice) != replica {
+		t.Errorf("expected alice to go back to the replica after the window")
+	}
+
+	router.Writer(context.Background())
+	if router.Reader(context.Background()) != replica {
+		t.Errorf("expected anonymous writes not to pin anonymous reads")
+	}
+
+	if router.Reader(database.WithPrimary(bob)) != primary {
+		t.Errorf("expected WithPrimary to force the primary")
+	}
+}
+
+func TestReadYourWrites_CarriesPinInCookie(t *testing.T) {
+	primary := openUnreachable(t)
+	replica := openUnreachable(t)
+	router := database.NewRouter(primary, []*sql.DB{replica}, 0)
+	defer router.Close()
+
+	var readFromPrimary bool
+	handler := middleware.ReadYourWrites(time.Minute, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		readFromPrimary = router.Reader(r.Context()) == primary
+		if r.URL.Query().Has("fail") {
+			http.Error(w, "bad request", http.StatusBadRequest)
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/itinerary", nil))
+	if readFromPrimary || len(rec.Result().Cookies()) != 0 {
+		t.Errorf("expected a read without a pin to use the replica and set no cookie")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/itinerary?fail", nil))
+	if len(rec.Result().Cookies()) != 0 {
+		t.Errorf("expected a failed write not to pin")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/itinerary", nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected a write to set the pin cookie, got %v", cookies)
+	}
+
+	// the next read may reach any instance, the cookie alone sends it to the primary
+	req := httptest.NewRequest(http.MethodGet, "/itinerary", nil)
+	req.AddCookie(cookies[0])
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !readFromPrimary {
+		t.Errorf("expected a pinned read to use the primary")
+	}
+
+	for _, value := range []string{"not-a-time", strconv.FormatInt(time.Now().Add(-time.Second).UnixMilli(), 10), strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10)} {
+		req := httptest.NewRequest(http.MethodGet, "/itinerary", nil)
+		req.AddCookie(&http.Cookie{Name: cookies[0].Name, Value: value})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if readFromPrimary {
+			t.Errorf("%s: expected an expired or forged pin to be ignored", value)
+		}
+	}
+}
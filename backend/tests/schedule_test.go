@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/schedule"
+)
+
+func TestSchedule_IsOpen(t *testing.T) {
+	hours := models.OpeningHours{
+		Timezone: "Asia/Singapore",
+		Weekly: []models.WeeklyHours{
+			{Weekday: 1, Opens: "09:00", Closes: "17:00"},
+			// friday night market running past midnight
+			{Weekday: 5, Opens: "18:00", Closes: "02:00"},
+		},
+		Exceptions: []models.OpeningException{{Date: "2026-08-10", Note: "National Day"}},
+		Closures:   []models.SeasonalClosure{{Start: "12-20", End: "01-05"}},
+	}
+	if err := schedule.Validate(hours); err != nil {
+		t.Fatal(err)
+	}
+	loc := schedule.Location(hours)
+	at := func(s string) time.Time {
+		ts, err := time.ParseInLocation("2006-01-02T15:04", s, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+
+	cases := map[string]bool{
+		"2026-07-06T10:00": true,  // monday
+		"2026-07-06T17:00": false, // closing time is exclusive
+		"2026-07-07T10:00": false, // tuesday
+		"2026-07-10T23:30": true,  // friday night
+		"2026-07-11T01:30": true,  // still friday's hours
+		"2026-07-11T02:30": false,
+		"2026-08-10T10:00": false, // exception on a monday
+		"2026-12-28T10:00": false, // seasonal closure over new year
+		"2027-01-04T10:00": false,
+		"2027-01-11T10:00": true,
+	}
+	for ts, want := range cases {
+		if got := schedule.IsOpen(hours, at(ts)); got != want {
+			t.Errorf("%s: expected open=%v, got %v", ts, want, got)
+		}
+	}
+	if schedule.OpenOn(hours, at("2026-07-08T00:00")) {
+		t.Error("expected closed all wednesday")
+	}
+
+	// no weekly hours means an unknown schedule, which counts as open
+	if !schedule.IsOpen(models.OpeningHours{}, at("2026-07-08T03:00")) {
+		t.Error("expected an activity without hours to be open")
+	}
+
+	bad := []models.OpeningHours{
+		{Weekly: []models.WeeklyHours{{Weekday: 7, Opens: "09:00", Closes: "17:00"}}},
+		{Weekly: []models.WeeklyHours{{Weekday: 1, Opens: "9am", Closes: "17:00"}}},
+		{Exceptions: []models.OpeningException{{Date: "2026-08-10", Opens: "09:00"}}},
+		{Closures: []models.SeasonalClosure{{Start: "13-01", End: "01-05"}}},
+	}
+	for i, h := range bad {
+		if schedule.Validate(h) == nil {
+			t.Errorf("case %d: expected a validation error", i)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Joshua-Pok/FYP-backend/money"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   int64
+		weights []int64
+		want    []int64
+	}{
+		{"even", 900, []int64{1, 1, 1}, []int64{300, 300, 300}},
+		{"leftover goes to the first parts", 1000, []int64{1, 1, 1}, []int64{334, 333, 333}},
+		{"by shares", 1000, []int64{2, 1}, []int64{667, 333}},
+		{"largest remainder", 10, []int64{1, 3, 3}, []int64{2, 4, 4}},
+		{"too little to go round", 1, []int64{1, 1}, []int64{1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := money.Split(tt.total, tt.weights)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Split(%d, %v) = %v, want %v", tt.total, tt.weights, got, tt.want)
+			}
+		})
+	}
+
+	if got := money.SplitEqual(100, 3); !slices.Equal(got, []int64{34, 33, 33}) {
+		t.Errorf("SplitEqual(100, 3) = %v", got)
+	}
+}
+
+// settled applies the transfers and reports whether everyone ends up square
+func settled(balances map[int]int64, transfers []money.Transfer) bool {
+	left := make(map[int]int64)
+	for id, b := range balances {
+		left[id] = b
+	}
+	for _, t := range transfers {
+		if t.AmountMinor <= 0 {
+			return false
+		}
+		left[t.From] += t.AmountMinor
+		left[t.To] -= t.AmountMinor
+	}
+	for _, b := range left {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSettle(t *testing.T) {
+	tests := []struct {
+		name     string
+		balances map[int]int64
+		want     int
+	}{
+		{"nothing owed", map[int]int64{1: 0, 2: 0}, 0},
+		{"one debt", map[int]int64{1: 500, 2: -500}, 1},
+		{"two pairs", map[int]int64{1: 500, 2: -500, 3: 300, 4: -300}, 2},
+		// greedy has 5 pay 1 first and needs four transfers, settling {1, 2, 3} and {4, 5} apart needs three
+		{"groups hidden from greedy", map[int]int64{1: 6, 2: -4, 3: -2, 4: 5, 5: -5}, 3},
+		{"one creditor", map[int]int64{1: 900, 2: -300, 3: -300, 4: -300}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfers := money.Settle(tt.balances)
+			if !settled(tt.balances, transfers) {
+				t.Fatalf("transfers %+v do not settle %v", transfers, tt.balances)
+			}
+			if len(transfers) != tt.want {
+				t.Errorf("expected %d transfers, got %+v", tt.want, transfers)
+			}
+		})
+	}
+}
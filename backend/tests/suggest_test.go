@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+type staticSuggestionSource []models.Suggestion
+
+func (s staticSuggestionSource) GetSuggestionCandidates(ctx context.Context) ([]models.Suggestion, error) {
+	return s, nil
+}
+
+func TestSuggestService_Suggest(t *testing.T) {
+	source := staticSuggestionSource{
+		{Type: models.SuggestionActivity, ID: 1, Text: "Eiffel Tower"},
+		{Type: models.SuggestionActivity, ID: 2, Text: "Tower of London"},
+		{Type: models.SuggestionCountry, ID: 3, Text: "Japan"},
+		{Type: models.SuggestionActivity, ID: 4, Text: "Jardin du Luxembourg"},
+	}
+	suggestService := service.NewSuggestService(source, nil)
+	if err := suggestService.Rebuild(context.Background()); err != nil {
+		t.Fatalf("failed to build index: %v", err)
+	}
+
+	got := suggestService.Suggest("TOW", 10)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 suggestions for tow, got %v", got)
+	}
+	if got[0].ID != 2 {
+		t.Errorf("expected the title starting with the prefix first, got %v", got)
+	}
+
+	got = suggestService.Suggest("ja", 10)
+	if len(got) != 2 || got[0].Type != models.SuggestionCountry {
+		t.Errorf("expected japan then jardin, got %v", got)
+	}
+
+	if got := suggestService.Suggest("ja", 1); len(got) != 1 {
+		t.Errorf("expected limit to be applied, got %v", got)
+	}
+	if got := suggestService.Suggest("zz", 10); len(got) != 0 {
+		t.Errorf("expected no suggestions, got %v", got)
+	}
+}
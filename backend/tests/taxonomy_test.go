@@ -0,0 +1,227 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/geo"
+	"github.com/Joshua-Pok/FYP-backend/handlers"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+// memoryActivities keeps activities and a category tree, only the taxonomy methods do anything
+type memoryActivities struct {
+	activities map[int]*models.Activity
+	// category slug to parent slug, empty for top level categories
+	parents map[string]string
+}
+
+func (m *memoryActivities) CreateActivity(ctx context.Context, input models.Activity) (models.Activity, error) {
+	return input, nil
+}
+
+func (m *memoryActivities) GetActivityById(ctx context.Context, activityID int) (*models.Activity, error) {
+	activity, ok := m.activities[activityID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *activity
+	return &copied, nil
+}
+
+func (m *memoryActivities) GetActivitiesByIds(ctx context.Context, ids []string) ([]models.Activity, error) {
+	return nil, nil
+}
+
+func (m *memoryActivities) ListActivities(ctx context.Context, filter models.ActivityListFilter) ([]models.Activity, error) {
+	return nil, nil
+}
+
+func (m *memoryActivities) SetActivityTaxonomy(ctx context.Context, activityID int, categories, tags []string) (models.Activity, error) {
+	activity, ok := m.activities[activityID]
+	if !ok {
+		return models.Activity{}, sql.ErrNoRows
+	}
+	for _, slug := range categories {
+		if _, ok := m.parents[slug]; !ok {
+			return models.Activity{}, repository.ErrUnknownCategory
+		}
+	}
+	activity.Categories, activity.Tags = categories, tags
+	return *activity, nil
+}
+
+func (m *memoryActivities) GetCategoryLineage(ctx context.Context, activityID int) ([]string, error) {
+	seen := make(map[string]bool)
+	var lineage []string
+	for _, slug := range m.activities[activityID].Categories {
+		for ; slug != "" && !seen[slug]; slug = m.parents[slug] {
+			seen[slug] = true
+			lineage = append(lineage, slug)
+		}
+	}
+	sort.Strings(lineage)
+	return lineage, nil
+}
+
+func (m *memoryActivities) DeleteActivity(ctx context.Context, activityID int) error {
+	return nil
+}
+
+func (m *memoryActivities) RestoreActivity(ctx context.Context, activityID int) error {
+	return nil
+}
+
+func (m *memoryActivities) SearchActivities(ctx context.Context, filter models.ActivitySearchFilter) ([]models.ActivitySearchResult, error) {
+	return nil, nil
+}
+
+func (m *memoryActivities) GetActivitiesNearby(ctx context.Context, center geo.Point, radiusMeters float64, limit int, filter models.ActivityTaxonomyFilter) ([]models.NearbyActivity, error) {
+	return nil, nil
+}
+
+// gorseItems records the items a fake gorse server is sent
+func gorseItems(t *testing.T) (*service.GorseService, <-chan models.GorseItem) {
+	items := make(chan models.GorseItem, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var item models.GorseItem
+		json.NewDecoder(r.Body).Decode(&item)
+		items <- item
+	}))
+	t.Cleanup(server.Close)
+	return service.NewGorseService(server.URL), items
+}
+
+func TestActivityHandler_RejectsBadTaxonomy(t *testing.T) {
+	handler := handlers.NewActivityhandler(&repository.ActivityRepository{}, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, openAccess())
+
+	cases := map[string]string{
+		"malformed category": `{"categories": ["Not A Slug!"], "tags": []}`,
+		"tag too long":       `{"categories": [], "tags": ["` + strings.Repeat("x", 51) + `"]}`,
+	}
+	for name, body := range cases {
+		req := httptest.NewRequest(http.MethodPut, "/activity/taxonomy?activity_id=1", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.SetActivityTaxonomy(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/activities?category=Food%20%26%20Drink", nil)
+	rec := httptest.NewRecorder()
+	handler.ListActivities(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a category name instead of a slug, got %d", rec.Code)
+	}
+}
+
+func TestActivityHandler_Retagging(t *testing.T) {
+	activities := &memoryActivities{
+		activities: map[int]*models.Activity{3: {ID: 3, Title: "Dragon's Back", Categories: []string{"museums"}, Tags: []string{"indoor"}}},
+		parents:    map[string]string{"adventure": "", "hiking": "adventure", "museums": ""},
+	}
+	gorse, items := gorseItems(t)
+	handler := handlers.NewActivityhandler(activities, nil, gorse, nil, nil, nil, nil, nil, nil, nil, nil, openAccess())
+
+	retag := func(activityID, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.SetActivityTaxonomy(rec, httptest.NewRequest(http.MethodPut, "/activity/taxonomy?activity_id="+activityID, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := retag("3", `{"categories": [" Hiking ", "hiking"], "tags": ["Sea  Views", "sea views", " ", "Sunrise"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	// the old category and tag are replaced, not added to
+	activity := activities.activities[3]
+	if !reflect.DeepEqual(activity.Categories, []string{"hiking"}) || !reflect.DeepEqual(activity.Tags, []string{"sea views", "sunrise"}) {
+		t.Errorf("expected the normalized taxonomy to replace the old one, got %v and %v", activity.Categories, activity.Tags)
+	}
+	// gorse is sent the whole lineage so recommendations scoped to a parent category find it
+	select {
+	case item := <-items:
+		want := models.GorseItem{ItemId: "3", Categories: []string{"adventure", "hiking"}, Labels: []string{"sea views", "sunrise"}, Comment: "Dragon's Back"}
+		if !reflect.DeepEqual(item, want) {
+			t.Errorf("expected gorse item %+v, got %+v", want, item)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the retagged activity to be pushed to gorse")
+	}
+
+	if rec := retag("3", `{"categories": [], "tags": []}`); rec.Code != http.StatusOK || len(activity.Categories) != 0 || len(activity.Tags) != 0 {
+		t.Errorf("expected an empty body to clear the taxonomy, got %d with %v and %v", rec.Code, activity.Categories, activity.Tags)
+	}
+	<-items
+
+	if rec := retag("3", `{"categories": ["volcanoes"], "tags": []}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown category, got %d", rec.Code)
+	}
+	if rec := retag("4", `{"categories": ["hiking"], "tags": []}`); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing activity, got %d", rec.Code)
+	}
+}
+
+func TestActivityRepository_Retagging(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	suffix := fmt.Sprintf("-%d", time.Now().UnixNano())
+	categories := repository.NewCategoryRepository(db)
+	for _, c := range []struct{ slug, parent string }{{"adventure", ""}, {"hiking", "adventure"}, {"museums", ""}} {
+		parent := ""
+		if c.parent != "" {
+			parent = c.parent + suffix
+		}
+		category := &models.Category{Slug: c.slug + suffix, Name: c.slug}
+		if err := categories.CreateCategory(ctx, category, parent); err != nil {
+			t.Fatalf("failed to create category: %v", err)
+		}
+		t.Cleanup(func() {
+			db.Primary().Exec(`DELETE FROM category WHERE id = $1`, category.ID)
+		})
+	}
+	activity := createTestActivity(t, db, "Ridge walk")
+	activities := repository.NewActivityRepository(db)
+
+	retagged, err := activities.SetActivityTaxonomy(ctx, activity.ID, []string{"hiking" + suffix}, []string{"sea views", "sunrise"})
+	if err != nil {
+		t.Fatalf("failed to retag: %v", err)
+	}
+	sort.Strings(retagged.Tags)
+	if !reflect.DeepEqual(retagged.Categories, []string{"hiking" + suffix}) || !reflect.DeepEqual(retagged.Tags, []string{"sea views", "sunrise"}) {
+		t.Errorf("unexpected taxonomy %v and %v", retagged.Categories, retagged.Tags)
+	}
+	lineage, err := activities.GetCategoryLineage(ctx, activity.ID)
+	if err != nil || !reflect.DeepEqual(lineage, []string{"adventure" + suffix, "hiking" + suffix}) {
+		t.Errorf("expected the parent category in the lineage, got %v: %v", lineage, err)
+	}
+
+	retagged, err = activities.SetActivityTaxonomy(ctx, activity.ID, []string{"museums" + suffix}, []string{"sunrise"})
+	if err != nil {
+		t.Fatalf("failed to retag: %v", err)
+	}
+	if !reflect.DeepEqual(retagged.Categories, []string{"museums" + suffix}) || !reflect.DeepEqual(retagged.Tags, []string{"sunrise"}) {
+		t.Errorf("expected the taxonomy to be replaced, got %v and %v", retagged.Categories, retagged.Tags)
+	}
+
+	// an unknown slug rolls back the whole retag
+	if _, err := activities.SetActivityTaxonomy(ctx, activity.ID, []string{"adventure" + suffix, "volcanoes" + suffix}, nil); err != repository.ErrUnknownCategory {
+		t.Errorf("expected ErrUnknownCategory, got %v", err)
+	}
+	current, err := activities.GetActivityById(ctx, activity.ID)
+	if err != nil || !reflect.DeepEqual(current.Categories, []string{"museums" + suffix}) || !reflect.DeepEqual(current.Tags, []string{"sunrise"}) {
+		t.Errorf("expected the failed retag to change nothing, got %+v: %v", current, err)
+	}
+}
@@ -1,26 +1,35 @@
 package tests
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/Joshua-Pok/FYP-backend/auth"
 	"github.com/Joshua-Pok/FYP-backend/handlers"
 	"github.com/Joshua-Pok/FYP-backend/models"
 )
 
 type MockUserRepository struct {
 	users map[int]*models.User
-	err   error
+	// every account ever added by email, deleted or not
+	accounts map[string]int
+	err      error
 }
 
 func NewMockUserRepository() *MockUserRepository {
 	return &MockUserRepository{
-		users: make(map[int]*models.User),
+		users:    make(map[int]*models.User),
+		accounts: make(map[string]int),
 	}
 }
 
-func (m *MockUserRepository) CreateUser(user models.User) error {
-	m.users[user.ID] = &user
+func (m *MockUserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	m.users[user.ID] = user
 	return nil
 }
 
@@ -46,8 +55,29 @@ func (m *MockUserRepository) GetUserById(id int) (*models.User, error) {
 	return user, nil
 }
 
+func (m *MockUserRepository) GetAccountIdByEmail(email string) (int, error) {
+	id, ok := m.accounts[email]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return id, nil
+}
+
+func (m *MockUserRepository) DeleteUser(ctx context.Context, id int) error {
+	if _, exists := m.users[id]; !exists {
+		return sql.ErrNoRows
+	}
+	delete(m.users, id)
+	return nil
+}
+
+func (m *MockUserRepository) RestoreUser(ctx context.Context, id int) error {
+	return nil
+}
+
 func (m *MockUserRepository) AddUser(user *models.User) {
 	m.users[user.ID] = user
+	m.accounts[user.Email] = user.ID
 }
 
 func TestUserHandler_GetUser(t *testing.T) {
@@ -60,5 +90,78 @@ func TestUserHandler_GetUser(t *testing.T) {
 
 	mockRepo.AddUser(expectedUser)
 
-	handler := handlers.NewUserHandler(mockRepo)
+	handler := handlers.NewUserHandler(mockRepo, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/users?id=1", nil)
+	rec := httptest.NewRecorder()
+	handler.GetUser(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var got models.User
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got.Email != expectedUser.Email {
+		t.Errorf("expected email %s, got %s", expectedUser.Email, got.Email)
+	}
+}
+
+func TestUserHandler_DeleteUser(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	mockRepo.AddUser(&models.User{ID: 1, Name: "Joshua Pok", Email: "joshua@example.com"})
+	mockRepo.AddUser(&models.User{ID: 2, Name: "Someone Else", Email: "someone@example.com"})
+	mockRepo.AddUser(&models.User{ID: 3, Name: "Third User", Email: "third@example.com"})
+	handler := handlers.NewUserHandler(mockRepo, []string{"admin@example.com"})
+
+	deleteAs := func(actor, id string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/users?id="+id, nil)
+		if actor != "" {
+			req = req.WithContext(auth.WithActor(req.Context(), actor))
+		}
+		rec := httptest.NewRecorder()
+		handler.DeleteUser(rec, req)
+		return rec.Code
+	}
+
+	if code := deleteAs("", "1"); code != http.StatusUnauthorized {
+		t.Errorf("expected status %d for anonymous, got %d", http.StatusUnauthorized, code)
+	}
+	if code := deleteAs("someone@example.com", "1"); code != http.StatusForbidden {
+		t.Errorf("expected status %d for another user, got %d", http.StatusForbidden, code)
+	}
+	if code := deleteAs("joshua@example.com", "1"); code != http.StatusOK {
+		t.Fatalf("expected status %d deleting own account, got %d", http.StatusOK, code)
+	}
+	if code := deleteAs("joshua@example.com", "1"); code != http.StatusNotFound {
+		t.Errorf("expected status %d for already deleted user, got %d", http.StatusNotFound, code)
+	}
+	if code := deleteAs("admin@example.com", "3"); code != http.StatusOK {
+		t.Errorf("expected status %d for admin, got %d", http.StatusOK, code)
+	}
+}
+
+func TestUserHandler_RestoreUser(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	mockRepo.AddUser(&models.User{ID: 1, Name: "Joshua Pok", Email: "joshua@example.com"})
+	mockRepo.AddUser(&models.User{ID: 2, Name: "Someone Else", Email: "someone@example.com"})
+	handler := handlers.NewUserHandler(mockRepo, nil)
+
+	restoreAs := func(actor string) int {
+		req := httptest.NewRequest(http.MethodPost, "/users/restore?id=1", nil)
+		req = req.WithContext(auth.WithActor(req.Context(), actor))
+		rec := httptest.NewRecorder()
+		handler.RestoreUser(rec, req)
+		return rec.Code
+	}
+
+	if code := restoreAs("someone@example.com"); code != http.StatusForbidden {
+		t.Errorf("expected status %d for another user, got %d", http.StatusForbidden, code)
+	}
+	// the account is deleted, but its owner can still bring it back
+	delete(mockRepo.users, 1)
+	if code := restoreAs("joshua@example.com"); code != http.StatusOK {
+		t.Errorf("expected status %d restoring own account, got %d", http.StatusOK, code)
+	}
 }
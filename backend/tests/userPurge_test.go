@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Joshua-Pok/FYP-backend/database"
+	"github.com/Joshua-Pok/FYP-backend/models"
+	"github.com/Joshua-Pok/FYP-backend/repository"
+	"github.com/Joshua-Pok/FYP-backend/service"
+)
+
+// itineraryBalances settles an itinerary in US dollars from the stored expenses, by user id
+func itineraryBalances(t *testing.T, db *database.Router, itineraryID int) map[int]int64 {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"base": "USD", "date": "2026-01-02", "rates": {}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	exchange := service.NewExchangeService(&service.FileRateProvider{Path: path}, &memoryRates{currencies: map[string]int{"USD": 2}})
+	if err := exchange.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	budget := service.NewBudgetService(repository.NewExpenseRepository(db), repository.NewItineraryRepository(db), repository.NewMemberRepository(db), exchange)
+	result, err := budget.Balances(database.WithPrimary(context.Background()), itineraryID, "USD")
+	if err != nil {
+		t.Fatalf("failed to settle itinerary: %v", err)
+	}
+	balances := make(map[int]int64)
+	for _, b := range result.Balances {
+		balances[b.UserID] = b.BalanceMinor
+	}
+	return balances
+}
+
+func TestUserRepository_PurgeKeepsSharedExpenses(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	owner := createTestUser(t, db, "owner")
+	traveller := createTestUser(t, db, "traveller")
+	friend := createTestUser(t, db, "friend")
+
+	start := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	itinerary, err := repository.NewItineraryRepository(db).CreateItinerary(ctx, owner.ID, "Trip", "", start, start.AddDate(0, 0, 3), nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create itinerary: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Primary().Exec(`DELETE FROM itinerary WHERE id = $1`, itinerary.Id)
+	})
+	for _, member := range []*models.User{traveller, friend} {
+		if _, err := db.Primary().Exec(`INSERT INTO itinerary_member (itinerary_id, user_id, role) VALUES ($1, $2, 'editor')`, itinerary.Id, member.ID); err != nil {
+			t.Fatalf("failed to add member: %v", err)
+		}
+	}
+
+	expenses := repository.NewExpenseRepository(db)
+	for _, expense := range []*models.Expense{
+		// paid by the traveller for everyone
+		{ItineraryID: itinerary.Id, Category: "food", AmountMinor: 900, Currency: "USD", PaidBy: traveller.ID, SplitMethod: models.SplitEqual, Date: "2026-07-01",
+			Shares: []models.ExpenseShare{{UserID: owner.ID, AmountMinor: 300}, {UserID: traveller.ID, AmountMinor: 300}, {UserID: friend.ID, AmountMinor: 300}}},
+		// paid by the owner and shared with the traveller
+		{ItineraryID: itinerary.Id, Category: "transport", AmountMinor: 3000, Currency: "USD", PaidBy: owner.ID, SplitMethod: models.SplitEqual, Date: "2026-07-02",
+			Shares: []models.ExpenseShare{{UserID: owner.ID, AmountMinor: 1500}, {UserID: traveller.ID, AmountMinor: 1500}}},
+		{ItineraryID: itinerary.Id, Category: "shopping", AmountMinor: 700, Currency: "USD", PaidBy: friend.ID, SplitMethod: models.SplitEqual, Date: "2026-07-02",
+			Shares: []models.ExpenseShare{{UserID: friend.ID, AmountMinor: 700}}},
+	} {
+		if err := expenses.CreateExpense(ctx, expense); err != nil {
+			t.Fatalf("failed to create expense: %v", err)
+		}
+	}
+	before := itineraryBalances(t, db, itinerary.Id)
+
+	users := repository.NewUserRepository(db.Primary())
+	if err := users.DeleteUser(ctx, traveller.ID); err != nil {
+		t.Fatalf("failed to delete user: %v", err)
+	}
+	if _, err := users.PurgeDeleted(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("expected the purge to succeed, got %v", err)
+	}
+
+	var remaining int
+	db.Primary().QueryRow(`SELECT COUNT(*) FROM users WHERE id = $1`, traveller.ID).Scan(&remaining)
+	if remaining != 0 {
+		t.Error("expected the user to be purged")
+	}
+	db.Primary().QueryRow(`SELECT COUNT(*) FROM itinerary_expense WHERE itinerary_id = $1`, itinerary.Id).Scan(&remaining)
+	if remaining != 3 {
+		t.Errorf("expected every expense to be kept, got %d", remaining)
+	}
+
+	after := itineraryBalances(t, db, itinerary.Id)
+	for _, member := range []*models.User{owner, friend} {
+		if after[member.ID] != before[member.ID] {
+			t.Errorf("expected %s's balance to stay %d, got %d", member.Name, before[member.ID], after[member.ID])
+		}
+	}
+	// the placeholder stands where the traveller was
+	if _, ok := after[traveller.ID]; ok {
+		t.Error("expected the purged user to be gone from the balances")
+	}
+	if after[repository.DeletedUserID] != before[traveller.ID] {
+		t.Errorf("expected the deleted user to carry the traveller's %d, got %d", before[traveller.ID], after[repository.DeletedUserID])
+	}
+}
+
+func TestUserRepository_PurgeHandsOverSharedItineraries(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	owner := createTestUser(t, db, "owner")
+	friend := createTestUser(t, db, "friend")
+
+	itineraries := repository.NewItineraryRepository(db)
+	start := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	shared, err := itineraries.CreateItinerary(ctx, owner.ID, "Shared", "", start, start.AddDate(0, 0, 3), nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create itinerary: %v", err)
+	}
+	solo, err := itineraries.CreateItinerary(ctx, owner.ID, "Solo", "", start, start.AddDate(0, 0, 3), nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create itinerary: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Primary().Exec(`DELETE FROM itinerary WHERE id IN ($1, $2)`, shared.Id, solo.Id)
+	})
+	if _, err := db.Primary().Exec(`INSERT INTO itinerary_member (itinerary_id, user_id, role) VALUES ($1, $2, 'viewer')`, shared.Id, friend.ID); err != nil {
+		t.Fatalf("failed to add member: %v", err)
+	}
+
+	users := repository.NewUserRepository(db.Primary())
+	if err := users.DeleteUser(ctx, owner.ID); err != nil {
+		t.Fatalf("failed to delete user: %v", err)
+	}
+	if _, err := users.PurgeDeleted(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("expected the purge to succeed, got %v", err)
+	}
+
+	var ownerID int
+	if err := db.Primary().QueryRow(`SELECT user_id FROM itinerary WHERE id = $1`, shared.Id).Scan(&ownerID); err != nil {
+		t.Fatalf("expected the shared itinerary to be kept: %v", err)
+	}
+	if ownerID != friend.ID {
+		t.Errorf("expected the itinerary to be handed to %d, got %d", friend.ID, ownerID)
+	}
+	members, err := repository.NewMemberRepository(db).GetMembers(database.WithPrimary(ctx), shared.Id)
+	if err != nil {
+		t.Fatalf("failed to list members: %v", err)
+	}
+	if len(members) != 1 || members[0].UserID != friend.ID || members[0].Role != models.RoleOwner {
+		t.Errorf("expected the friend to be the only member and the owner, got %+v", members)
+	}
+
+	var remaining int
+	db.Primary().QueryRow(`SELECT COUNT(*) FROM itinerary WHERE id = $1`, solo.Id).Scan(&remaining)
+	if remaining != 0 {
+		t.Error("expected the itinerary nobody else was on to be purged")
+	}
+}
@@ -1,5 +1,21 @@
 import pandas as pd
 
+# gorse categories for each survey activity, a subcategory is always listed with its parent
+# so recommendations scoped to the parent include it. Slugs match the category table.
+ITEM_CATEGORIES = {
+    "i_need_time_alone_to_recharge_after_social_interactions": ["relaxation"],
+    "bungee_jumping": ["adventure", "extreme-sports"],
+    "homestay_with_locals": ["culture", "local-life"],
+    "hiking_trekking": ["adventure", "hiking"],
+    "attending_religious_ceremonies_festivals_eg:_songkran": ["culture", "festivals"],
+    "cooking_class_": ["food", "cooking-classes"],
+    "private_museum_art_tour_(eg:_louvre)": ["culture", "museums"],
+    "backpacking_+_food_street_tour_in_bangkok": ["adventure", "food", "street-food"],
+    "theme_parks": ["entertainment", "theme-parks"],
+    "wildlife_trails": ["nature", "wildlife"],
+    "all_inclusive_luxury_resort_(eg:_maldives)": ["relaxation", "resorts"],
+}
+
 def process_items_csv(input_file, output_file, activity_columns=range(20,31)):
     try:
         df = pd.read_csv(input_file)
@@ -16,10 +32,11 @@ def process_items_csv(input_file, output_file, activity_columns=range(20,31)):
     items_data = []
 
     for activity_name in activity_names:
+        item_id = activity_name.replace(" ", "_").replace("/", "_").lower()
         item_entry = {
-            "ItemId": activity_name.replace(" ", "_").replace("/", "_").lower(),
+            "ItemId": item_id,
             "IsHidden": False,
-            "Categories": ["activity"],
+            "Categories": ITEM_CATEGORIES.get(item_id, ["activity"]),
             "Timestamp": "",
             "Labels": activity_name.replace(" ", "_").replace("/", "_").lower(),
             "Comment": f"Activity: {activity_name}"